@@ -32,7 +32,11 @@ type ReportRequest struct {
 	Net  *NetSummary  `protobuf:"bytes,6,opt,name=net,proto3" json:"net,omitempty"`
 	Kvm  *KVMSummary  `protobuf:"bytes,7,opt,name=kvm,proto3" json:"kvm,omitempty"`
 	// 网络连通性探测测算结果
-	PingResults   []*PingResult `protobuf:"bytes,8,rep,name=ping_results,json=pingResults,proto3" json:"ping_results,omitempty"`
+	PingResults []*PingResult `protobuf:"bytes,8,rep,name=ping_results,json=pingResults,proto3" json:"ping_results,omitempty"`
+	// 节点自报的名称、标签与分组 (来自节点启动参数)，主控上通过 API 设置的值优先；旧版节点为空
+	Info *NodeInfo `protobuf:"bytes,9,opt,name=info,proto3" json:"info,omitempty"`
+	// 诊断任务的执行结果。只回传结果的帧不带 cpu 等指标，主控不会将其写入存储
+	TaskResults   []*TaskResult `protobuf:"bytes,10,rep,name=task_results,json=taskResults,proto3" json:"task_results,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -123,6 +127,88 @@ func (x *ReportRequest) GetPingResults() []*PingResult {
 	return nil
 }
 
+func (x *ReportRequest) GetInfo() *NodeInfo {
+	if x != nil {
+		return x.Info
+	}
+	return nil
+}
+
+func (x *ReportRequest) GetTaskResults() []*TaskResult {
+	if x != nil {
+		return x.TaskResults
+	}
+	return nil
+}
+
+type NodeInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DisplayName   string                 `protobuf:"bytes,1,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 如 region / datacenter / provider / role
+	Groups        []string               `protobuf:"bytes,4,rep,name=groups,proto3" json:"groups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NodeInfo) Reset() {
+	*x = NodeInfo{}
+	mi := &file_geegee_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NodeInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NodeInfo) ProtoMessage() {}
+
+func (x *NodeInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_geegee_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NodeInfo.ProtoReflect.Descriptor instead.
+func (*NodeInfo) Descriptor() ([]byte, []int) {
+	return file_geegee_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NodeInfo) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *NodeInfo) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *NodeInfo) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *NodeInfo) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
 type CPUSummary struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ModelName     string                 `protobuf:"bytes,1,opt,name=model_name,json=modelName,proto3" json:"model_name,omitempty"`
@@ -138,7 +224,7 @@ type CPUSummary struct {
 
 func (x *CPUSummary) Reset() {
 	*x = CPUSummary{}
-	mi := &file_geegee_proto_msgTypes[1]
+	mi := &file_geegee_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -150,7 +236,7 @@ func (x *CPUSummary) String() string {
 func (*CPUSummary) ProtoMessage() {}
 
 func (x *CPUSummary) ProtoReflect() protoreflect.Message {
-	mi := &file_geegee_proto_msgTypes[1]
+	mi := &file_geegee_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -163,7 +249,7 @@ func (x *CPUSummary) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CPUSummary.ProtoReflect.Descriptor instead.
 func (*CPUSummary) Descriptor() ([]byte, []int) {
-	return file_geegee_proto_rawDescGZIP(), []int{1}
+	return file_geegee_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *CPUSummary) GetModelName() string {
@@ -229,7 +315,7 @@ type MemSummary struct {
 
 func (x *MemSummary) Reset() {
 	*x = MemSummary{}
-	mi := &file_geegee_proto_msgTypes[2]
+	mi := &file_geegee_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -241,7 +327,7 @@ func (x *MemSummary) String() string {
 func (*MemSummary) ProtoMessage() {}
 
 func (x *MemSummary) ProtoReflect() protoreflect.Message {
-	mi := &file_geegee_proto_msgTypes[2]
+	mi := &file_geegee_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -254,7 +340,7 @@ func (x *MemSummary) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MemSummary.ProtoReflect.Descriptor instead.
 func (*MemSummary) Descriptor() ([]byte, []int) {
-	return file_geegee_proto_rawDescGZIP(), []int{2}
+	return file_geegee_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *MemSummary) GetTotal() uint64 {
@@ -312,7 +398,7 @@ type DiskSummary struct {
 
 func (x *DiskSummary) Reset() {
 	*x = DiskSummary{}
-	mi := &file_geegee_proto_msgTypes[3]
+	mi := &file_geegee_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -324,7 +410,7 @@ func (x *DiskSummary) String() string {
 func (*DiskSummary) ProtoMessage() {}
 
 func (x *DiskSummary) ProtoReflect() protoreflect.Message {
-	mi := &file_geegee_proto_msgTypes[3]
+	mi := &file_geegee_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -337,7 +423,7 @@ func (x *DiskSummary) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DiskSummary.ProtoReflect.Descriptor instead.
 func (*DiskSummary) Descriptor() ([]byte, []int) {
-	return file_geegee_proto_rawDescGZIP(), []int{3}
+	return file_geegee_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *DiskSummary) GetReadBytes() uint64 {
@@ -384,13 +470,16 @@ type NetSummary struct {
 	// 高级突发网络特征
 	MicroburstEvents uint64  `protobuf:"varint,5,opt,name=microburst_events,json=microburstEvents,proto3" json:"microburst_events,omitempty"`
 	BurstP95Rate     float64 `protobuf:"fixed64,6,opt,name=burst_p95_rate,json=burstP95Rate,proto3" json:"burst_p95_rate,omitempty"` // 例如 p95 的发包/收包率极值
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+	// 逐网卡累计字节数 (不含回环)，主控据此按接口计算 95 计费；旧版节点为空。
+	// 顶层计数只汇总物理网卡，没有物理网卡 (如运行在容器中) 时汇总全部
+	Interfaces    []*NetInterface `protobuf:"bytes,7,rep,name=interfaces,proto3" json:"interfaces,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *NetSummary) Reset() {
 	*x = NetSummary{}
-	mi := &file_geegee_proto_msgTypes[4]
+	mi := &file_geegee_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -402,7 +491,7 @@ func (x *NetSummary) String() string {
 func (*NetSummary) ProtoMessage() {}
 
 func (x *NetSummary) ProtoReflect() protoreflect.Message {
-	mi := &file_geegee_proto_msgTypes[4]
+	mi := &file_geegee_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -415,7 +504,7 @@ func (x *NetSummary) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NetSummary.ProtoReflect.Descriptor instead.
 func (*NetSummary) Descriptor() ([]byte, []int) {
-	return file_geegee_proto_rawDescGZIP(), []int{4}
+	return file_geegee_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *NetSummary) GetBytesRecv() uint64 {
@@ -460,6 +549,82 @@ func (x *NetSummary) GetBurstP95Rate() float64 {
 	return 0
 }
 
+func (x *NetSummary) GetInterfaces() []*NetInterface {
+	if x != nil {
+		return x.Interfaces
+	}
+	return nil
+}
+
+type NetInterface struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Name      string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	BytesRecv uint64                 `protobuf:"varint,2,opt,name=bytes_recv,json=bytesRecv,proto3" json:"bytes_recv,omitempty"`
+	BytesSent uint64                 `protobuf:"varint,3,opt,name=bytes_sent,json=bytesSent,proto3" json:"bytes_sent,omitempty"`
+	// 网桥、tap、veth 等虚拟网卡：流量已经计入承载它的物理网卡，不参与合计与计费
+	Virtual       bool `protobuf:"varint,4,opt,name=virtual,proto3" json:"virtual,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetInterface) Reset() {
+	*x = NetInterface{}
+	mi := &file_geegee_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetInterface) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetInterface) ProtoMessage() {}
+
+func (x *NetInterface) ProtoReflect() protoreflect.Message {
+	mi := &file_geegee_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetInterface.ProtoReflect.Descriptor instead.
+func (*NetInterface) Descriptor() ([]byte, []int) {
+	return file_geegee_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *NetInterface) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *NetInterface) GetBytesRecv() uint64 {
+	if x != nil {
+		return x.BytesRecv
+	}
+	return 0
+}
+
+func (x *NetInterface) GetBytesSent() uint64 {
+	if x != nil {
+		return x.BytesSent
+	}
+	return 0
+}
+
+func (x *NetInterface) GetVirtual() bool {
+	if x != nil {
+		return x.Virtual
+	}
+	return false
+}
+
 type KVMSummary struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	TotalVms       int32                  `protobuf:"varint,1,opt,name=total_vms,json=totalVms,proto3" json:"total_vms,omitempty"`
@@ -472,7 +637,7 @@ type KVMSummary struct {
 
 func (x *KVMSummary) Reset() {
 	*x = KVMSummary{}
-	mi := &file_geegee_proto_msgTypes[5]
+	mi := &file_geegee_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -484,7 +649,7 @@ func (x *KVMSummary) String() string {
 func (*KVMSummary) ProtoMessage() {}
 
 func (x *KVMSummary) ProtoReflect() protoreflect.Message {
-	mi := &file_geegee_proto_msgTypes[5]
+	mi := &file_geegee_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -497,7 +662,7 @@ func (x *KVMSummary) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KVMSummary.ProtoReflect.Descriptor instead.
 func (*KVMSummary) Descriptor() ([]byte, []int) {
-	return file_geegee_proto_rawDescGZIP(), []int{5}
+	return file_geegee_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *KVMSummary) GetTotalVms() int32 {
@@ -536,14 +701,21 @@ type PingResult struct {
 	MaxRttMs       float64                `protobuf:"fixed64,4,opt,name=max_rtt_ms,json=maxRttMs,proto3" json:"max_rtt_ms,omitempty"`
 	AvgRttMs       float64                `protobuf:"fixed64,5,opt,name=avg_rtt_ms,json=avgRttMs,proto3" json:"avg_rtt_ms,omitempty"`
 	PacketLossRate float64                `protobuf:"fixed64,6,opt,name=packet_loss_rate,json=packetLossRate,proto3" json:"packet_loss_rate,omitempty"` // 丢包率 0.0 - 1.0
-	TargetType     string                 `protobuf:"bytes,7,opt,name=target_type,json=targetType,proto3" json:"target_type,omitempty"`                 // tcpping, icmp
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+	TargetType     string                 `protobuf:"bytes,7,opt,name=target_type,json=targetType,proto3" json:"target_type,omitempty"`                 // tcpping, icmp, udp
+	// 以下仅 udp 探测填充 (对端须运行反射器)
+	JitterMs        float64 `protobuf:"fixed64,8,opt,name=jitter_ms,json=jitterMs,proto3" json:"jitter_ms,omitempty"`                         // RFC 3550 式到达间隔抖动
+	Reordered       uint32  `protobuf:"varint,9,opt,name=reordered,proto3" json:"reordered,omitempty"`                                        // 乱序到达的回包数
+	Duplicates      uint32  `protobuf:"varint,10,opt,name=duplicates,proto3" json:"duplicates,omitempty"`                                     // 重复的回包数
+	LossForwardRate float64 `protobuf:"fixed64,11,opt,name=loss_forward_rate,json=lossForwardRate,proto3" json:"loss_forward_rate,omitempty"` // 去程丢包率，由反射器回报的收包数推算
+	LossReverseRate float64 `protobuf:"fixed64,12,opt,name=loss_reverse_rate,json=lossReverseRate,proto3" json:"loss_reverse_rate,omitempty"` // 回程丢包率
+	Mos             float64 `protobuf:"fixed64,13,opt,name=mos,proto3" json:"mos,omitempty"`                                                  // 由延迟、抖动与丢包推算的语音质量分 (1.0 - 4.5)
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *PingResult) Reset() {
 	*x = PingResult{}
-	mi := &file_geegee_proto_msgTypes[6]
+	mi := &file_geegee_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -555,7 +727,7 @@ func (x *PingResult) String() string {
 func (*PingResult) ProtoMessage() {}
 
 func (x *PingResult) ProtoReflect() protoreflect.Message {
-	mi := &file_geegee_proto_msgTypes[6]
+	mi := &file_geegee_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -568,7 +740,7 @@ func (x *PingResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PingResult.ProtoReflect.Descriptor instead.
 func (*PingResult) Descriptor() ([]byte, []int) {
-	return file_geegee_proto_rawDescGZIP(), []int{6}
+	return file_geegee_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *PingResult) GetTargetIp() string {
@@ -620,20 +792,67 @@ func (x *PingResult) GetTargetType() string {
 	return ""
 }
 
+func (x *PingResult) GetJitterMs() float64 {
+	if x != nil {
+		return x.JitterMs
+	}
+	return 0
+}
+
+func (x *PingResult) GetReordered() uint32 {
+	if x != nil {
+		return x.Reordered
+	}
+	return 0
+}
+
+func (x *PingResult) GetDuplicates() uint32 {
+	if x != nil {
+		return x.Duplicates
+	}
+	return 0
+}
+
+func (x *PingResult) GetLossForwardRate() float64 {
+	if x != nil {
+		return x.LossForwardRate
+	}
+	return 0
+}
+
+func (x *PingResult) GetLossReverseRate() float64 {
+	if x != nil {
+		return x.LossReverseRate
+	}
+	return 0
+}
+
+func (x *PingResult) GetMos() float64 {
+	if x != nil {
+		return x.Mos
+	}
+	return 0
+}
+
 // ReportResponse 主控端针对探针流返回的下发指令或确认
 type ReportResponse struct {
 	state   protoimpl.MessageState `protogen:"open.v1"`
 	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
 	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
-	// 留给将来动态下发网络质量探测的目标（比如让节点执行对某个 IP 的 TCPPing）
-	ProbeTargets  []*ProbeTarget `protobuf:"bytes,3,rep,name=probe_targets,json=probeTargets,proto3" json:"probe_targets,omitempty"`
+	// 按节点标签 / 分组分配的探测目标，仅在变化时下发，节点收到后整体替换本地列表
+	ProbeTargets []*ProbeTarget `protobuf:"bytes,3,rep,name=probe_targets,json=probeTargets,proto3" json:"probe_targets,omitempty"`
+	// probe_targets 携带一次分配 (含空列表)。空列表表示没有规则匹配该节点，节点恢复本地默认目标；
+	// 旧版主控不设置，此时只有非空列表才视为分配
+	TargetsAssigned bool `protobuf:"varint,5,opt,name=targets_assigned,json=targetsAssigned,proto3" json:"targets_assigned,omitempty"`
+	// 一次性诊断任务，节点执行后经 task_results 回传，不等下一个上报周期
+	Tasks         []*Task `protobuf:"bytes,4,rep,name=tasks,proto3" json:"tasks,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ReportResponse) Reset() {
 	*x = ReportResponse{}
-	mi := &file_geegee_proto_msgTypes[7]
+	mi := &file_geegee_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -645,7 +864,7 @@ func (x *ReportResponse) String() string {
 func (*ReportResponse) ProtoMessage() {}
 
 func (x *ReportResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_geegee_proto_msgTypes[7]
+	mi := &file_geegee_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -658,7 +877,7 @@ func (x *ReportResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReportResponse.ProtoReflect.Descriptor instead.
 func (*ReportResponse) Descriptor() ([]byte, []int) {
-	return file_geegee_proto_rawDescGZIP(), []int{7}
+	return file_geegee_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *ReportResponse) GetSuccess() bool {
@@ -682,6 +901,20 @@ func (x *ReportResponse) GetProbeTargets() []*ProbeTarget {
 	return nil
 }
 
+func (x *ReportResponse) GetTargetsAssigned() bool {
+	if x != nil {
+		return x.TargetsAssigned
+	}
+	return false
+}
+
+func (x *ReportResponse) GetTasks() []*Task {
+	if x != nil {
+		return x.Tasks
+	}
+	return nil
+}
+
 type ProbeTarget struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Ip            string                 `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
@@ -693,7 +926,7 @@ type ProbeTarget struct {
 
 func (x *ProbeTarget) Reset() {
 	*x = ProbeTarget{}
-	mi := &file_geegee_proto_msgTypes[8]
+	mi := &file_geegee_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -705,7 +938,7 @@ func (x *ProbeTarget) String() string {
 func (*ProbeTarget) ProtoMessage() {}
 
 func (x *ProbeTarget) ProtoReflect() protoreflect.Message {
-	mi := &file_geegee_proto_msgTypes[8]
+	mi := &file_geegee_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -718,7 +951,7 @@ func (x *ProbeTarget) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ProbeTarget.ProtoReflect.Descriptor instead.
 func (*ProbeTarget) Descriptor() ([]byte, []int) {
-	return file_geegee_proto_rawDescGZIP(), []int{8}
+	return file_geegee_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *ProbeTarget) GetIp() string {
@@ -742,67 +975,622 @@ func (x *ProbeTarget) GetTargetType() string {
 	return ""
 }
 
-var File_geegee_proto protoreflect.FileDescriptor
+// Task 主控下发的一次性诊断任务
+type Task struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`                             // tcpping / udp / ping / traceroute / http / throughput_server / throughput_client
+	Target        string                 `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`                         // tcpping、udp、throughput_client 为 host:port；ping、traceroute 为 host；http 为 URL；throughput_server 为监听地址
+	Count         int32                  `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`                          // 探测次数，traceroute 为最大跳数；0 取节点默认值
+	TimeoutMs     int32                  `protobuf:"varint,5,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"` // 整个任务的时限
+	Throughput    *ThroughputParams      `protobuf:"bytes,6,opt,name=throughput,proto3" json:"throughput,omitempty"`                 // 仅吞吐测试
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_geegee_proto_rawDesc = "" +
-	"\n" +
-	"\fgeegee.proto\x12\vgeegeepb.v1\"\xdc\x02\n" +
-	"\rReportRequest\x12\x17\n" +
-	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1c\n" +
-	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12)\n" +
-	"\x03cpu\x18\x03 \x01(\v2\x17.geegeepb.v1.CPUSummaryR\x03cpu\x12)\n" +
-	"\x03mem\x18\x04 \x01(\v2\x17.geegeepb.v1.MemSummaryR\x03mem\x12,\n" +
-	"\x04disk\x18\x05 \x01(\v2\x18.geegeepb.v1.DiskSummaryR\x04disk\x12)\n" +
-	"\x03net\x18\x06 \x01(\v2\x17.geegeepb.v1.NetSummaryR\x03net\x12)\n" +
-	"\x03kvm\x18\a \x01(\v2\x17.geegeepb.v1.KVMSummaryR\x03kvm\x12:\n" +
-	"\fping_results\x18\b \x03(\v2\x17.geegeepb.v1.PingResultR\vpingResults\"\xb6\x01\n" +
-	"\n" +
-	"CPUSummary\x12\x1d\n" +
-	"\n" +
-	"model_name\x18\x01 \x01(\tR\tmodelName\x12\x14\n" +
-	"\x05cores\x18\x02 \x01(\x05R\x05cores\x12\x10\n" +
-	"\x03mhz\x18\x03 \x01(\x01R\x03mhz\x12\x1d\n" +
-	"\n" +
-	"usage_perc\x18\x04 \x03(\x01R\tusagePerc\x12\x14\n" +
-	"\x05load1\x18\x05 \x01(\x01R\x05load1\x12\x14\n" +
-	"\x05load5\x18\x06 \x01(\x01R\x05load5\x12\x16\n" +
-	"\x06load15\x18\a \x01(\x01R\x06load15\"\xb3\x01\n" +
-	"\n" +
-	"MemSummary\x12\x14\n" +
-	"\x05total\x18\x01 \x01(\x04R\x05total\x12\x1c\n" +
-	"\tavailable\x18\x02 \x01(\x04R\tavailable\x12\x12\n" +
-	"\x04used\x18\x03 \x01(\x04R\x04used\x12!\n" +
-	"\fused_percent\x18\x04 \x01(\x01R\vusedPercent\x12\x1d\n" +
-	"\n" +
-	"swap_total\x18\x05 \x01(\x04R\tswapTotal\x12\x1b\n" +
-	"\tswap_free\x18\x06 \x01(\x04R\bswapFree\"\xb7\x01\n" +
-	"\vDiskSummary\x12\x1d\n" +
-	"\n" +
-	"read_bytes\x18\x01 \x01(\x04R\treadBytes\x12\x1f\n" +
-	"\vwrite_bytes\x18\x02 \x01(\x04R\n" +
-	"writeBytes\x12\x1d\n" +
-	"\n" +
-	"read_count\x18\x03 \x01(\x04R\treadCount\x12\x1f\n" +
-	"\vwrite_count\x18\x04 \x01(\x04R\n" +
-	"writeCount\x12(\n" +
-	"\x10iops_in_progress\x18\x05 \x01(\x04R\x0eiopsInProgress\"\xe3\x01\n" +
-	"\n" +
-	"NetSummary\x12\x1d\n" +
-	"\n" +
-	"bytes_recv\x18\x01 \x01(\x04R\tbytesRecv\x12\x1d\n" +
-	"\n" +
-	"bytes_sent\x18\x02 \x01(\x04R\tbytesSent\x12!\n" +
-	"\fpackets_recv\x18\x03 \x01(\x04R\vpacketsRecv\x12!\n" +
-	"\fpackets_sent\x18\x04 \x01(\x04R\vpacketsSent\x12+\n" +
-	"\x11microburst_events\x18\x05 \x01(\x04R\x10microburstEvents\x12$\n" +
-	"\x0eburst_p95_rate\x18\x06 \x01(\x01R\fburstP95Rate\"\x9a\x01\n" +
-	"\n" +
-	"KVMSummary\x12\x1b\n" +
-	"\ttotal_vms\x18\x01 \x01(\x05R\btotalVms\x12\x1d\n" +
-	"\n" +
+func (x *Task) Reset() {
+	*x = Task{}
+	mi := &file_geegee_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Task) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Task) ProtoMessage() {}
+
+func (x *Task) ProtoReflect() protoreflect.Message {
+	mi := &file_geegee_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Task.ProtoReflect.Descriptor instead.
+func (*Task) Descriptor() ([]byte, []int) {
+	return file_geegee_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Task) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Task) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Task) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *Task) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Task) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *Task) GetThroughput() *ThroughputParams {
+	if x != nil {
+		return x.Throughput
+	}
+	return nil
+}
+
+// ThroughputParams 节点间吞吐测试的参数，服务端与客户端取值相同
+type ThroughputParams struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Protocol      string                 `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`                        // tcp / udp
+	DurationMs    int64                  `protobuf:"varint,2,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"` // 发送时长
+	MaxBytes      int64                  `protobuf:"varint,3,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`       // 发送字节上限，与时长先到者为准
+	RateBps       int64                  `protobuf:"varint,4,opt,name=rate_bps,json=rateBps,proto3" json:"rate_bps,omitempty"`          // udp 的目标发送速率 (bit/s)
+	Token         string                 `protobuf:"bytes,5,opt,name=token,proto3" json:"token,omitempty"`                              // 本次测试的口令，服务端只接受携带该口令的连接
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ThroughputParams) Reset() {
+	*x = ThroughputParams{}
+	mi := &file_geegee_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ThroughputParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ThroughputParams) ProtoMessage() {}
+
+func (x *ThroughputParams) ProtoReflect() protoreflect.Message {
+	mi := &file_geegee_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ThroughputParams.ProtoReflect.Descriptor instead.
+func (*ThroughputParams) Descriptor() ([]byte, []int) {
+	return file_geegee_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ThroughputParams) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *ThroughputParams) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *ThroughputParams) GetMaxBytes() int64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+func (x *ThroughputParams) GetRateBps() int64 {
+	if x != nil {
+		return x.RateBps
+	}
+	return 0
+}
+
+func (x *ThroughputParams) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+// TaskResult 节点执行诊断任务的结果
+type TaskResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`                           // 非空表示执行失败
+	StartedAt     int64                  `protobuf:"varint,3,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"` // Unix 毫秒
+	FinishedAt    int64                  `protobuf:"varint,4,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	Ping          *PingResult            `protobuf:"bytes,5,opt,name=ping,proto3" json:"ping,omitempty"`             // tcpping / udp / ping
+	Hops          []*TraceHop            `protobuf:"bytes,6,rep,name=hops,proto3" json:"hops,omitempty"`             // traceroute
+	Http          *HttpResult            `protobuf:"bytes,7,opt,name=http,proto3" json:"http,omitempty"`             // http
+	Throughput    *ThroughputResult      `protobuf:"bytes,8,opt,name=throughput,proto3" json:"throughput,omitempty"` // throughput_server / throughput_client
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TaskResult) Reset() {
+	*x = TaskResult{}
+	mi := &file_geegee_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaskResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaskResult) ProtoMessage() {}
+
+func (x *TaskResult) ProtoReflect() protoreflect.Message {
+	mi := &file_geegee_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaskResult.ProtoReflect.Descriptor instead.
+func (*TaskResult) Descriptor() ([]byte, []int) {
+	return file_geegee_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *TaskResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TaskResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *TaskResult) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *TaskResult) GetFinishedAt() int64 {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return 0
+}
+
+func (x *TaskResult) GetPing() *PingResult {
+	if x != nil {
+		return x.Ping
+	}
+	return nil
+}
+
+func (x *TaskResult) GetHops() []*TraceHop {
+	if x != nil {
+		return x.Hops
+	}
+	return nil
+}
+
+func (x *TaskResult) GetHttp() *HttpResult {
+	if x != nil {
+		return x.Http
+	}
+	return nil
+}
+
+func (x *TaskResult) GetThroughput() *ThroughputResult {
+	if x != nil {
+		return x.Throughput
+	}
+	return nil
+}
+
+// TraceHop traceroute 的一跳，addr 为空表示该跳超时无应答
+type TraceHop struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ttl           int32                  `protobuf:"varint,1,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Addr          string                 `protobuf:"bytes,2,opt,name=addr,proto3" json:"addr,omitempty"`
+	RttMs         float64                `protobuf:"fixed64,3,opt,name=rtt_ms,json=rttMs,proto3" json:"rtt_ms,omitempty"`
+	Reached       bool                   `protobuf:"varint,4,opt,name=reached,proto3" json:"reached,omitempty"` // 已到达目标
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TraceHop) Reset() {
+	*x = TraceHop{}
+	mi := &file_geegee_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TraceHop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceHop) ProtoMessage() {}
+
+func (x *TraceHop) ProtoReflect() protoreflect.Message {
+	mi := &file_geegee_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceHop.ProtoReflect.Descriptor instead.
+func (*TraceHop) Descriptor() ([]byte, []int) {
+	return file_geegee_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *TraceHop) GetTtl() int32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *TraceHop) GetAddr() string {
+	if x != nil {
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *TraceHop) GetRttMs() float64 {
+	if x != nil {
+		return x.RttMs
+	}
+	return 0
+}
+
+func (x *TraceHop) GetReached() bool {
+	if x != nil {
+		return x.Reached
+	}
+	return false
+}
+
+// HttpResult HTTP 检查的各阶段耗时 (毫秒)，复用连接或非 TLS 时对应阶段为 0
+type HttpResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StatusCode    int32                  `protobuf:"varint,1,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	RemoteAddr    string                 `protobuf:"bytes,2,opt,name=remote_addr,json=remoteAddr,proto3" json:"remote_addr,omitempty"`
+	DnsMs         float64                `protobuf:"fixed64,3,opt,name=dns_ms,json=dnsMs,proto3" json:"dns_ms,omitempty"`
+	ConnectMs     float64                `protobuf:"fixed64,4,opt,name=connect_ms,json=connectMs,proto3" json:"connect_ms,omitempty"`
+	TlsMs         float64                `protobuf:"fixed64,5,opt,name=tls_ms,json=tlsMs,proto3" json:"tls_ms,omitempty"`
+	TtfbMs        float64                `protobuf:"fixed64,6,opt,name=ttfb_ms,json=ttfbMs,proto3" json:"ttfb_ms,omitempty"`
+	TotalMs       float64                `protobuf:"fixed64,7,opt,name=total_ms,json=totalMs,proto3" json:"total_ms,omitempty"`
+	BodyBytes     int64                  `protobuf:"varint,8,opt,name=body_bytes,json=bodyBytes,proto3" json:"body_bytes,omitempty"`
+	CertExpiresAt int64                  `protobuf:"varint,9,opt,name=cert_expires_at,json=certExpiresAt,proto3" json:"cert_expires_at,omitempty"` // 证书到期时间 (Unix 毫秒)，非 HTTPS 为 0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HttpResult) Reset() {
+	*x = HttpResult{}
+	mi := &file_geegee_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HttpResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HttpResult) ProtoMessage() {}
+
+func (x *HttpResult) ProtoReflect() protoreflect.Message {
+	mi := &file_geegee_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HttpResult.ProtoReflect.Descriptor instead.
+func (*HttpResult) Descriptor() ([]byte, []int) {
+	return file_geegee_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *HttpResult) GetStatusCode() int32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *HttpResult) GetRemoteAddr() string {
+	if x != nil {
+		return x.RemoteAddr
+	}
+	return ""
+}
+
+func (x *HttpResult) GetDnsMs() float64 {
+	if x != nil {
+		return x.DnsMs
+	}
+	return 0
+}
+
+func (x *HttpResult) GetConnectMs() float64 {
+	if x != nil {
+		return x.ConnectMs
+	}
+	return 0
+}
+
+func (x *HttpResult) GetTlsMs() float64 {
+	if x != nil {
+		return x.TlsMs
+	}
+	return 0
+}
+
+func (x *HttpResult) GetTtfbMs() float64 {
+	if x != nil {
+		return x.TtfbMs
+	}
+	return 0
+}
+
+func (x *HttpResult) GetTotalMs() float64 {
+	if x != nil {
+		return x.TotalMs
+	}
+	return 0
+}
+
+func (x *HttpResult) GetBodyBytes() int64 {
+	if x != nil {
+		return x.BodyBytes
+	}
+	return 0
+}
+
+func (x *HttpResult) GetCertExpiresAt() int64 {
+	if x != nil {
+		return x.CertExpiresAt
+	}
+	return 0
+}
+
+// ThroughputResult 吞吐测试一端的统计。服务端为接收方，其字节数与时长用于计算吞吐
+type ThroughputResult struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Bytes           int64                  `protobuf:"varint,1,opt,name=bytes,proto3" json:"bytes,omitempty"` // 发送端为已发送，接收端为已接收的负载字节数
+	DurationMs      int64                  `protobuf:"varint,2,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	BitsPerSecond   float64                `protobuf:"fixed64,3,opt,name=bits_per_second,json=bitsPerSecond,proto3" json:"bits_per_second,omitempty"`
+	Retransmits     uint32                 `protobuf:"varint,4,opt,name=retransmits,proto3" json:"retransmits,omitempty"`                                // tcp 发送端的重传段数，平台不支持时为 0
+	PacketsSent     uint64                 `protobuf:"varint,5,opt,name=packets_sent,json=packetsSent,proto3" json:"packets_sent,omitempty"`             // udp：发送端为已发送；接收端为发送端在结束报文中声明的数量
+	PacketsReceived uint64                 `protobuf:"varint,6,opt,name=packets_received,json=packetsReceived,proto3" json:"packets_received,omitempty"` // udp 接收端
+	JitterMs        float64                `protobuf:"fixed64,7,opt,name=jitter_ms,json=jitterMs,proto3" json:"jitter_ms,omitempty"`                     // udp 接收端，RFC 3550 到达间隔抖动
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ThroughputResult) Reset() {
+	*x = ThroughputResult{}
+	mi := &file_geegee_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ThroughputResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ThroughputResult) ProtoMessage() {}
+
+func (x *ThroughputResult) ProtoReflect() protoreflect.Message {
+	mi := &file_geegee_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ThroughputResult.ProtoReflect.Descriptor instead.
+func (*ThroughputResult) Descriptor() ([]byte, []int) {
+	return file_geegee_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ThroughputResult) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *ThroughputResult) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *ThroughputResult) GetBitsPerSecond() float64 {
+	if x != nil {
+		return x.BitsPerSecond
+	}
+	return 0
+}
+
+func (x *ThroughputResult) GetRetransmits() uint32 {
+	if x != nil {
+		return x.Retransmits
+	}
+	return 0
+}
+
+func (x *ThroughputResult) GetPacketsSent() uint64 {
+	if x != nil {
+		return x.PacketsSent
+	}
+	return 0
+}
+
+func (x *ThroughputResult) GetPacketsReceived() uint64 {
+	if x != nil {
+		return x.PacketsReceived
+	}
+	return 0
+}
+
+func (x *ThroughputResult) GetJitterMs() float64 {
+	if x != nil {
+		return x.JitterMs
+	}
+	return 0
+}
+
+var File_geegee_proto protoreflect.FileDescriptor
+
+const file_geegee_proto_rawDesc = "" +
+	"\n" +
+	"\fgeegee.proto\x12\vgeegeepb.v1\"\xc3\x03\n" +
+	"\rReportRequest\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp\x12)\n" +
+	"\x03cpu\x18\x03 \x01(\v2\x17.geegeepb.v1.CPUSummaryR\x03cpu\x12)\n" +
+	"\x03mem\x18\x04 \x01(\v2\x17.geegeepb.v1.MemSummaryR\x03mem\x12,\n" +
+	"\x04disk\x18\x05 \x01(\v2\x18.geegeepb.v1.DiskSummaryR\x04disk\x12)\n" +
+	"\x03net\x18\x06 \x01(\v2\x17.geegeepb.v1.NetSummaryR\x03net\x12)\n" +
+	"\x03kvm\x18\a \x01(\v2\x17.geegeepb.v1.KVMSummaryR\x03kvm\x12:\n" +
+	"\fping_results\x18\b \x03(\v2\x17.geegeepb.v1.PingResultR\vpingResults\x12)\n" +
+	"\x04info\x18\t \x01(\v2\x15.geegeepb.v1.NodeInfoR\x04info\x12:\n" +
+	"\ftask_results\x18\n" +
+	" \x03(\v2\x17.geegeepb.v1.TaskResultR\vtaskResults\"\xdd\x01\n" +
+	"\bNodeInfo\x12!\n" +
+	"\fdisplay_name\x18\x01 \x01(\tR\vdisplayName\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x129\n" +
+	"\x06labels\x18\x03 \x03(\v2!.geegeepb.v1.NodeInfo.LabelsEntryR\x06labels\x12\x16\n" +
+	"\x06groups\x18\x04 \x03(\tR\x06groups\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb6\x01\n" +
+	"\n" +
+	"CPUSummary\x12\x1d\n" +
+	"\n" +
+	"model_name\x18\x01 \x01(\tR\tmodelName\x12\x14\n" +
+	"\x05cores\x18\x02 \x01(\x05R\x05cores\x12\x10\n" +
+	"\x03mhz\x18\x03 \x01(\x01R\x03mhz\x12\x1d\n" +
+	"\n" +
+	"usage_perc\x18\x04 \x03(\x01R\tusagePerc\x12\x14\n" +
+	"\x05load1\x18\x05 \x01(\x01R\x05load1\x12\x14\n" +
+	"\x05load5\x18\x06 \x01(\x01R\x05load5\x12\x16\n" +
+	"\x06load15\x18\a \x01(\x01R\x06load15\"\xb3\x01\n" +
+	"\n" +
+	"MemSummary\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x04R\x05total\x12\x1c\n" +
+	"\tavailable\x18\x02 \x01(\x04R\tavailable\x12\x12\n" +
+	"\x04used\x18\x03 \x01(\x04R\x04used\x12!\n" +
+	"\fused_percent\x18\x04 \x01(\x01R\vusedPercent\x12\x1d\n" +
+	"\n" +
+	"swap_total\x18\x05 \x01(\x04R\tswapTotal\x12\x1b\n" +
+	"\tswap_free\x18\x06 \x01(\x04R\bswapFree\"\xb7\x01\n" +
+	"\vDiskSummary\x12\x1d\n" +
+	"\n" +
+	"read_bytes\x18\x01 \x01(\x04R\treadBytes\x12\x1f\n" +
+	"\vwrite_bytes\x18\x02 \x01(\x04R\n" +
+	"writeBytes\x12\x1d\n" +
+	"\n" +
+	"read_count\x18\x03 \x01(\x04R\treadCount\x12\x1f\n" +
+	"\vwrite_count\x18\x04 \x01(\x04R\n" +
+	"writeCount\x12(\n" +
+	"\x10iops_in_progress\x18\x05 \x01(\x04R\x0eiopsInProgress\"\x9e\x02\n" +
+	"\n" +
+	"NetSummary\x12\x1d\n" +
+	"\n" +
+	"bytes_recv\x18\x01 \x01(\x04R\tbytesRecv\x12\x1d\n" +
+	"\n" +
+	"bytes_sent\x18\x02 \x01(\x04R\tbytesSent\x12!\n" +
+	"\fpackets_recv\x18\x03 \x01(\x04R\vpacketsRecv\x12!\n" +
+	"\fpackets_sent\x18\x04 \x01(\x04R\vpacketsSent\x12+\n" +
+	"\x11microburst_events\x18\x05 \x01(\x04R\x10microburstEvents\x12$\n" +
+	"\x0eburst_p95_rate\x18\x06 \x01(\x01R\fburstP95Rate\x129\n" +
+	"\n" +
+	"interfaces\x18\a \x03(\v2\x19.geegeepb.v1.NetInterfaceR\n" +
+	"interfaces\"z\n" +
+	"\fNetInterface\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"bytes_recv\x18\x02 \x01(\x04R\tbytesRecv\x12\x1d\n" +
+	"\n" +
+	"bytes_sent\x18\x03 \x01(\x04R\tbytesSent\x12\x18\n" +
+	"\avirtual\x18\x04 \x01(\bR\avirtual\"\x9a\x01\n" +
+	"\n" +
+	"KVMSummary\x12\x1b\n" +
+	"\ttotal_vms\x18\x01 \x01(\x05R\btotalVms\x12\x1d\n" +
+	"\n" +
 	"active_vms\x18\x02 \x01(\x05R\tactiveVms\x12(\n" +
 	"\x10total_alloc_vcpu\x18\x03 \x01(\x05R\x0etotalAllocVcpu\x12&\n" +
-	"\x0ftotal_alloc_mem\x18\x04 \x01(\x04R\rtotalAllocMem\"\xef\x01\n" +
+	"\x0ftotal_alloc_mem\x18\x04 \x01(\x04R\rtotalAllocMem\"\xb4\x03\n" +
 	"\n" +
 	"PingResult\x12\x1b\n" +
 	"\ttarget_ip\x18\x01 \x01(\tR\btargetIp\x12\x1f\n" +
@@ -816,16 +1604,87 @@ const file_geegee_proto_rawDesc = "" +
 	"avg_rtt_ms\x18\x05 \x01(\x01R\bavgRttMs\x12(\n" +
 	"\x10packet_loss_rate\x18\x06 \x01(\x01R\x0epacketLossRate\x12\x1f\n" +
 	"\vtarget_type\x18\a \x01(\tR\n" +
-	"targetType\"\x83\x01\n" +
+	"targetType\x12\x1b\n" +
+	"\tjitter_ms\x18\b \x01(\x01R\bjitterMs\x12\x1c\n" +
+	"\treordered\x18\t \x01(\rR\treordered\x12\x1e\n" +
+	"\n" +
+	"duplicates\x18\n" +
+	" \x01(\rR\n" +
+	"duplicates\x12*\n" +
+	"\x11loss_forward_rate\x18\v \x01(\x01R\x0flossForwardRate\x12*\n" +
+	"\x11loss_reverse_rate\x18\f \x01(\x01R\x0flossReverseRate\x12\x10\n" +
+	"\x03mos\x18\r \x01(\x01R\x03mos\"\xd7\x01\n" +
 	"\x0eReportResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12=\n" +
-	"\rprobe_targets\x18\x03 \x03(\v2\x18.geegeepb.v1.ProbeTargetR\fprobeTargets\"R\n" +
+	"\rprobe_targets\x18\x03 \x03(\v2\x18.geegeepb.v1.ProbeTargetR\fprobeTargets\x12)\n" +
+	"\x10targets_assigned\x18\x05 \x01(\bR\x0ftargetsAssigned\x12'\n" +
+	"\x05tasks\x18\x04 \x03(\v2\x11.geegeepb.v1.TaskR\x05tasks\"R\n" +
 	"\vProbeTarget\x12\x0e\n" +
 	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x12\n" +
 	"\x04port\x18\x02 \x01(\x05R\x04port\x12\x1f\n" +
 	"\vtarget_type\x18\x03 \x01(\tR\n" +
-	"targetType2\\\n" +
+	"targetType\"\xb6\x01\n" +
+	"\x04Task\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x16\n" +
+	"\x06target\x18\x03 \x01(\tR\x06target\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x05R\x05count\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x05 \x01(\x05R\ttimeoutMs\x12=\n" +
+	"\n" +
+	"throughput\x18\x06 \x01(\v2\x1d.geegeepb.v1.ThroughputParamsR\n" +
+	"throughput\"\x9d\x01\n" +
+	"\x10ThroughputParams\x12\x1a\n" +
+	"\bprotocol\x18\x01 \x01(\tR\bprotocol\x12\x1f\n" +
+	"\vduration_ms\x18\x02 \x01(\x03R\n" +
+	"durationMs\x12\x1b\n" +
+	"\tmax_bytes\x18\x03 \x01(\x03R\bmaxBytes\x12\x19\n" +
+	"\brate_bps\x18\x04 \x01(\x03R\arateBps\x12\x14\n" +
+	"\x05token\x18\x05 \x01(\tR\x05token\"\xb6\x02\n" +
+	"\n" +
+	"TaskResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\x03 \x01(\x03R\tstartedAt\x12\x1f\n" +
+	"\vfinished_at\x18\x04 \x01(\x03R\n" +
+	"finishedAt\x12+\n" +
+	"\x04ping\x18\x05 \x01(\v2\x17.geegeepb.v1.PingResultR\x04ping\x12)\n" +
+	"\x04hops\x18\x06 \x03(\v2\x15.geegeepb.v1.TraceHopR\x04hops\x12+\n" +
+	"\x04http\x18\a \x01(\v2\x17.geegeepb.v1.HttpResultR\x04http\x12=\n" +
+	"\n" +
+	"throughput\x18\b \x01(\v2\x1d.geegeepb.v1.ThroughputResultR\n" +
+	"throughput\"a\n" +
+	"\bTraceHop\x12\x10\n" +
+	"\x03ttl\x18\x01 \x01(\x05R\x03ttl\x12\x12\n" +
+	"\x04addr\x18\x02 \x01(\tR\x04addr\x12\x15\n" +
+	"\x06rtt_ms\x18\x03 \x01(\x01R\x05rttMs\x12\x18\n" +
+	"\areached\x18\x04 \x01(\bR\areached\"\x96\x02\n" +
+	"\n" +
+	"HttpResult\x12\x1f\n" +
+	"\vstatus_code\x18\x01 \x01(\x05R\n" +
+	"statusCode\x12\x1f\n" +
+	"\vremote_addr\x18\x02 \x01(\tR\n" +
+	"remoteAddr\x12\x15\n" +
+	"\x06dns_ms\x18\x03 \x01(\x01R\x05dnsMs\x12\x1d\n" +
+	"\n" +
+	"connect_ms\x18\x04 \x01(\x01R\tconnectMs\x12\x15\n" +
+	"\x06tls_ms\x18\x05 \x01(\x01R\x05tlsMs\x12\x17\n" +
+	"\attfb_ms\x18\x06 \x01(\x01R\x06ttfbMs\x12\x19\n" +
+	"\btotal_ms\x18\a \x01(\x01R\atotalMs\x12\x1d\n" +
+	"\n" +
+	"body_bytes\x18\b \x01(\x03R\tbodyBytes\x12&\n" +
+	"\x0fcert_expires_at\x18\t \x01(\x03R\rcertExpiresAt\"\xfe\x01\n" +
+	"\x10ThroughputResult\x12\x14\n" +
+	"\x05bytes\x18\x01 \x01(\x03R\x05bytes\x12\x1f\n" +
+	"\vduration_ms\x18\x02 \x01(\x03R\n" +
+	"durationMs\x12&\n" +
+	"\x0fbits_per_second\x18\x03 \x01(\x01R\rbitsPerSecond\x12 \n" +
+	"\vretransmits\x18\x04 \x01(\rR\vretransmits\x12!\n" +
+	"\fpackets_sent\x18\x05 \x01(\x04R\vpacketsSent\x12)\n" +
+	"\x10packets_received\x18\x06 \x01(\x04R\x0fpacketsReceived\x12\x1b\n" +
+	"\tjitter_ms\x18\a \x01(\x01R\bjitterMs2\\\n" +
 	"\fProbeService\x12L\n" +
 	"\rReportMetrics\x12\x1a.geegeepb.v1.ReportRequest\x1a\x1b.geegeepb.v1.ReportResponse(\x010\x01B2Z0github.com/geelinx-ltd/geegee/api/proto;geegeepbb\x06proto3"
 
@@ -841,33 +1700,52 @@ func file_geegee_proto_rawDescGZIP() []byte {
 	return file_geegee_proto_rawDescData
 }
 
-var file_geegee_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_geegee_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_geegee_proto_goTypes = []any{
-	(*ReportRequest)(nil),  // 0: geegeepb.v1.ReportRequest
-	(*CPUSummary)(nil),     // 1: geegeepb.v1.CPUSummary
-	(*MemSummary)(nil),     // 2: geegeepb.v1.MemSummary
-	(*DiskSummary)(nil),    // 3: geegeepb.v1.DiskSummary
-	(*NetSummary)(nil),     // 4: geegeepb.v1.NetSummary
-	(*KVMSummary)(nil),     // 5: geegeepb.v1.KVMSummary
-	(*PingResult)(nil),     // 6: geegeepb.v1.PingResult
-	(*ReportResponse)(nil), // 7: geegeepb.v1.ReportResponse
-	(*ProbeTarget)(nil),    // 8: geegeepb.v1.ProbeTarget
+	(*ReportRequest)(nil),    // 0: geegeepb.v1.ReportRequest
+	(*NodeInfo)(nil),         // 1: geegeepb.v1.NodeInfo
+	(*CPUSummary)(nil),       // 2: geegeepb.v1.CPUSummary
+	(*MemSummary)(nil),       // 3: geegeepb.v1.MemSummary
+	(*DiskSummary)(nil),      // 4: geegeepb.v1.DiskSummary
+	(*NetSummary)(nil),       // 5: geegeepb.v1.NetSummary
+	(*NetInterface)(nil),     // 6: geegeepb.v1.NetInterface
+	(*KVMSummary)(nil),       // 7: geegeepb.v1.KVMSummary
+	(*PingResult)(nil),       // 8: geegeepb.v1.PingResult
+	(*ReportResponse)(nil),   // 9: geegeepb.v1.ReportResponse
+	(*ProbeTarget)(nil),      // 10: geegeepb.v1.ProbeTarget
+	(*Task)(nil),             // 11: geegeepb.v1.Task
+	(*ThroughputParams)(nil), // 12: geegeepb.v1.ThroughputParams
+	(*TaskResult)(nil),       // 13: geegeepb.v1.TaskResult
+	(*TraceHop)(nil),         // 14: geegeepb.v1.TraceHop
+	(*HttpResult)(nil),       // 15: geegeepb.v1.HttpResult
+	(*ThroughputResult)(nil), // 16: geegeepb.v1.ThroughputResult
+	nil,                      // 17: geegeepb.v1.NodeInfo.LabelsEntry
 }
 var file_geegee_proto_depIdxs = []int32{
-	1, // 0: geegeepb.v1.ReportRequest.cpu:type_name -> geegeepb.v1.CPUSummary
-	2, // 1: geegeepb.v1.ReportRequest.mem:type_name -> geegeepb.v1.MemSummary
-	3, // 2: geegeepb.v1.ReportRequest.disk:type_name -> geegeepb.v1.DiskSummary
-	4, // 3: geegeepb.v1.ReportRequest.net:type_name -> geegeepb.v1.NetSummary
-	5, // 4: geegeepb.v1.ReportRequest.kvm:type_name -> geegeepb.v1.KVMSummary
-	6, // 5: geegeepb.v1.ReportRequest.ping_results:type_name -> geegeepb.v1.PingResult
-	8, // 6: geegeepb.v1.ReportResponse.probe_targets:type_name -> geegeepb.v1.ProbeTarget
-	0, // 7: geegeepb.v1.ProbeService.ReportMetrics:input_type -> geegeepb.v1.ReportRequest
-	7, // 8: geegeepb.v1.ProbeService.ReportMetrics:output_type -> geegeepb.v1.ReportResponse
-	8, // [8:9] is the sub-list for method output_type
-	7, // [7:8] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	2,  // 0: geegeepb.v1.ReportRequest.cpu:type_name -> geegeepb.v1.CPUSummary
+	3,  // 1: geegeepb.v1.ReportRequest.mem:type_name -> geegeepb.v1.MemSummary
+	4,  // 2: geegeepb.v1.ReportRequest.disk:type_name -> geegeepb.v1.DiskSummary
+	5,  // 3: geegeepb.v1.ReportRequest.net:type_name -> geegeepb.v1.NetSummary
+	7,  // 4: geegeepb.v1.ReportRequest.kvm:type_name -> geegeepb.v1.KVMSummary
+	8,  // 5: geegeepb.v1.ReportRequest.ping_results:type_name -> geegeepb.v1.PingResult
+	1,  // 6: geegeepb.v1.ReportRequest.info:type_name -> geegeepb.v1.NodeInfo
+	13, // 7: geegeepb.v1.ReportRequest.task_results:type_name -> geegeepb.v1.TaskResult
+	17, // 8: geegeepb.v1.NodeInfo.labels:type_name -> geegeepb.v1.NodeInfo.LabelsEntry
+	6,  // 9: geegeepb.v1.NetSummary.interfaces:type_name -> geegeepb.v1.NetInterface
+	10, // 10: geegeepb.v1.ReportResponse.probe_targets:type_name -> geegeepb.v1.ProbeTarget
+	11, // 11: geegeepb.v1.ReportResponse.tasks:type_name -> geegeepb.v1.Task
+	12, // 12: geegeepb.v1.Task.throughput:type_name -> geegeepb.v1.ThroughputParams
+	8,  // 13: geegeepb.v1.TaskResult.ping:type_name -> geegeepb.v1.PingResult
+	14, // 14: geegeepb.v1.TaskResult.hops:type_name -> geegeepb.v1.TraceHop
+	15, // 15: geegeepb.v1.TaskResult.http:type_name -> geegeepb.v1.HttpResult
+	16, // 16: geegeepb.v1.TaskResult.throughput:type_name -> geegeepb.v1.ThroughputResult
+	0,  // 17: geegeepb.v1.ProbeService.ReportMetrics:input_type -> geegeepb.v1.ReportRequest
+	9,  // 18: geegeepb.v1.ProbeService.ReportMetrics:output_type -> geegeepb.v1.ReportResponse
+	18, // [18:19] is the sub-list for method output_type
+	17, // [17:18] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
 }
 
 func init() { file_geegee_proto_init() }
@@ -881,7 +1759,7 @@ func file_geegee_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_geegee_proto_rawDesc), len(file_geegee_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   18,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -1,63 +1,357 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	pb "github.com/geelinx-ltd/geegee/api/proto"
 	"github.com/geelinx-ltd/geegee/controller/config"
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+	"github.com/geelinx-ltd/geegee/controller/internal/anomaly"
 	"github.com/geelinx-ltd/geegee/controller/internal/api"
+	"github.com/geelinx-ltd/geegee/controller/internal/billing"
+	"github.com/geelinx-ltd/geegee/controller/internal/lifecycle"
+	"github.com/geelinx-ltd/geegee/controller/internal/mesh"
+	"github.com/geelinx-ltd/geegee/controller/internal/nodemeta"
+	"github.com/geelinx-ltd/geegee/controller/internal/nodestate"
 	"github.com/geelinx-ltd/geegee/controller/internal/server"
+	"github.com/geelinx-ltd/geegee/controller/internal/silence"
+	"github.com/geelinx-ltd/geegee/controller/internal/sla"
 	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+	"github.com/geelinx-ltd/geegee/controller/internal/tasks"
+	"github.com/geelinx-ltd/geegee/controller/internal/throughput"
 	"google.golang.org/grpc"
 )
 
 func main() {
+	migrateOnly := flag.Bool("migrate-only", false, "apply SQLite schema migrations and exit")
+	backupTo := flag.String("backup", "", "write a consistent snapshot of the SQLite store to this file and exit (safe while the controller runs)")
+	restoreFrom := flag.String("restore", "", "replace the SQLite store with this snapshot and exit (stop the controller first)")
+	flag.Parse()
+
 	log.Println("Starting GeeGee Controller...")
 
 	// 0. 加载外部配置
 	config.LoadConfig("config.yaml")
 	cfg := config.Cfg
 
+	// 升级前可单独执行迁移，确认无误后再启动服务
+	if *migrateOnly {
+		from, to, err := storage.MigrateSqlite(cfg.Storage.Sqlite.Dsn)
+		if err != nil {
+			log.Fatalf("Schema migration failed: %v", err)
+		}
+		log.Printf("Schema migrated from v%d to v%d", from, to)
+		return
+	}
+	if *backupTo != "" {
+		if err := storage.BackupSqlite(cfg.Storage.Sqlite.Dsn, *backupTo); err != nil {
+			log.Fatalf("Backup failed: %v", err)
+		}
+		log.Printf("Backup written to %s", *backupTo)
+		return
+	}
+	if *restoreFrom != "" {
+		previous, err := storage.RestoreSqlite(cfg.Storage.Sqlite.Dsn, *restoreFrom)
+		if err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+		if previous != "" {
+			log.Printf("Previous database kept as %s", previous)
+		}
+		log.Printf("Restored %s from %s", cfg.Storage.Sqlite.Dsn, *restoreFrom)
+		return
+	}
+
 	// 监听端口
 	lis, err := net.Listen("tcp", ":50051")
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	// 1. 初始化统一持久化接口工厂
-	var persister storage.Persister
+	// 1. 初始化统一持久化接口工厂：按配置扇出到多个后端
+	persister, err := newPersister(cfg)
+	if err != nil {
+		log.Fatalf("Failed to init storage: %v", err)
+	}
 
-	if cfg.Storage.Type == "sqlite" {
-		log.Println("Using SQLite Storage Engine...")
-		sqliteDB, err := storage.NewSqliteStore(cfg.Storage.Sqlite.Dsn, cfg.Storage.RetentionDays)
+	// 1.1 最新值快照供 /metrics 抓取，其余可选出口与主存储并行推送
+	latest := storage.NewLatestStore()
+	sinks := []storage.Sink{latest}
+	var remoteWriter *storage.RemoteWriter
+	if rw := cfg.Storage.RemoteWrite; rw.Url != "" {
+		log.Printf("Enabling Prometheus remote_write sink -> %s", rw.Url)
+		remoteWriter, err = storage.NewRemoteWriter(storage.RemoteWriteOptions{
+			URL:            rw.Url,
+			ExternalLabels: rw.ExternalLabels,
+			Shards:         rw.Shards,
+			QueueSize:      rw.QueueSize,
+			BatchSize:      rw.BatchSize,
+			BatchInterval:  rw.BatchInterval,
+			Timeout:        rw.Timeout,
+			MaxRetries:     rw.MaxRetries,
+			MinBackoff:     rw.MinBackoff,
+			MaxBackoff:     rw.MaxBackoff,
+		})
 		if err != nil {
-			log.Fatalf("Failed to open SQLite: %v", err)
+			log.Fatalf("Failed to init remote_write: %v", err)
 		}
-		persister = sqliteDB
+		sinks = append(sinks, remoteWriter)
+	}
 
-	} else if cfg.Storage.Type == "victoria" {
-		log.Println("Using VictoriaMetrics Storage Engine... (Routing back to original MemoryCache layout + TSDB Push stub)")
-		// 为了给 Victoria 用户同样的前端体验，保留内存环路
-		memCache := storage.NewMemoryCache(300)
-		persister = memCache
-	} else {
-		log.Println("Unknown storage type, fallback to Memory-Only.")
-		persister = storage.NewMemoryCache(300)
+	// 1.2 节点元数据：收节点自报的标签与分组，须排在告警引擎之前，新标签对同一帧即生效
+	targetRules := make([]nodemeta.TargetRule, 0, len(cfg.ProbeTargets))
+	for _, t := range cfg.ProbeTargets {
+		targetRules = append(targetRules, nodemeta.TargetRule{
+			Name:     t.Name,
+			Nodes:    t.Nodes,
+			Selector: nodemeta.Selector{Labels: t.Labels, Groups: t.Groups},
+			Targets:  t.Targets,
+			Type:     t.Type,
+		})
+	}
+	nodeMeta, err := nodemeta.NewStore(nodemeta.Options{Path: cfg.NodeMeta.Path, Targets: targetRules})
+	if err != nil {
+		log.Fatalf("Failed to init node metadata: %v", err)
+	}
+	sinks = append(sinks, nodeMeta)
+
+	// 节点生命周期：退役 / 归档的节点再次上报即恢复为 active，须排在状态跟踪与告警之前
+	lc := cfg.Lifecycle
+	lifecycles, err := lifecycle.NewManager(lifecycle.Options{
+		Path:         lc.Path,
+		ArchiveAfter: lc.ArchiveAfter,
+		EventLimit:   lc.EventLimit,
+	}, persister)
+	if err != nil {
+		log.Fatalf("Failed to init node lifecycle: %v", err)
+	}
+	sinks = append(sinks, lifecycles)
+
+	// 节点连接状态机：收上报帧，并由 gRPC 服务通知流的建立与断开
+	ns := cfg.NodeState
+	nodeStates, err := nodestate.NewTracker(nodestate.Options{
+		StaleAfter:       ns.StaleAfter,
+		OfflineAfter:     ns.OfflineAfter,
+		StatePath:        ns.StatePath,
+		HistoryLimit:     ns.HistoryLimit,
+		HistoryRetention: ns.HistoryRetention,
+	})
+	if err != nil {
+		log.Fatalf("Failed to init node state tracking: %v", err)
+	}
+	sinks = append(sinks, nodeStates)
+	// 上报流仍挂着或刚有上报的节点不允许退役、删除与合并
+	lifecycles.SetConnected(func(nodeID string) bool {
+		st, ok := nodeStates.State(nodeID)
+		return ok && (st.Streams > 0 || st.State == nodestate.StateReporting)
+	})
+
+	// 1.3 95 计费计量，同样只写
+	billingLoc, err := loadLocation(cfg.Billing.Timezone)
+	if err != nil {
+		log.Fatalf("Invalid billing.timezone: %v", err)
+	}
+	meter, err := billing.NewMeter(billing.Options{
+		Path:              cfg.Billing.Path,
+		Retention:         cfg.Billing.Retention,
+		CycleDay:          cfg.Billing.CycleDay,
+		Location:          billingLoc,
+		ExcludeInterfaces: cfg.Billing.ExcludeInterfaces,
+	})
+	if err != nil {
+		log.Fatalf("Failed to init billing: %v", err)
+	}
+	sinks = append(sinks, meter)
+
+	// 1.4 延迟异常检测
+	ad := cfg.Anomaly
+	anomalyLoc, err := loadLocation(ad.Timezone)
+	if err != nil {
+		log.Fatalf("Invalid anomaly.timezone: %v", err)
+	}
+	detector, err := anomaly.NewDetector(anomaly.Options{
+		StatePath:      ad.StatePath,
+		Threshold:      ad.Threshold,
+		LossThreshold:  ad.LossThreshold,
+		MinRTTStd:      ad.MinRTTStd,
+		RecentHalfLife: ad.RecentHalfLife,
+		WeeklyHalfLife: ad.WeeklyHalfLife,
+		Warmup:         ad.Warmup,
+		EventRetention: ad.EventRetention,
+		Location:       anomalyLoc,
+	})
+	if err != nil {
+		log.Fatalf("Failed to init anomaly detection: %v", err)
+	}
+	sinks = append(sinks, detector)
+
+	// 1.5 告警引擎同样作为出口逐帧评估，窗口类规则从主存储查询历史
+	ac := cfg.Alerting
+	alerts, err := alerting.NewEngine(alerting.Options{
+		RulesFile:      ac.RulesFile,
+		StatePath:      ac.StatePath,
+		EvalInterval:   ac.EvalInterval,
+		ResolveTimeout: ac.ResolveTimeout,
+		HistoryLimit:   ac.HistoryLimit,
+	}, persister)
+	if err != nil {
+		log.Fatalf("Failed to init alerting: %v", err)
+	}
+	sinks = append(sinks, alerts)
+	alerts.SetNodeMeta(nodeMeta.Get)
+
+	// 节点状态转换推给告警引擎评估 node_up 规则，已退役 / 归档的节点不再告警
+	for _, st := range nodeStates.States() {
+		if lifecycles.Active(st.NodeID) {
+			alerts.SetNodeUp(st.NodeID, st.State.Up())
+		}
+	}
+	nodeStates.Subscribe(func(t nodestate.Transition) {
+		if lifecycles.Active(t.NodeID) {
+			alerts.SetNodeUp(t.NodeID, t.To.Up())
+		}
+	})
+	lifecycles.Subscribe(func(nodeID string, st lifecycle.State) {
+		if st != lifecycle.StateActive {
+			alerts.ForgetNode(nodeID, "node "+string(st))
+		}
+	})
+	// 异常分数同样推给告警引擎评估 ping_*_anomaly 规则
+	detector.Subscribe(func(s anomaly.Score) {
+		alerts.SetAnomaly(s.NodeID, s.Target, s.RTTScore, s.LossScore)
+	})
+
+	// 1.6 告警通知：重启前已在 firing 的告警视为已通知，之后的状态变化按分组推送；
+	// 命中静默或维护窗口的告警不推送
+	silences, err := silence.NewStore(silence.Options{
+		Path:      cfg.Silences.Path,
+		Retention: cfg.Silences.Retention,
+	})
+	if err != nil {
+		log.Fatalf("Failed to load silences: %v", err)
+	}
+	silences.SetGroups(nodeMeta.NodeGroups)
+	notifier, err := newDispatcher(cfg, silences)
+	if err != nil {
+		log.Fatalf("Failed to init notify: %v", err)
+	}
+	active, _ := alerts.Alerts()
+	notifier.Seed(active)
+	alerts.Subscribe(notifier.Notify)
+
+	// 1.7 可用性报表
+	slaLoc, err := loadLocation(cfg.SLA.Timezone)
+	if err != nil {
+		log.Fatalf("Invalid sla.timezone: %v", err)
+	}
+	reporter := sla.NewReporter(nodeStates, persister, sla.Options{
+		Target:      cfg.SLA.Target,
+		Location:    slaLoc,
+		StaleAsDown: cfg.SLA.StaleAsDown,
+	})
+
+	// 1.8 全网格探测：接管探测目标下发，在 probe_targets 规则之外追加其余参与节点
+	var meshes *mesh.Mesh
+	if mc := cfg.Mesh; mc.Enabled {
+		meshes, err = mesh.New(mesh.Options{
+			Selector:         nodemeta.Selector{Labels: mc.Labels, Groups: mc.Groups},
+			Port:             mc.Port,
+			Type:             mc.Type,
+			AddressLabel:     mc.AddressLabel,
+			Window:           mc.Window,
+			BaselineHalfLife: mc.BaselineHalfLife,
+			MinBaseline:      mc.MinBaseline,
+			DegradedLoss:     mc.DegradedLoss,
+			DegradedRatio:    mc.DegradedRatio,
+			DegradedDeltaMs:  mc.DegradedDeltaMs,
+			StatePath:        mc.StatePath,
+		}, nodeMeta, persister)
+		if err != nil {
+			log.Fatalf("Failed to init mesh: %v", err)
+		}
+		meshes.SetNodeMeta(nodeMeta.Get)
+		meshes.SetActive(lifecycles.Active)
+		sinks = append(sinks, meshes)
+	}
+
+	// 1.9 按需诊断任务与节点间吞吐测试，均经上报流下发
+	taskMgr := tasks.NewManager(tasks.Options{
+		PerNode:        cfg.Tasks.PerNode,
+		QueuePerNode:   cfg.Tasks.QueuePerNode,
+		DefaultTimeout: cfg.Tasks.DefaultTimeout,
+		MaxTimeout:     cfg.Tasks.MaxTimeout,
+		HistoryLimit:   cfg.Tasks.HistoryLimit,
+	})
+	tc := cfg.Throughput
+	tester, err := throughput.New(throughput.Options{
+		Port:            tc.Port,
+		AddressLabel:    tc.AddressLabel,
+		DefaultDuration: tc.DefaultDuration,
+		MaxDuration:     tc.MaxDuration,
+		MaxBytes:        tc.MaxBytes,
+		DefaultRateBps:  int64(tc.DefaultRateMbps * 1e6),
+		MaxRateBps:      int64(tc.MaxRateMbps * 1e6),
+		Path:            tc.Path,
+		HistoryLimit:    tc.HistoryLimit,
+	}, taskMgr)
+	if err != nil {
+		log.Fatalf("Failed to init throughput tester: %v", err)
+	}
+	tester.SetNodeMeta(nodeMeta.Get)
+
+	// 删除与合并按顺序作用于存储及各个保存了节点数据的组件
+	lifecycles.Register("storage", persister)
+	lifecycles.Register("latest", latest)
+	lifecycles.Register("node_state", nodeStates)
+	lifecycles.Register("node_meta", nodeMeta)
+	lifecycles.Register("alerting", alerts)
+	lifecycles.Register("anomaly", detector)
+	lifecycles.Register("billing", meter)
+	if meshes != nil {
+		lifecycles.Register("mesh", meshes)
 	}
+	lifecycles.Register("throughput", tester)
 
 	// 2. 实例化 API 服务供大屏调用
 	httpApi := api.NewHttpServer(cfg.Http.Port, persister)
+	httpApi.SetAPIToken(cfg.Http.APIToken)
+	httpApi.EnableMetricsExport(latest)
+	httpApi.EnableBackups(cfg.Storage.Sqlite.Backup.Dir, cfg.Storage.Sqlite.Backup.Keep)
+	httpApi.EnableAlerts(alerts)
+	httpApi.EnableNotify(notifier)
+	httpApi.EnableSilences(silences)
+	httpApi.EnableNodeState(nodeStates)
+	httpApi.EnableNodeMeta(nodeMeta)
+	httpApi.EnableLifecycle(lifecycles)
+	httpApi.EnableSLA(reporter)
+	httpApi.EnableBilling(meter)
+	httpApi.EnableAnomalies(detector)
+	if meshes != nil {
+		httpApi.EnableMesh(meshes)
+	}
+	httpApi.EnableTasks(taskMgr)
+	httpApi.EnableThroughput(tester)
 	go httpApi.Start()
 
 	// 3. 实例化 gRPC 接收端
 	grpcServer := grpc.NewServer()
-	// 旧版的 NewGrpcServer 目前只接受单一的 persister 或者 (db, memCache)
-	// 我们已经抽象化了存储接口，因此同级重构 `server.NewGrpcServer`
-	probeServer := server.NewGrpcServer(nil, persister)
+	// 主存储负责 API 读取，其余出口只写
+	probeServer := server.NewGrpcServer(persister, sinks...)
+	probeServer.Observe(nodeStates)
+	if meshes != nil {
+		probeServer.Observe(meshes)
+		probeServer.AssignTargets(meshes)
+	} else {
+		probeServer.AssignTargets(nodeMeta)
+	}
+	probeServer.Observe(tester)
+	probeServer.HandleTasks(taskMgr)
 
 	// 注册服务
 	pb.RegisterProbeServiceServer(grpcServer, probeServer)
@@ -75,5 +369,56 @@ func main() {
 	<-sigChan
 
 	log.Println("Shutting down GeeGee Controller...")
-	grpcServer.GracefulStop()
+	// 先停状态跟踪，主控自身退出导致的断流不记为节点掉线
+	if err := nodeStates.Close(); err != nil {
+		log.Printf("Node state close error: %v", err)
+	}
+	stopGrpc(grpcServer, 5*time.Second)
+	lifecycles.Close()
+	if meshes != nil {
+		meshes.Close()
+	}
+	tester.Close()
+	if remoteWriter != nil {
+		remoteWriter.Close()
+	}
+	if err := alerts.Close(); err != nil {
+		log.Printf("Alerting close error: %v", err)
+	}
+	notifier.Close()
+	if err := detector.Close(); err != nil {
+		log.Printf("Anomaly close error: %v", err)
+	}
+	if err := meter.Close(); err != nil {
+		log.Printf("Billing close error: %v", err)
+	}
+	// 上报流已全部结束，把各后端缓冲中剩余的数据落盘或发出
+	if err := persister.Close(); err != nil {
+		log.Printf("Storage close error: %v", err)
+	}
+}
+
+// stopGrpc 节点的上报流是长连接，不会自行结束，GracefulStop 会一直等下去；
+// 超时后强制断开，确保后面各组件的落盘得以执行
+func stopGrpc(s *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("gRPC streams still open after %v, closing them", timeout)
+		s.Stop()
+		<-done
+	}
+}
+
+// loadLocation 解析配置中的 IANA 时区名，留空为主控本地时区
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/geelinx-ltd/geegee/controller/config"
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+	"github.com/geelinx-ltd/geegee/controller/internal/notify"
+	"github.com/geelinx-ltd/geegee/controller/internal/silence"
+)
+
+// newDispatcher 把 notify 配置段映射为分发器参数，并由静默存储决定哪些告警不推送
+func newDispatcher(cfg *config.Config, silences *silence.Store) (*notify.Dispatcher, error) {
+	nc := cfg.Notify
+	channels := make([]notify.ChannelConfig, 0, len(nc.Channels))
+	for _, c := range nc.Channels {
+		channels = append(channels, notify.ChannelConfig{
+			Name:         c.Name,
+			Type:         c.Type,
+			MinSeverity:  c.MinSeverity,
+			SendResolved: c.SendResolved,
+			Title:        c.Title,
+			Message:      c.Message,
+			Timeout:      c.Timeout,
+			URL:          c.Url,
+			Secret:       c.Secret,
+			Headers:      c.Headers,
+			SMTPHost:     c.Smtp.Host,
+			SMTPPort:     c.Smtp.Port,
+			Username:     c.Smtp.Username,
+			Password:     c.Smtp.Password,
+			From:         c.Smtp.From,
+			To:           c.Smtp.To,
+			StartTLS:     c.Smtp.StartTLS,
+			TLS:          c.Smtp.TLS,
+			BotToken:     c.Telegram.BotToken,
+			ChatID:       c.Telegram.ChatID,
+			APIURL:       c.Telegram.ApiUrl,
+		})
+	}
+	return notify.NewDispatcher(notify.Options{
+		Channels:       channels,
+		GroupBy:        nc.GroupBy,
+		GroupWait:      nc.GroupWait,
+		GroupInterval:  nc.GroupInterval,
+		RepeatInterval: nc.RepeatInterval,
+		MaxRetries:     nc.MaxRetries,
+		MinBackoff:     nc.MinBackoff,
+		MaxBackoff:     nc.MaxBackoff,
+		Timeout:        nc.Timeout,
+		Muted: func(a alerting.Alert) bool {
+			return len(silences.Muted(a)) > 0
+		},
+	})
+}
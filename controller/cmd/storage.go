@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/config"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// newPersister 按 storage.backends 组装扇出存储；未配置时按旧的 storage.type 推导
+func newPersister(cfg *config.Config) (*storage.FanoutPersister, error) {
+	backends := cfg.Storage.Backends
+	if len(backends) == 0 {
+		switch cfg.Storage.Type {
+		case "sqlite":
+			backends = []config.Backend{{Type: "sqlite"}}
+		case "tsdb":
+			backends = []config.Backend{{Type: "tsdb"}}
+		case "victoria":
+			// 为了给 Victoria 用户同样的前端体验，保留内存环路
+			backends = []config.Backend{{Type: "memory"}, {Type: "victoria"}}
+		default:
+			log.Println("Unknown storage type, fallback to Memory-Only.")
+			backends = []config.Backend{{Type: "memory"}}
+		}
+	}
+
+	list := make([]storage.FanoutBackend, 0, len(backends))
+	for _, b := range backends {
+		name := b.Name
+		if name == "" {
+			name = b.Type
+		}
+		fb := storage.FanoutBackend{Name: name}
+
+		switch b.Type {
+		case "memory":
+			log.Printf("Using Memory Storage Engine [%s]...", name)
+			mem := storage.NewMemoryCache(300)
+			fb.Writer, fb.Reader = mem, mem
+
+		case "sqlite":
+			log.Printf("Using SQLite Storage Engine [%s]...", name)
+			// 未单独配置原始点保留期时沿用全局 retention_days
+			rc, wc := cfg.Storage.Sqlite.Retention, cfg.Storage.Sqlite.Writer
+			retention := storage.SqliteRetention{
+				RawDays:    rc.RawDays,
+				MinuteDays: rc.MinuteDays,
+				HourDays:   rc.HourDays,
+			}
+			if retention.RawDays == 0 {
+				retention.RawDays = cfg.Storage.RetentionDays
+			}
+			db, err := storage.NewSqliteStore(cfg.Storage.Sqlite.Dsn, storage.SqliteOptions{
+				Retention: retention,
+				HotWindow: cfg.Storage.Sqlite.HotWindow,
+				Writer: storage.SqliteWriterOptions{
+					QueueSize:      wc.QueueSize,
+					BatchSize:      wc.BatchSize,
+					FlushInterval:  wc.FlushInterval,
+					EnqueueTimeout: wc.EnqueueTimeout,
+				},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("open sqlite: %w", err)
+			}
+			fb.Writer, fb.Reader = db, db
+
+		case "tsdb":
+			tc := cfg.Storage.Tsdb
+			log.Printf("Using embedded TSDB Storage Engine [%s] at %s...", name, tc.Path)
+			days := tc.RetentionDays
+			if days == 0 {
+				days = cfg.Storage.RetentionDays
+			}
+			ts, err := storage.NewTsdbStore(storage.TsdbOptions{
+				Path:            tc.Path,
+				Retention:       time.Duration(days) * 24 * time.Hour,
+				BlockDuration:   tc.BlockDuration,
+				CompactDuration: tc.CompactDuration,
+			})
+			if err != nil {
+				return nil, err
+			}
+			fb.Writer, fb.Reader = ts, ts
+
+		case "victoria":
+			vc := cfg.Storage.Victoria
+			log.Printf("Using VictoriaMetrics Storage Engine [%s] -> %s (write-only)", name, vc.Url)
+			vw, err := storage.NewVictoriaWriter(storage.VictoriaOptions{
+				URL:           vc.Url,
+				QueueSize:     vc.QueueSize,
+				BatchSize:     vc.BatchSize,
+				BatchInterval: vc.BatchInterval,
+				Timeout:       vc.Timeout,
+				MaxRetries:    vc.MaxRetries,
+				MinBackoff:    vc.MinBackoff,
+				MaxBackoff:    vc.MaxBackoff,
+			})
+			if err != nil {
+				return nil, err
+			}
+			fb.Writer = vw
+
+		default:
+			return nil, fmt.Errorf("unknown storage backend type %q", b.Type)
+		}
+		list = append(list, fb)
+	}
+
+	p := cfg.Storage.Primary
+	return storage.NewFanoutPersister(list, storage.FanoutPrimary{
+		Nodes:   p.Nodes,
+		History: p.History,
+		Ping:    p.Ping,
+	})
+}
@@ -2,24 +2,238 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+// Backend 扇出存储中的一个成员，各类型的参数仍取自 storage 下对应的配置段
+type Backend struct {
+	Name string `mapstructure:"name"` // 省略时等于 type
+	Type string `mapstructure:"type"` // memory / sqlite / tsdb / victoria
+}
+
 type Config struct {
 	Storage struct {
 		Type          string `mapstructure:"type"`
 		RetentionDays int    `mapstructure:"retention_days"`
-		Sqlite        struct {
+		// Backends 同时写入的多个后端；为空时按 type 推导
+		Backends []Backend `mapstructure:"backends"`
+		// Primary 各类查询的读取来源 (后端 name)，留空取第一个可读后端
+		Primary struct {
+			Nodes   string `mapstructure:"nodes"`
+			History string `mapstructure:"history"`
+			Ping    string `mapstructure:"ping"`
+		} `mapstructure:"primary"`
+		Sqlite struct {
 			Dsn string `mapstructure:"dsn"`
+			// HotWindow 内存热层每个节点保留的最近点数，大屏近期读取不走数据库
+			HotWindow int `mapstructure:"hot_window"`
+			// 分层保留：原始点、1 分钟汇总、1 小时汇总各自的保留天数
+			Retention struct {
+				RawDays    int `mapstructure:"raw_days"`
+				MinuteDays int `mapstructure:"minute_days"`
+				HourDays   int `mapstructure:"hour_days"`
+			} `mapstructure:"retention"`
+			// Writer 批量写入队列：攒够 batch_size 或等满 flush_interval 提交一次事务
+			Writer struct {
+				QueueSize      int           `mapstructure:"queue_size"`
+				BatchSize      int           `mapstructure:"batch_size"`
+				FlushInterval  time.Duration `mapstructure:"flush_interval"`
+				EnqueueTimeout time.Duration `mapstructure:"enqueue_timeout"`
+			} `mapstructure:"writer"`
+			// Backup POST /api/storage/backups 生成的快照存放位置，只保留最新 keep 份
+			Backup struct {
+				Dir  string `mapstructure:"dir"`
+				Keep int    `mapstructure:"keep"`
+			} `mapstructure:"backup"`
 		} `mapstructure:"sqlite"`
+		// Tsdb 内置压缩时序引擎 (type: tsdb)
+		Tsdb struct {
+			Path            string        `mapstructure:"path"`
+			RetentionDays   int           `mapstructure:"retention_days"` // 0 时沿用全局 retention_days
+			BlockDuration   time.Duration `mapstructure:"block_duration"`
+			CompactDuration time.Duration `mapstructure:"compact_duration"`
+		} `mapstructure:"tsdb"`
 		Victoria struct {
-			Url string `mapstructure:"url"`
+			Url           string        `mapstructure:"url"`
+			QueueSize     int           `mapstructure:"queue_size"`
+			BatchSize     int           `mapstructure:"batch_size"`
+			BatchInterval time.Duration `mapstructure:"batch_interval"`
+			Timeout       time.Duration `mapstructure:"timeout"`
+			MaxRetries    int           `mapstructure:"max_retries"`
+			MinBackoff    time.Duration `mapstructure:"min_backoff"`
+			MaxBackoff    time.Duration `mapstructure:"max_backoff"`
 		} `mapstructure:"victoria"`
+		// RemoteWrite 额外推送到任意 Prometheus remote_write 接收端，留空 url 即关闭
+		RemoteWrite struct {
+			Url            string            `mapstructure:"url"`
+			ExternalLabels map[string]string `mapstructure:"external_labels"`
+			Shards         int               `mapstructure:"shards"`
+			QueueSize      int               `mapstructure:"queue_size"`
+			BatchSize      int               `mapstructure:"batch_size"`
+			BatchInterval  time.Duration     `mapstructure:"batch_interval"`
+			Timeout        time.Duration     `mapstructure:"timeout"`
+			MaxRetries     int               `mapstructure:"max_retries"`
+			MinBackoff     time.Duration     `mapstructure:"min_backoff"`
+			MaxBackoff     time.Duration     `mapstructure:"max_backoff"`
+		} `mapstructure:"remote_write"`
 	} `mapstructure:"storage"`
 	Http struct {
 		Port string `mapstructure:"port"`
+		// APIToken 破坏性接口 (删除 / 合并节点、备份、诊断任务、吞吐测试) 的 Bearer 令牌，为空时只接受本机请求
+		APIToken string `mapstructure:"api_token"`
 	} `mapstructure:"http"`
+	// NodeState 节点连接状态机：超过 stale_after 无上报为 stale，超过 offline_after 或上报流断开为 offline
+	NodeState struct {
+		StaleAfter       time.Duration `mapstructure:"stale_after"`
+		OfflineAfter     time.Duration `mapstructure:"offline_after"`
+		StatePath        string        `mapstructure:"state_path"`
+		HistoryLimit     int           `mapstructure:"history_limit"`
+		HistoryRetention time.Duration `mapstructure:"history_retention"`
+	} `mapstructure:"node_state"`
+	// NodeMeta 节点显示名、描述、标签与分组的持久化 (节点自报与 API 设置两层)
+	NodeMeta struct {
+		Path string `mapstructure:"path"`
+	} `mapstructure:"node_meta"`
+	// Lifecycle 节点退役、删除与合并的状态与审计日志；archive_after 大于 0 时自动归档超过该时长未上报的节点
+	Lifecycle struct {
+		Path         string        `mapstructure:"path"`
+		ArchiveAfter time.Duration `mapstructure:"archive_after"`
+		EventLimit   int           `mapstructure:"event_limit"`
+	} `mapstructure:"lifecycle"`
+	// ProbeTargets 按节点 ID、标签与分组分配探测目标，随上报响应下发；为空或未命中时节点沿用本地列表
+	ProbeTargets []ProbeTargetRule `mapstructure:"probe_targets"`
+	// Mesh 全网格探测：参与的节点互相探测对方的公网地址 (address_label 标签或上报流来源 IP) 的 port 端口，
+	// 窗口内平均丢包达到 degraded_loss，或延迟达到基线的 degraded_ratio 倍且高出 degraded_delta_ms 即判为劣化
+	Mesh struct {
+		Enabled          bool              `mapstructure:"enabled"`
+		Labels           map[string]string `mapstructure:"labels"`
+		Groups           []string          `mapstructure:"groups"`
+		Port             int               `mapstructure:"port"`
+		Type             string            `mapstructure:"type"`
+		AddressLabel     string            `mapstructure:"address_label"`
+		Window           time.Duration     `mapstructure:"window"`
+		BaselineHalfLife time.Duration     `mapstructure:"baseline_half_life"`
+		MinBaseline      time.Duration     `mapstructure:"min_baseline"`
+		DegradedLoss     float64           `mapstructure:"degraded_loss"`
+		DegradedRatio    float64           `mapstructure:"degraded_ratio"`
+		DegradedDeltaMs  float64           `mapstructure:"degraded_delta_ms"`
+		StatePath        string            `mapstructure:"state_path"`
+	} `mapstructure:"mesh"`
+	// Tasks 按需诊断任务：每个节点同时执行 per_node 个，另可排队 queue_per_node 个；
+	// 结果只保存在内存中，保留最近 history_limit 个已结束的任务
+	Tasks struct {
+		PerNode        int           `mapstructure:"per_node"`
+		QueuePerNode   int           `mapstructure:"queue_per_node"`
+		DefaultTimeout time.Duration `mapstructure:"default_timeout"`
+		MaxTimeout     time.Duration `mapstructure:"max_timeout"`
+		HistoryLimit   int           `mapstructure:"history_limit"`
+	} `mapstructure:"tasks"`
+	// Throughput 节点间吞吐测试：服务端节点在 port 上监听 (TCP 与 UDP)，客户端连接其 address_label 标签或上报流来源地址；
+	// 单次测试不超过 max_duration 与 max_bytes，udp 速率不超过 max_rate_mbps
+	Throughput struct {
+		Port            int           `mapstructure:"port"`
+		AddressLabel    string        `mapstructure:"address_label"`
+		DefaultDuration time.Duration `mapstructure:"default_duration"`
+		MaxDuration     time.Duration `mapstructure:"max_duration"`
+		MaxBytes        int64         `mapstructure:"max_bytes"`
+		DefaultRateMbps float64       `mapstructure:"default_rate_mbps"`
+		MaxRateMbps     float64       `mapstructure:"max_rate_mbps"`
+		Path            string        `mapstructure:"path"`
+		HistoryLimit    int           `mapstructure:"history_limit"`
+	} `mapstructure:"throughput"`
+	// SLA 可用性报表：target 为默认目标百分比，日 / 月按 timezone 划分 (留空为主控本地时区)
+	SLA struct {
+		Target      float64 `mapstructure:"target"`
+		Timezone    string  `mapstructure:"timezone"`
+		StaleAsDown bool    `mapstructure:"stale_as_down"`
+	} `mapstructure:"sla"`
+	// Billing 95 计费：5 分钟样本写入 path，保留 retention；计费周期每月 cycle_day 日零点 (按 timezone) 起算
+	Billing struct {
+		Path      string        `mapstructure:"path"`
+		Retention time.Duration `mapstructure:"retention"`
+		CycleDay  int           `mapstructure:"cycle_day"`
+		Timezone  string        `mapstructure:"timezone"`
+		// ExcludeInterfaces 不计费的网卡名通配符，节点标记为虚拟的网卡总是排除
+		ExcludeInterfaces []string `mapstructure:"exclude_interfaces"`
+	} `mapstructure:"billing"`
+	// Anomaly 延迟 / 丢包自适应基线：分数达到 threshold 判为异常，新序列学习 warmup 后才打分
+	Anomaly struct {
+		StatePath      string        `mapstructure:"state_path"`
+		Threshold      float64       `mapstructure:"threshold"`
+		LossThreshold  float64       `mapstructure:"loss_threshold"`
+		MinRTTStd      float64       `mapstructure:"min_rtt_std_ms"`
+		RecentHalfLife time.Duration `mapstructure:"recent_half_life"`
+		WeeklyHalfLife time.Duration `mapstructure:"weekly_half_life"`
+		Warmup         time.Duration `mapstructure:"warmup"`
+		EventRetention time.Duration `mapstructure:"event_retention"`
+		Timezone       string        `mapstructure:"timezone"`
+	} `mapstructure:"anomaly"`
+	// Alerting 告警规则引擎，规则文件不存在时不评估任何规则
+	Alerting struct {
+		RulesFile      string        `mapstructure:"rules_file"`
+		StatePath      string        `mapstructure:"state_path"`
+		EvalInterval   time.Duration `mapstructure:"eval_interval"`
+		ResolveTimeout time.Duration `mapstructure:"resolve_timeout"`
+		HistoryLimit   int           `mapstructure:"history_limit"`
+	} `mapstructure:"alerting"`
+	// Notify 告警通知：按 group_by 合并后推送到 channels，未配置渠道时不发送
+	Notify struct {
+		GroupBy        []string        `mapstructure:"group_by"`
+		GroupWait      time.Duration   `mapstructure:"group_wait"`
+		GroupInterval  time.Duration   `mapstructure:"group_interval"`
+		RepeatInterval time.Duration   `mapstructure:"repeat_interval"`
+		MaxRetries     int             `mapstructure:"max_retries"`
+		MinBackoff     time.Duration   `mapstructure:"min_backoff"`
+		MaxBackoff     time.Duration   `mapstructure:"max_backoff"`
+		Timeout        time.Duration   `mapstructure:"timeout"`
+		Channels       []NotifyChannel `mapstructure:"channels"`
+	} `mapstructure:"notify"`
+	// Silences 静默与维护窗口的持久化，过期静默保留 retention 后清理
+	Silences struct {
+		Path      string        `mapstructure:"path"`
+		Retention time.Duration `mapstructure:"retention"`
+	} `mapstructure:"silences"`
+}
+
+// ProbeTargetRule 一组探测目标：nodes 为 node_id 通配，labels / groups 为节点元数据通配，同时满足才分配
+type ProbeTargetRule struct {
+	Name    string            `mapstructure:"name"`
+	Nodes   []string          `mapstructure:"nodes"`
+	Labels  map[string]string `mapstructure:"labels"`
+	Groups  []string          `mapstructure:"groups"`
+	Targets []string          `mapstructure:"targets"` // ip:port
+	Type    string            `mapstructure:"type"`    // tcpping (默认) 或 udp
+}
+
+// NotifyChannel 一个通知渠道，type 为 webhook / email / dingtalk / feishu / wecom / telegram
+type NotifyChannel struct {
+	Name         string            `mapstructure:"name"`
+	Type         string            `mapstructure:"type"`
+	MinSeverity  string            `mapstructure:"min_severity"`
+	SendResolved bool              `mapstructure:"send_resolved"`
+	Title        string            `mapstructure:"title"`   // Go text/template，留空用默认
+	Message      string            `mapstructure:"message"` // 同上
+	Timeout      time.Duration     `mapstructure:"timeout"`
+	Url          string            `mapstructure:"url"`
+	Secret       string            `mapstructure:"secret"`
+	Headers      map[string]string `mapstructure:"headers"`
+	Smtp         struct {
+		Host     string   `mapstructure:"host"`
+		Port     int      `mapstructure:"port"`
+		Username string   `mapstructure:"username"`
+		Password string   `mapstructure:"password"`
+		From     string   `mapstructure:"from"`
+		To       []string `mapstructure:"to"`
+		StartTLS bool     `mapstructure:"starttls"`
+		TLS      bool     `mapstructure:"tls"`
+	} `mapstructure:"smtp"`
+	Telegram struct {
+		BotToken string `mapstructure:"bot_token"`
+		ChatID   string `mapstructure:"chat_id"`
+		ApiUrl   string `mapstructure:"api_url"`
+	} `mapstructure:"telegram"`
 }
 
 var Cfg *Config
@@ -32,7 +246,105 @@ func LoadConfig(path string) {
 	viper.SetDefault("storage.type", "sqlite")
 	viper.SetDefault("storage.retention_days", 30)
 	viper.SetDefault("http.port", ":8080")
+	viper.SetDefault("http.api_token", "")
 	viper.SetDefault("storage.sqlite.dsn", "./geegee.db")
+	viper.SetDefault("storage.sqlite.retention.minute_days", 30)
+	viper.SetDefault("storage.sqlite.retention.hour_days", 730)
+	viper.SetDefault("storage.sqlite.hot_window", 720)
+	viper.SetDefault("storage.sqlite.writer.queue_size", 10000)
+	viper.SetDefault("storage.sqlite.writer.batch_size", 500)
+	viper.SetDefault("storage.sqlite.writer.flush_interval", "1s")
+	viper.SetDefault("storage.sqlite.writer.enqueue_timeout", "5s")
+	viper.SetDefault("storage.sqlite.backup.dir", "./backups")
+	viper.SetDefault("storage.sqlite.backup.keep", 7)
+	viper.SetDefault("storage.tsdb.path", "./data/tsdb")
+	viper.SetDefault("storage.tsdb.block_duration", "2h")
+	viper.SetDefault("storage.tsdb.compact_duration", "24h")
+	viper.SetDefault("storage.victoria.url", "http://localhost:8428/api/v1/import/prometheus")
+	viper.SetDefault("storage.victoria.queue_size", 50000)
+	viper.SetDefault("storage.victoria.batch_size", 5000)
+	viper.SetDefault("storage.victoria.batch_interval", "5s")
+	viper.SetDefault("storage.victoria.timeout", "30s")
+	viper.SetDefault("storage.victoria.max_retries", 5)
+	viper.SetDefault("storage.victoria.min_backoff", "100ms")
+	viper.SetDefault("storage.victoria.max_backoff", "10s")
+	viper.SetDefault("storage.remote_write.shards", 4)
+	viper.SetDefault("storage.remote_write.queue_size", 10000)
+	viper.SetDefault("storage.remote_write.batch_size", 500)
+	viper.SetDefault("storage.remote_write.batch_interval", "5s")
+	viper.SetDefault("storage.remote_write.timeout", "30s")
+	viper.SetDefault("storage.remote_write.max_retries", 5)
+	viper.SetDefault("storage.remote_write.min_backoff", "100ms")
+	viper.SetDefault("storage.remote_write.max_backoff", "10s")
+	viper.SetDefault("node_state.stale_after", "15s")
+	viper.SetDefault("node_state.offline_after", "60s")
+	viper.SetDefault("node_state.state_path", "./data/node_state.json")
+	viper.SetDefault("node_state.history_limit", 5000)
+	viper.SetDefault("node_state.history_retention", "2160h")
+	viper.SetDefault("node_meta.path", "./data/node_meta.json")
+	viper.SetDefault("lifecycle.path", "./data/lifecycle.json")
+	viper.SetDefault("lifecycle.archive_after", "0s")
+	viper.SetDefault("lifecycle.event_limit", 1000)
+	viper.SetDefault("mesh.enabled", false)
+	viper.SetDefault("mesh.port", 22)
+	viper.SetDefault("mesh.type", "tcpping")
+	viper.SetDefault("mesh.address_label", "mesh_address")
+	viper.SetDefault("mesh.window", "5m")
+	viper.SetDefault("mesh.baseline_half_life", "24h")
+	viper.SetDefault("mesh.min_baseline", "30m")
+	viper.SetDefault("mesh.degraded_loss", 0.05)
+	viper.SetDefault("mesh.degraded_ratio", 1.5)
+	viper.SetDefault("mesh.degraded_delta_ms", 5)
+	viper.SetDefault("mesh.state_path", "./data/mesh.json")
+	viper.SetDefault("tasks.per_node", 2)
+	viper.SetDefault("tasks.queue_per_node", 10)
+	viper.SetDefault("tasks.default_timeout", "15s")
+	viper.SetDefault("tasks.max_timeout", "2m")
+	viper.SetDefault("tasks.history_limit", 500)
+	viper.SetDefault("throughput.port", 8621)
+	viper.SetDefault("throughput.address_label", "mesh_address")
+	viper.SetDefault("throughput.default_duration", "10s")
+	viper.SetDefault("throughput.max_duration", "1m")
+	viper.SetDefault("throughput.max_bytes", int64(5<<30))
+	viper.SetDefault("throughput.default_rate_mbps", 100)
+	viper.SetDefault("throughput.max_rate_mbps", 1000)
+	viper.SetDefault("throughput.path", "./data/throughput.json")
+	viper.SetDefault("throughput.history_limit", 500)
+	viper.SetDefault("sla.target", 99.9)
+	viper.SetDefault("sla.timezone", "")
+	viper.SetDefault("sla.stale_as_down", false)
+	viper.SetDefault("billing.path", "./data/billing.db")
+	viper.SetDefault("billing.retention", "9600h")
+	viper.SetDefault("billing.cycle_day", 1)
+	viper.SetDefault("billing.timezone", "")
+	viper.SetDefault("billing.exclude_interfaces", []string{
+		"br*", "virbr*", "docker*", "veth*", "tap*", "vnet*", "tun*", "bond*",
+		"cali*", "flannel*", "cni*", "kube-*", "lxc*", "vxlan*",
+	})
+	viper.SetDefault("anomaly.state_path", "./data/anomaly.json")
+	viper.SetDefault("anomaly.threshold", 4)
+	viper.SetDefault("anomaly.loss_threshold", 0.1)
+	viper.SetDefault("anomaly.min_rtt_std_ms", 1)
+	viper.SetDefault("anomaly.recent_half_life", "1h")
+	viper.SetDefault("anomaly.weekly_half_life", "2h")
+	viper.SetDefault("anomaly.warmup", "1h")
+	viper.SetDefault("anomaly.event_retention", "720h")
+	viper.SetDefault("anomaly.timezone", "")
+	viper.SetDefault("alerting.rules_file", "./alerts.yaml")
+	viper.SetDefault("alerting.state_path", "./data/alerts.json")
+	viper.SetDefault("alerting.eval_interval", "15s")
+	viper.SetDefault("alerting.resolve_timeout", "5m")
+	viper.SetDefault("alerting.history_limit", 200)
+	viper.SetDefault("notify.group_by", []string{"rule"})
+	viper.SetDefault("notify.group_wait", "30s")
+	viper.SetDefault("notify.group_interval", "5m")
+	viper.SetDefault("notify.repeat_interval", "4h")
+	viper.SetDefault("notify.max_retries", 3)
+	viper.SetDefault("notify.min_backoff", "1s")
+	viper.SetDefault("notify.max_backoff", "1m")
+	viper.SetDefault("notify.timeout", "10s")
+	viper.SetDefault("silences.path", "./data/silences.json")
+	viper.SetDefault("silences.retention", "168h")
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Printf("Config file not found or error parsing (%s), using defaults. Err: %v\n", path, err)
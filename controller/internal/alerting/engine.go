@@ -0,0 +1,643 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	"github.com/geelinx-ltd/geegee/controller/internal/nodemeta"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// State 告警状态
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Alert 一条规则在某个节点 (及探测目标) 上的告警实例
+type Alert struct {
+	ID          string            `json:"id"` // 规则 + 节点 + 目标的指纹，同一序列重复触发时不变
+	Rule        string            `json:"rule"`
+	Severity    string            `json:"severity"`
+	NodeID      string            `json:"node_id"`
+	Target      string            `json:"target,omitempty"`
+	Metric      string            `json:"metric"`
+	Op          string            `json:"op"`
+	Threshold   float64           `json:"threshold"`
+	Value       float64           `json:"value"`
+	State       State             `json:"state"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	ActiveAt    int64             `json:"active_at"` // 条件首次满足 (Unix 毫秒)
+	FiredAt     int64             `json:"fired_at,omitempty"`
+	ResolvedAt  int64             `json:"resolved_at,omitempty"`
+	LastEvalAt  int64             `json:"last_eval_at"`
+	Reason      string            `json:"reason,omitempty"` // 恢复原因：条件不再满足 / 无数据 / 规则删除
+}
+
+// Options 告警引擎参数
+type Options struct {
+	RulesFile      string
+	StatePath      string        // 告警状态持久化文件，重启后恢复 pending/firing 计时
+	EvalInterval   time.Duration // 窗口类规则的评估周期
+	ResolveTimeout time.Duration // 序列超过该时长没有新数据即自动恢复
+	HistoryLimit   int           // 保留的已恢复告警条数
+}
+
+func (o *Options) applyDefaults() {
+	if o.EvalInterval <= 0 {
+		o.EvalInterval = 15 * time.Second
+	}
+	if o.ResolveTimeout <= 0 {
+		o.ResolveTimeout = 5 * time.Minute
+	}
+	if o.HistoryLimit <= 0 {
+		o.HistoryLimit = 200
+	}
+}
+
+// nodeSeen 引擎见过的节点及其探测目标，窗口类规则据此枚举序列
+type nodeSeen struct {
+	lastSeen int64
+	targets  map[string]int64
+}
+
+// Listener 告警转为 firing 或恢复时回调。在引擎锁内调用，实现方只能做入队之类的轻量操作
+type Listener func(a Alert)
+
+// Engine 告警引擎。挂在 gRPC 服务的出口列表上，逐帧评估即时规则；窗口类规则由后台协程按周期查询存储评估
+type Engine struct {
+	opts  Options
+	store storage.Persister
+
+	mu     sync.Mutex
+	rules  []*Rule
+	active map[string]*Alert // pending / firing
+	recent []Alert           // 已恢复，最新在前
+	nodes  map[string]*nodeSeen
+	dirty  bool
+	// nodeUp 各节点最新的 node_up 取值，由 SetNodeUp 推送
+	nodeUp map[string]float64
+	// meta 节点元数据查询，供规则的 node_labels / groups 条件使用
+	meta func(nodeID string) nodemeta.Meta
+
+	listeners []Listener
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+type persistedState struct {
+	Active []*Alert `json:"active"`
+	Recent []Alert  `json:"recent"`
+}
+
+// NewEngine 加载规则与上次保存的告警状态并启动后台评估
+func NewEngine(opts Options, store storage.Persister) (*Engine, error) {
+	opts.applyDefaults()
+	rules, err := LoadRulesIfExists(opts.RulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("load alert rules: %w", err)
+	}
+
+	e := &Engine{
+		opts:   opts,
+		store:  store,
+		rules:  rules,
+		active: make(map[string]*Alert),
+		nodes:  make(map[string]*nodeSeen),
+		nodeUp: make(map[string]float64),
+		stop:   make(chan struct{}),
+	}
+	if err := e.loadState(); err != nil {
+		return nil, fmt.Errorf("load alert state: %w", err)
+	}
+	e.dropOrphans(time.Now().UnixMilli())
+	log.Printf("[Alerting] %d rules loaded from %s, %d active alerts restored", len(rules), opts.RulesFile, len(e.active))
+
+	e.wg.Add(1)
+	go e.loop()
+	return e, nil
+}
+
+func (e *Engine) loadState() error {
+	if e.opts.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(e.opts.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st persistedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	for _, a := range st.Active {
+		e.active[a.ID] = a
+	}
+	e.recent = st.Recent
+	return nil
+}
+
+func (e *Engine) saveState() error {
+	if e.opts.StatePath == "" {
+		return nil
+	}
+	e.mu.Lock()
+	if !e.dirty {
+		e.mu.Unlock()
+		return nil
+	}
+	st := persistedState{Recent: e.recent}
+	for _, a := range e.active {
+		st.Active = append(st.Active, a)
+	}
+	data, err := json.Marshal(st)
+	e.dirty = false
+	e.mu.Unlock()
+	if err == nil {
+		err = writeState(e.opts.StatePath, data)
+	}
+	if err != nil {
+		// 下个周期重试
+		e.mu.Lock()
+		e.dirty = true
+		e.mu.Unlock()
+	}
+	return err
+}
+
+func writeState(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// dropOrphans 规则已从文件中删除的告警直接恢复
+func (e *Engine) dropOrphans(now int64) {
+	names := make(map[string]bool, len(e.rules))
+	for _, r := range e.rules {
+		names[r.Name] = true
+	}
+	for _, a := range e.active {
+		if !names[a.Rule] {
+			e.resolve(a, now, "rule removed")
+		}
+	}
+}
+
+func fingerprint(rule, nodeID, target string) string {
+	h := fnv.New64a()
+	h.Write([]byte(rule + "\x1f" + nodeID + "\x1f" + target))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Ingest 实现 storage.Sink：记录节点与目标，并用这一帧评估所有即时规则
+func (e *Engine) Ingest(req *pb.ReportRequest) error {
+	now := time.Now().UnixMilli()
+	snap := storage.NewMetricSnapshot(req)
+	pings := storage.NewPingSnapshots(req)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seen, ok := e.nodes[req.NodeId]
+	if !ok {
+		seen = &nodeSeen{targets: make(map[string]int64)}
+		e.nodes[req.NodeId] = seen
+	}
+	seen.lastSeen = now
+	for _, p := range pings {
+		seen.targets[p.Target] = now
+	}
+
+	for _, r := range e.rules {
+		if r.Window > 0 || r.isNodeState() || r.isAnomaly() || !r.matchNode(req.NodeId, e.meta) {
+			continue
+		}
+		if !r.isPing() {
+			v, _ := snap.Field(r.Metric)
+			e.evaluate(r, req.NodeId, "", v, now)
+			continue
+		}
+		for _, p := range pings {
+			if r.matchTarget(p.Target) {
+				e.evaluate(r, req.NodeId, p.Target, pingValue(r.Metric, p), now)
+			}
+		}
+	}
+	return nil
+}
+
+// evaluate 推进单条序列的状态机，调用方持有 e.mu
+func (e *Engine) evaluate(r *Rule, nodeID, target string, v float64, now int64) {
+	id := fingerprint(r.Name, nodeID, target)
+	a := e.active[id]
+
+	if math.IsNaN(v) || !r.breached(v) {
+		if a != nil {
+			// NaN 无法编码为 JSON，保留最后一次的有效值
+			if !math.IsNaN(v) {
+				a.Value = v
+			}
+			e.resolve(a, now, "condition cleared")
+		}
+		return
+	}
+
+	if a == nil {
+		a = &Alert{
+			ID:        id,
+			Rule:      r.Name,
+			Severity:  r.Severity,
+			NodeID:    nodeID,
+			Target:    target,
+			Metric:    r.Metric,
+			Op:        r.Op,
+			Threshold: r.Threshold,
+			Labels:    r.Labels,
+			State:     StatePending,
+			ActiveAt:  now,
+		}
+		e.active[id] = a
+		e.dirty = true
+	}
+	a.Value = v
+	a.LastEvalAt = now
+
+	if a.State == StatePending && now-a.ActiveAt >= r.For.Milliseconds() {
+		a.State = StateFiring
+		a.FiredAt = now
+		a.Annotations = r.render(a)
+		e.dirty = true
+		log.Printf("[Alerting] FIRING %s on node [%s]%s: %s=%.4g %s %.4g",
+			r.Name, nodeID, targetSuffix(target), r.Metric, v, r.Op, r.Threshold)
+		e.publish(*a)
+	}
+}
+
+// resolve 结束一条告警。pending 未到 for 时长即消失的不计入历史
+func (e *Engine) resolve(a *Alert, now int64, reason string) {
+	delete(e.active, a.ID)
+	e.dirty = true
+	if a.State != StateFiring {
+		return
+	}
+	a.State = StateResolved
+	a.ResolvedAt = now
+	a.Reason = reason
+	log.Printf("[Alerting] RESOLVED %s on node [%s]%s (%s)", a.Rule, a.NodeID, targetSuffix(a.Target), reason)
+
+	e.recent = append([]Alert{*a}, e.recent...)
+	if len(e.recent) > e.opts.HistoryLimit {
+		e.recent = e.recent[:e.opts.HistoryLimit]
+	}
+	e.publish(*a)
+}
+
+// Subscribe 注册状态变化回调，须在上报开始前调用
+func (e *Engine) Subscribe(l Listener) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, l)
+}
+
+func (e *Engine) publish(a Alert) {
+	for _, l := range e.listeners {
+		l(a)
+	}
+}
+
+func targetSuffix(target string) string {
+	if target == "" {
+		return ""
+	}
+	return " -> " + target
+}
+
+func (e *Engine) loop() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.opts.EvalInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.evalNodeUp(time.Now().UnixMilli())
+			e.evalWindowRules()
+			e.expireStale(time.Now().UnixMilli())
+			if err := e.saveState(); err != nil {
+				log.Printf("[Alerting] Save state error: %v", err)
+			}
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// SetNodeMeta 接入节点元数据查询。查询在引擎锁内调用，不能反过来调用引擎
+func (e *Engine) SetNodeMeta(f func(nodeID string) nodemeta.Meta) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.meta = f
+}
+
+// SetNodeUp 接收节点状态变化并立即评估 node_up 规则，供节点状态跟踪器回调
+func (e *Engine) SetNodeUp(nodeID string, up float64) {
+	now := time.Now().UnixMilli()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.nodeUp[nodeID] = up
+	for _, r := range e.rules {
+		if r.isNodeState() && r.matchNode(nodeID, e.meta) {
+			e.evaluate(r, nodeID, "", up, now)
+		}
+	}
+}
+
+// SetAnomaly 接收某个探测目标最新的延迟 / 丢包异常分数并立即评估对应规则，供异常检测器回调。
+// 学习期内以及全部丢包时的延迟分数为 NaN，视为无数据：不触发也不恢复，长时间无分数由 ResolveTimeout 恢复
+func (e *Engine) SetAnomaly(nodeID, target string, rttScore, lossScore float64) {
+	now := time.Now().UnixMilli()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if !r.isAnomaly() || !r.matchNode(nodeID, e.meta) || !r.matchTarget(target) {
+			continue
+		}
+		v := rttScore
+		if r.Metric == MetricLossAnomaly {
+			v = lossScore
+		}
+		if math.IsNaN(v) {
+			continue
+		}
+		e.evaluate(r, nodeID, target, v, now)
+	}
+}
+
+// evalNodeUp 离线节点没有上报帧，按最新状态周期性评估，推动 pending 到 firing
+func (e *Engine) evalNodeUp(now int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if !r.isNodeState() {
+			continue
+		}
+		for nodeID, up := range e.nodeUp {
+			if r.matchNode(nodeID, e.meta) {
+				e.evaluate(r, nodeID, "", up, now)
+			}
+		}
+	}
+}
+
+// windowSeries 一次窗口评估的输入，先在锁外查询存储再回到锁内推进状态
+type windowSeries struct {
+	rule   *Rule
+	nodeID string
+	target string
+}
+
+func (e *Engine) evalWindowRules() {
+	now := time.Now().UnixMilli()
+	fresh := now - e.opts.ResolveTimeout.Milliseconds()
+
+	var series []windowSeries
+	e.mu.Lock()
+	for _, r := range e.rules {
+		if r.Window == 0 {
+			continue
+		}
+		for nodeID, seen := range e.nodes {
+			if seen.lastSeen < fresh || !r.matchNode(nodeID, e.meta) {
+				continue
+			}
+			if !r.isPing() {
+				series = append(series, windowSeries{r, nodeID, ""})
+				continue
+			}
+			for target, ts := range seen.targets {
+				if ts >= fresh && r.matchTarget(target) {
+					series = append(series, windowSeries{r, nodeID, target})
+				}
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	for _, s := range series {
+		v, ok, err := e.windowValue(s, now)
+		if err != nil {
+			log.Printf("[Alerting] Rule %s query for node [%s] failed: %v", s.rule.Name, s.nodeID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		e.mu.Lock()
+		e.evaluate(s.rule, s.nodeID, s.target, v, time.Now().UnixMilli())
+		e.mu.Unlock()
+	}
+}
+
+// windowValue 查询最近 window 的分桶结果并按 reduce 合并为一个值
+func (e *Engine) windowValue(s windowSeries, now int64) (float64, bool, error) {
+	r := s.rule
+	q := storage.RangeQuery{
+		From: now - r.Window.Milliseconds(),
+		To:   now,
+		Step: max(r.Window.Milliseconds()/60, storage.MinStepMs),
+	}
+	if !r.isPing() {
+		q.Fields = []string{r.Metric}
+	}
+	if err := q.Normalize(); err != nil {
+		return 0, false, err
+	}
+
+	var red reducer
+	if r.isPing() {
+		buckets, err := e.store.QueryPingHistory(s.nodeID, s.target, q)
+		if err != nil {
+			return 0, false, err
+		}
+		// 桶内只有各指标自身的统计，reduce 的 min/max 以桶为粒度近似
+		for _, b := range buckets {
+			switch r.Metric {
+			case MetricPingMinRTT:
+				red.add(b.Count, b.MinRTT, b.MinRTT, b.MinRTT)
+			case MetricPingMaxRTT:
+				red.add(b.Count, b.MaxRTT, b.MaxRTT, b.MaxRTT)
+			case MetricPingLoss:
+				red.add(b.Count, b.Loss, b.Loss, b.MaxLoss)
+			default:
+				red.add(b.Count, b.AvgRTT, b.AvgRTT, b.AvgRTT)
+			}
+		}
+	} else {
+		buckets, err := e.store.QueryNodeHistory(s.nodeID, q)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, b := range buckets {
+			agg := b.Values[r.Metric]
+			red.add(b.Count, agg.Min, agg.Avg, agg.Max)
+		}
+	}
+	return red.result(r.Reduce)
+}
+
+// reducer 按点数加权合并多个桶
+type reducer struct {
+	count         int64
+	min, sum, max float64
+}
+
+func (r *reducer) add(count int64, lo, avg, hi float64) {
+	if count == 0 {
+		return
+	}
+	if r.count == 0 {
+		r.min, r.max = lo, hi
+	}
+	r.count += count
+	r.sum += avg * float64(count)
+	r.min = math.Min(r.min, lo)
+	r.max = math.Max(r.max, hi)
+}
+
+func (r *reducer) result(reduce string) (float64, bool, error) {
+	if r.count == 0 {
+		return 0, false, nil
+	}
+	switch reduce {
+	case "min":
+		return r.min, true, nil
+	case "max":
+		return r.max, true, nil
+	}
+	return r.sum / float64(r.count), true, nil
+}
+
+// expireStale 序列长时间没有新数据 (节点掉线、目标被移除) 时自动恢复，避免告警永远挂着
+func (e *Engine) expireStale(now int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	deadline := now - e.opts.ResolveTimeout.Milliseconds()
+	for _, a := range e.active {
+		if a.LastEvalAt < deadline {
+			e.resolve(a, now, "no data")
+		}
+	}
+}
+
+// Alerts 当前 pending/firing 告警 (按级别、触发时间排序) 与最近恢复的告警
+func (e *Engine) Alerts() (active []Alert, recent []Alert) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	active = make([]Alert, 0, len(e.active))
+	for _, a := range e.active {
+		active = append(active, *a)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		if si, sj := SeverityRank(active[i].Severity), SeverityRank(active[j].Severity); si != sj {
+			return si > sj
+		}
+		return active[i].ActiveAt < active[j].ActiveAt
+	})
+	return active, append([]Alert{}, e.recent...)
+}
+
+// SeverityRank 级别的序号，critical 最高；未知级别按 info 处理
+func SeverityRank(s string) int {
+	switch s {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// ForgetNode 节点退役：恢复其全部告警并不再为其评估窗口类与 node_up 规则，直到再次上报
+func (e *Engine) ForgetNode(nodeID, reason string) {
+	now := time.Now().UnixMilli()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.forget(nodeID, reason, now)
+}
+
+// forget 调用方持有 e.mu
+func (e *Engine) forget(nodeID, reason string, now int64) {
+	for _, a := range e.active {
+		if a.NodeID == nodeID {
+			e.resolve(a, now, reason)
+		}
+	}
+	delete(e.nodes, nodeID)
+	delete(e.nodeUp, nodeID)
+}
+
+// DeleteNode 恢复节点的告警并从历史中删除
+func (e *Engine) DeleteNode(nodeID string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.forget(nodeID, "node deleted", time.Now().UnixMilli())
+	e.recent = slices.DeleteFunc(e.recent, func(a Alert) bool { return a.NodeID == nodeID })
+	e.dirty = true
+	return nil
+}
+
+// MergeNode 恢复 from 的告警，历史改记到 into 名下
+func (e *Engine) MergeNode(from, into string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.forget(from, "node merged into "+into, time.Now().UnixMilli())
+	for i := range e.recent {
+		if e.recent[i].NodeID == from {
+			e.recent[i].NodeID = into
+		}
+	}
+	e.dirty = true
+	return nil
+}
+
+// Rules 当前生效的规则
+func (e *Engine) Rules() []*Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Rule{}, e.rules...)
+}
+
+// Close 停止后台评估并保存状态
+func (e *Engine) Close() error {
+	close(e.stop)
+	e.wg.Wait()
+	e.mu.Lock()
+	e.dirty = true
+	e.mu.Unlock()
+	return e.saveState()
+}
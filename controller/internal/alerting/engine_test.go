@@ -0,0 +1,113 @@
+package alerting
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testRules = `
+rules:
+  - name: LatencyAnomaly
+    metric: ping_rtt_anomaly
+    op: ">"
+    threshold: 4
+    annotations:
+      summary: "score {{ .Value }}"
+  - name: LossAnomaly
+    metric: ping_loss_anomaly
+    op: ">"
+    threshold: 4
+  - name: NodeDown
+    metric: node_up
+    op: "=="
+    threshold: 0
+`
+
+func newTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(rules, []byte(testRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEngine(Options{RulesFile: rules, StatePath: filepath.Join(dir, "state.json"), EvalInterval: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func findAlert(as []Alert, rule string) *Alert {
+	for i := range as {
+		if as[i].Rule == rule {
+			return &as[i]
+		}
+	}
+	return nil
+}
+
+// expectEncodable 告警状态、接口响应与通知都要经过 JSON 编码
+func expectEncodable(t *testing.T, e *Engine) {
+	t.Helper()
+	active, recent := e.Alerts()
+	if _, err := json.Marshal(map[string][]Alert{"active": active, "recent": recent}); err != nil {
+		t.Fatalf("alerts not encodable: %v", err)
+	}
+	if err := e.saveState(); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+}
+
+// 全部丢包时检测器给出的延迟分数为 NaN：延迟异常既不恢复也不记录 NaN，丢包异常照常触发
+func TestAnomalyTotalLoss(t *testing.T) {
+	e := newTestEngine(t)
+	e.SetAnomaly("hk-01", "1.1.1.1", 6, 0)
+	active, _ := e.Alerts()
+	if a := findAlert(active, "LatencyAnomaly"); a == nil || a.State != StateFiring {
+		t.Fatalf("latency anomaly not firing: %+v", active)
+	}
+
+	e.SetAnomaly("hk-01", "1.1.1.1", math.NaN(), 9)
+	active, recent := e.Alerts()
+	a := findAlert(active, "LatencyAnomaly")
+	if a == nil || a.State != StateFiring || a.Value != 6 {
+		t.Fatalf("latency anomaly after total loss: %+v, recent %+v", a, recent)
+	}
+	if a := findAlert(active, "LossAnomaly"); a == nil || a.State != StateFiring {
+		t.Fatalf("loss anomaly not firing: %+v", active)
+	}
+	expectEncodable(t, e)
+
+	// 学习期内两个分数都是 NaN，不会新开告警
+	e.SetAnomaly("hk-02", "1.1.1.1", math.NaN(), math.NaN())
+	if active, _ := e.Alerts(); len(active) != 2 {
+		t.Fatalf("%d active alerts, want 2", len(active))
+	}
+
+	e.SetAnomaly("hk-01", "1.1.1.1", 1, 0)
+	active, recent = e.Alerts()
+	if len(active) != 0 || len(recent) != 2 {
+		t.Fatalf("active %d recent %d, want 0 / 2", len(active), len(recent))
+	}
+	if r := findAlert(recent, "LatencyAnomaly"); r.Value != 1 || r.Reason != "condition cleared" {
+		t.Fatalf("resolved latency anomaly %+v", r)
+	}
+	expectEncodable(t, e)
+}
+
+// 其它来源的 NaN 按条件不再满足恢复，恢复记录保留最后一次的有效值
+func TestResolveWithNaNKeepsValue(t *testing.T) {
+	e := newTestEngine(t)
+	e.SetNodeUp("hk-01", 0)
+	e.SetNodeUp("hk-01", math.NaN())
+	_, recent := e.Alerts()
+	if len(recent) != 1 || recent[0].Rule != "NodeDown" || recent[0].Value != 0 {
+		t.Fatalf("recent %+v", recent)
+	}
+	expectEncodable(t, e)
+}
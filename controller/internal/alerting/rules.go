@@ -0,0 +1,284 @@
+// Package alerting 按 YAML 规则持续评估节点上报与历史数据，维护每条序列的 pending/firing/resolved 状态
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/nodemeta"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+	"github.com/spf13/viper"
+)
+
+// 逐目标的 Ping 指标，其余指标名取自 storage.SnapshotFieldNames
+const (
+	MetricPingMinRTT = "ping_min_rtt_ms"
+	MetricPingAvgRTT = "ping_avg_rtt_ms"
+	MetricPingMaxRTT = "ping_max_rtt_ms"
+	MetricPingLoss   = "ping_loss"
+)
+
+var pingMetrics = []string{MetricPingMinRTT, MetricPingAvgRTT, MetricPingMaxRTT, MetricPingLoss}
+
+// MetricNodeUp 节点连接状态：reporting/connected 为 1，stale 为 0.5，offline 为 0。
+// 由节点状态跟踪器在状态转换时推送，并在每个评估周期按最新状态重新评估，不依赖上报帧
+const MetricNodeUp = "node_up"
+
+// 逐目标的延迟 / 丢包偏离自适应基线的分数 (约为标准差倍数)，由异常检测器随每次 Ping 结果推送；
+// 学习期内没有分数，不支持 window
+const (
+	MetricRTTAnomaly  = "ping_rtt_anomaly"
+	MetricLossAnomaly = "ping_loss_anomaly"
+)
+
+// 告警级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Rule 一条告警规则
+//
+//	metric:    序列名，如 cpu_usage_percent；ping_* 按探测目标逐个评估；node_up 为节点连接状态；
+//	           ping_rtt_anomaly / ping_loss_anomaly 为偏离自适应基线的分数
+//	nodes:     节点 ID 通配 (path.Match 语法)，空表示全部节点
+//	node_labels / groups: 按节点元数据进一步筛选，见 nodemeta.Selector
+//	targets:   探测目标 ip:port 通配，仅对 ping_* 指标生效
+//	op:        > >= < <= == !=
+//	for:       条件持续满足多久才从 pending 转为 firing，0 表示立即
+//	window:    0 表示用最新一帧；否则取存储中最近 window 的聚合值 (reduce: avg/min/max)
+type Rule struct {
+	Name        string            `mapstructure:"name" json:"name"`
+	Metric      string            `mapstructure:"metric" json:"metric"`
+	Nodes       []string          `mapstructure:"nodes" json:"nodes,omitempty"`
+	NodeLabels  map[string]string `mapstructure:"node_labels" json:"node_labels,omitempty"`
+	Groups      []string          `mapstructure:"groups" json:"groups,omitempty"`
+	Targets     []string          `mapstructure:"targets" json:"targets,omitempty"`
+	Op          string            `mapstructure:"op" json:"op"`
+	Threshold   float64           `mapstructure:"threshold" json:"threshold"`
+	For         time.Duration     `mapstructure:"for" json:"for"`
+	Window      time.Duration     `mapstructure:"window" json:"window,omitempty"`
+	Reduce      string            `mapstructure:"reduce" json:"reduce,omitempty"`
+	Severity    string            `mapstructure:"severity" json:"severity"`
+	Labels      map[string]string `mapstructure:"labels" json:"labels,omitempty"`
+	Annotations map[string]string `mapstructure:"annotations" json:"annotations,omitempty"`
+
+	annotations map[string]*template.Template
+}
+
+type ruleFile struct {
+	Rules []*Rule `mapstructure:"rules"`
+}
+
+// LoadRules 读取并校验规则文件，任何一条有误则整体拒绝
+func LoadRules(file string) ([]*Rule, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var rf ruleFile
+	if err := v.Unmarshal(&rf); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(rf.Rules))
+	var errs []error
+	for i, r := range rf.Rules {
+		if err := r.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rule #%d (%s): %w", i+1, r.Name, err))
+			continue
+		}
+		if seen[r.Name] {
+			errs = append(errs, fmt.Errorf("rule #%d: duplicate name %q", i+1, r.Name))
+		}
+		seen[r.Name] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return rf.Rules, nil
+}
+
+// LoadRulesIfExists 规则文件不存在时返回空规则集，便于未配置告警的部署照常启动
+func LoadRulesIfExists(file string) ([]*Rule, error) {
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return LoadRules(file)
+}
+
+func (r *Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("missing name")
+	}
+	if !r.isPing() && !r.isNodeState() && !r.isAnomaly() && !isSnapshotField(r.Metric) {
+		return fmt.Errorf("unknown metric %q", r.Metric)
+	}
+	if (r.isNodeState() || r.isAnomaly()) && r.Window > 0 {
+		return fmt.Errorf("window does not apply to %s", r.Metric)
+	}
+	if len(r.Targets) > 0 && !r.isPing() && !r.isAnomaly() {
+		return errors.New("targets only apply to ping_* metrics")
+	}
+	if _, ok := comparators[r.Op]; !ok {
+		return fmt.Errorf("unknown op %q", r.Op)
+	}
+	if r.For < 0 || r.Window < 0 {
+		return errors.New("for and window must not be negative")
+	}
+	switch r.Reduce {
+	case "":
+		r.Reduce = "avg"
+	case "avg", "min", "max":
+	default:
+		return fmt.Errorf("unknown reduce %q (want avg, min or max)", r.Reduce)
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityWarning
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("unknown severity %q", r.Severity)
+	}
+	if err := r.selector().Validate(); err != nil {
+		return err
+	}
+	for _, pattern := range append(append([]string{}, r.Nodes...), r.Targets...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %w", pattern, err)
+		}
+	}
+
+	r.annotations = make(map[string]*template.Template, len(r.Annotations))
+	for k, text := range r.Annotations {
+		t, err := template.New(k).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return fmt.Errorf("annotation %s: %w", k, err)
+		}
+		r.annotations[k] = t
+	}
+	return nil
+}
+
+func isSnapshotField(name string) bool {
+	_, ok := (&storage.MetricSnapshot{}).Field(name)
+	return ok
+}
+
+func (r *Rule) isPing() bool {
+	return slices.Contains(pingMetrics, r.Metric)
+}
+
+func (r *Rule) isNodeState() bool {
+	return r.Metric == MetricNodeUp
+}
+
+func (r *Rule) isAnomaly() bool {
+	return r.Metric == MetricRTTAnomaly || r.Metric == MetricLossAnomaly
+}
+
+func (r *Rule) selector() nodemeta.Selector {
+	return nodemeta.Selector{Labels: r.NodeLabels, Groups: r.Groups}
+}
+
+// matchNode meta 为节点元数据查询，未接入时带标签 / 分组条件的规则不匹配任何节点
+func (r *Rule) matchNode(nodeID string, meta func(nodeID string) nodemeta.Meta) bool {
+	if !matchAny(r.Nodes, nodeID) {
+		return false
+	}
+	sel := r.selector()
+	if sel.Empty() {
+		return true
+	}
+	return meta != nil && sel.Match(meta(nodeID))
+}
+
+func (r *Rule) matchTarget(target string) bool {
+	return matchAny(r.Targets, target)
+}
+
+func matchAny(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var comparators = map[string]func(v, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+func (r *Rule) breached(v float64) bool {
+	return comparators[r.Op](v, r.Threshold)
+}
+
+// pingValue 从单个目标的结果中取规则指标
+func pingValue(metric string, p storage.PingSnapshot) float64 {
+	switch metric {
+	case MetricPingMinRTT:
+		return p.MinRTT
+	case MetricPingMaxRTT:
+		return p.MaxRTT
+	case MetricPingLoss:
+		return p.Loss
+	}
+	return p.AvgRTT
+}
+
+// render 渲染注解模板，失败时保留原文，不影响告警本身
+func (r *Rule) render(a *Alert) map[string]string {
+	if len(r.annotations) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(r.annotations))
+	for k, t := range r.annotations {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, a); err != nil {
+			out[k] = r.Annotations[k]
+			continue
+		}
+		out[k] = buf.String()
+	}
+	return out
+}
+
+// MarshalJSON 时长按 "5m0s" 形式输出，便于前端展示
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	type plain Rule
+	return json.Marshal(struct {
+		*plain
+		For    string `json:"for"`
+		Window string `json:"window,omitempty"`
+	}{
+		plain:  (*plain)(r),
+		For:    r.For.String(),
+		Window: durationOrEmpty(r.Window),
+	})
+}
+
+func durationOrEmpty(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
@@ -0,0 +1,82 @@
+package anomaly
+
+import (
+	"math"
+	"time"
+)
+
+// hoursPerWeek 周期画像的槽数：星期几 × 小时
+const hoursPerWeek = 7 * 24
+
+// ewma 按时间衰减的指数加权均值与方差。Weight 为累计观测时长 (秒)，用于判断是否已学到足够数据
+type ewma struct {
+	Mean   float64 `json:"mean"`
+	Var    float64 `json:"var"`
+	Weight float64 `json:"weight"`
+}
+
+// alpha 经过 dt 后旧值的衰减比例，半衰期为 halfLife
+func alpha(dt, halfLife time.Duration) float64 {
+	return 1 - math.Exp(-math.Ln2*dt.Seconds()/halfLife.Seconds())
+}
+
+func (e *ewma) update(x float64, dt, halfLife time.Duration) {
+	if e.Weight == 0 {
+		e.Mean, e.Var = x, 0
+	} else {
+		a := alpha(dt, halfLife)
+		diff := x - e.Mean
+		incr := a * diff
+		e.Mean += incr
+		e.Var = (1 - a) * (e.Var + diff*incr)
+	}
+	e.Weight += dt.Seconds()
+}
+
+func (e *ewma) std() float64 {
+	return math.Sqrt(max(e.Var, 0))
+}
+
+// baseline 单个指标的基线：短期 EWMA 跟随近期水平，周画像记录每周同一小时的典型值
+type baseline struct {
+	Recent ewma               `json:"recent"`
+	Weekly [hoursPerWeek]ewma `json:"weekly"`
+}
+
+// Expectation 某一时刻的期望值与离散程度
+type Expectation struct {
+	Mean   float64 `json:"mean"`
+	Std    float64 `json:"std"`
+	Source string  `json:"source"` // recent: 短期 EWMA；weekly: 周画像中的同一小时
+}
+
+// hourOfWeek 周日 0 点为 0
+func hourOfWeek(t time.Time) int {
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// expect 可用的期望：短期 EWMA 总是可用，周画像对应小时积累满 minWeekly 时长后才加入
+func (b *baseline) expect(how int, minWeekly time.Duration) []Expectation {
+	out := []Expectation{{Mean: b.Recent.Mean, Std: b.Recent.std(), Source: "recent"}}
+	if w := &b.Weekly[how]; w.Weight >= minWeekly.Seconds() {
+		out = append(out, Expectation{Mean: w.Mean, Std: w.std(), Source: "weekly"})
+	}
+	return out
+}
+
+// score 对每个期望计算 (x - mean) / max(std, floor(mean))，取最小者：
+// 只有同时偏离近期水平与往周同一时段时才算异常，既容忍每天固定的晚高峰，也能在链路水平整体变化后数小时内适应
+func score(x float64, exps []Expectation, floor func(mean float64) float64) (float64, Expectation) {
+	best, bestExp := math.Inf(1), exps[0]
+	for _, e := range exps {
+		if s := (x - e.Mean) / max(e.Std, floor(e.Mean)); s < best {
+			best, bestExp = s, e
+		}
+	}
+	return best, bestExp
+}
+
+func (b *baseline) update(x float64, how int, dt, recentHalfLife, weeklyHalfLife time.Duration) {
+	b.Recent.update(x, dt, recentHalfLife)
+	b.Weekly[how].update(x, dt, weeklyHalfLife)
+}
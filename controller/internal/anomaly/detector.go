@@ -0,0 +1,500 @@
+// Package anomaly 为每个节点 / 探测目标学习延迟与丢包的自适应基线 (短期 EWMA + 按星期几、小时的周画像)，
+// 对偏离基线的样本打分并记录异常事件，分数推给告警引擎，事件供大屏在延迟图上标注
+package anomaly
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// 异常类型
+const (
+	KindRTT  = "rtt"
+	KindLoss = "loss"
+)
+
+// Options 检测参数
+type Options struct {
+	StatePath      string         // 基线与事件持久化文件
+	Threshold      float64        // 分数达到该值即判为异常，回落到一半以下结束
+	LossThreshold  float64        // 丢包异常还要求比基线至少高出这么多 (0-1)
+	MinRTTStd      float64        // 延迟标准差下限 (ms)，避免极平稳的链路上微小抖动被放大
+	RecentHalfLife time.Duration  // 短期 EWMA 半衰期
+	WeeklyHalfLife time.Duration  // 周画像每个小时槽的半衰期 (按槽内累计时长计，2h 约等于两周)
+	MinWeekly      time.Duration  // 周画像某小时槽累计多少数据后才用于判定
+	Warmup         time.Duration  // 新序列学习多久后才开始打分
+	EventLimit     int            // 最多保留的事件数
+	EventRetention time.Duration  // 事件保留时长
+	Location       *time.Location // 周画像按该时区划分星期与小时，默认主控本地时区
+}
+
+func (o *Options) applyDefaults() {
+	if o.Threshold <= 0 {
+		o.Threshold = 4
+	}
+	if o.LossThreshold <= 0 {
+		o.LossThreshold = 0.1
+	}
+	if o.MinRTTStd <= 0 {
+		o.MinRTTStd = 1
+	}
+	if o.RecentHalfLife <= 0 {
+		o.RecentHalfLife = time.Hour
+	}
+	if o.WeeklyHalfLife <= 0 {
+		o.WeeklyHalfLife = 2 * time.Hour
+	}
+	if o.MinWeekly <= 0 {
+		o.MinWeekly = 30 * time.Minute
+	}
+	if o.Warmup <= 0 {
+		o.Warmup = time.Hour
+	}
+	if o.EventLimit <= 0 {
+		o.EventLimit = 5000
+	}
+	if o.EventRetention <= 0 {
+		o.EventRetention = 30 * 24 * time.Hour
+	}
+	if o.Location == nil {
+		o.Location = time.Local
+	}
+}
+
+// 相邻两次样本间隔的上限：节点断线重连后不把整段空白都算作学习时长
+const (
+	defaultStep = 5 * time.Second
+	maxStep     = 5 * time.Minute
+)
+
+// minLossStd 丢包率标准差下限
+const minLossStd = 0.02
+
+// Event 一次异常：从分数越过阈值开始，回落到阈值一半以下或序列停止上报结束
+type Event struct {
+	ID       string  `json:"id"`
+	NodeID   string  `json:"node_id"`
+	Target   string  `json:"target"`
+	Kind     string  `json:"kind"` // rtt / loss
+	Start    int64   `json:"start"`
+	End      int64   `json:"end,omitempty"` // 0 表示仍在持续
+	Score    float64 `json:"score"`         // 期间最高分
+	Value    float64 `json:"value"`         // 最高分时的实测值
+	Expected float64 `json:"expected"`      // 最高分时的期望值
+}
+
+// Score 一次样本的评分结果，学习期内分数为 NaN
+type Score struct {
+	NodeID       string
+	Target       string
+	At           int64
+	RTT          float64
+	RTTExpected  Expectation
+	RTTScore     float64
+	Loss         float64
+	LossExpected Expectation
+	LossScore    float64
+}
+
+// Listener 评分回调，在检测器锁内调用，实现方只能做轻量操作
+type Listener func(s Score)
+
+type series struct {
+	NodeID   string   `json:"node_id"`
+	Target   string   `json:"target"`
+	Last     int64    `json:"last"`
+	Observed float64  `json:"observed"` // 累计学习时长 (秒)
+	RTT      baseline `json:"rtt"`
+	Loss     baseline `json:"loss"`
+
+	open map[string]*Event // 进行中的事件，按类型
+}
+
+type state struct {
+	Series []*series `json:"series"`
+	Events []*Event  `json:"events"`
+}
+
+// Detector 作为 Sink 逐帧学习与打分
+type Detector struct {
+	opts Options
+
+	mu        sync.Mutex
+	series    map[string]*series // key: node_id + "\x1f" + target
+	events    []*Event           // 按开始时间升序
+	listeners []Listener
+	dirty     bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func seriesKey(nodeID, target string) string {
+	return nodeID + "\x1f" + target
+}
+
+// NewDetector 恢复上次保存的基线与事件并启动定期保存
+func NewDetector(opts Options) (*Detector, error) {
+	opts.applyDefaults()
+	d := &Detector{opts: opts, series: make(map[string]*series), stop: make(chan struct{})}
+	if err := d.load(); err != nil {
+		return nil, fmt.Errorf("load anomaly state: %w", err)
+	}
+	d.wg.Add(1)
+	go d.loop()
+	return d, nil
+}
+
+func (d *Detector) load() error {
+	if d.opts.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(d.opts.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	for _, s := range st.Series {
+		s.open = make(map[string]*Event)
+		d.series[seriesKey(s.NodeID, s.Target)] = s
+	}
+	d.events = st.Events
+	for _, ev := range d.events {
+		if ev.End == 0 {
+			if s, ok := d.series[seriesKey(ev.NodeID, ev.Target)]; ok {
+				s.open[ev.Kind] = ev
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Detector) save() error {
+	if d.opts.StatePath == "" {
+		return nil
+	}
+	d.mu.Lock()
+	if !d.dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	st := state{Series: make([]*series, 0, len(d.series)), Events: d.events}
+	for _, s := range d.series {
+		st.Series = append(st.Series, s)
+	}
+	data, err := json.Marshal(st)
+	d.dirty = false
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(d.opts.StatePath), 0o755); err != nil {
+		return err
+	}
+	tmp := d.opts.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.opts.StatePath)
+}
+
+// Subscribe 注册评分回调，须在上报开始前调用
+func (d *Detector) Subscribe(l Listener) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, l)
+}
+
+// Ingest 实现 storage.Sink
+func (d *Detector) Ingest(req *pb.ReportRequest) error {
+	pings := storage.NewPingSnapshots(req)
+	if len(pings) == 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, p := range pings {
+		d.observe(req.NodeId, p)
+	}
+	return nil
+}
+
+// observe 先按学习到的基线打分，再用 (截断后的) 样本更新基线。调用方持有 d.mu
+func (d *Detector) observe(nodeID string, p storage.PingSnapshot) {
+	key := seriesKey(nodeID, p.Target)
+	s, ok := d.series[key]
+	if !ok {
+		s = &series{NodeID: nodeID, Target: p.Target, open: make(map[string]*Event)}
+		d.series[key] = s
+	}
+	now := p.Timestamp
+	dt := defaultStep
+	if s.Last > 0 {
+		if now <= s.Last {
+			return
+		}
+		dt = min(time.Duration(now-s.Last)*time.Millisecond, maxStep)
+	}
+	s.Last = now
+	d.dirty = true
+	how := hourOfWeek(time.UnixMilli(now).In(d.opts.Location))
+	warm := s.Observed >= d.opts.Warmup.Seconds()
+
+	sc := Score{NodeID: nodeID, Target: p.Target, At: now, RTT: p.AvgRTT, Loss: p.Loss, RTTScore: math.NaN(), LossScore: math.NaN()}
+
+	// 丢包
+	lossFloor := func(float64) float64 { return minLossStd }
+	var lossScore float64
+	lossScore, sc.LossExpected = score(p.Loss, s.Loss.expect(how, d.opts.MinWeekly), lossFloor)
+	lossSample := p.Loss
+	if warm {
+		sc.LossScore = lossScore
+		if p.Loss-sc.LossExpected.Mean < d.opts.LossThreshold {
+			// 绝对增幅不够时不视为异常，分数压到事件结束线以下
+			sc.LossScore = min(sc.LossScore, d.opts.Threshold/4)
+		}
+		// 异常样本截断后再学习，避免基线被一次故障迅速带偏
+		lossSample = min(p.Loss, sc.LossExpected.Mean+d.opts.Threshold*max(sc.LossExpected.Std, minLossStd))
+	}
+	s.Loss.update(lossSample, how, dt, d.opts.RecentHalfLife, d.opts.WeeklyHalfLife)
+	d.track(s, KindLoss, sc.LossScore, p.Loss, sc.LossExpected.Mean, now)
+
+	// 全部丢包时没有有效延迟，不打分也不学习
+	if p.Loss < 1 {
+		rttFloor := func(mean float64) float64 { return max(d.opts.MinRTTStd, 0.05*mean) }
+		var rttScore float64
+		rttScore, sc.RTTExpected = score(p.AvgRTT, s.RTT.expect(how, d.opts.MinWeekly), rttFloor)
+		rttSample := p.AvgRTT
+		if warm {
+			sc.RTTScore = rttScore
+			rttSample = min(p.AvgRTT, sc.RTTExpected.Mean+d.opts.Threshold*max(sc.RTTExpected.Std, rttFloor(sc.RTTExpected.Mean)))
+		}
+		s.RTT.update(rttSample, how, dt, d.opts.RecentHalfLife, d.opts.WeeklyHalfLife)
+		d.track(s, KindRTT, sc.RTTScore, p.AvgRTT, sc.RTTExpected.Mean, now)
+	}
+	s.Observed += dt.Seconds()
+
+	for _, l := range d.listeners {
+		l(sc)
+	}
+}
+
+// track 按分数开启、更新或结束事件，调用方持有 d.mu
+func (d *Detector) track(s *series, kind string, score, value, expected float64, now int64) {
+	ev := s.open[kind]
+	if math.IsNaN(score) {
+		return
+	}
+	if ev == nil {
+		if score < d.opts.Threshold {
+			return
+		}
+		ev = &Event{ID: newID(), NodeID: s.NodeID, Target: s.Target, Kind: kind, Start: now, Score: score, Value: value, Expected: expected}
+		s.open[kind] = ev
+		d.events = append(d.events, ev)
+		d.prune(now)
+		log.Printf("[Anomaly] %s anomaly on node [%s] -> %s: %.4g (expected %.4g, score %.1f)",
+			kind, s.NodeID, s.Target, value, expected, score)
+		return
+	}
+	if score > ev.Score {
+		ev.Score, ev.Value, ev.Expected = score, value, expected
+	}
+	if score < d.opts.Threshold/2 {
+		d.closeEvent(s, kind, now)
+	}
+}
+
+func (d *Detector) closeEvent(s *series, kind string, now int64) {
+	ev := s.open[kind]
+	ev.End = now
+	delete(s.open, kind)
+	d.dirty = true
+	log.Printf("[Anomaly] %s anomaly on node [%s] -> %s ended after %v",
+		kind, s.NodeID, s.Target, time.Duration(ev.End-ev.Start)*time.Millisecond)
+}
+
+// prune 按条数与时长裁剪已结束的事件，调用方持有 d.mu
+func (d *Detector) prune(now int64) {
+	cutoff := now - d.opts.EventRetention.Milliseconds()
+	kept := d.events[:0]
+	over := len(d.events) - d.opts.EventLimit
+	for _, ev := range d.events {
+		if ev.End != 0 && (ev.End < cutoff || over > 0) {
+			over--
+			continue
+		}
+		kept = append(kept, ev)
+	}
+	clear(d.events[len(kept):])
+	d.events = kept
+}
+
+func (d *Detector) loop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.closeStale(now.UnixMilli())
+			if err := d.save(); err != nil {
+				log.Printf("[Anomaly] Save state error: %v", err)
+			}
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// closeStale 停止上报的序列 (节点掉线、目标被移除) 结束其进行中的事件
+func (d *Detector) closeStale(now int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.series {
+		if len(s.open) == 0 || now-s.Last < maxStep.Milliseconds() {
+			continue
+		}
+		for kind := range s.open {
+			d.closeEvent(s, kind, s.Last)
+		}
+	}
+	d.prune(now)
+}
+
+// Events [from, to) 内有交集的事件，可按节点、目标过滤，按开始时间倒序
+func (d *Detector) Events(nodeID, target string, from, to int64) []Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := []Event{}
+	for i := len(d.events) - 1; i >= 0; i-- {
+		ev := d.events[i]
+		if (nodeID != "" && ev.NodeID != nodeID) || (target != "" && ev.Target != target) {
+			continue
+		}
+		if ev.Start < to && (ev.End == 0 || ev.End >= from) {
+			out = append(out, *ev)
+		}
+	}
+	return out
+}
+
+// Profile 某个目标的当前基线：短期 EWMA 与周画像各小时槽
+type Profile struct {
+	NodeID   string        `json:"node_id"`
+	Target   string        `json:"target"`
+	Learning bool          `json:"learning"` // 仍在学习期，不打分
+	RTT      []Expectation `json:"rtt"`      // 当前时刻可用的期望
+	Loss     []Expectation `json:"loss"`
+	Weekly   []WeeklyPoint `json:"weekly"`
+}
+
+// WeeklyPoint 周画像的一个小时槽，hour 为周日 0 点起的小时序号
+type WeeklyPoint struct {
+	Hour     int     `json:"hour"`
+	RTTMean  float64 `json:"rtt_mean"`
+	RTTStd   float64 `json:"rtt_std"`
+	LossMean float64 `json:"loss_mean"`
+	Learned  float64 `json:"learned_s"` // 该槽累计学习时长 (秒)
+}
+
+// Profile 单个节点单个目标的基线
+func (d *Detector) Profile(nodeID, target string) (Profile, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.series[seriesKey(nodeID, target)]
+	if !ok {
+		return Profile{}, false
+	}
+	how := hourOfWeek(time.Now().In(d.opts.Location))
+	p := Profile{
+		NodeID:   nodeID,
+		Target:   target,
+		Learning: s.Observed < d.opts.Warmup.Seconds(),
+		RTT:      s.RTT.expect(how, d.opts.MinWeekly),
+		Loss:     s.Loss.expect(how, d.opts.MinWeekly),
+		Weekly:   make([]WeeklyPoint, hoursPerWeek),
+	}
+	for h := range p.Weekly {
+		r, l := &s.RTT.Weekly[h], &s.Loss.Weekly[h]
+		p.Weekly[h] = WeeklyPoint{Hour: h, RTTMean: r.Mean, RTTStd: r.std(), LossMean: l.Mean, Learned: r.Weight}
+	}
+	return p, true
+}
+
+// DeleteNode 删除节点全部目标的基线与事件
+func (d *Detector) DeleteNode(nodeID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, s := range d.series {
+		if s.NodeID == nodeID {
+			delete(d.series, k)
+		}
+	}
+	d.events = slices.DeleteFunc(d.events, func(ev *Event) bool { return ev.NodeID == nodeID })
+	d.dirty = true
+	return nil
+}
+
+// MergeNode 事件改记到 into 名下；into 尚未学习过的目标沿用 from 的基线，已有的以 into 为准
+func (d *Detector) MergeNode(from, into string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, s := range d.series {
+		if s.NodeID != from {
+			continue
+		}
+		delete(d.series, k)
+		if _, ok := d.series[seriesKey(into, s.Target)]; ok {
+			for kind := range s.open {
+				d.closeEvent(s, kind, s.Last)
+			}
+			continue
+		}
+		s.NodeID = into
+		d.series[seriesKey(into, s.Target)] = s
+	}
+	for _, ev := range d.events {
+		if ev.NodeID == from {
+			ev.NodeID = into
+		}
+	}
+	d.dirty = true
+	return nil
+}
+
+// Close 停止后台协程并保存
+func (d *Detector) Close() error {
+	close(d.stop)
+	d.wg.Wait()
+	d.mu.Lock()
+	d.dirty = true
+	d.mu.Unlock()
+	return d.save()
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
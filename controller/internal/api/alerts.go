@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+)
+
+// EnableAlerts 打开 /api/alerts 与 /api/alerts/rules
+func (s *HttpServer) EnableAlerts(engine *alerting.Engine) {
+	s.alerts = engine
+}
+
+// alertList /api/alerts 的响应体
+type alertList struct {
+	Active []alertView `json:"active"`
+	Recent []alertView `json:"recent"`
+}
+
+// alertView 告警附带当前屏蔽它的静默 / 维护窗口 ID
+type alertView struct {
+	alerting.Alert
+	SilencedBy []string `json:"silenced_by,omitempty"`
+}
+
+func (s *HttpServer) alertView(a alerting.Alert) alertView {
+	v := alertView{Alert: a}
+	if s.silences != nil && a.State != alerting.StateResolved {
+		v.SilencedBy = s.silences.Muted(a)
+	}
+	return v
+}
+
+// handleAlerts 列出当前与最近恢复的告警，可按 state / severity / node_id 过滤
+func (s *HttpServer) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	q := r.URL.Query()
+	keep := func(a alerting.Alert) bool {
+		return (q.Get("state") == "" || string(a.State) == q.Get("state")) &&
+			(q.Get("severity") == "" || a.Severity == q.Get("severity")) &&
+			(q.Get("node_id") == "" || a.NodeID == q.Get("node_id"))
+	}
+
+	active, recent := s.alerts.Alerts()
+	resp := alertList{Active: []alertView{}, Recent: []alertView{}}
+	for _, a := range active {
+		if keep(a) {
+			resp.Active = append(resp.Active, s.alertView(a))
+		}
+	}
+	for _, a := range recent {
+		if keep(a) {
+			resp.Recent = append(resp.Recent, s.alertView(a))
+		}
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *HttpServer) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	rules := s.alerts.Rules()
+	if rules == nil {
+		rules = []*alerting.Rule{}
+	}
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
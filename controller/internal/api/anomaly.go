@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/anomaly"
+)
+
+// EnableAnomalies 打开 /api/anomalies 与 /api/anomalies/baseline
+func (s *HttpServer) EnableAnomalies(d *anomaly.Detector) {
+	s.anomalies = d
+}
+
+// handleAnomalies 与 [from, to) 有交集的异常事件 (默认最近 24 小时)，可按 node_id / target / kind 过滤
+func (s *HttpServer) handleAnomalies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	q := r.URL.Query()
+	from, to, err := parseTimeRange(q, 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events := s.anomalies.Events(q.Get("node_id"), q.Get("target"), from, to)
+	if kind := q.Get("kind"); kind != "" {
+		kept := events[:0]
+		for _, ev := range events {
+			if ev.Kind == kind {
+				kept = append(kept, ev)
+			}
+		}
+		events = kept
+	}
+	json.NewEncoder(w).Encode(events)
+}
+
+// handleAnomalyBaseline 单个节点单个目标学习到的基线与周画像
+func (s *HttpServer) handleAnomalyBaseline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	q := r.URL.Query()
+	nodeID, target := q.Get("node_id"), q.Get("target")
+	if nodeID == "" || target == "" {
+		http.Error(w, "missing node_id or target", http.StatusBadRequest)
+		return
+	}
+	p, ok := s.anomalies.Profile(nodeID, target)
+	if !ok {
+		http.Error(w, "no baseline for this node and target", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
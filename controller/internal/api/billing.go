@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/billing"
+	"github.com/geelinx-ltd/geegee/controller/internal/export"
+)
+
+// EnableBilling 打开 /api/billing 与 /api/billing/samples
+func (s *HttpServer) EnableBilling(m *billing.Meter) {
+	s.billing = m
+}
+
+// billingPeriod 解析计费区间：month=2026-09 取该月起算的计费周期，否则取 from/to，都省略时为当前周期
+func (s *HttpServer) billingPeriod(q url.Values) (from, to int64, err error) {
+	cycle := s.billing.Cycle()
+	if v := q.Get("month"); v != "" {
+		m, err := time.ParseInLocation("2006-01", v, cycle.Location)
+		if err != nil {
+			return 0, 0, errors.New("invalid month, want YYYY-MM")
+		}
+		start, end := cycle.Month(m)
+		return start.UnixMilli(), end.UnixMilli(), nil
+	}
+	if q.Get("from") == "" && q.Get("to") == "" {
+		start, end := cycle.Period(time.Now())
+		return start.UnixMilli(), end.UnixMilli(), nil
+	}
+	return parseTimeRange(q, 30*24*time.Hour)
+}
+
+func (s *HttpServer) billingError(w http.ResponseWriter, err error) {
+	if errors.Is(err, billing.ErrInvalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// handleBilling 计费周期内各节点各接口的 95 值与总流量，format=csv 时作为附件下载
+func (s *HttpServer) handleBilling(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	q := r.URL.Query()
+	from, to, err := s.billingPeriod(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rep, err := s.billing.Report(billing.Query{Nodes: splitList(q.Get("node_id")), From: from, To: to})
+	if err != nil {
+		s.billingError(w, err)
+		return
+	}
+
+	switch q.Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rep)
+	case "csv":
+		start := time.UnixMilli(from).In(s.billing.Cycle().Location)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="geegee-billing-%s.csv"`, start.Format("20060102")))
+		writeBillingCSV(w, rep)
+	default:
+		http.Error(w, "unknown format (want json or csv)", http.StatusBadRequest)
+	}
+}
+
+// writeBillingCSV 每个节点每个接口一行，速率为 bit/s，流量为字节
+func writeBillingCSV(w http.ResponseWriter, rep *billing.Report) {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"node_id", "interface", "period_start", "period_end", "samples", "expected_samples",
+		"p95_in_bps", "p95_out_bps", "p95_max_bps", "peak_in_bps", "peak_out_bps", "bytes_in", "bytes_out", "bytes_total"})
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) }
+	start := time.UnixMilli(rep.From).Format(time.RFC3339)
+	end := time.UnixMilli(rep.To).Format(time.RFC3339)
+	for _, n := range rep.Nodes {
+		for _, u := range n.Interfaces {
+			cw.Write([]string{n.NodeID, u.Interface, start, end, strconv.Itoa(u.Samples), strconv.Itoa(u.ExpectedSamples),
+				num(u.P95InBps), num(u.P95OutBps), num(u.P95MaxBps), num(u.PeakInBps), num(u.PeakOutBps),
+				num(u.BytesIn), num(u.BytesOut), num(u.BytesTotal)})
+		}
+	}
+	cw.Flush()
+}
+
+var billingSampleColumns = []export.Column{
+	{Name: "timestamp", Type: export.TypeTimestamp},
+	{Name: "in_bps"},
+	{Name: "out_bps"},
+	{Name: "bytes_in"},
+	{Name: "bytes_out"},
+	{Name: "covered_pct"},
+}
+
+// handleBillingSamples 单个节点单个接口 (iface 缺省为整机合计) 的 5 分钟样本，用于逐点对账；
+// format 为 json (默认) 或 csv / jsonl / parquet 附件
+func (s *HttpServer) handleBillingSamples(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	q := r.URL.Query()
+	nodeID := q.Get("node_id")
+	if nodeID == "" {
+		http.Error(w, "missing node_id", http.StatusBadRequest)
+		return
+	}
+	iface := q.Get("iface")
+	if iface == "" {
+		iface = billing.TotalInterface
+	}
+	from, to, err := s.billingPeriod(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	samples, err := s.billing.Samples(nodeID, iface, from, to)
+	if err != nil {
+		s.billingError(w, err)
+		return
+	}
+
+	if f := q.Get("format"); f == "" || f == "json" {
+		if samples == nil {
+			samples = []billing.Sample{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(samples)
+		return
+	}
+	format, err := export.ParseFormat(q.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := unsafeFilename.ReplaceAllString(nodeID+"-"+iface, "_")
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-5m-%s.%s"`, name,
+		time.UnixMilli(from).UTC().Format("20060102T150405Z"), format.Ext()))
+	out, err := export.NewWriter(format, w, billingSampleColumns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, sm := range samples {
+		if err := out.WriteRow([]float64{float64(sm.Slot), sm.InBps, sm.OutBps, sm.BytesIn, sm.BytesOut, sm.CoveredPct}); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if err := out.Close(); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
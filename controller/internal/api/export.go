@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/export"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// EnableBackups 打开 /api/storage/backups，快照写入 dir 并只保留最新 keep 份
+func (s *HttpServer) EnableBackups(dir string, keep int) {
+	s.backupDir = dir
+	s.backupKeep = keep
+}
+
+// exportRequest 导出参数。时间范围按 step 分桶，每次查询 MaxBuckets 个桶，边查边写
+type exportRequest struct {
+	nodeID string
+	target string // 非空时导出该目标的 Ping 序列
+	from   int64
+	to     int64
+	step   int64
+	fields []string
+	aggs   []string
+	format export.Format
+}
+
+var exportAggs = map[string]func(a storage.Aggregate) float64{
+	"min": func(a storage.Aggregate) float64 { return a.Min },
+	"avg": func(a storage.Aggregate) float64 { return a.Avg },
+	"max": func(a storage.Aggregate) float64 { return a.Max },
+}
+
+// parseExportRequest 解析导出参数
+//
+//	from/to: 同 /api/metrics，缺省为最近 24 小时
+//	step:    桶宽，缺省 MinStepMs 即原始分辨率
+//	fields:  逗号分隔的序列名，缺省全部；agg: min,avg,max 任选，缺省 avg
+func parseExportRequest(q url.Values) (req exportRequest, err error) {
+	req.nodeID = q.Get("node_id")
+	if req.nodeID == "" {
+		return req, errors.New("missing node_id")
+	}
+	req.target = q.Get("target")
+	if req.format, err = export.ParseFormat(q.Get("format")); err != nil {
+		return req, err
+	}
+
+	now := time.Now()
+	req.to = now.UnixMilli()
+	if v := q.Get("to"); v != "" {
+		if req.to, err = parseTime(v, now); err != nil {
+			return req, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	req.from = req.to - 24*time.Hour.Milliseconds()
+	if v := q.Get("from"); v != "" {
+		if req.from, err = parseTime(v, now); err != nil {
+			return req, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if req.to < req.from {
+		return req, fmt.Errorf("invalid range: to (%d) is before from (%d)", req.to, req.from)
+	}
+
+	req.step = storage.MinStepMs
+	if v := q.Get("step"); v != "" {
+		d, err := parseDuration(v)
+		if err != nil {
+			return req, fmt.Errorf("invalid step: %w", err)
+		}
+		req.step = max(d.Milliseconds(), storage.MinStepMs)
+	}
+	req.from -= req.from % req.step
+
+	req.fields = storage.SnapshotFieldNames()
+	if v := q.Get("fields"); v != "" {
+		req.fields = splitList(v)
+		for _, f := range req.fields {
+			if _, ok := (&storage.MetricSnapshot{}).Field(f); !ok {
+				return req, fmt.Errorf("unknown field %q", f)
+			}
+		}
+	}
+	req.aggs = []string{"avg"}
+	if v := q.Get("agg"); v != "" {
+		req.aggs = splitList(v)
+		for _, a := range req.aggs {
+			if _, ok := exportAggs[a]; !ok {
+				return req, fmt.Errorf("unknown agg %q (want min, avg or max)", a)
+			}
+		}
+	}
+	return req, nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (req *exportRequest) columns() []export.Column {
+	cols := []export.Column{{Name: "timestamp", Type: export.TypeTimestamp}, {Name: "count", Type: export.TypeInt}}
+	if req.target != "" {
+		for _, name := range []string{"min_rtt_ms", "avg_rtt_ms", "max_rtt_ms", "loss", "max_loss"} {
+			cols = append(cols, export.Column{Name: name})
+		}
+		return cols
+	}
+	for _, f := range req.fields {
+		for _, a := range req.aggs {
+			name := f
+			if len(req.aggs) > 1 || a != "avg" {
+				name = f + "_" + a
+			}
+			cols = append(cols, export.Column{Name: name})
+		}
+	}
+	return cols
+}
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (req *exportRequest) filename() string {
+	name := req.nodeID
+	if req.target != "" {
+		name += "-" + req.target
+	}
+	name = unsafeFilename.ReplaceAllString(name, "_")
+	return fmt.Sprintf("%s-%s-%s.%s", name,
+		time.UnixMilli(req.from).UTC().Format("20060102T150405Z"),
+		time.UnixMilli(req.to).UTC().Format("20060102T150405Z"),
+		req.format.Ext())
+}
+
+// handleExport 流式导出单个节点的历史。响应头发出后出错只能中断连接，客户端据此判定文件不完整
+func (s *HttpServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	req, err := parseExportRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", req.format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, req.filename()))
+	out, err := export.NewWriter(req.format, w, req.columns())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	start, rows := time.Now(), 0
+	if err := s.streamExport(w, r, &req, out, &rows); err != nil {
+		log.Printf("Export for node [%s] aborted after %d rows: %v", req.nodeID, rows, err)
+		panic(http.ErrAbortHandler)
+	}
+	if err := out.Close(); err != nil {
+		log.Printf("Export for node [%s] aborted after %d rows: %v", req.nodeID, rows, err)
+		panic(http.ErrAbortHandler)
+	}
+	log.Printf("Exported %d rows for node [%s] as %s in %v", rows, req.nodeID, req.format, time.Since(start))
+}
+
+// streamExport 逐个时间窗查询并写出，每个窗口结束后把已编码的数据推给客户端
+func (s *HttpServer) streamExport(w http.ResponseWriter, r *http.Request, req *exportRequest, out export.Writer, rows *int) error {
+	flusher, _ := w.(http.Flusher)
+	row := make([]float64, len(req.columns()))
+	window := req.step * storage.MaxBuckets
+
+	for from := req.from; from <= req.to; from += window {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		q := storage.RangeQuery{From: from, To: min(from+window-1, req.to), Step: req.step, Fields: req.fields}
+		if err := q.Normalize(); err != nil {
+			return err
+		}
+
+		if req.target != "" {
+			buckets, err := s.cache.QueryPingHistory(req.nodeID, req.target, q)
+			if err != nil {
+				return err
+			}
+			for _, b := range buckets {
+				row = append(row[:0], float64(b.Timestamp), float64(b.Count), b.MinRTT, b.AvgRTT, b.MaxRTT, b.Loss, b.MaxLoss)
+				if err := out.WriteRow(row); err != nil {
+					return err
+				}
+				*rows++
+			}
+		} else {
+			buckets, err := s.cache.QueryNodeHistory(req.nodeID, q)
+			if err != nil {
+				return err
+			}
+			for _, b := range buckets {
+				row = append(row[:0], float64(b.Timestamp), float64(b.Count))
+				for _, f := range req.fields {
+					for _, a := range req.aggs {
+						row = append(row, exportAggs[a](b.Values[f]))
+					}
+				}
+				if err := out.WriteRow(row); err != nil {
+					return err
+				}
+				*rows++
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return nil
+}
+
+// handleBackups GET 列出快照；POST 立即生成一份 (Content-Type 须为 application/json，请求体可为空)，
+// 超出保留份数时会删除最旧的快照，因此需要鉴权
+func (s *HttpServer) handleBackups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		list, err := storage.ListBackups(s.backupDir)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		if !checkMutation(w, r) || !s.authorize(w, r) {
+			return
+		}
+		b, ok := s.cache.(storage.Backuper)
+		if !ok {
+			http.Error(w, storage.ErrBackupUnsupported.Error(), http.StatusNotFound)
+			return
+		}
+		info, err := storage.BackupToDir(b, s.backupDir, s.backupKeep)
+		if errors.Is(err, storage.ErrBackupUnsupported) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(info)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBackupDownload GET /api/storage/backups/<name> 下载快照文件
+func (s *HttpServer) handleBackupDownload(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/storage/backups/")
+	if !storage.IsBackupName(name) {
+		http.Error(w, "invalid backup name", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	http.ServeFile(w, r, filepath.Join(s.backupDir, name))
+}
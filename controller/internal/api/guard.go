@@ -0,0 +1,52 @@
+package api
+
+import (
+	"crypto/subtle"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// SetAPIToken 设置破坏性接口 (删除 / 合并节点、备份、下发任务等) 的访问令牌，请求须携带
+// Authorization: Bearer <token>。为空时这些接口只接受来自本机回环地址的请求
+func (s *HttpServer) SetAPIToken(token string) {
+	s.apiToken = token
+	if token == "" {
+		log.Println("http.api_token is not set, destructive API calls are only accepted from loopback")
+	}
+}
+
+// checkMutation 变更类请求的公共检查：带请求体的方法只接受 application/json。
+// 跨站页面只能发出 text/plain、表单等简单请求，要求 JSON 会触发 CORS 预检，而变更类接口不返回 CORS 头，预检必然失败
+func checkMutation(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mt != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+// authorize 破坏性操作的鉴权：配置了令牌时校验 Bearer 令牌，否则只放行回环地址
+func (s *HttpServer) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if s.apiToken == "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "set http.api_token to allow this operation from remote hosts", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.apiToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="geegee"`)
+		http.Error(w, "invalid or missing API token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -2,16 +2,52 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+	"github.com/geelinx-ltd/geegee/controller/internal/anomaly"
+	"github.com/geelinx-ltd/geegee/controller/internal/billing"
+	"github.com/geelinx-ltd/geegee/controller/internal/lifecycle"
+	"github.com/geelinx-ltd/geegee/controller/internal/mesh"
+	"github.com/geelinx-ltd/geegee/controller/internal/nodemeta"
+	"github.com/geelinx-ltd/geegee/controller/internal/nodestate"
+	"github.com/geelinx-ltd/geegee/controller/internal/notify"
+	"github.com/geelinx-ltd/geegee/controller/internal/silence"
+	"github.com/geelinx-ltd/geegee/controller/internal/sla"
 	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+	"github.com/geelinx-ltd/geegee/controller/internal/tasks"
+	"github.com/geelinx-ltd/geegee/controller/internal/throughput"
 )
 
 // HttpServer 构建 RESTful API 并暴露 /api 节点用于前端画图读取
 type HttpServer struct {
-	addr  string
-	cache storage.Persister
+	addr   string
+	cache  storage.Persister
+	latest *storage.LatestStore // 可选：启用后暴露 Prometheus /metrics
+
+	backupDir  string // 可选：启用后暴露 /api/storage/backups
+	backupKeep int
+
+	apiToken string // 破坏性接口的访问令牌，为空时只接受本机请求
+
+	alerts   *alerting.Engine   // 可选：启用后暴露 /api/alerts
+	notifier *notify.Dispatcher // 可选：启用后暴露 /api/notify
+	silences *silence.Store     // 可选：启用后暴露 /api/silences 等
+
+	nodeState *nodestate.Tracker // 可选：启用后暴露 /api/nodes/state 与 /api/nodes/events
+	sla       *sla.Reporter      // 可选：启用后暴露 /api/sla
+	billing   *billing.Meter     // 可选：启用后暴露 /api/billing
+	anomalies *anomaly.Detector  // 可选：启用后暴露 /api/anomalies
+	nodeMeta  *nodemeta.Store    // 可选：启用后暴露 /api/nodes/meta 与 /api/groups
+	lifecycle *lifecycle.Manager // 可选：启用后暴露 /api/nodes/lifecycle
+	mesh      *mesh.Mesh         // 可选：启用后暴露 /api/mesh
+	tasks     *tasks.Manager     // 可选：启用后暴露 /api/tasks
+
+	throughput *throughput.Tester // 可选：启用后暴露 /api/throughput
 }
 
 func NewHttpServer(addr string, cache storage.Persister) *HttpServer {
@@ -21,19 +57,49 @@ func NewHttpServer(addr string, cache storage.Persister) *HttpServer {
 	}
 }
 
+// EnableMetricsExport 打开 /metrics 抓取端点，数据来自各节点最新一帧上报
+func (s *HttpServer) EnableMetricsExport(latest *storage.LatestStore) {
+	s.latest = latest
+}
+
 func (s *HttpServer) Start() {
 	mux := http.NewServeMux()
 
-	// API 1: 列出当前环境已知所有节点的卡片信息
+	// API 1: 列出当前环境已知所有节点的卡片信息，启用节点元数据时可用 label / group 过滤；
+	// 启用生命周期管理时默认不含已退役 / 归档的节点，include=all 全部列出
 	mux.HandleFunc("/api/nodes", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+		var sel nodemeta.Selector
+		if s.nodeMeta != nil {
+			var err error
+			if sel, err = parseSelector(r.URL.Query()); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 		nodes, err := s.cache.GetNodes()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if err := json.NewEncoder(w).Encode(nodes); err != nil {
+		// 启用状态跟踪时以跟踪器为准，不再按最后上报时间推算
+		if s.nodeState != nil {
+			for i := range nodes {
+				if st, ok := s.nodeState.State(nodes[i].NodeID); ok {
+					nodes[i].State = string(st.State)
+					nodes[i].IsOnline = st.State != nodestate.StateOffline
+				}
+			}
+		}
+		if s.lifecycle != nil {
+			nodes = s.filterLifecycle(nodes, r.URL.Query().Get("include") == "all")
+		}
+		var payload any = nodes
+		if s.nodeMeta != nil {
+			payload = s.filterNodes(nodes, sel)
+		}
+		if err := json.NewEncoder(w).Encode(payload); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	})
@@ -48,23 +114,256 @@ func (s *HttpServer) Start() {
 			return
 		}
 
+		// 携带 from/to/step 时按时间范围分桶返回 min/avg/max
+		rq, isRange, err := parseRangeQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if isRange {
+			buckets, err := s.cache.QueryNodeHistory(nodeID, rq)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if buckets == nil {
+				buckets = []storage.MetricBucket{}
+			}
+			if err := json.NewEncoder(w).Encode(buckets); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
 		history, err := s.cache.GetNodeHistory(nodeID, 300)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		if err := json.NewEncoder(w).Encode(history); err != nil {
+		// 可选 fields=cpu_load5,disk_read_bytes 只返回指定序列，减小前端负载
+		var payload any = history
+		if fields := r.URL.Query().Get("fields"); fields != "" {
+			projected, err := projectFields(history, strings.Split(fields, ","))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			payload = projected
+		}
+
+		if err := json.NewEncoder(w).Encode(payload); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	// API 3: 逐目标延迟。无 target 参数时列出全部目标及最新值，带 target 时返回该目标历史
+	mux.HandleFunc("/api/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		q := r.URL.Query()
+		nodeID := q.Get("node_id")
+		if nodeID == "" {
+			http.Error(w, "missing node_id", http.StatusBadRequest)
+			return
+		}
+
+		rq, isRange, err := parseRangeQuery(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if target := q.Get("target"); target != "" && isRange {
+			buckets, err := s.cache.QueryPingHistory(nodeID, target, rq)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if buckets == nil {
+				buckets = []storage.PingBucket{}
+			}
+			if err := json.NewEncoder(w).Encode(buckets); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		var result []storage.PingSnapshot
+		if target := q.Get("target"); target != "" {
+			limit := 300
+			if v, convErr := strconv.Atoi(q.Get("limit")); convErr == nil && v > 0 {
+				limit = v
+			}
+			result, err = s.cache.GetPingHistory(nodeID, target, limit)
+		} else {
+			result, err = s.cache.GetPingTargets(nodeID)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if result == nil {
+			result = []storage.PingSnapshot{}
+		}
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	// 存储写入管道状态：队列深度、批次耗时等，仅带写入队列的后端提供
+	mux.HandleFunc("/api/storage/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		provider, ok := s.cache.(storage.IngestStatsProvider)
+		if !ok {
+			http.Error(w, "storage backend has no ingest queue", http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(provider.IngestStats()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	// 扇出存储各成员的写入健康度
+	mux.HandleFunc("/api/storage/backends", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		fanout, ok := s.cache.(*storage.FanoutPersister)
+		if !ok {
+			http.Error(w, "storage is not a fan-out persister", http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(fanout.Backends()); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	})
 
+	// 按时间范围导出单个节点的历史：format=csv|jsonl|parquet，带 target 时导出该目标的延迟
+	mux.HandleFunc("/api/export", s.handleExport)
+
+	// 在线备份：GET 列出、POST 生成快照，/api/storage/backups/<name> 下载
+	if s.backupDir != "" {
+		mux.HandleFunc("/api/storage/backups", s.handleBackups)
+		mux.HandleFunc("/api/storage/backups/", s.handleBackupDownload)
+	}
+
+	// 告警：当前 pending/firing 与最近恢复的告警，以及生效的规则
+	if s.alerts != nil {
+		mux.HandleFunc("/api/alerts", s.handleAlerts)
+		mux.HandleFunc("/api/alerts/rules", s.handleAlertRules)
+	}
+
+	// 通知渠道状态与试发
+	if s.notifier != nil {
+		mux.HandleFunc("/api/notify/channels", s.handleNotifyChannels)
+		mux.HandleFunc("/api/notify/test", s.handleNotifyTest)
+	}
+
+	// 节点连接状态与 up/down 转换历史
+	if s.nodeState != nil {
+		mux.HandleFunc("/api/nodes/state", s.handleNodeStates)
+		mux.HandleFunc("/api/nodes/events", s.handleNodeEvents)
+	}
+
+	// 节点显示名、描述、标签与分组
+	if s.nodeMeta != nil {
+		mux.HandleFunc("/api/nodes/meta", s.handleNodeMetaList)
+		mux.HandleFunc("/api/nodes/meta/", s.handleNodeMeta)
+		mux.HandleFunc("/api/groups", s.handleGroups)
+	}
+
+	// 节点退役、删除与合并
+	if s.lifecycle != nil {
+		mux.HandleFunc("/api/nodes/lifecycle", s.handleLifecycle)
+	}
+
+	// 可用性报表：JSON 与可打印 HTML
+	if s.sla != nil {
+		mux.HandleFunc("/api/sla", s.handleSLA)
+		mux.HandleFunc("/api/sla/report", s.handleSLAReport)
+	}
+
+	// 95 计费：周期汇总 (可下载 CSV) 与逐点 5 分钟样本
+	if s.billing != nil {
+		mux.HandleFunc("/api/billing", s.handleBilling)
+		mux.HandleFunc("/api/billing/samples", s.handleBillingSamples)
+	}
+
+	// 节点间全网格延迟矩阵与劣化节点对的历史
+	if s.mesh != nil {
+		mux.HandleFunc("/api/mesh", s.handleMesh)
+		mux.HandleFunc("/api/mesh/history", s.handleMeshHistory)
+	}
+
+	// 按需下发到节点的诊断任务 (ping / traceroute / HTTP 检查等)
+	if s.tasks != nil {
+		mux.HandleFunc("/api/tasks", s.handleTasks)
+		mux.HandleFunc("/api/tasks/", s.handleTask)
+	}
+
+	// 节点间吞吐测试及其历史
+	if s.throughput != nil {
+		mux.HandleFunc("/api/throughput", s.handleThroughput)
+		mux.HandleFunc("/api/throughput/", s.handleThroughputTest)
+	}
+
+	// 延迟 / 丢包异常事件与学习到的基线
+	if s.anomalies != nil {
+		mux.HandleFunc("/api/anomalies", s.handleAnomalies)
+		mux.HandleFunc("/api/anomalies/baseline", s.handleAnomalyBaseline)
+	}
+
+	// 静默与周期性维护窗口，以及各节点当前的维护状态
+	if s.silences != nil {
+		mux.HandleFunc("/api/silences", s.handleSilences)
+		mux.HandleFunc("/api/silences/", s.handleSilence)
+		mux.HandleFunc("/api/maintenance-windows", s.handleWindows)
+		mux.HandleFunc("/api/maintenance-windows/", s.handleWindow)
+		mux.HandleFunc("/api/maintenance", s.handleMaintenance)
+	}
+
+	// Prometheus 抓取端点：一个主控即可覆盖全部节点
+	if s.latest != nil {
+		mux.HandleFunc("/metrics", s.handleMetrics)
+	}
+
 	// Web Static Server: / 将作为前端网页托管根路径
 	// 开发期间，我们先用一个极其简单的文字做打桩，下一个阶段直接构建静态页面。
 	mux.Handle("/", http.FileServer(http.Dir("./web/static")))
 
+	// 可查询的序列清单，供前端生成选择器
+	mux.HandleFunc("/api/metrics/fields", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		json.NewEncoder(w).Encode(append(storage.SnapshotFieldNames(), "cpu_usage_perc"))
+	})
+
 	log.Printf("HTTP Dashboard API Server listening on %s", s.addr)
 	if err := http.ListenAndServe(s.addr, mux); err != nil {
 		log.Fatalf("HTTP Server failed: %v", err)
 	}
 }
+
+// projectFields 将完整快照裁剪为 {timestamp, 指定字段...}
+func projectFields(history []storage.MetricSnapshot, fields []string) ([]map[string]any, error) {
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+		if _, ok := (&storage.MetricSnapshot{}).Field(fields[i]); !ok && fields[i] != "cpu_usage_perc" {
+			return nil, fmt.Errorf("unknown field %q", fields[i])
+		}
+	}
+
+	out := make([]map[string]any, len(history))
+	for i := range history {
+		row := map[string]any{"timestamp": history[i].Timestamp}
+		for _, f := range fields {
+			if f == "cpu_usage_perc" {
+				row[f] = history[i].CPUUsagePerCore
+				continue
+			}
+			row[f], _ = history[i].Field(f)
+		}
+		out[i] = row
+	}
+	return out, nil
+}
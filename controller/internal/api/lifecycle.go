@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/lifecycle"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// EnableLifecycle 打开 /api/nodes/lifecycle，/api/nodes 默认隐藏已退役 / 归档的节点
+func (s *HttpServer) EnableLifecycle(m *lifecycle.Manager) {
+	s.lifecycle = m
+}
+
+// maxLifecycleBody 生命周期操作的请求体上限
+const maxLifecycleBody = 16 * 1024
+
+// lifecycleRequest POST /api/nodes/lifecycle 的请求体
+type lifecycleRequest struct {
+	Action string `json:"action"` // decommission / reactivate / delete / merge
+	NodeID string `json:"node_id"`
+	Into   string `json:"into,omitempty"` // merge 的目标节点
+	By     string `json:"by,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// lifecycleOverview GET /api/nodes/lifecycle 的响应体
+type lifecycleOverview struct {
+	Nodes  []lifecycle.Record `json:"nodes"`
+	Events []lifecycle.Event  `json:"events"`
+}
+
+// filterLifecycle 填充生命周期状态，all 为 false 时去掉非 active 的节点
+func (s *HttpServer) filterLifecycle(nodes []storage.NodeStatus, all bool) []storage.NodeStatus {
+	out := nodes[:0]
+	for _, n := range nodes {
+		st := s.lifecycle.State(n.NodeID)
+		if st != lifecycle.StateActive && !all {
+			continue
+		}
+		n.Lifecycle = string(st)
+		out = append(out, n)
+	}
+	return out
+}
+
+// handleLifecycle GET 列出非 active 节点与审计日志 (node_id、limit 可选，limit 默认 200)；
+// POST 执行 decommission / reactivate / delete / merge，其中 delete / merge 不可撤销，需要鉴权
+func (s *HttpServer) handleLifecycle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		q := r.URL.Query()
+		limit := 200
+		if v := q.Get("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(lifecycleOverview{
+			Nodes:  s.lifecycle.Records(),
+			Events: s.lifecycle.Events(q.Get("node_id"), limit),
+		})
+
+	case http.MethodPost:
+		if !checkMutation(w, r) {
+			return
+		}
+		var req lifecycleRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLifecycleBody)).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.NodeID, req.Into = strings.TrimSpace(req.NodeID), strings.TrimSpace(req.Into)
+		if req.By == "" {
+			req.By = "api"
+		}
+
+		switch req.Action {
+		case lifecycle.ActionDecommission:
+			rec, err := s.lifecycle.Decommission(req.NodeID, req.By, req.Reason)
+			if writeLifecycleError(w, err) {
+				json.NewEncoder(w).Encode(rec)
+			}
+		case lifecycle.ActionReactivate:
+			if writeLifecycleError(w, s.lifecycle.Reactivate(req.NodeID, req.By, req.Reason)) {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		case lifecycle.ActionDelete, lifecycle.ActionMerge:
+			if !s.authorize(w, r) {
+				return
+			}
+			var (
+				ev  lifecycle.Event
+				err error
+			)
+			if req.Action == lifecycle.ActionDelete {
+				ev, err = s.lifecycle.Delete(req.NodeID, req.By, req.Reason)
+			} else {
+				ev, err = s.lifecycle.Merge(req.NodeID, req.Into, req.By, req.Reason)
+			}
+			// 部分组件失败时仍返回各步骤结果，便于排查后重试
+			if errors.Is(err, lifecycle.ErrIncomplete) {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(ev)
+				return
+			}
+			if writeLifecycleError(w, err) {
+				json.NewEncoder(w).Encode(ev)
+			}
+		default:
+			http.Error(w, fmt.Sprintf("unknown action %q", req.Action), http.StatusBadRequest)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeLifecycleError 写出错误响应，无错误时返回 true
+func writeLifecycleError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, lifecycle.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, lifecycle.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, lifecycle.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
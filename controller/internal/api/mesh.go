@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/mesh"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// EnableMesh 打开 /api/mesh 与 /api/mesh/history
+func (s *HttpServer) EnableMesh(m *mesh.Mesh) {
+	s.mesh = m
+}
+
+// meshHistory GET /api/mesh/history 的响应体
+type meshHistory struct {
+	From   int64         `json:"from"`
+	To     int64         `json:"to"`
+	Step   int64         `json:"step"`
+	Series []mesh.Series `json:"series"`
+}
+
+// handleMesh 参与节点与最近一个窗口的延迟 / 丢包矩阵
+func (s *HttpServer) handleMesh(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(s.mesh.Matrix())
+}
+
+// handleMeshHistory 各节点对在 [from, to] 内的分桶历史 (默认最近 24 小时)，
+// 默认只返回出现过劣化的节点对，all=1 返回全部；可按 src / dst 过滤
+func (s *HttpServer) handleMeshHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	q := r.URL.Query()
+	from, to, err := parseTimeRange(q, 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rq := storage.RangeQuery{From: from, To: to}
+	if v := q.Get("step"); v != "" {
+		d, err := parseDuration(v)
+		if err != nil {
+			http.Error(w, "invalid step: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		rq.Step = d.Milliseconds()
+	}
+	if err := rq.Normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	series, err := s.mesh.History(mesh.HistoryQuery{
+		RangeQuery: rq,
+		Src:        q.Get("src"),
+		Dst:        q.Get("dst"),
+		All:        q.Get("all") == "1" || q.Get("all") == "true",
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(meshHistory{From: rq.From, To: rq.To, Step: rq.Step, Series: series})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/nodemeta"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// EnableNodeMeta 打开 /api/nodes/meta 与 /api/groups，并让 /api/nodes 附带元数据、支持按标签 / 分组过滤
+func (s *HttpServer) EnableNodeMeta(store *nodemeta.Store) {
+	s.nodeMeta = store
+}
+
+// maxNodeMetaBody 设置节点元数据的请求体上限
+const maxNodeMetaBody = 64 * 1024
+
+// nodeCard /api/nodes 的一项：上报状态加上生效的元数据
+type nodeCard struct {
+	storage.NodeStatus
+	nodemeta.Meta
+}
+
+// parseSelector 解析 label=region=hk (可重复) 与 group=edge,core (可重复)，值均支持通配
+func parseSelector(q url.Values) (nodemeta.Selector, error) {
+	var sel nodemeta.Selector
+	for _, v := range q["label"] {
+		k, pattern, ok := strings.Cut(v, "=")
+		if !ok {
+			return sel, fmt.Errorf("invalid label %q, want name=value", v)
+		}
+		if sel.Labels == nil {
+			sel.Labels = make(map[string]string)
+		}
+		sel.Labels[strings.TrimSpace(k)] = strings.TrimSpace(pattern)
+	}
+	for _, v := range q["group"] {
+		sel.Groups = append(sel.Groups, splitList(v)...)
+	}
+	return sel, sel.Validate()
+}
+
+// filterNodes 按选择器过滤并附带元数据
+func (s *HttpServer) filterNodes(nodes []storage.NodeStatus, sel nodemeta.Selector) []nodeCard {
+	out := make([]nodeCard, 0, len(nodes))
+	for _, n := range nodes {
+		m := s.nodeMeta.Get(n.NodeID)
+		if sel.Match(m) {
+			out = append(out, nodeCard{NodeStatus: n, Meta: m})
+		}
+	}
+	return out
+}
+
+// handleNodeMetaList 全部节点的两层元数据 (节点自报 / API 设置) 与生效值
+func (s *HttpServer) handleNodeMetaList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(s.nodeMeta.Nodes())
+}
+
+// handleNodeMeta /api/nodes/meta/<node_id>：GET 查看，PUT 整体替换 API 层元数据，DELETE 恢复为节点自报的值
+func (s *HttpServer) handleNodeMeta(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	nodeID := strings.TrimPrefix(r.URL.Path, "/api/nodes/meta/")
+	if nodeID == "" {
+		http.Error(w, "missing node_id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		n, ok := s.nodeMeta.Node(nodeID)
+		if !ok {
+			http.Error(w, nodemeta.ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(n)
+
+	case http.MethodPut:
+		if !checkMutation(w, r) {
+			return
+		}
+		var m nodemeta.Meta
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNodeMetaBody)).Decode(&m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n, err := s.nodeMeta.SetManual(nodeID, m)
+		if !writeNodeMetaError(w, err) {
+			return
+		}
+		json.NewEncoder(w).Encode(n)
+
+	case http.MethodDelete:
+		if writeNodeMetaError(w, s.nodeMeta.ClearManual(nodeID)) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGroups 全部分组及其成员节点
+func (s *HttpServer) handleGroups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(s.nodeMeta.Groups())
+}
+
+// writeNodeMetaError 写出错误响应，无错误时返回 true
+func writeNodeMetaError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, nodemeta.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, nodemeta.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
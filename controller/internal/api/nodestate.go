@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/nodestate"
+)
+
+// EnableNodeState 打开 /api/nodes/state 与 /api/nodes/events，/api/nodes 的在线状态改由跟踪器给出
+func (s *HttpServer) EnableNodeState(t *nodestate.Tracker) {
+	s.nodeState = t
+}
+
+// handleNodeStates 所有节点的当前连接状态，带 node_id 时只返回该节点
+func (s *HttpServer) handleNodeStates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if nodeID := r.URL.Query().Get("node_id"); nodeID != "" {
+		st, ok := s.nodeState.State(nodeID)
+		if !ok {
+			http.Error(w, "unknown node", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(st)
+		return
+	}
+	json.NewEncoder(w).Encode(s.nodeState.States())
+}
+
+// nodeHistory /api/nodes/events 的响应体
+type nodeHistory struct {
+	Events    []nodestate.Transition `json:"events"`
+	Intervals []nodestate.Interval   `json:"intervals,omitempty"` // 仅指定 node_id 时给出
+}
+
+// handleNodeEvents 状态转换历史：from/to 默认最近 24 小时，limit 默认 500；
+// 指定 node_id 时额外给出按状态展开的连续区间，便于绘制 up/down 时间线
+func (s *HttpServer) handleNodeEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	q := r.URL.Query()
+	from, to, err := parseTimeRange(q, 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := 500
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	nodeID := q.Get("node_id")
+	resp := nodeHistory{Events: s.nodeState.Events(nodeID, from, to, limit)}
+	if resp.Events == nil {
+		resp.Events = []nodestate.Transition{}
+	}
+	if nodeID != "" {
+		resp.Intervals = s.nodeState.Intervals(nodeID, from, to)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
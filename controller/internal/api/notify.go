@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/notify"
+)
+
+// EnableNotify 打开 /api/notify/channels 与 /api/notify/test
+func (s *HttpServer) EnableNotify(d *notify.Dispatcher) {
+	s.notifier = d
+}
+
+// handleNotifyChannels 列出渠道及其发送统计
+func (s *HttpServer) handleNotifyChannels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(s.notifier.Channels()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleNotifyTest POST ?channel=<name> 同步试发一条测试告警，渠道返回错误时以 502 透出
+func (s *HttpServer) handleNotifyTest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("channel")
+	if name == "" {
+		http.Error(w, "channel is required", http.StatusBadRequest)
+		return
+	}
+
+	err := s.notifier.Test(r.Context(), name)
+	switch {
+	case errors.Is(err, notify.ErrUnknownChannel):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "sent", "channel": name})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// parseRangeQuery 解析 from/to/step/fields 参数。三者都未提供时 ok=false，调用方走旧的"最近 N 点"逻辑
+//
+//	from/to: Unix 毫秒、RFC3339、"now" 或相对当前的 "-24h" / "-7d"
+//	step:    毫秒整数或时长 "30s" / "5m" / "1d"，省略则自动按约 300 个点推算
+func parseRangeQuery(q url.Values) (rq storage.RangeQuery, ok bool, err error) {
+	if q.Get("from") == "" && q.Get("to") == "" && q.Get("step") == "" {
+		return rq, false, nil
+	}
+
+	now := time.Now()
+	to := now.UnixMilli()
+	if v := q.Get("to"); v != "" {
+		if to, err = parseTime(v, now); err != nil {
+			return rq, true, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	from := to - time.Hour.Milliseconds()
+	if v := q.Get("from"); v != "" {
+		if from, err = parseTime(v, now); err != nil {
+			return rq, true, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+
+	var step int64
+	if v := q.Get("step"); v != "" {
+		d, err := parseDuration(v)
+		if err != nil {
+			return rq, true, fmt.Errorf("invalid step: %w", err)
+		}
+		step = d.Milliseconds()
+	}
+
+	rq = storage.RangeQuery{From: from, To: to, Step: step}
+	if fields := q.Get("fields"); fields != "" {
+		for _, f := range strings.Split(fields, ",") {
+			rq.Fields = append(rq.Fields, strings.TrimSpace(f))
+		}
+	}
+	return rq, true, rq.Normalize()
+}
+
+// parseTimeRange 解析 from/to，to 默认 now，from 默认 to 之前 span
+func parseTimeRange(q url.Values, span time.Duration) (from, to int64, err error) {
+	now := time.Now()
+	to = now.UnixMilli()
+	if v := q.Get("to"); v != "" {
+		if to, err = parseTime(v, now); err != nil {
+			return 0, 0, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	from = to - span.Milliseconds()
+	if v := q.Get("from"); v != "" {
+		if from, err = parseTime(v, now); err != nil {
+			return 0, 0, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if to < from {
+		return 0, 0, fmt.Errorf("invalid range: to (%d) is before from (%d)", to, from)
+	}
+	return from, to, nil
+}
+
+func parseTime(v string, now time.Time) (int64, error) {
+	if v == "now" {
+		return now.UnixMilli(), nil
+	}
+	if strings.HasPrefix(v, "-") {
+		d, err := parseDuration(v[1:])
+		if err != nil {
+			return 0, err
+		}
+		return now.Add(-d).UnixMilli(), nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+// parseDuration 在 time.ParseDuration 基础上额外支持纯毫秒数与天 ("7d")
+func parseDuration(v string) (time.Duration, error) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	if days, found := strings.CutSuffix(v, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(v)
+}
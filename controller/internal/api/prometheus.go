@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+const metricPrefix = "geegee_"
+
+// metricHelp 各指标的 HELP 文本，未列出的指标以名称代替
+var metricHelp = map[string]string{
+	"node_up":                            "Node connection state: 1 reporting, 0.5 stale, 0 offline.",
+	"node_last_seen_age_seconds":         "Seconds since the controller last received a report from the node.",
+	"node_last_report_timestamp_seconds": "Node-side timestamp of the latest report.",
+
+	"cpu_cores":               "Number of physical CPU cores.",
+	"cpu_mhz":                 "CPU frequency in MHz.",
+	"cpu_load1":               "1-minute load average.",
+	"cpu_load5":               "5-minute load average.",
+	"cpu_load15":              "15-minute load average.",
+	"cpu_usage_percent":       "Per-core CPU usage in percent.",
+	"mem_total_bytes":         "Total physical memory in bytes.",
+	"mem_available_bytes":     "Available memory in bytes.",
+	"mem_used_bytes":          "Used memory in bytes.",
+	"mem_used_percent":        "Used memory in percent.",
+	"swap_total_bytes":        "Total swap in bytes.",
+	"swap_free_bytes":         "Free swap in bytes.",
+	"disk_read_bytes":         "Cumulative bytes read from all disks.",
+	"disk_write_bytes":        "Cumulative bytes written to all disks.",
+	"disk_read_count":         "Cumulative read operations on all disks.",
+	"disk_write_count":        "Cumulative write operations on all disks.",
+	"disk_iops_in_progress":   "Disk I/O operations currently in progress.",
+	"net_bytes_recv":          "Cumulative bytes received on all interfaces.",
+	"net_bytes_sent":          "Cumulative bytes sent on all interfaces.",
+	"net_packets_recv":        "Cumulative packets received on all interfaces.",
+	"net_packets_sent":        "Cumulative packets sent on all interfaces.",
+	"net_microburst_events":   "Max microburst events seen in the last report window.",
+	"net_burst_p95_rate":      "95th percentile packet rate during bursts.",
+	"kvm_total_vms":           "Number of defined KVM guests.",
+	"kvm_active_vms":          "Number of running KVM guests.",
+	"kvm_alloc_vcpu":          "vCPUs allocated to KVM guests.",
+	"kvm_alloc_mem_bytes":     "Memory allocated to KVM guests in bytes.",
+	"ping_min_rtt_ms":         "Minimum probe RTT to the target in milliseconds.",
+	"ping_avg_rtt_ms":         "Average probe RTT to the target in milliseconds.",
+	"ping_max_rtt_ms":         "Maximum probe RTT to the target in milliseconds.",
+	"ping_loss_ratio":         "Probe packet loss ratio to the target (0-1).",
+	"ping_jitter_ms":          "RFC 3550 interarrival jitter of udp probes in milliseconds.",
+	"ping_reordered":          "Out-of-order udp probe replies in the last round.",
+	"ping_duplicates":         "Duplicate udp probe replies in the last round.",
+	"ping_loss_forward_ratio": "Udp probe loss on the way to the reflector (0-1).",
+	"ping_loss_reverse_ratio": "Udp probe loss on the way back from the reflector (0-1).",
+	"ping_mos":                "Voice quality score estimated from udp probe latency, jitter and loss (1-4.5).",
+
+	"storage_queue_depth":             "Reports waiting in the storage ingest queue.",
+	"storage_queue_capacity":          "Capacity of the storage ingest queue.",
+	"storage_reports_written_total":   "Reports committed by the storage ingest queue.",
+	"storage_reports_failed_total":    "Reports lost because their batch failed to commit.",
+	"storage_reports_rejected_total":  "Reports rejected because the ingest queue was full or closed.",
+	"storage_batches_total":           "Batches committed by the storage ingest queue.",
+	"storage_last_flush_seconds":      "Duration of the latest batch commit.",
+	"storage_avg_flush_seconds":       "Average duration of batch commits.",
+	"storage_max_flush_seconds":       "Longest batch commit observed.",
+	"storage_last_ingest_lag_seconds": "Time from enqueue to commit for the oldest report of the latest batch.",
+}
+
+// metricTypes 非 gauge 的指标类型。节点上报的累计值按 counter 输出，名称补 _total 后缀，
+// 存储与查询接口仍使用原名
+var metricTypes = map[string]string{
+	"disk_read_bytes":                "counter",
+	"disk_write_bytes":               "counter",
+	"disk_read_count":                "counter",
+	"disk_write_count":               "counter",
+	"net_bytes_recv":                 "counter",
+	"net_bytes_sent":                 "counter",
+	"net_packets_recv":               "counter",
+	"net_packets_sent":               "counter",
+	"storage_reports_written_total":  "counter",
+	"storage_reports_failed_total":   "counter",
+	"storage_reports_rejected_total": "counter",
+	"storage_batches_total":          "counter",
+}
+
+// promSeries 一条待输出的序列
+type promSeries struct {
+	labels string
+	value  float64
+}
+
+// handleMetrics 以 Prometheus 文本格式输出所有节点最新一帧的指标
+func (s *HttpServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UnixMilli()
+	reports := s.latest.Snapshot()
+
+	// 按指标名归并，保证同名序列连续输出 (exposition 格式要求)
+	families := make(map[string][]promSeries)
+	for _, rep := range reports {
+		up := 0.0
+		if now-rep.LastSeen < storage.OnlineTimeoutMs {
+			up = 1
+		}
+		if s.nodeState != nil {
+			if st, ok := s.nodeState.State(rep.NodeID); ok {
+				up = st.State.Up()
+			}
+		}
+		nodeLabels := formatLabels(rep.NodeID, nil)
+		families["node_up"] = append(families["node_up"], promSeries{nodeLabels, up})
+		families["node_last_seen_age_seconds"] = append(families["node_last_seen_age_seconds"],
+			promSeries{nodeLabels, float64(now-rep.LastSeen) / 1000})
+		families["node_last_report_timestamp_seconds"] = append(families["node_last_report_timestamp_seconds"],
+			promSeries{nodeLabels, float64(rep.Timestamp) / 1000})
+
+		for _, smp := range rep.Samples {
+			families[smp.Name] = append(families[smp.Name], promSeries{formatLabels(rep.NodeID, smp.Labels), smp.Value})
+		}
+	}
+
+	// 主控自身的存储写入管道
+	if provider, ok := s.cache.(storage.IngestStatsProvider); ok {
+		st := provider.IngestStats()
+		for name, v := range map[string]float64{
+			"storage_queue_depth":             float64(st.QueueDepth),
+			"storage_queue_capacity":          float64(st.QueueCapacity),
+			"storage_reports_written_total":   float64(st.Written),
+			"storage_reports_failed_total":    float64(st.Failed),
+			"storage_reports_rejected_total":  float64(st.Rejected),
+			"storage_batches_total":           float64(st.Batches),
+			"storage_last_flush_seconds":      st.LastFlushMs / 1000,
+			"storage_avg_flush_seconds":       st.AvgFlushMs / 1000,
+			"storage_max_flush_seconds":       st.MaxFlushMs / 1000,
+			"storage_last_ingest_lag_seconds": st.LastLagMs / 1000,
+		} {
+			families[name] = []promSeries{{"", v}}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		help, ok := metricHelp[name]
+		if !ok {
+			help = name
+		}
+		typ, ok := metricTypes[name]
+		if !ok {
+			typ = "gauge"
+		}
+		exposed := name
+		if typ == "counter" && !strings.HasSuffix(name, "_total") {
+			exposed += "_total"
+		}
+		fmt.Fprintf(bw, "# HELP %s%s %s\n", metricPrefix, exposed, help)
+		fmt.Fprintf(bw, "# TYPE %s%s %s\n", metricPrefix, exposed, typ)
+		for _, ps := range families[name] {
+			fmt.Fprintf(bw, "%s%s%s %s\n", metricPrefix, exposed, ps.labels, strconv.FormatFloat(ps.value, 'g', -1, 64))
+		}
+	}
+	bw.Flush()
+}
+
+// formatLabels 拼装 {node_id="..",k="v"}，标签按名称排序以保持输出稳定
+func formatLabels(nodeID string, extra map[string]string) string {
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(`{node_id="`)
+	sb.WriteString(escapeLabelValue(nodeID))
+	sb.WriteByte('"')
+	for _, k := range keys {
+		sb.WriteByte(',')
+		sb.WriteString(k)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(extra[k]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelEscaper.Replace(v)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/silence"
+)
+
+// EnableSilences 打开 /api/silences、/api/maintenance-windows 与 /api/maintenance
+func (s *HttpServer) EnableSilences(store *silence.Store) {
+	s.silences = store
+}
+
+// maxSilenceBody 创建静默 / 维护窗口的请求体上限
+const maxSilenceBody = 64 * 1024
+
+// handleSilences GET 列出静默 (可按 state 过滤)，POST 创建或按 id 更新
+func (s *HttpServer) handleSilences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		state := r.URL.Query().Get("state")
+		now := time.Now().UnixMilli()
+		out := []*silence.Silence{}
+		for _, sil := range s.silences.Silences() {
+			if state == "" || sil.State(now) == state {
+				out = append(out, sil)
+			}
+		}
+		json.NewEncoder(w).Encode(out)
+
+	case http.MethodPost:
+		if !checkMutation(w, r) {
+			return
+		}
+		var sil silence.Silence
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSilenceBody)).Decode(&sil); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		created := sil.ID == ""
+		saved, err := s.silences.PutSilence(sil)
+		if !writeSilenceError(w, err) {
+			return
+		}
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(saved)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSilence DELETE /api/silences/<id> 立即结束静默
+func (s *HttpServer) handleSilence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	err := s.silences.ExpireSilence(strings.TrimPrefix(r.URL.Path, "/api/silences/"))
+	if writeSilenceError(w, err) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleWindows GET 列出维护窗口，POST 创建或按 id 更新
+func (s *HttpServer) handleWindows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		json.NewEncoder(w).Encode(s.silences.Windows())
+
+	case http.MethodPost:
+		if !checkMutation(w, r) {
+			return
+		}
+		var win silence.Window
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSilenceBody)).Decode(&win); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		created := win.ID == ""
+		saved, err := s.silences.PutWindow(win)
+		if !writeSilenceError(w, err) {
+			return
+		}
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(saved)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWindow DELETE /api/maintenance-windows/<id> 删除维护窗口
+func (s *HttpServer) handleWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	err := s.silences.DeleteWindow(strings.TrimPrefix(r.URL.Path, "/api/maintenance-windows/"))
+	if writeSilenceError(w, err) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleMaintenance 各节点当前的维护状态 {node_id: [...]}，不在维护中的节点不出现
+func (s *HttpServer) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	nodes, err := s.cache.GetNodes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out := make(map[string][]silence.Maintenance)
+	for _, n := range nodes {
+		if m := s.silences.NodeMaintenance(n.NodeID); len(m) > 0 {
+			out[n.NodeID] = m
+		}
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// writeSilenceError 写出错误响应，无错误时返回 true
+func writeSilenceError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, silence.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, silence.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/sla"
+)
+
+// EnableSLA 打开 /api/sla 与可打印的 /api/sla/report
+func (s *HttpServer) EnableSLA(r *sla.Reporter) {
+	s.sla = r
+}
+
+// slaQuery 解析报表参数
+//
+//	node_id:     逗号分隔，省略表示全部节点
+//	month:       "2026-09"，按报表时区取整月；与 from/to 二选一
+//	from/to:     同 /api/metrics，默认最近 30 天
+//	granularity: day / month，默认 62 天内按天
+//	target:      可用性目标百分比，默认取配置
+func (s *HttpServer) slaQuery(q url.Values) (sla.Query, error) {
+	var sq sla.Query
+	if v := q.Get("node_id"); v != "" {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				sq.Nodes = append(sq.Nodes, id)
+			}
+		}
+	}
+	if v := q.Get("month"); v != "" {
+		m, err := time.ParseInLocation("2006-01", v, s.sla.Location())
+		if err != nil {
+			return sq, errors.New("invalid month, want YYYY-MM")
+		}
+		sq.From, sq.To = m.UnixMilli(), m.AddDate(0, 1, 0).UnixMilli()
+	} else {
+		var err error
+		if sq.From, sq.To, err = parseTimeRange(q, 30*24*time.Hour); err != nil {
+			return sq, err
+		}
+	}
+	sq.Granularity = sla.Granularity(q.Get("granularity"))
+	if v := q.Get("target"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return sq, errors.New("invalid target")
+		}
+		sq.Target = t
+	}
+	return sq, nil
+}
+
+func (s *HttpServer) slaReport(w http.ResponseWriter, r *http.Request) (*sla.Report, bool) {
+	q, err := s.slaQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	rep, err := s.sla.Report(q)
+	if errors.Is(err, sla.ErrInvalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return rep, true
+}
+
+// handleSLA 可用性报表 JSON
+func (s *HttpServer) handleSLA(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	rep, ok := s.slaReport(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleSLAReport 与 /api/sla 参数相同，输出可打印的 HTML
+func (s *HttpServer) handleSLAReport(w http.ResponseWriter, r *http.Request) {
+	rep, ok := s.slaReport(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := rep.WriteHTML(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
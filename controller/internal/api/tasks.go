@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/tasks"
+)
+
+// EnableTasks 打开 /api/tasks，按需向节点下发诊断任务
+func (s *HttpServer) EnableTasks(m *tasks.Manager) {
+	s.tasks = m
+}
+
+const (
+	// maxTaskBody 提交任务的请求体上限
+	maxTaskBody = 16 * 1024
+	// maxTaskWait wait 参数的上限
+	maxTaskWait = 3 * time.Minute
+)
+
+// taskRequest POST /api/tasks 的请求体
+type taskRequest struct {
+	NodeID  string `json:"node_id"`
+	Type    string `json:"type"` // tcpping / udp / ping / traceroute / http
+	Target  string `json:"target"`
+	Count   int    `json:"count,omitempty"`   // 探测次数，traceroute 为最大跳数
+	Timeout string `json:"timeout,omitempty"` // 如 "15s"
+	By      string `json:"by,omitempty"`
+}
+
+// taskList GET /api/tasks 的响应体
+type taskList struct {
+	Nodes []string     `json:"nodes"` // 当前可接收任务的节点
+	Tasks []tasks.Task `json:"tasks"`
+}
+
+// parseWait 解析 wait 参数，空表示不等待
+func parseWait(r *http.Request) (time.Duration, error) {
+	v := r.URL.Query().Get("wait")
+	if v == "" {
+		return 0, nil
+	}
+	d, err := parseDuration(v)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid wait")
+	}
+	return min(d, maxTaskWait), nil
+}
+
+// writeTask 按需等待任务结束后输出；未结束时返回 202
+func (s *HttpServer) writeTask(w http.ResponseWriter, r *http.Request, t tasks.Task, wait time.Duration) {
+	if wait > 0 && !t.State.Finished() {
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		defer cancel()
+		var err error
+		if t, err = s.tasks.Wait(ctx, t.ID); !writeTaskError(w, err) {
+			return
+		}
+	}
+	if !t.State.Finished() {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// handleTasks GET 列出最近的任务 (node_id、limit 可选，limit 默认 100)；
+// POST 提交任务，wait=30s 时阻塞到任务结束或超时。任务由节点对任意目标发起请求，提交需鉴权
+func (s *HttpServer) handleTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		q := r.URL.Query()
+		limit := 100
+		if v := q.Get("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(taskList{
+			Nodes: s.tasks.Nodes(),
+			Tasks: s.tasks.Tasks(q.Get("node_id"), limit),
+		})
+
+	case http.MethodPost:
+		if !checkMutation(w, r) || !s.authorize(w, r) {
+			return
+		}
+		wait, err := parseWait(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req taskRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTaskBody)).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		spec := tasks.Spec{NodeID: req.NodeID, Type: req.Type, Target: req.Target, Count: req.Count, By: req.By}
+		if req.Timeout != "" {
+			if spec.Timeout, err = parseDuration(req.Timeout); err != nil {
+				http.Error(w, "invalid timeout: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if spec.By == "" {
+			spec.By = "api"
+		}
+		t, err := s.tasks.Submit(spec)
+		if writeTaskError(w, err) {
+			s.writeTask(w, r, t, wait)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTask GET /api/tasks/{id}，wait=30s 时阻塞到任务结束或超时
+func (s *HttpServer) handleTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	wait, err := parseWait(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := s.tasks.Get(strings.TrimPrefix(r.URL.Path, "/api/tasks/"))
+	if writeTaskError(w, err) {
+		s.writeTask(w, r, t, wait)
+	}
+}
+
+func writeTaskError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, tasks.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, tasks.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, tasks.ErrNotConnected):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, tasks.ErrBusy):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/tasks"
+	"github.com/geelinx-ltd/geegee/controller/internal/throughput"
+)
+
+// EnableThroughput 打开 /api/throughput，节点间吞吐测试
+func (s *HttpServer) EnableThroughput(t *throughput.Tester) {
+	s.throughput = t
+}
+
+// throughputRequest POST /api/throughput 的请求体
+type throughputRequest struct {
+	Server   string  `json:"server"`             // 接收端节点
+	Client   string  `json:"client"`             // 发送端节点
+	Protocol string  `json:"protocol,omitempty"` // tcp (默认) / udp
+	Duration string  `json:"duration,omitempty"` // 如 "10s"
+	Bytes    int64   `json:"bytes,omitempty"`    // 字节预算
+	RateMbps float64 `json:"rate_mbps,omitempty"`
+	By       string  `json:"by,omitempty"`
+}
+
+// writeThroughput 按需等待测试结束后输出；未结束时返回 202
+func (s *HttpServer) writeThroughput(w http.ResponseWriter, r *http.Request, t throughput.Test, wait time.Duration) {
+	if wait > 0 && t.State == throughput.StateRunning {
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		defer cancel()
+		var err error
+		if t, err = s.throughput.Wait(ctx, t.ID); !writeThroughputError(w, err) {
+			return
+		}
+	}
+	if t.State == throughput.StateRunning {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// handleThroughput GET 列出测试历史 (node_id、limit 可选，limit 默认 100)；
+// POST 发起测试，wait=30s 时阻塞到测试结束或超时。测试会产生大量流量，发起需鉴权
+func (s *HttpServer) handleThroughput(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		q := r.URL.Query()
+		limit := 100
+		if v := q.Get("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		json.NewEncoder(w).Encode(s.throughput.Tests(q.Get("node_id"), limit))
+
+	case http.MethodPost:
+		if !checkMutation(w, r) || !s.authorize(w, r) {
+			return
+		}
+		wait, err := parseWait(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req throughputRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTaskBody)).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		spec := throughput.Spec{
+			Server:   req.Server,
+			Client:   req.Client,
+			Protocol: req.Protocol,
+			MaxBytes: req.Bytes,
+			RateBps:  int64(req.RateMbps * 1e6),
+			By:       req.By,
+		}
+		if req.Duration != "" {
+			if spec.Duration, err = parseDuration(req.Duration); err != nil {
+				http.Error(w, "invalid duration: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if spec.By == "" {
+			spec.By = "api"
+		}
+		t, err := s.throughput.Start(spec)
+		if writeThroughputError(w, err) {
+			s.writeThroughput(w, r, t, wait)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleThroughputTest GET /api/throughput/{id}，wait=30s 时阻塞到测试结束或超时
+func (s *HttpServer) handleThroughputTest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	wait, err := parseWait(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := s.throughput.Get(strings.TrimPrefix(r.URL.Path, "/api/throughput/"))
+	if writeThroughputError(w, err) {
+		s.writeThroughput(w, r, t, wait)
+	}
+}
+
+// writeThroughputError 除自身的错误外，下发任务时的错误按 /api/tasks 的方式映射
+func writeThroughputError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, throughput.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, throughput.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, throughput.ErrBusy):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, tasks.ErrBusy):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		return writeTaskError(w, err)
+	}
+	return false
+}
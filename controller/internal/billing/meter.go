@@ -0,0 +1,462 @@
+// Package billing 按 95 计费口径统计流量：由节点上报的累计收发字节数算出每 5 分钟的平均速率，
+// 再按计费周期取第 95 百分位与总流量，便于与上游账单对账
+package billing
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	_ "modernc.org/sqlite" // 纯 Go SQLite 驱动，无 CGO 依赖
+)
+
+// SlotMs 计费采样间隔：5 分钟
+const SlotMs = int64(5 * time.Minute / time.Millisecond)
+
+// TotalInterface 整机合计使用的接口名，只汇总计费的 (物理) 网卡
+const TotalInterface = "total"
+
+// nodeCounter 节点顶层累计计数的基线，只在没有计费网卡时用于合计，不产生样本
+const nodeCounter = ""
+
+// Options 计量参数
+type Options struct {
+	Path          string         // 计费库文件
+	Retention     time.Duration  // 5 分钟样本保留时长
+	FlushInterval time.Duration  // 累计量落盘周期
+	CycleDay      int            // 计费周期起始日，1-31
+	Location      *time.Location // 计费周期边界所在时区，默认主控本地时区
+	// ExcludeInterfaces 不计费的网卡名通配符 (path.Match 语法)。节点标记为虚拟的网卡总是排除，
+	// 这里用于旧版节点 (未标记) 或需要额外排除的网卡，如管理网口
+	ExcludeInterfaces []string
+}
+
+func (o *Options) applyDefaults() {
+	if o.Path == "" {
+		o.Path = "./data/billing.db"
+	}
+	if o.Retention <= 0 {
+		o.Retention = 400 * 24 * time.Hour
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 10 * time.Second
+	}
+	if o.CycleDay < 1 || o.CycleDay > 31 {
+		o.CycleDay = 1
+	}
+	if o.Location == nil {
+		o.Location = time.Local
+	}
+}
+
+type seriesKey struct {
+	node, iface string
+}
+
+// counter 某个序列上一次看到的累计计数
+type counter struct {
+	ts         int64
+	recv, sent uint64
+}
+
+type slotKey struct {
+	seriesKey
+	slot int64
+}
+
+// slotAcc 一个 5 分钟槽内已计入的字节数与被计数覆盖的时长
+type slotAcc struct {
+	in, out float64
+	covered int64
+}
+
+// Meter 作为 Sink 接收上报，把相邻两次累计计数之差按时间均摊到所跨的各个 5 分钟槽，定期累加写入计费库。
+// 计数回退 (节点重启、网卡重置) 时丢弃该段差值，只重设基线
+type Meter struct {
+	opts Options
+	db   *sql.DB
+
+	mu       sync.Mutex
+	counters map[seriesKey]counter
+	dirty    map[seriesKey]bool
+	pending  map[slotKey]*slotAcc
+	flushMu  sync.Mutex // 串行化落盘，避免计数基线被较旧的快照覆盖
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewMeter 打开计费库并恢复各序列的计数基线，主控重启期间的流量在下次上报时补记
+func NewMeter(opts Options) (*Meter, error) {
+	opts.applyDefaults()
+	for _, p := range opts.ExcludeInterfaces {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude_interfaces pattern %q: %w", p, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+opts.Path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS traffic_5m (
+			node_id    TEXT    NOT NULL,
+			iface      TEXT    NOT NULL,
+			slot       INTEGER NOT NULL,
+			bytes_in   REAL    NOT NULL,
+			bytes_out  REAL    NOT NULL,
+			covered_ms INTEGER NOT NULL,
+			PRIMARY KEY (node_id, iface, slot)
+		) WITHOUT ROWID;
+		CREATE TABLE IF NOT EXISTS counters (
+			node_id    TEXT    NOT NULL,
+			iface      TEXT    NOT NULL,
+			timestamp  INTEGER NOT NULL,
+			bytes_recv INTEGER NOT NULL,
+			bytes_sent INTEGER NOT NULL,
+			PRIMARY KEY (node_id, iface)
+		) WITHOUT ROWID;
+	`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init billing schema: %w", err)
+	}
+
+	m := &Meter{
+		opts:     opts,
+		db:       db,
+		counters: make(map[seriesKey]counter),
+		dirty:    make(map[seriesKey]bool),
+		pending:  make(map[slotKey]*slotAcc),
+		stop:     make(chan struct{}),
+	}
+	if err := m.loadCounters(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("load billing counters: %w", err)
+	}
+	m.wg.Add(1)
+	go m.loop()
+	return m, nil
+}
+
+// Cycle 计费周期
+func (m *Meter) Cycle() Cycle {
+	return Cycle{Day: m.opts.CycleDay, Location: m.opts.Location}
+}
+
+func (m *Meter) loadCounters() error {
+	rows, err := m.db.Query(`SELECT node_id, iface, timestamp, bytes_recv, bytes_sent FROM counters`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k seriesKey
+		var c counter
+		var recv, sent int64
+		if err := rows.Scan(&k.node, &k.iface, &c.ts, &recv, &sent); err != nil {
+			return err
+		}
+		c.recv, c.sent = uint64(recv), uint64(sent)
+		m.counters[k] = c
+	}
+	return rows.Err()
+}
+
+// Ingest 实现 storage.Sink
+func (m *Meter) Ingest(req *pb.ReportRequest) error {
+	nw := req.GetNet()
+	if nw == nil {
+		return nil
+	}
+	ts := req.GetTimestamp()
+	if ts == 0 {
+		ts = time.Now().UnixMilli()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := seriesKey{req.NodeId, TotalInterface}
+	prev, seen := m.counters[total]
+	if seen && ts <= prev.ts {
+		return nil // 乱序或重复的上报
+	}
+	m.counters[total] = counter{ts: ts}
+	m.dirty[total] = true
+
+	// 合计由各计费网卡的增量相加，网卡增减、计数回退或排除规则变化只影响该网卡自身。
+	// 节点未上报逐网卡计数或全部被排除时退回顶层计数
+	addTotal := func(from int64, in, out float64) {
+		// 网卡的基线早于上次上报 (期间未上报、被排除或使用顶层计数) 时，只计入本次上报间隔内的份额
+		if seen && from < prev.ts {
+			share := float64(ts-prev.ts) / float64(ts-from)
+			from, in, out = prev.ts, in*share, out*share
+		}
+		m.spread(total, from, ts, in, out, false)
+	}
+	billed := 0
+	for _, nic := range nw.GetInterfaces() {
+		if !m.billed(nic) {
+			continue
+		}
+		billed++
+		k := seriesKey{req.NodeId, nic.GetName()}
+		if from, in, out, ok := m.observe(k, ts, nic.GetBytesRecv(), nic.GetBytesSent()); ok {
+			m.spread(k, from, ts, in, out, true)
+			addTotal(from, in, out)
+		}
+	}
+	if billed == 0 {
+		k := seriesKey{req.NodeId, nodeCounter}
+		if from, in, out, ok := m.observe(k, ts, nw.GetBytesRecv(), nw.GetBytesSent()); ok {
+			addTotal(from, in, out)
+		}
+	}
+	// 合计的覆盖时长按整机上报间隔计
+	if seen {
+		m.spread(total, prev.ts, ts, 0, 0, true)
+	}
+	return nil
+}
+
+// billed 网卡是否计费：虚拟网卡的流量已经计入物理网卡，重复计入会让合计翻倍
+func (m *Meter) billed(nic *pb.NetInterface) bool {
+	if nic.GetVirtual() {
+		return false
+	}
+	for _, p := range m.opts.ExcludeInterfaces {
+		if ok, _ := path.Match(p, nic.GetName()); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// observe 记录一次累计计数，返回与上一次计数之间的时间起点与字节差。
+// 首次出现、时间未前进或计数回退时只重设基线，ok 为 false。调用方持有 m.mu
+func (m *Meter) observe(k seriesKey, ts int64, recv, sent uint64) (from int64, in, out float64, ok bool) {
+	prev, seen := m.counters[k]
+	m.counters[k] = counter{ts: ts, recv: recv, sent: sent}
+	m.dirty[k] = true
+	if !seen || ts <= prev.ts || recv < prev.recv || sent < prev.sent {
+		return 0, 0, 0, false
+	}
+	return prev.ts, float64(recv - prev.recv), float64(sent - prev.sent), true
+}
+
+// spread 把 (from, to] 内的字节数按时长比例分摊到所跨的各个槽，cover 为 true 时同时计入覆盖时长
+func (m *Meter) spread(k seriesKey, from, to int64, in, out float64, cover bool) {
+	span := float64(to - from)
+	for start := from - from%SlotMs; start < to; start += SlotMs {
+		overlap := min(to, start+SlotMs) - max(from, start)
+		if overlap <= 0 {
+			continue
+		}
+		share := float64(overlap) / span
+		sk := slotKey{k, start}
+		acc, ok := m.pending[sk]
+		if !ok {
+			acc = &slotAcc{}
+			m.pending[sk] = acc
+		}
+		acc.in += in * share
+		acc.out += out * share
+		if cover {
+			acc.covered += overlap
+		}
+	}
+}
+
+func (m *Meter) loop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.opts.FlushInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.flush(); err != nil {
+				log.Printf("[Billing] flush failed: %v", err)
+			}
+		case <-cleanup.C:
+			cutoff := time.Now().Add(-m.opts.Retention).UnixMilli()
+			if _, err := m.db.Exec(`DELETE FROM traffic_5m WHERE slot < ?`, cutoff); err != nil {
+				log.Printf("[Billing] cleanup failed: %v", err)
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// flush 把累计量加到计费库中对应的槽上，并保存最新计数基线
+func (m *Meter) flush() error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[slotKey]*slotAcc)
+	counters := make(map[seriesKey]counter, len(m.dirty))
+	for k := range m.dirty {
+		counters[k] = m.counters[k]
+	}
+	m.dirty = make(map[seriesKey]bool)
+	m.mu.Unlock()
+	if len(pending) == 0 && len(counters) == 0 {
+		return nil
+	}
+
+	err := m.write(pending, counters)
+	if err != nil {
+		// 写入失败时放回，下次合并重试
+		m.mu.Lock()
+		for sk, acc := range pending {
+			if cur, ok := m.pending[sk]; ok {
+				cur.in += acc.in
+				cur.out += acc.out
+				cur.covered += acc.covered
+			} else {
+				m.pending[sk] = acc
+			}
+		}
+		for k := range counters {
+			m.dirty[k] = true
+		}
+		m.mu.Unlock()
+	}
+	return err
+}
+
+func (m *Meter) write(pending map[slotKey]*slotAcc, counters map[seriesKey]counter) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	slotStmt, err := tx.Prepare(`
+		INSERT INTO traffic_5m (node_id, iface, slot, bytes_in, bytes_out, covered_ms) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (node_id, iface, slot) DO UPDATE SET
+			bytes_in = bytes_in + excluded.bytes_in,
+			bytes_out = bytes_out + excluded.bytes_out,
+			covered_ms = MIN(covered_ms + excluded.covered_ms, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer slotStmt.Close()
+	for sk, acc := range pending {
+		if _, err := slotStmt.Exec(sk.node, sk.iface, sk.slot, acc.in, acc.out, min(acc.covered, SlotMs), SlotMs); err != nil {
+			return err
+		}
+	}
+
+	counterStmt, err := tx.Prepare(`
+		INSERT INTO counters (node_id, iface, timestamp, bytes_recv, bytes_sent) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (node_id, iface) DO UPDATE SET
+			timestamp = excluded.timestamp, bytes_recv = excluded.bytes_recv, bytes_sent = excluded.bytes_sent
+	`)
+	if err != nil {
+		return err
+	}
+	defer counterStmt.Close()
+	for k, c := range counters {
+		if _, err := counterStmt.Exec(k.node, k.iface, c.ts, int64(c.recv), int64(c.sent)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// forget 丢弃节点在内存中的计数基线与未落盘的累计量，调用方持有 flushMu
+func (m *Meter) forget(nodeID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.counters {
+		if k.node == nodeID {
+			delete(m.counters, k)
+			delete(m.dirty, k)
+		}
+	}
+	for sk := range m.pending {
+		if sk.node == nodeID {
+			delete(m.pending, sk)
+		}
+	}
+}
+
+// DeleteNode 删除节点全部计费样本与计数基线
+func (m *Meter) DeleteNode(nodeID string) error {
+	if err := m.flush(); err != nil {
+		return err
+	}
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+	m.forget(nodeID)
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM traffic_5m WHERE node_id = ?`, nodeID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM counters WHERE node_id = ?`, nodeID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeNode 把 from 的 5 分钟样本累加到 into 的同名接口上；计数基线属于具体节点进程，from 的直接丢弃
+func (m *Meter) MergeNode(from, into string) error {
+	if err := m.flush(); err != nil {
+		return err
+	}
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+	m.forget(from)
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
+		INSERT INTO traffic_5m (node_id, iface, slot, bytes_in, bytes_out, covered_ms)
+		SELECT ?, iface, slot, bytes_in, bytes_out, covered_ms FROM traffic_5m WHERE node_id = ?
+		ON CONFLICT (node_id, iface, slot) DO UPDATE SET
+			bytes_in = bytes_in + excluded.bytes_in,
+			bytes_out = bytes_out + excluded.bytes_out,
+			covered_ms = MIN(covered_ms + excluded.covered_ms, ?)
+	`, into, from, SlotMs); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM traffic_5m WHERE node_id = ?`, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM counters WHERE node_id = ?`, from); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close 停止后台协程，落盘剩余累计量并关闭计费库
+func (m *Meter) Close() error {
+	close(m.stop)
+	m.wg.Wait()
+	err := m.flush()
+	if cerr := m.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
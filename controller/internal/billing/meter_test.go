@@ -0,0 +1,180 @@
+package billing
+
+import (
+	"testing"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+// newTestMeter 不打开计费库，只检查内存中待落盘的累计量
+func newTestMeter(opts Options) *Meter {
+	opts.applyDefaults()
+	return &Meter{
+		opts:     opts,
+		counters: make(map[seriesKey]counter),
+		dirty:    make(map[seriesKey]bool),
+		pending:  make(map[slotKey]*slotAcc),
+	}
+}
+
+// slots 某个序列待落盘的各槽，键为槽序号 (相对 0 点)
+func (m *Meter) slots(node, iface string) map[int64]slotAcc {
+	out := make(map[int64]slotAcc)
+	for sk, acc := range m.pending {
+		if sk.node == node && sk.iface == iface {
+			out[sk.slot/SlotMs] = *acc
+		}
+	}
+	return out
+}
+
+type nicCounter struct {
+	name       string
+	recv, sent uint64
+	virtual    bool
+}
+
+func report(ts int64, nics ...nicCounter) *pb.ReportRequest {
+	nw := &pb.NetSummary{}
+	for _, n := range nics {
+		nw.Interfaces = append(nw.Interfaces, &pb.NetInterface{Name: n.name, BytesRecv: n.recv, BytesSent: n.sent, Virtual: n.virtual})
+		nw.BytesRecv += n.recv
+		nw.BytesSent += n.sent
+	}
+	return &pb.ReportRequest{NodeId: "hk-01", Timestamp: ts, Net: nw}
+}
+
+func TestSpread(t *testing.T) {
+	const sec = int64(1000)
+	for _, tc := range []struct {
+		name     string
+		from, to int64
+		bytes    float64
+		want     map[int64]slotAcc // 槽序号 -> 入方向字节与覆盖时长
+	}{
+		{"inside one slot", 60 * sec, 120 * sec, 600, map[int64]slotAcc{0: {in: 600, covered: 60 * sec}}},
+		{"ends on boundary", 240 * sec, 300 * sec, 600, map[int64]slotAcc{0: {in: 600, covered: 60 * sec}}},
+		{"crosses boundary", 270 * sec, 330 * sec, 600, map[int64]slotAcc{0: {in: 300, covered: 30 * sec}, 1: {in: 300, covered: 30 * sec}}},
+		// 15 分钟的上报间隔 (节点短暂失联) 按时长比例分摊到 4 个槽
+		{"gap over several slots", 150 * sec, 1050 * sec, 900, map[int64]slotAcc{
+			0: {in: 150, covered: 150 * sec},
+			1: {in: 300, covered: 300 * sec},
+			2: {in: 300, covered: 300 * sec},
+			3: {in: 150, covered: 150 * sec},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestMeter(Options{})
+			m.spread(seriesKey{"hk-01", "eth0"}, tc.from, tc.to, tc.bytes, 0, true)
+			got := m.slots("hk-01", "eth0")
+			if len(got) != len(tc.want) {
+				t.Fatalf("slots %v, want %v", got, tc.want)
+			}
+			for slot, w := range tc.want {
+				if g := got[slot]; !approx(g.in, w.in) || g.covered != w.covered {
+					t.Fatalf("slot %d: %+v, want %+v", slot, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestIngestTotal(t *testing.T) {
+	const step = 60_000 // 每分钟上报一次，从第 1 分钟开始，4 次都落在槽 0 内
+	for _, tc := range []struct {
+		name    string
+		opts    Options
+		reports [][]nicCounter
+		// 合计与 eth1 在槽 0 内的入方向字节
+		total, eth1 float64
+	}{
+		{
+			name: "sum of interfaces",
+			reports: [][]nicCounter{
+				{{name: "eth0", recv: 1000}, {name: "eth1", recv: 5000}},
+				{{name: "eth0", recv: 1100}, {name: "eth1", recv: 5200}},
+				{{name: "eth0", recv: 1300}, {name: "eth1", recv: 5300}},
+			},
+			total: 600, eth1: 300,
+		},
+		{
+			// eth1 计数回退只丢弃它自己的这一段，不会让合计出现负值或整段清零
+			name: "counter reset",
+			reports: [][]nicCounter{
+				{{name: "eth0", recv: 1000}, {name: "eth1", recv: 5000}},
+				{{name: "eth0", recv: 1100}, {name: "eth1", recv: 200}},
+				{{name: "eth0", recv: 1300}, {name: "eth1", recv: 300}},
+			},
+			total: 400, eth1: 100,
+		},
+		{
+			// 新出现的网卡以首次计数为基线，不会把累计值整体计入合计
+			name: "interface added",
+			reports: [][]nicCounter{
+				{{name: "eth0", recv: 1000}},
+				{{name: "eth0", recv: 1100}, {name: "eth1", recv: 1 << 40}},
+				{{name: "eth0", recv: 1200}, {name: "eth1", recv: 1<<40 + 50}},
+			},
+			total: 250, eth1: 50,
+		},
+		{
+			// 网卡消失后合计只少了它的增量，不会因为总和下降而丢掉其它网卡
+			name: "interface removed",
+			reports: [][]nicCounter{
+				{{name: "eth0", recv: 1000}, {name: "eth1", recv: 1 << 40}},
+				{{name: "eth0", recv: 1100}},
+				{{name: "eth0", recv: 1200}},
+			},
+			total: 200,
+		},
+		{
+			name: "virtual and excluded interfaces",
+			opts: Options{ExcludeInterfaces: []string{"mgmt*"}},
+			reports: [][]nicCounter{
+				{{name: "eth0", recv: 1000}, {name: "docker0", recv: 1000, virtual: true}, {name: "mgmt0", recv: 1000}},
+				{{name: "eth0", recv: 1100}, {name: "docker0", recv: 9000, virtual: true}, {name: "mgmt0", recv: 9000}},
+			},
+			total: 100,
+		},
+		{
+			// 全部网卡被排除时退回顶层计数；切回逐网卡后旧基线只计入本次上报间隔的份额，不重复计入
+			name: "fallback switch",
+			opts: Options{ExcludeInterfaces: []string{"eth1"}},
+			reports: [][]nicCounter{
+				{{name: "eth0", recv: 1000}, {name: "eth1", recv: 1000}},
+				{{name: "eth1", recv: 1100}},
+				{{name: "eth1", recv: 1300}},
+				{{name: "eth0", recv: 1300}, {name: "eth1", recv: 1400}},
+			},
+			// 顶层计数 1100 -> 1300 计入 200；eth0 在 3 个间隔里增加 300，只计最后一个间隔的 100
+			total: 300,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newTestMeter(tc.opts)
+			for i, nics := range tc.reports {
+				m.Ingest(report(int64(i+1)*step, nics...))
+			}
+			total := m.slots("hk-01", TotalInterface)[0]
+			if !approx(total.in, tc.total) || !approx(m.slots("hk-01", "eth1")[0].in, tc.eth1) {
+				t.Fatalf("total %v eth1 %v, want %v / %v", total.in, m.slots("hk-01", "eth1")[0].in, tc.total, tc.eth1)
+			}
+			if want := int64(len(tc.reports)-1) * step; total.covered != want {
+				t.Fatalf("total covered %d, want %d", total.covered, want)
+			}
+			if len(m.slots("hk-01", nodeCounter)) != 0 {
+				t.Fatal("node counter produced samples")
+			}
+		})
+	}
+}
+
+func TestIngestOutOfOrder(t *testing.T) {
+	m := newTestMeter(Options{})
+	m.Ingest(report(120_000, nicCounter{name: "eth0", recv: 1000}))
+	m.Ingest(report(60_000, nicCounter{name: "eth0", recv: 500}))
+	m.Ingest(report(180_000, nicCounter{name: "eth0", recv: 1100}))
+	if got := m.slots("hk-01", TotalInterface)[0]; !approx(got.in, 100) || got.covered != 60_000 {
+		t.Fatalf("total %+v, want 100 bytes over 60s", got)
+	}
+}
@@ -0,0 +1,233 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ErrInvalid 查询参数不合法
+var ErrInvalid = errors.New("invalid billing query")
+
+// maxSpan 单次报表允许的最长时间范围
+const maxSpan = 400 * 24 * time.Hour
+
+// Cycle 计费周期：每月 Day 日零点 (按 Location) 起算
+type Cycle struct {
+	Day      int
+	Location *time.Location
+}
+
+// Period 包含 t 的计费周期 [start, end)
+func (c Cycle) Period(t time.Time) (start, end time.Time) {
+	t = t.In(c.Location)
+	start = c.start(t.Year(), t.Month())
+	if t.Before(start) {
+		start = c.start(t.Year(), t.Month()-1)
+	}
+	return start, c.start(start.Year(), start.Month()+1)
+}
+
+// Month 以 month 月起算的计费周期，month 为该月任意时刻
+func (c Cycle) Month(month time.Time) (start, end time.Time) {
+	month = month.In(c.Location)
+	start = c.start(month.Year(), month.Month())
+	return start, c.start(start.Year(), start.Month()+1)
+}
+
+// start 某月的周期起点，日数超过当月天数时取月末 (如 31 日在 2 月取 28/29 日)
+func (c Cycle) start(year int, month time.Month) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, c.Location)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(c.Day, last)-1)
+}
+
+// Query 一次计费报表请求
+type Query struct {
+	Nodes    []string // 空表示全部有流量记录的节点
+	From, To int64    // Unix 毫秒，[From, To)
+}
+
+// Usage 单个节点单个接口在计费周期内的用量，速率单位 bit/s，均为 5 分钟平均
+type Usage struct {
+	Interface       string  `json:"interface"`
+	Samples         int     `json:"samples"`          // 有数据的 5 分钟样本数
+	ExpectedSamples int     `json:"expected_samples"` // 周期内 (截至当前) 应有的样本数
+	P95InBps        float64 `json:"p95_in_bps"`
+	P95OutBps       float64 `json:"p95_out_bps"`
+	P95MaxBps       float64 `json:"p95_max_bps"` // 入、出两个方向 95 值中较大者，即常见的计费值
+	PeakInBps       float64 `json:"peak_in_bps"`
+	PeakOutBps      float64 `json:"peak_out_bps"`
+	BytesIn         float64 `json:"bytes_in"`
+	BytesOut        float64 `json:"bytes_out"`
+	BytesTotal      float64 `json:"bytes_total"`
+}
+
+// NodeUsage 单个节点各接口的用量，整机合计 (total) 排在最前
+type NodeUsage struct {
+	NodeID     string  `json:"node_id"`
+	Interfaces []Usage `json:"interfaces"`
+}
+
+// Report 计费报表
+type Report struct {
+	From        int64       `json:"from"`
+	To          int64       `json:"to"`
+	Timezone    string      `json:"timezone"`
+	GeneratedAt int64       `json:"generated_at"`
+	Nodes       []NodeUsage `json:"nodes"`
+}
+
+// Sample 一个 5 分钟样本
+type Sample struct {
+	Slot       int64   `json:"slot"` // 槽起点，Unix 毫秒
+	InBps      float64 `json:"in_bps"`
+	OutBps     float64 `json:"out_bps"`
+	BytesIn    float64 `json:"bytes_in"`
+	BytesOut   float64 `json:"bytes_out"`
+	CoveredPct float64 `json:"covered_pct"` // 槽内被上报计数覆盖的比例
+}
+
+func validRange(from, to int64) error {
+	if to <= from {
+		return fmt.Errorf("%w: to must be after from", ErrInvalid)
+	}
+	if to-from > maxSpan.Milliseconds() {
+		return fmt.Errorf("%w: range exceeds %v", ErrInvalid, maxSpan)
+	}
+	return nil
+}
+
+// Samples 单个节点单个接口在 [from, to) 内的 5 分钟样本，按时间升序。尚未落盘的累计量先行写入
+func (m *Meter) Samples(nodeID, iface string, from, to int64) ([]Sample, error) {
+	if err := validRange(from, to); err != nil {
+		return nil, err
+	}
+	if err := m.flush(); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query(`
+		SELECT slot, bytes_in, bytes_out, covered_ms FROM traffic_5m
+		WHERE node_id = ? AND iface = ? AND slot >= ? AND slot < ?
+		ORDER BY slot
+	`, nodeID, iface, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Sample
+	for rows.Next() {
+		var s Sample
+		var covered int64
+		if err := rows.Scan(&s.Slot, &s.BytesIn, &s.BytesOut, &covered); err != nil {
+			return nil, err
+		}
+		s.InBps, s.OutBps = rate(s.BytesIn), rate(s.BytesOut)
+		s.CoveredPct = float64(covered) / float64(SlotMs) * 100
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// Report 计算计费周期内各节点各接口的 95 值与总流量。槽按起点归属，from/to 应对齐到 5 分钟
+func (m *Meter) Report(q Query) (*Report, error) {
+	if err := validRange(q.From, q.To); err != nil {
+		return nil, err
+	}
+	if err := m.flush(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`
+		SELECT node_id, iface, bytes_in, bytes_out FROM traffic_5m
+		WHERE slot >= ? AND slot < ?
+	`, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wanted := make(map[string]bool, len(q.Nodes))
+	for _, id := range q.Nodes {
+		wanted[id] = true
+	}
+	type series struct{ in, out []float64 }
+	data := make(map[seriesKey]*series)
+	for rows.Next() {
+		var k seriesKey
+		var in, out float64
+		if err := rows.Scan(&k.node, &k.iface, &in, &out); err != nil {
+			return nil, err
+		}
+		if len(wanted) > 0 && !wanted[k.node] {
+			continue
+		}
+		s, ok := data[k]
+		if !ok {
+			s = &series{}
+			data[k] = s
+		}
+		s.in = append(s.in, in)
+		s.out = append(s.out, out)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	expected := int((min(q.To, now) - q.From + SlotMs - 1) / SlotMs)
+	rep := &Report{From: q.From, To: q.To, Timezone: m.opts.Location.String(), GeneratedAt: now, Nodes: []NodeUsage{}}
+	byNode := make(map[string]*NodeUsage)
+	for _, id := range q.Nodes {
+		byNode[id] = &NodeUsage{NodeID: id, Interfaces: []Usage{}}
+	}
+	for k, s := range data {
+		u := Usage{Interface: k.iface, Samples: len(s.in), ExpectedSamples: max(expected, 0)}
+		for i := range s.in {
+			u.BytesIn += s.in[i]
+			u.BytesOut += s.out[i]
+		}
+		u.BytesTotal = u.BytesIn + u.BytesOut
+		u.P95InBps, u.PeakInBps = percentile95(s.in)
+		u.P95OutBps, u.PeakOutBps = percentile95(s.out)
+		u.P95MaxBps = math.Max(u.P95InBps, u.P95OutBps)
+
+		n, ok := byNode[k.node]
+		if !ok {
+			n = &NodeUsage{NodeID: k.node}
+			byNode[k.node] = n
+		}
+		n.Interfaces = append(n.Interfaces, u)
+	}
+	for _, n := range byNode {
+		sort.Slice(n.Interfaces, func(i, j int) bool {
+			a, b := n.Interfaces[i].Interface, n.Interfaces[j].Interface
+			if (a == TotalInterface) != (b == TotalInterface) {
+				return a == TotalInterface
+			}
+			return a < b
+		})
+		rep.Nodes = append(rep.Nodes, *n)
+	}
+	sort.Slice(rep.Nodes, func(i, j int) bool { return rep.Nodes[i].NodeID < rep.Nodes[j].NodeID })
+	return rep, nil
+}
+
+// percentile95 返回 95 值与峰值 (bit/s)：样本升序排列后去掉最高的 5%，取剩余最大者
+func percentile95(bytes []float64) (p95, peak float64) {
+	if len(bytes) == 0 {
+		return 0, 0
+	}
+	sorted := append([]float64(nil), bytes...)
+	sort.Float64s(sorted)
+	idx := int(math.Ceil(float64(len(sorted))*0.95)) - 1
+	return rate(sorted[max(idx, 0)]), rate(sorted[len(sorted)-1])
+}
+
+// rate 5 分钟字节数换算为平均 bit/s
+func rate(bytes float64) float64 {
+	return bytes * 8 / (float64(SlotMs) / 1000)
+}
@@ -0,0 +1,87 @@
+package billing
+
+import (
+	"testing"
+	"time"
+)
+
+// bytesFor 由 bit/s 反推 5 分钟字节数
+func bytesFor(bps float64) float64 {
+	return bps * float64(SlotMs) / 1000 / 8
+}
+
+func TestPercentile95(t *testing.T) {
+	seq := func(n int) []float64 {
+		out := make([]float64, n)
+		for i := range out {
+			// 逆序写入，确认会先排序
+			out[i] = bytesFor(float64(n - i))
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name      string
+		samples   []float64
+		p95, peak float64
+	}{
+		{"empty", nil, 0, 0},
+		{"single", seq(1), 1, 1},
+		// ceil(0.95*19)=19：样本不足 20 个时不丢弃任何样本
+		{"19 samples", seq(19), 19, 19},
+		// ceil(0.95*20)=19：去掉最高的 1 个
+		{"20 samples", seq(20), 19, 20},
+		// ceil(0.95*21)=20：仍只去掉 1 个，不是 floor 得到的 19
+		{"21 samples", seq(21), 20, 21},
+		// ceil(0.95*8640)=8208：30 天的 5 分钟样本去掉最高的 432 个
+		{"30 days", seq(8640), 8208, 8640},
+		{"flat", []float64{bytesFor(5), bytesFor(5), bytesFor(5)}, 5, 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p95, peak := percentile95(tc.samples)
+			if !approx(p95, tc.p95) || !approx(peak, tc.peak) {
+				t.Fatalf("p95 %v peak %v, want %v / %v", p95, peak, tc.p95, tc.peak)
+			}
+		})
+	}
+}
+
+func approx(a, b float64) bool {
+	d := a - b
+	return d < 1e-6 && d > -1e-6
+}
+
+func TestCyclePeriod(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, shanghai) }
+	for _, tc := range []struct {
+		name       string
+		day        int
+		at         time.Time
+		start, end time.Time
+	}{
+		{"first of month", 1, date(2025, 3, 15), date(2025, 3, 1), date(2025, 4, 1)},
+		{"before cycle day", 15, date(2025, 3, 10), date(2025, 2, 15), date(2025, 3, 15)},
+		{"on cycle day", 15, date(2025, 3, 15), date(2025, 3, 15), date(2025, 4, 15)},
+		// 31 日在 2 月取月末
+		{"clamped in february", 31, date(2025, 3, 10), date(2025, 2, 28), date(2025, 3, 31)},
+		{"clamped in leap february", 31, date(2024, 3, 10), date(2024, 2, 29), date(2024, 3, 31)},
+		{"inside clamped february", 30, date(2025, 2, 28).Add(time.Hour), date(2025, 2, 28), date(2025, 3, 30)},
+		{"before clamped february", 30, date(2025, 2, 27), date(2025, 1, 30), date(2025, 2, 28)},
+		{"year boundary", 20, date(2025, 1, 5), date(2024, 12, 20), date(2025, 1, 20)},
+		// 按计费时区判断：UTC 2 月 27 日 20 点已是东八区 2 月 28 日
+		{"location", 31, time.Date(2025, 2, 27, 20, 0, 0, 0, time.UTC), date(2025, 2, 28), date(2025, 3, 31)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Cycle{Day: tc.day, Location: shanghai}
+			start, end := c.Period(tc.at)
+			if !start.Equal(tc.start) || !end.Equal(tc.end) {
+				t.Fatalf("period [%v, %v), want [%v, %v)", start, end, tc.start, tc.end)
+			}
+		})
+	}
+
+	c := Cycle{Day: 31, Location: shanghai}
+	if start, end := c.Month(date(2025, 2, 10)); !start.Equal(date(2025, 2, 28)) || !end.Equal(date(2025, 3, 31)) {
+		t.Fatalf("february cycle [%v, %v)", start, end)
+	}
+}
@@ -0,0 +1,84 @@
+// Package export 将时序查询结果流式编码为 CSV / JSON Lines / Parquet，供客户下载或离线分析
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format 导出文件格式
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat 解析 format 参数，空值按 CSV 处理
+func ParseFormat(v string) (Format, error) {
+	switch f := Format(strings.ToLower(v)); f {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatJSONL, FormatParquet:
+		return f, nil
+	case "ndjson":
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (want csv, jsonl or parquet)", v)
+}
+
+// ContentType HTTP 响应头
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Ext 文件扩展名 (不含点)
+func (f Format) Ext() string {
+	return string(f)
+}
+
+// ColumnType 列的数据类型，决定文本格式的写法与 Parquet 物理类型
+type ColumnType int
+
+const (
+	TypeFloat     ColumnType = iota // float64
+	TypeInt                         // int64
+	TypeTimestamp                   // int64 Unix 毫秒
+)
+
+// Column 导出表的一列
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Writer 逐行写出。一行的取值与列一一对应，整数列同样以 float64 传入 (毫秒时间戳在 2^53 以内不丢精度)
+type Writer interface {
+	WriteRow(row []float64) error
+	// Close 写出缓冲与文件尾，不关闭底层 io.Writer
+	Close() error
+}
+
+// NewWriter 按格式创建 Writer，表头 (如有) 立即写出
+func NewWriter(format Format, w io.Writer, cols []Column) (Writer, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("export: no columns")
+	}
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatJSONL:
+		return newJSONLWriter(w, cols), nil
+	case FormatParquet:
+		return newParquetWriter(w, cols)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
@@ -0,0 +1,287 @@
+package export
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/golang/snappy"
+)
+
+// 只实现导出所需的最小子集：扁平 schema、REQUIRED 列、PLAIN 编码、每列每个行组一个 snappy 压缩的数据页。
+// 元数据使用 Thrift Compact 协议，字段编号见 parquet-format 的 parquet.thrift
+//
+//	"PAR1" | 行组 1 各列数据页 | 行组 2 ... | FileMetaData | 元数据长度 (4 字节小端) | "PAR1"
+const (
+	parquetMagic        = "PAR1"
+	parquetRowGroupRows = 16384 // 每个行组缓冲的行数，决定导出时的内存占用
+	parquetCreatedBy    = "geegee controller"
+)
+
+// parquet.thrift 中的枚举值
+const (
+	pqTypeInt64  = 2
+	pqTypeDouble = 5
+
+	pqRepetitionRequired   = 0
+	pqConvertedTimestampMs = 9
+	pqEncodingPlain        = 0
+	pqCodecSnappy          = 1
+	pqPageTypeData         = 0
+	pqEncodingRLE          = 3
+)
+
+type parquetColumnChunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type parquetRowGroup struct {
+	columns []parquetColumnChunk
+	rows    int64
+	bytes   int64
+}
+
+type parquetWriter struct {
+	w      io.Writer
+	off    int64
+	cols   []Column
+	values [][]float64 // 当前行组按列缓冲
+	groups []parquetRowGroup
+	rows   int64
+	page   []byte
+}
+
+func newParquetWriter(w io.Writer, cols []Column) (*parquetWriter, error) {
+	pw := &parquetWriter{w: w, cols: cols, values: make([][]float64, len(cols))}
+	if err := pw.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *parquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.off += int64(n)
+	return err
+}
+
+func (pw *parquetWriter) WriteRow(row []float64) error {
+	for i := range pw.cols {
+		pw.values[i] = append(pw.values[i], row[i])
+	}
+	if len(pw.values[0]) >= parquetRowGroupRows {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+// flushRowGroup 将缓冲的行写成一个行组，每列一个数据页
+func (pw *parquetWriter) flushRowGroup() error {
+	n := len(pw.values[0])
+	if n == 0 {
+		return nil
+	}
+	rg := parquetRowGroup{rows: int64(n), columns: make([]parquetColumnChunk, len(pw.cols))}
+	for i, c := range pw.cols {
+		raw := pw.page[:0]
+		for _, v := range pw.values[i] {
+			if c.Type == TypeFloat {
+				raw = binary.LittleEndian.AppendUint64(raw, math.Float64bits(v))
+			} else {
+				raw = binary.LittleEndian.AppendUint64(raw, uint64(int64(v)))
+			}
+		}
+		pw.page = raw
+		compressed := snappy.Encode(nil, raw)
+
+		var h thriftWriter
+		h.i32(1, pqPageTypeData)
+		h.i32(2, int32(len(raw)))
+		h.i32(3, int32(len(compressed)))
+		h.structBegin(5) // DataPageHeader
+		h.i32(1, int32(n))
+		h.i32(2, pqEncodingPlain)
+		h.i32(3, pqEncodingRLE)
+		h.i32(4, pqEncodingRLE)
+		h.structEnd()
+		h.stop()
+
+		rg.columns[i] = parquetColumnChunk{
+			offset:           pw.off,
+			uncompressedSize: int64(len(h.buf) + len(raw)),
+			compressedSize:   int64(len(h.buf) + len(compressed)),
+		}
+		rg.bytes += rg.columns[i].uncompressedSize
+		if err := pw.write(h.buf); err != nil {
+			return err
+		}
+		if err := pw.write(compressed); err != nil {
+			return err
+		}
+		pw.values[i] = pw.values[i][:0]
+	}
+	pw.groups = append(pw.groups, rg)
+	pw.rows += rg.rows
+	return nil
+}
+
+func (pw *parquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+
+	var m thriftWriter
+	m.i32(1, 1) // version
+	m.listBegin(2, thriftStruct, len(pw.cols)+1)
+	m.elemBegin() // 根节点
+	m.binary(4, "schema")
+	m.i32(5, int32(len(pw.cols)))
+	m.elemEnd()
+	for _, c := range pw.cols {
+		m.elemBegin()
+		m.i32(1, physicalType(c.Type))
+		m.i32(3, pqRepetitionRequired)
+		m.binary(4, c.Name)
+		if c.Type == TypeTimestamp {
+			m.i32(6, pqConvertedTimestampMs)
+		}
+		m.elemEnd()
+	}
+	m.i64(3, pw.rows)
+	m.listBegin(4, thriftStruct, len(pw.groups))
+	for _, rg := range pw.groups {
+		m.elemBegin()
+		m.listBegin(1, thriftStruct, len(rg.columns))
+		for i, cc := range rg.columns {
+			m.elemBegin() // ColumnChunk
+			m.i64(2, cc.offset)
+			m.structBegin(3) // ColumnMetaData
+			m.i32(1, physicalType(pw.cols[i].Type))
+			m.listBegin(2, thriftI32, 1)
+			m.listI32(pqEncodingPlain)
+			m.listBegin(3, thriftBinary, 1)
+			m.listBinary(pw.cols[i].Name)
+			m.i32(4, pqCodecSnappy)
+			m.i64(5, rg.rows)
+			m.i64(6, cc.uncompressedSize)
+			m.i64(7, cc.compressedSize)
+			m.i64(9, cc.offset)
+			m.structEnd()
+			m.elemEnd()
+		}
+		m.i64(2, rg.bytes)
+		m.i64(3, rg.rows)
+		m.elemEnd()
+	}
+	m.binary(6, parquetCreatedBy)
+	m.stop()
+
+	if err := pw.write(m.buf); err != nil {
+		return err
+	}
+	footer := binary.LittleEndian.AppendUint32(nil, uint32(len(m.buf)))
+	footer = append(footer, parquetMagic...)
+	if err := pw.write(footer); err != nil {
+		return fmt.Errorf("write parquet footer: %w", err)
+	}
+	return nil
+}
+
+func physicalType(t ColumnType) int32 {
+	if t == TypeFloat {
+		return pqTypeDouble
+	}
+	return pqTypeInt64
+}
+
+// Thrift Compact 协议的类型标记
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter 只写的 Thrift Compact 编码器，字段须按编号升序写入
+type thriftWriter struct {
+	buf   []byte
+	last  int16   // 当前结构体中上一个字段编号，用于差值编码
+	stack []int16 // 嵌套结构体时保存外层的 last
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendUvarint(t.buf, zigzag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.buf = binary.AppendUvarint(t.buf, zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.buf = binary.AppendUvarint(t.buf, zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftWriter) structEnd() {
+	t.elemEnd()
+}
+
+// elemBegin / elemEnd 列表中的结构体元素没有字段头
+func (t *thriftWriter) elemBegin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) elemEnd() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf = append(t.buf, 0)
+}
+
+func (t *thriftWriter) listBegin(id int16, elem byte, n int) {
+	t.fieldHeader(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|elem)
+	t.buf = binary.AppendUvarint(t.buf, uint64(n))
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.buf = binary.AppendUvarint(t.buf, zigzag(int64(v)))
+}
+
+func (t *thriftWriter) listBinary(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
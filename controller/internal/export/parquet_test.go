@@ -0,0 +1,353 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+// 测试侧独立实现的 Thrift Compact 解码，不复用 thriftWriter，按 parquet.thrift 的字段编号读回元数据
+
+type thriftStructVal map[int16]any
+
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, fmt.Errorf("unexpected end of thrift data at %d", r.pos)
+	}
+	c := r.b[r.pos]
+	r.pos++
+	return c, nil
+}
+
+func (r *thriftReader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("bad varint at %d", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) varint() (int64, error) {
+	u, err := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1), err
+}
+
+func (r *thriftReader) value(typ byte) (any, error) {
+	switch typ {
+	case 1, 2: // bool 值在类型标记中
+		return typ == 1, nil
+	case 3:
+		c, err := r.byte()
+		return int64(int8(c)), err
+	case 4, 5, 6:
+		return r.varint()
+	case 7:
+		if r.pos+8 > len(r.b) {
+			return nil, fmt.Errorf("short double at %d", r.pos)
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos:]))
+		r.pos += 8
+		return v, nil
+	case 8:
+		n, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(r.b)-r.pos) < n {
+			return nil, fmt.Errorf("short binary at %d", r.pos)
+		}
+		s := string(r.b[r.pos : r.pos+int(n)])
+		r.pos += int(n)
+		return s, nil
+	case 9, 10:
+		h, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		n := uint64(h >> 4)
+		if n == 15 {
+			if n, err = r.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		list := make([]any, 0, n)
+		for range n {
+			v, err := r.value(h & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case 12:
+		return r.structVal()
+	}
+	return nil, fmt.Errorf("unsupported thrift type %d at %d", typ, r.pos)
+}
+
+func (r *thriftReader) structVal() (thriftStructVal, error) {
+	s := thriftStructVal{}
+	var last int16
+	for {
+		h, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if h == 0 {
+			return s, nil
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			v, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		if id <= last {
+			return nil, fmt.Errorf("field %d after %d: compact protocol requires ascending ids here", id, last)
+		}
+		if s[id], err = r.value(h & 0x0f); err != nil {
+			return nil, err
+		}
+		last = id
+	}
+}
+
+func (s thriftStructVal) int(t *testing.T, id int16) int64 {
+	t.Helper()
+	v, ok := s[id].(int64)
+	if !ok {
+		t.Fatalf("field %d: want integer, got %T", id, s[id])
+	}
+	return v
+}
+
+func (s thriftStructVal) str(t *testing.T, id int16) string {
+	t.Helper()
+	v, ok := s[id].(string)
+	if !ok {
+		t.Fatalf("field %d: want binary, got %T", id, s[id])
+	}
+	return v
+}
+
+func (s thriftStructVal) list(t *testing.T, id int16) []any {
+	t.Helper()
+	v, ok := s[id].([]any)
+	if !ok {
+		t.Fatalf("field %d: want list, got %T", id, s[id])
+	}
+	return v
+}
+
+func (s thriftStructVal) child(t *testing.T, id int16) thriftStructVal {
+	t.Helper()
+	v, ok := s[id].(thriftStructVal)
+	if !ok {
+		t.Fatalf("field %d: want struct, got %T", id, s[id])
+	}
+	return v
+}
+
+// readParquet 按 parquet-format 规范解析文件，返回列名、各列物理类型与逐行数据
+func readParquet(t *testing.T, file []byte) (schema []thriftStructVal, rows [][]float64) {
+	t.Helper()
+	if len(file) < 12 || string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatalf("missing PAR1 magic")
+	}
+	metaLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	metaStart := len(file) - 8 - metaLen
+	if metaStart < 4 {
+		t.Fatalf("footer length %d out of range", metaLen)
+	}
+	mr := &thriftReader{b: file[metaStart : len(file)-8]}
+	meta, err := mr.structVal()
+	if err != nil {
+		t.Fatalf("decode FileMetaData: %v", err)
+	}
+	if mr.pos != metaLen {
+		t.Fatalf("FileMetaData used %d of %d footer bytes", mr.pos, metaLen)
+	}
+
+	elems := meta.list(t, 2)
+	root := elems[0].(thriftStructVal)
+	if n := root.int(t, 5); int(n) != len(elems)-1 {
+		t.Fatalf("root num_children %d, schema has %d leaves", n, len(elems)-1)
+	}
+	for _, e := range elems[1:] {
+		schema = append(schema, e.(thriftStructVal))
+	}
+
+	total := meta.int(t, 3)
+	for gi, g := range meta.list(t, 4) {
+		rg := g.(thriftStructVal)
+		n := int(rg.int(t, 3))
+		chunks := rg.list(t, 1)
+		if len(chunks) != len(schema) {
+			t.Fatalf("row group %d has %d column chunks, want %d", gi, len(chunks), len(schema))
+		}
+		group := make([][]float64, n)
+		for i := range group {
+			group[i] = make([]float64, len(schema))
+		}
+		var groupBytes int64
+		for ci, c := range chunks {
+			cm := c.(thriftStructVal).child(t, 3)
+			if typ := cm.int(t, 1); typ != schema[ci].int(t, 1) {
+				t.Fatalf("column %d chunk type %d, schema type %d", ci, typ, schema[ci].int(t, 1))
+			}
+			if path := cm.list(t, 3); len(path) != 1 || path[0] != schema[ci].str(t, 4) {
+				t.Fatalf("column %d path_in_schema %v", ci, path)
+			}
+			if codec := cm.int(t, 4); codec != 1 {
+				t.Fatalf("column %d codec %d, want SNAPPY", ci, codec)
+			}
+			if nv := cm.int(t, 5); int(nv) != n {
+				t.Fatalf("column %d num_values %d, want %d", ci, nv, n)
+			}
+			off := cm.int(t, 9)
+			pr := &thriftReader{b: file[off:]}
+			ph, err := pr.structVal()
+			if err != nil {
+				t.Fatalf("decode PageHeader: %v", err)
+			}
+			if ph.int(t, 1) != 0 {
+				t.Fatalf("page type %d, want DATA_PAGE", ph.int(t, 1))
+			}
+			dph := ph.child(t, 5)
+			if int(dph.int(t, 1)) != n || dph.int(t, 2) != 0 {
+				t.Fatalf("data page header %v", dph)
+			}
+			compSize, rawSize := int(ph.int(t, 3)), int(ph.int(t, 2))
+			if got := int64(pr.pos + compSize); got != cm.int(t, 7) {
+				t.Fatalf("column %d total_compressed_size %d, page spans %d", ci, cm.int(t, 7), got)
+			}
+			if got := int64(pr.pos + rawSize); got != cm.int(t, 6) {
+				t.Fatalf("column %d total_uncompressed_size %d, page spans %d", ci, cm.int(t, 6), got)
+			}
+			groupBytes += cm.int(t, 6)
+			raw, err := snappy.Decode(nil, file[int(off)+pr.pos:int(off)+pr.pos+compSize])
+			if err != nil {
+				t.Fatalf("snappy: %v", err)
+			}
+			// REQUIRED 列无定义/重复级别，PLAIN 编码的 8 字节小端值
+			if len(raw) != rawSize || len(raw) != 8*n {
+				t.Fatalf("column %d page has %d bytes, header %d, want %d", ci, len(raw), rawSize, 8*n)
+			}
+			for i := range n {
+				bits := binary.LittleEndian.Uint64(raw[8*i:])
+				if schema[ci].int(t, 1) == pqTypeDouble {
+					group[i][ci] = math.Float64frombits(bits)
+				} else {
+					group[i][ci] = float64(int64(bits))
+				}
+			}
+		}
+		if rg.int(t, 2) != groupBytes {
+			t.Fatalf("row group %d total_byte_size %d, columns sum to %d", gi, rg.int(t, 2), groupBytes)
+		}
+		rows = append(rows, group...)
+	}
+	if int64(len(rows)) != total {
+		t.Fatalf("num_rows %d, row groups hold %d", total, len(rows))
+	}
+	return schema, rows
+}
+
+func writeParquet(t *testing.T, cols []Column, rows [][]float64) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatParquet, &buf, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rows {
+		if err := w.WriteRow(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	cols := []Column{
+		{Name: "timestamp", Type: TypeTimestamp},
+		{Name: "latency_ms", Type: TypeFloat},
+		{Name: "loss", Type: TypeInt},
+	}
+	// 跨越多个行组，最后一组不满
+	n := 2*parquetRowGroupRows + 123
+	rows := make([][]float64, n)
+	for i := range rows {
+		rows[i] = []float64{float64(1700000000000 + int64(i)*1000), float64(i)*0.25 - 7.5, float64(i%7 - 3)}
+	}
+	rows[5][1] = math.NaN()
+
+	schema, got := readParquet(t, writeParquet(t, cols, rows))
+	wantTypes := []int64{pqTypeInt64, pqTypeDouble, pqTypeInt64}
+	for i, e := range schema {
+		if e.str(t, 4) != cols[i].Name || e.int(t, 1) != wantTypes[i] || e.int(t, 3) != pqRepetitionRequired {
+			t.Fatalf("schema element %d: %v", i, e)
+		}
+		_, converted := e[6]
+		if converted != (cols[i].Type == TypeTimestamp) || converted && e.int(t, 6) != pqConvertedTimestampMs {
+			t.Fatalf("schema element %d converted_type: %v", i, e)
+		}
+	}
+	if len(got) != n {
+		t.Fatalf("read %d rows, wrote %d", len(got), n)
+	}
+	for i := range rows {
+		for j := range cols {
+			w, g := rows[i][j], got[i][j]
+			if w != g && !(math.IsNaN(w) && math.IsNaN(g)) {
+				t.Fatalf("row %d col %d: got %v, want %v", i, j, g, w)
+			}
+		}
+	}
+}
+
+// 15 列以上时 schema 与列块列表改用长格式的元素个数
+func TestParquetManyColumns(t *testing.T) {
+	cols := make([]Column, 20)
+	for i := range cols {
+		cols[i] = Column{Name: fmt.Sprintf("c%02d_%s", i, strings.Repeat("x", i)), Type: TypeFloat}
+	}
+	rows := [][]float64{make([]float64, 20), make([]float64, 20)}
+	for j := range cols {
+		rows[0][j], rows[1][j] = float64(j), -float64(j)/3
+	}
+	schema, got := readParquet(t, writeParquet(t, cols, rows))
+	if len(schema) != len(cols) {
+		t.Fatalf("schema has %d columns, want %d", len(schema), len(cols))
+	}
+	for i := range rows {
+		for j := range cols {
+			if got[i][j] != rows[i][j] {
+				t.Fatalf("row %d col %d: got %v, want %v", i, j, got[i][j], rows[i][j])
+			}
+		}
+	}
+}
+
+func TestParquetEmpty(t *testing.T) {
+	schema, got := readParquet(t, writeParquet(t, []Column{{Name: "timestamp", Type: TypeTimestamp}}, nil))
+	if len(schema) != 1 || len(got) != 0 {
+		t.Fatalf("empty export: %d columns, %d rows", len(schema), len(got))
+	}
+}
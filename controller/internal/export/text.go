@@ -0,0 +1,91 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// formatValue 整数列不带小数点，浮点列用最短表示；NaN/Inf 写为空值
+func formatValue(t ColumnType, v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return ""
+	}
+	if t != TypeFloat {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+type csvWriter struct {
+	w    *csv.Writer
+	cols []Column
+	rec  []string
+}
+
+func newCSVWriter(w io.Writer, cols []Column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), cols: cols, rec: make([]string, len(cols))}
+	for i, c := range cols {
+		cw.rec[i] = c.Name
+	}
+	if err := cw.w.Write(cw.rec); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteRow(row []float64) error {
+	for i, c := range cw.cols {
+		cw.rec[i] = formatValue(c.Type, row[i])
+	}
+	return cw.w.Write(cw.rec)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// jsonlWriter 每行一个 JSON 对象，键顺序与列顺序一致
+type jsonlWriter struct {
+	w    *bufio.Writer
+	cols []Column
+	keys []string // 预先转义好的 "name":
+	line []byte
+}
+
+func newJSONLWriter(w io.Writer, cols []Column) *jsonlWriter {
+	jw := &jsonlWriter{w: bufio.NewWriter(w), cols: cols, keys: make([]string, len(cols))}
+	for i, c := range cols {
+		jw.keys[i] = strconv.Quote(c.Name) + ":"
+	}
+	return jw
+}
+
+func (jw *jsonlWriter) WriteRow(row []float64) error {
+	line := append(jw.line[:0], '{')
+	for i, c := range jw.cols {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = append(line, jw.keys[i]...)
+		if s := formatValue(c.Type, row[i]); s != "" {
+			line = append(line, s...)
+		} else {
+			line = append(line, "null"...)
+		}
+	}
+	line = append(line, '}', '\n')
+	jw.line = line
+	if _, err := jw.w.Write(line); err != nil {
+		return fmt.Errorf("write jsonl: %w", err)
+	}
+	return nil
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.w.Flush()
+}
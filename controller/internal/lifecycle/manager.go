@@ -0,0 +1,501 @@
+// Package lifecycle 管理节点的生命周期：退役 (隐藏但保留历史)、自动归档长期未上报的节点、
+// 连同全部数据永久删除，以及节点改名后把旧 ID 的历史并入新 ID
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+var (
+	// ErrNotFound 节点不存在，或恢复时节点不处于退役 / 归档状态
+	ErrNotFound = errors.New("node not found")
+	// ErrInvalid 请求参数不合法
+	ErrInvalid = errors.New("invalid lifecycle request")
+	// ErrConflict 节点仍连着主控，删除与合并会被随后的上报重新写入
+	ErrConflict = errors.New("node is still connected")
+	// ErrIncomplete 部分组件未能完成删除或合并，可修复后重试
+	ErrIncomplete = errors.New("lifecycle action incomplete")
+)
+
+// State 节点生命周期状态。没有记录的节点均为 active
+type State string
+
+const (
+	StateActive         State = "active"
+	StateDecommissioned State = "decommissioned" // 手动退役
+	StateArchived       State = "archived"       // 超过 archive_after 未上报，自动归档
+)
+
+// 操作类型
+const (
+	ActionDecommission = "decommission"
+	ActionArchive      = "archive"
+	ActionReactivate   = "reactivate"
+	ActionDelete       = "delete"
+	ActionMerge        = "merge"
+)
+
+// Record 非 active 节点的当前状态
+type Record struct {
+	NodeID string `json:"node_id"`
+	State  State  `json:"state"`
+	Since  int64  `json:"since"` // Unix 毫秒
+	By     string `json:"by,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Step 删除 / 合并时单个组件的执行结果
+type Step struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// Event 一次生命周期操作，构成审计日志
+type Event struct {
+	NodeID string `json:"node_id"`
+	Action string `json:"action"`
+	Into   string `json:"into,omitempty"` // 合并目标
+	At     int64  `json:"at"`
+	By     string `json:"by,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Steps  []Step `json:"steps,omitempty"`
+}
+
+// Failed 是否有组件执行失败
+func (e Event) Failed() bool {
+	return slices.ContainsFunc(e.Steps, func(s Step) bool { return s.Error != "" })
+}
+
+// Listener 节点转为非 active 或重新激活时回调，在管理器锁外调用
+type Listener func(nodeID string, state State)
+
+// Options 生命周期管理参数
+type Options struct {
+	Path         string        // 状态与审计日志持久化文件，留空只保存在内存
+	ArchiveAfter time.Duration // 超过该时长未上报即自动归档，<= 0 关闭
+	EventLimit   int           // 审计日志保留条数
+}
+
+func (o *Options) applyDefaults() {
+	if o.EventLimit <= 0 {
+		o.EventLimit = 1000
+	}
+}
+
+// archiveInterval 自动归档检查周期
+const archiveInterval = time.Hour
+
+type participant struct {
+	name string
+	storage.NodeAdmin
+}
+
+type persisted struct {
+	Nodes  []*Record `json:"nodes"`
+	Events []Event   `json:"events"`
+}
+
+// Manager 节点生命周期。作为 Sink 挂在 gRPC 出口上：退役或归档的节点再次上报即自动恢复为 active
+type Manager struct {
+	opts  Options
+	store storage.Persister
+
+	mu        sync.RWMutex
+	nodes     map[string]*Record
+	events    []Event // 最新在后
+	listeners []Listener
+	connected func(nodeID string) bool
+
+	// opMu 串行化删除与合并，各组件依次执行
+	opMu         sync.Mutex
+	participants []participant
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewManager 恢复上次保存的状态；配置了 archive_after 时启动自动归档
+func NewManager(opts Options, store storage.Persister) (*Manager, error) {
+	opts.applyDefaults()
+	m := &Manager{
+		opts:      opts,
+		store:     store,
+		nodes:     make(map[string]*Record),
+		connected: func(string) bool { return false },
+		stop:      make(chan struct{}),
+	}
+	if err := m.load(); err != nil {
+		return nil, fmt.Errorf("load node lifecycle: %w", err)
+	}
+	if opts.ArchiveAfter > 0 {
+		m.wg.Add(1)
+		go m.loop()
+	}
+	return m, nil
+}
+
+func (m *Manager) load() error {
+	if m.opts.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st persisted
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	for _, r := range st.Nodes {
+		m.nodes[r.NodeID] = r
+	}
+	m.events = st.Events
+	return nil
+}
+
+// save 调用方持有写锁
+func (m *Manager) save() error {
+	if m.opts.Path == "" {
+		return nil
+	}
+	st := persisted{Nodes: make([]*Record, 0, len(m.nodes)), Events: m.events}
+	for _, r := range m.nodes {
+		st.Nodes = append(st.Nodes, r)
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.opts.Path), 0o755); err != nil {
+		return err
+	}
+	tmp := m.opts.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.opts.Path)
+}
+
+// Register 登记参与删除与合并的组件，按登记顺序执行，须在上报开始前调用
+func (m *Manager) Register(name string, p storage.NodeAdmin) {
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	m.participants = append(m.participants, participant{name: name, NodeAdmin: p})
+}
+
+// SetConnected 接入节点是否仍连着主控的判断，须在上报开始前调用
+func (m *Manager) SetConnected(f func(nodeID string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connected = f
+}
+
+// Subscribe 注册状态变化回调，须在上报开始前调用
+func (m *Manager) Subscribe(l Listener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, l)
+}
+
+func (m *Manager) publish(nodeID string, state State) {
+	m.mu.RLock()
+	listeners := m.listeners
+	m.mu.RUnlock()
+	for _, l := range listeners {
+		l(nodeID, state)
+	}
+}
+
+func (m *Manager) isConnected(nodeID string) bool {
+	m.mu.RLock()
+	f := m.connected
+	m.mu.RUnlock()
+	return f(nodeID)
+}
+
+// record 追加审计日志，调用方持有写锁
+func (m *Manager) record(ev Event) {
+	m.events = append(m.events, ev)
+	if over := len(m.events) - m.opts.EventLimit; over > 0 {
+		m.events = append([]Event(nil), m.events[over:]...)
+	}
+}
+
+// Active 节点是否处于 active
+func (m *Manager) Active(nodeID string) bool {
+	return m.State(nodeID) == StateActive
+}
+
+// State 节点的生命周期状态
+func (m *Manager) State(nodeID string) State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if r, ok := m.nodes[nodeID]; ok {
+		return r.State
+	}
+	return StateActive
+}
+
+// Records 全部非 active 节点，按 ID 排序
+func (m *Manager) Records() []Record {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Record, 0, len(m.nodes))
+	for _, r := range m.nodes {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NodeID < out[j].NodeID })
+	return out
+}
+
+// Events 审计日志，nodeID 为空时返回全部节点 (合并操作对两个节点都可见)，最新在前，最多 limit 条 (0 不限)
+func (m *Manager) Events(nodeID string, limit int) []Event {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []Event{}
+	for i := len(m.events) - 1; i >= 0; i-- {
+		ev := m.events[i]
+		if nodeID != "" && ev.NodeID != nodeID && ev.Into != nodeID {
+			continue
+		}
+		out = append(out, ev)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// Decommission 手动退役：节点从列表中隐藏、告警恢复，历史数据保留
+func (m *Manager) Decommission(nodeID, by, reason string) (Record, error) {
+	if nodeID == "" {
+		return Record{}, fmt.Errorf("%w: missing node_id", ErrInvalid)
+	}
+	// 节点仍在上报时会立即被自动恢复
+	if m.isConnected(nodeID) {
+		return Record{}, fmt.Errorf("%w: stop the node before decommissioning it", ErrConflict)
+	}
+	return m.deactivate(nodeID, StateDecommissioned, ActionDecommission, by, reason)
+}
+
+func (m *Manager) deactivate(nodeID string, state State, action, by, reason string) (Record, error) {
+	now := time.Now().UnixMilli()
+	m.mu.Lock()
+	r := &Record{NodeID: nodeID, State: state, Since: now, By: by, Reason: reason}
+	if cur, ok := m.nodes[nodeID]; ok && cur.State == state {
+		m.mu.Unlock()
+		return *cur, nil
+	}
+	m.nodes[nodeID] = r
+	m.record(Event{NodeID: nodeID, Action: action, At: now, By: by, Reason: reason})
+	err := m.save()
+	m.mu.Unlock()
+
+	log.Printf("[Lifecycle] Node [%s] %s by %s: %s", nodeID, state, by, reason)
+	m.publish(nodeID, state)
+	return *r, err
+}
+
+// Reactivate 恢复为 active。退役或归档的节点再次上报时也会自动恢复
+func (m *Manager) Reactivate(nodeID, by, reason string) error {
+	now := time.Now().UnixMilli()
+	m.mu.Lock()
+	if _, ok := m.nodes[nodeID]; !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s is not decommissioned", ErrNotFound, nodeID)
+	}
+	delete(m.nodes, nodeID)
+	m.record(Event{NodeID: nodeID, Action: ActionReactivate, At: now, By: by, Reason: reason})
+	err := m.save()
+	m.mu.Unlock()
+
+	log.Printf("[Lifecycle] Node [%s] reactivated by %s: %s", nodeID, by, reason)
+	m.publish(nodeID, StateActive)
+	return err
+}
+
+// Ingest 实现 storage.Sink：非 active 节点再次上报即自动恢复
+func (m *Manager) Ingest(req *pb.ReportRequest) error {
+	m.mu.RLock()
+	_, inactive := m.nodes[req.NodeId]
+	m.mu.RUnlock()
+	if !inactive {
+		return nil
+	}
+	if err := m.Reactivate(req.NodeId, "node", "node reported again"); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Delete 永久删除节点及其在各组件中的全部数据。先退役再逐个组件删除；
+// 有组件失败时节点保持退役，返回 ErrIncomplete，可重试；存储与各组件都不认识的节点返回 ErrNotFound
+func (m *Manager) Delete(nodeID, by, reason string) (Event, error) {
+	if nodeID == "" {
+		return Event{}, fmt.Errorf("%w: missing node_id", ErrInvalid)
+	}
+	if m.isConnected(nodeID) {
+		return Event{}, fmt.Errorf("%w: stop the node before deleting it", ErrConflict)
+	}
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	if !m.known(nodeID) {
+		return Event{}, fmt.Errorf("%w: %s", ErrNotFound, nodeID)
+	}
+
+	// 先退役：删除过程中节点已从列表隐藏、告警已恢复
+	if m.Active(nodeID) {
+		if _, err := m.deactivate(nodeID, StateDecommissioned, ActionDecommission, by, "pending delete"); err != nil {
+			return Event{}, err
+		}
+	}
+
+	ev := Event{NodeID: nodeID, Action: ActionDelete, By: by, Reason: reason}
+	for _, p := range m.participants {
+		ev.Steps = append(ev.Steps, step(p.name, p.DeleteNode(nodeID)))
+	}
+	return ev, m.finish(&ev, nodeID)
+}
+
+// Merge 节点改名后把 from 的历史并入 into，成功后 from 不再出现。into 可以正在上报
+func (m *Manager) Merge(from, into, by, reason string) (Event, error) {
+	switch {
+	case from == "" || into == "":
+		return Event{}, fmt.Errorf("%w: node_id and into are required", ErrInvalid)
+	case from == into:
+		return Event{}, fmt.Errorf("%w: cannot merge a node into itself", ErrInvalid)
+	}
+	if m.isConnected(from) {
+		return Event{}, fmt.Errorf("%w: stop node %s before merging it", ErrConflict, from)
+	}
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	if !m.known(from) {
+		return Event{}, fmt.Errorf("%w: %s", ErrNotFound, from)
+	}
+
+	if m.Active(from) {
+		if _, err := m.deactivate(from, StateDecommissioned, ActionDecommission, by, "pending merge into "+into); err != nil {
+			return Event{}, err
+		}
+	}
+
+	ev := Event{NodeID: from, Action: ActionMerge, Into: into, By: by, Reason: reason}
+	for _, p := range m.participants {
+		ev.Steps = append(ev.Steps, step(p.name, p.MergeNode(from, into)))
+	}
+	return ev, m.finish(&ev, from)
+}
+
+// known 节点是否存在：有生命周期记录、存储中有节点状态，或任一组件持有其数据。
+// 调用方持有 opMu
+func (m *Manager) known(nodeID string) bool {
+	if !m.Active(nodeID) {
+		return true
+	}
+	nodes, err := m.store.GetNodes()
+	if err != nil {
+		// 无法确认时按存在处理，由各组件自行判断
+		log.Printf("[Lifecycle] List nodes failed: %v", err)
+		return true
+	}
+	for _, n := range nodes {
+		if n.NodeID == nodeID {
+			return true
+		}
+	}
+	for _, p := range m.participants {
+		if k, ok := p.NodeAdmin.(storage.NodeKnower); ok && k.HasNode(nodeID) {
+			return true
+		}
+	}
+	return false
+}
+
+func step(name string, err error) Step {
+	s := Step{Name: name}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// finish 记录删除 / 合并结果，全部成功时节点的生命周期记录一并移除
+func (m *Manager) finish(ev *Event, nodeID string) error {
+	ev.At = time.Now().UnixMilli()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	failed := ev.Failed()
+	if !failed {
+		delete(m.nodes, nodeID)
+	}
+	m.record(*ev)
+	if err := m.save(); err != nil {
+		return err
+	}
+	if failed {
+		log.Printf("[Lifecycle] %s of node [%s] incomplete: %+v", ev.Action, nodeID, ev.Steps)
+		return ErrIncomplete
+	}
+	if ev.Into != "" {
+		log.Printf("[Lifecycle] Node [%s] merged into [%s] by %s", nodeID, ev.Into, ev.By)
+	} else {
+		log.Printf("[Lifecycle] Node [%s] deleted by %s", nodeID, ev.By)
+	}
+	return nil
+}
+
+func (m *Manager) loop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(archiveInterval)
+	defer ticker.Stop()
+	for {
+		m.archiveStale(time.Now())
+		select {
+		case <-ticker.C:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// archiveStale 归档超过 archive_after 未上报且未连接的 active 节点
+func (m *Manager) archiveStale(now time.Time) {
+	nodes, err := m.store.GetNodes()
+	if err != nil {
+		log.Printf("[Lifecycle] Archive check failed: %v", err)
+		return
+	}
+	cutoff := now.Add(-m.opts.ArchiveAfter).UnixMilli()
+	for _, n := range nodes {
+		if n.LastSeen == 0 || n.LastSeen >= cutoff || !m.Active(n.NodeID) || m.isConnected(n.NodeID) {
+			continue
+		}
+		reason := fmt.Sprintf("not seen since %s", time.UnixMilli(n.LastSeen).Format(time.RFC3339))
+		if _, err := m.deactivate(n.NodeID, StateArchived, ActionArchive, "auto", reason); err != nil {
+			log.Printf("[Lifecycle] Save state error: %v", err)
+		}
+	}
+}
+
+// Close 停止自动归档
+func (m *Manager) Close() error {
+	close(m.stop)
+	m.wg.Wait()
+	return nil
+}
@@ -0,0 +1,92 @@
+package mesh
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// Point 节点对在一个时间桶内的平均延迟与丢包
+type Point struct {
+	T        int64   `json:"t"`
+	RTT      float64 `json:"rtt_ms"`
+	Loss     float64 `json:"loss"`
+	Degraded bool    `json:"degraded"`
+}
+
+// Series 一个节点对的历史，按当前基线判定每个桶是否劣化
+type Series struct {
+	Src      string  `json:"src"`
+	Dst      string  `json:"dst"`
+	Target   string  `json:"target"`
+	Baseline float64 `json:"baseline_ms,omitempty"`
+	Degraded int     `json:"degraded"` // 劣化的桶数
+	Points   []Point `json:"points"`
+}
+
+// HistoryQuery 历史查询条件，Src / Dst 为空表示不限
+type HistoryQuery struct {
+	storage.RangeQuery
+	Src, Dst string
+	All      bool // false 时只返回至少有一个劣化桶的节点对
+}
+
+// History 从存储读取各节点对的探测历史，按劣化桶数从多到少排列
+func (m *Mesh) History(q HistoryQuery) ([]Series, error) {
+	type want struct {
+		src, dst, target string
+		baseline         float64
+	}
+	m.mu.Lock()
+	members := m.members()
+	in := make(map[string]bool, len(members))
+	for _, p := range members {
+		in[p.NodeID] = true
+	}
+	var pairs []want
+	for _, p := range m.pairs {
+		if !in[p.Src] || !in[p.Dst] || p.Target == "" {
+			continue
+		}
+		if (q.Src != "" && p.Src != q.Src) || (q.Dst != "" && p.Dst != q.Dst) {
+			continue
+		}
+		pairs = append(pairs, want{p.Src, p.Dst, p.Target, m.baseline(p)})
+	}
+	m.mu.Unlock()
+
+	out := []Series{}
+	for _, p := range pairs {
+		buckets, err := m.store.QueryPingHistory(p.src, p.target, q.RangeQuery)
+		if err != nil {
+			return nil, fmt.Errorf("query %s -> %s: %w", p.src, p.dst, err)
+		}
+		s := Series{Src: p.src, Dst: p.dst, Target: p.target, Baseline: p.baseline, Points: make([]Point, 0, len(buckets))}
+		for _, b := range buckets {
+			if b.Count == 0 {
+				continue
+			}
+			pt := Point{T: b.Timestamp, RTT: b.AvgRTT, Loss: b.Loss}
+			pt.Degraded = m.degraded(pt.RTT, pt.Loss, p.baseline)
+			if pt.Degraded {
+				s.Degraded++
+			}
+			s.Points = append(s.Points, pt)
+		}
+		if s.Degraded == 0 && !q.All {
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Degraded != out[j].Degraded {
+			return out[i].Degraded > out[j].Degraded
+		}
+		if out[i].Src != out[j].Src {
+			return out[i].Src < out[j].Src
+		}
+		return out[i].Dst < out[j].Dst
+	})
+	return out, nil
+}
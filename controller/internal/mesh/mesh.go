@@ -0,0 +1,514 @@
+// Package mesh 让参与的节点互相探测对方的公网地址，汇总为节点间延迟 / 丢包矩阵：
+// 探测目标经 probe_targets 的下发通道分配，结果从各节点的上报中按目标地址认领回对端节点
+package mesh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	"github.com/geelinx-ltd/geegee/controller/internal/nodemeta"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// TargetSource 其余的探测目标分配 (probe_targets 规则)，网格目标追加在其后
+type TargetSource interface {
+	ProbeTargets(nodeID string) []*pb.ProbeTarget
+}
+
+// Options 网格参数
+type Options struct {
+	Selector         nodemeta.Selector // 参与的节点，空为全部
+	Port             int               // 对端被探测的端口
+	Type             string            // 探测类型，默认 tcpping
+	AddressLabel     string            // 节点标签中的公网地址 (host 或 host:port)，缺省取上报流的来源地址
+	Window           time.Duration     // 矩阵当前值的平均窗口
+	BaselineHalfLife time.Duration     // 基线 EWMA 半衰期
+	MinBaseline      time.Duration     // 累计学习该时长后基线才参与判定
+	DegradedLoss     float64           // 丢包率达到该值即劣化
+	DegradedRatio    float64           // 延迟达到基线的该倍数…
+	DegradedDeltaMs  float64           // …且高出基线至少该毫秒数即劣化
+	StatePath        string            // 节点地址与各对基线的持久化文件
+}
+
+func (o *Options) applyDefaults() {
+	if o.Port <= 0 {
+		o.Port = 22
+	}
+	if o.Type == "" {
+		o.Type = "tcpping"
+	}
+	if o.AddressLabel == "" {
+		o.AddressLabel = "mesh_address"
+	}
+	if o.Window <= 0 {
+		o.Window = 5 * time.Minute
+	}
+	if o.BaselineHalfLife <= 0 {
+		o.BaselineHalfLife = 24 * time.Hour
+	}
+	if o.MinBaseline <= 0 {
+		o.MinBaseline = 30 * time.Minute
+	}
+	if o.DegradedLoss <= 0 {
+		o.DegradedLoss = 0.05
+	}
+	if o.DegradedRatio <= 1 {
+		o.DegradedRatio = 1.5
+	}
+	if o.DegradedDeltaMs <= 0 {
+		o.DegradedDeltaMs = 5
+	}
+}
+
+// maxStep 两次样本间隔超过该值时不计入基线学习时长，避免掉线期间的空白被当作学习
+const maxStep = time.Minute
+
+type sample struct {
+	t         int64
+	rtt, loss float64
+}
+
+// pair 一个有向节点对：src 探测 dst
+type pair struct {
+	Src      string  `json:"src"`
+	Dst      string  `json:"dst"`
+	Target   string  `json:"target"`
+	Last     int64   `json:"last"`
+	Baseline float64 `json:"baseline"` // 延迟 EWMA (ms)
+	Learned  float64 `json:"learned"`  // 累计学习时长 (秒)
+
+	recent []sample // Window 内的样本
+}
+
+type pairKey struct{ src, dst string }
+
+type state struct {
+	Addrs map[string]string `json:"addrs"`
+	Pairs []*pair           `json:"pairs"`
+}
+
+// Mesh 作为 Sink 认领网格探测结果，作为 StreamObserver 记下节点的来源地址，
+// 作为探测目标来源把其余参与节点下发给每个参与节点
+type Mesh struct {
+	opts  Options
+	base  TargetSource
+	store storage.Persister
+
+	mu     sync.Mutex
+	addrs  map[string]string // node_id -> 最近一次上报流的来源 IP
+	pairs  map[pairKey]*pair
+	dirty  bool
+	meta   func(nodeID string) nodemeta.Meta
+	active func(nodeID string) bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New 恢复上次保存的地址与基线。base 为 nil 时只下发网格目标
+func New(opts Options, base TargetSource, store storage.Persister) (*Mesh, error) {
+	opts.applyDefaults()
+	if err := opts.Selector.Validate(); err != nil {
+		return nil, err
+	}
+	m := &Mesh{
+		opts:   opts,
+		base:   base,
+		store:  store,
+		addrs:  make(map[string]string),
+		pairs:  make(map[pairKey]*pair),
+		meta:   func(string) nodemeta.Meta { return nodemeta.Meta{} },
+		active: func(string) bool { return true },
+		stop:   make(chan struct{}),
+	}
+	if err := m.load(); err != nil {
+		return nil, fmt.Errorf("load mesh state: %w", err)
+	}
+	m.wg.Add(1)
+	go m.loop()
+	return m, nil
+}
+
+func (m *Mesh) load() error {
+	if m.opts.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.opts.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	for id, addr := range st.Addrs {
+		m.addrs[id] = addr
+	}
+	for _, p := range st.Pairs {
+		m.pairs[pairKey{p.Src, p.Dst}] = p
+	}
+	return nil
+}
+
+func (m *Mesh) save() error {
+	if m.opts.StatePath == "" {
+		return nil
+	}
+	m.mu.Lock()
+	if !m.dirty {
+		m.mu.Unlock()
+		return nil
+	}
+	st := state{Addrs: m.addrs, Pairs: make([]*pair, 0, len(m.pairs))}
+	for _, p := range m.pairs {
+		st.Pairs = append(st.Pairs, p)
+	}
+	data, err := json.Marshal(st)
+	m.dirty = false
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.opts.StatePath), 0o755); err != nil {
+		return err
+	}
+	tmp := m.opts.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.opts.StatePath)
+}
+
+func (m *Mesh) loop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.save(); err != nil {
+				log.Printf("[Mesh] Save state error: %v", err)
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// SetNodeMeta 接入节点元数据，用于选择参与节点与读取地址标签。查询在网格锁内调用
+func (m *Mesh) SetNodeMeta(f func(nodeID string) nodemeta.Meta) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.meta = f
+}
+
+// SetActive 接入节点生命周期，已退役的节点不参与网格。查询在网格锁内调用
+func (m *Mesh) SetActive(f func(nodeID string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active = f
+}
+
+// StreamOpened 实现 server.StreamObserver：记下节点的来源地址
+func (m *Mesh) StreamOpened(nodeID, remoteAddr string) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil || host == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.addrs[nodeID] != host {
+		m.addrs[nodeID] = host
+		m.dirty = true
+	}
+}
+
+// StreamClosed 地址保留，节点重连前仍可被其余节点探测
+func (m *Mesh) StreamClosed(nodeID string, err error) {}
+
+// Member 参与网格的节点
+type Member struct {
+	NodeID      string `json:"node_id"`
+	DisplayName string `json:"display_name,omitempty"`
+	Address     string `json:"address"` // host:port
+	ip          string
+	port        int
+}
+
+// members 参与节点，按 ID 排序。调用方持有 m.mu
+func (m *Mesh) members() []Member {
+	ids := make(map[string]bool, len(m.addrs))
+	for id := range m.addrs {
+		ids[id] = true
+	}
+	out := make([]Member, 0, len(ids))
+	for id := range ids {
+		if !m.active(id) {
+			continue
+		}
+		meta := m.meta(id)
+		if !m.opts.Selector.Match(meta) {
+			continue
+		}
+		host, port := m.addrs[id], m.opts.Port
+		if v := meta.Labels[m.opts.AddressLabel]; v != "" {
+			host = v
+			if h, p, err := net.SplitHostPort(v); err == nil {
+				if n, err := strconv.Atoi(p); err == nil && n > 0 && n <= 65535 {
+					host, port = h, n
+				}
+			}
+		}
+		if host == "" {
+			continue
+		}
+		out = append(out, Member{
+			NodeID:      id,
+			DisplayName: meta.DisplayName,
+			Address:     net.JoinHostPort(host, strconv.Itoa(port)),
+			ip:          host,
+			port:        port,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NodeID < out[j].NodeID })
+	return out
+}
+
+// Members 当前参与网格的节点
+func (m *Mesh) Members() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.members()
+}
+
+// ProbeTargets 实现 server.TargetSource：probe_targets 规则分配的目标之后追加其余参与节点
+func (m *Mesh) ProbeTargets(nodeID string) []*pb.ProbeTarget {
+	var out []*pb.ProbeTarget
+	if m.base != nil {
+		out = m.base.ProbeTargets(nodeID)
+	}
+	m.mu.Lock()
+	members := m.members()
+	m.mu.Unlock()
+
+	if indexOf(members, nodeID) < 0 {
+		return out
+	}
+	seen := make(map[string]bool, len(out))
+	for _, t := range out {
+		seen[t.TargetType+"|"+storage.PingTargetKey(t.Ip, t.Port)] = true
+	}
+	for _, p := range members {
+		key := m.opts.Type + "|" + p.Address
+		if p.NodeID == nodeID || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, &pb.ProbeTarget{Ip: p.ip, Port: int32(p.port), TargetType: m.opts.Type})
+	}
+	return out
+}
+
+// indexOf 节点在参与列表中的位置，不参与时为 -1
+func indexOf(members []Member, nodeID string) int {
+	for i, p := range members {
+		if p.NodeID == nodeID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Ingest 实现 storage.Sink：把上报中发往其他参与节点的探测结果记到对应的节点对上
+func (m *Mesh) Ingest(req *pb.ReportRequest) error {
+	pings := storage.NewPingSnapshots(req)
+	if len(pings) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := m.members()
+	if indexOf(members, req.NodeId) < 0 {
+		return nil
+	}
+	byAddr := make(map[string]string, len(members))
+	for _, p := range members {
+		byAddr[p.Address] = p.NodeID
+	}
+	for _, ping := range pings {
+		dst, ok := byAddr[ping.Target]
+		if !ok || dst == req.NodeId {
+			continue
+		}
+		m.observe(req.NodeId, dst, ping, now)
+	}
+	return nil
+}
+
+// observe 调用方持有 m.mu
+func (m *Mesh) observe(src, dst string, ping storage.PingSnapshot, now int64) {
+	k := pairKey{src, dst}
+	p, ok := m.pairs[k]
+	if !ok {
+		p = &pair{Src: src, Dst: dst}
+		m.pairs[k] = p
+	}
+	p.Target = ping.Target
+
+	// 全丢包时没有有效延迟，只计丢包
+	if ping.Loss < 1 && ping.AvgRTT > 0 {
+		dt := time.Duration(now-p.Last) * time.Millisecond
+		if p.Last == 0 || dt > maxStep || dt <= 0 {
+			dt = 0
+		}
+		if p.Baseline == 0 {
+			p.Baseline = ping.AvgRTT
+		} else if dt > 0 {
+			a := 1 - math.Exp(-math.Ln2*dt.Seconds()/m.opts.BaselineHalfLife.Seconds())
+			p.Baseline += a * (ping.AvgRTT - p.Baseline)
+		}
+		p.Learned += dt.Seconds()
+	}
+	p.Last = now
+	p.recent = append(p.recent, sample{t: now, rtt: ping.AvgRTT, loss: ping.Loss})
+	cutoff := now - m.opts.Window.Milliseconds()
+	drop := 0
+	for drop < len(p.recent) && p.recent[drop].t < cutoff {
+		drop++
+	}
+	p.recent = p.recent[drop:]
+	m.dirty = true
+}
+
+// Cell 矩阵中的一格：src 到 dst 在窗口内的平均延迟与丢包
+type Cell struct {
+	Src       string  `json:"src"`
+	Dst       string  `json:"dst"`
+	Target    string  `json:"target"`
+	RTT       float64 `json:"rtt_ms"`                // 窗口内平均延迟，全丢包时为 0
+	Loss      float64 `json:"loss"`                  // 窗口内平均丢包率
+	Baseline  float64 `json:"baseline_ms,omitempty"` // 学习到的常态延迟，学习期内为 0
+	Samples   int     `json:"samples"`
+	Degraded  bool    `json:"degraded"`
+	UpdatedAt int64   `json:"updated_at"`
+}
+
+// Matrix 当前网格
+type Matrix struct {
+	Nodes    []Member `json:"nodes"`
+	Cells    []Cell   `json:"cells"`
+	WindowMs int64    `json:"window_ms"`
+	At       int64    `json:"at"`
+}
+
+// baseline 学习期满后的基线，否则为 0
+func (m *Mesh) baseline(p *pair) float64 {
+	if p.Learned < m.opts.MinBaseline.Seconds() {
+		return 0
+	}
+	return p.Baseline
+}
+
+// degraded 丢包达到阈值，或延迟相对基线既超过倍数又超过绝对差
+func (m *Mesh) degraded(rtt, loss, baseline float64) bool {
+	if loss >= m.opts.DegradedLoss {
+		return true
+	}
+	return baseline > 0 && rtt >= baseline*m.opts.DegradedRatio && rtt-baseline >= m.opts.DegradedDeltaMs
+}
+
+// Matrix 参与节点之间最近一个窗口的延迟与丢包，没有数据的节点对不出现
+func (m *Mesh) Matrix() Matrix {
+	now := time.Now().UnixMilli()
+	cutoff := now - m.opts.Window.Milliseconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := m.members()
+	in := make(map[string]bool, len(members))
+	for _, p := range members {
+		in[p.NodeID] = true
+	}
+
+	out := Matrix{Nodes: members, Cells: []Cell{}, WindowMs: m.opts.Window.Milliseconds(), At: now}
+	for _, p := range m.pairs {
+		if !in[p.Src] || !in[p.Dst] {
+			continue
+		}
+		c := Cell{Src: p.Src, Dst: p.Dst, Target: p.Target, Baseline: m.baseline(p), UpdatedAt: p.Last}
+		var rttSum float64
+		var rttN int
+		for _, s := range p.recent {
+			if s.t < cutoff {
+				continue
+			}
+			c.Samples++
+			c.Loss += s.loss
+			if s.loss < 1 && s.rtt > 0 {
+				rttSum += s.rtt
+				rttN++
+			}
+		}
+		if c.Samples == 0 {
+			continue
+		}
+		c.Loss /= float64(c.Samples)
+		if rttN > 0 {
+			c.RTT = rttSum / float64(rttN)
+		}
+		c.Degraded = m.degraded(c.RTT, c.Loss, c.Baseline)
+		out.Cells = append(out.Cells, c)
+	}
+	sort.Slice(out.Cells, func(i, j int) bool {
+		if out.Cells[i].Src != out.Cells[j].Src {
+			return out.Cells[i].Src < out.Cells[j].Src
+		}
+		return out.Cells[i].Dst < out.Cells[j].Dst
+	})
+	return out
+}
+
+// DeleteNode 忘掉节点的地址与所有相关节点对
+func (m *Mesh) DeleteNode(nodeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.addrs, nodeID)
+	for k := range m.pairs {
+		if k.src == nodeID || k.dst == nodeID {
+			delete(m.pairs, k)
+		}
+	}
+	m.dirty = true
+	return nil
+}
+
+// MergeNode 节点对的基线与具体地址相关，改名后重新学习，from 的记录直接丢弃
+func (m *Mesh) MergeNode(from, into string) error {
+	return m.DeleteNode(from)
+}
+
+// Close 停止后台协程并保存
+func (m *Mesh) Close() error {
+	close(m.stop)
+	m.wg.Wait()
+	m.mu.Lock()
+	m.dirty = true
+	m.mu.Unlock()
+	return m.save()
+}
@@ -0,0 +1,408 @@
+// Package nodemeta 保存节点的显示名、描述、标签与分组：节点随上报自带一份，主控 API 可覆盖，
+// 供 /api/nodes 过滤、探测目标分配、告警规则与静默选择节点
+package nodemeta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+var (
+	// ErrNotFound 节点没有任何元数据
+	ErrNotFound = errors.New("node metadata not found")
+	// ErrInvalid 提交的元数据未通过校验
+	ErrInvalid = errors.New("invalid node metadata")
+)
+
+// 长度上限
+const (
+	maxNameLen  = 128
+	maxDescLen  = 1024
+	maxValueLen = 256
+	maxLabels   = 64
+	maxGroups   = 64
+)
+
+// labelKeyPattern 标签名，与 Prometheus 标签名相近，另允许 . - /
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-/]{0,62}$`)
+
+// Meta 一份节点元数据
+type Meta struct {
+	DisplayName string            `json:"display_name,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Groups      []string          `json:"groups,omitempty"`
+}
+
+// clean 去掉首尾空白、去重并排序分组，丢弃不合法的条目，返回被丢弃的原因
+func (m Meta) clean() (Meta, error) {
+	var errs []error
+	out := Meta{
+		DisplayName: strings.TrimSpace(m.DisplayName),
+		Description: strings.TrimSpace(m.Description),
+	}
+	if utf8.RuneCountInString(out.DisplayName) > maxNameLen {
+		errs = append(errs, fmt.Errorf("display_name longer than %d characters", maxNameLen))
+		out.DisplayName = ""
+	}
+	if utf8.RuneCountInString(out.Description) > maxDescLen {
+		errs = append(errs, fmt.Errorf("description longer than %d characters", maxDescLen))
+		out.Description = ""
+	}
+	for k, v := range m.Labels {
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		switch {
+		case !labelKeyPattern.MatchString(k):
+			errs = append(errs, fmt.Errorf("bad label name %q", k))
+		case utf8.RuneCountInString(v) > maxValueLen:
+			errs = append(errs, fmt.Errorf("label %s longer than %d characters", k, maxValueLen))
+		case len(out.Labels) >= maxLabels:
+			errs = append(errs, fmt.Errorf("more than %d labels", maxLabels))
+		default:
+			if out.Labels == nil {
+				out.Labels = make(map[string]string)
+			}
+			out.Labels[k] = v
+		}
+	}
+	for _, g := range m.Groups {
+		g = strings.TrimSpace(g)
+		switch {
+		case g == "" || strings.Contains(g, ","):
+			errs = append(errs, fmt.Errorf("bad group name %q", g))
+		case utf8.RuneCountInString(g) > maxValueLen:
+			errs = append(errs, fmt.Errorf("group name longer than %d characters", maxValueLen))
+		case len(out.Groups) >= maxGroups:
+			errs = append(errs, fmt.Errorf("more than %d groups", maxGroups))
+		case !slices.Contains(out.Groups, g):
+			out.Groups = append(out.Groups, g)
+		}
+	}
+	sort.Strings(out.Groups)
+	return out, errors.Join(errs...)
+}
+
+func (m Meta) equal(o Meta) bool {
+	return m.DisplayName == o.DisplayName && m.Description == o.Description &&
+		maps.Equal(m.Labels, o.Labels) && slices.Equal(m.Groups, o.Groups)
+}
+
+func (m Meta) empty() bool {
+	return m.equal(Meta{})
+}
+
+// fromProto 节点上报的 NodeInfo
+func fromProto(info *pb.NodeInfo) Meta {
+	return Meta{
+		DisplayName: info.DisplayName,
+		Description: info.Description,
+		Labels:      info.Labels,
+		Groups:      info.Groups,
+	}
+}
+
+// Node 单个节点的两层元数据
+type Node struct {
+	NodeID    string `json:"node_id"`
+	Reported  Meta   `json:"reported"` // 节点启动参数，随上报更新
+	Manual    Meta   `json:"manual"`   // 主控 API 设置
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// Effective 生效的元数据：名称与描述以 API 设置为准；标签按键合并，API 设为空值即删除节点自报的同名标签；
+// 分组取两者并集
+func (n Node) Effective() Meta {
+	out := n.Reported
+	if n.Manual.DisplayName != "" {
+		out.DisplayName = n.Manual.DisplayName
+	}
+	if n.Manual.Description != "" {
+		out.Description = n.Manual.Description
+	}
+	if len(n.Manual.Labels) > 0 {
+		out.Labels = maps.Clone(n.Reported.Labels)
+		if out.Labels == nil {
+			out.Labels = make(map[string]string, len(n.Manual.Labels))
+		}
+		for k, v := range n.Manual.Labels {
+			if v == "" {
+				delete(out.Labels, k)
+			} else {
+				out.Labels[k] = v
+			}
+		}
+	}
+	if len(n.Manual.Groups) > 0 {
+		out.Groups = slices.Clone(n.Reported.Groups)
+		for _, g := range n.Manual.Groups {
+			if !slices.Contains(out.Groups, g) {
+				out.Groups = append(out.Groups, g)
+			}
+		}
+		sort.Strings(out.Groups)
+	}
+	return out
+}
+
+// MarshalJSON 附带合并后的 effective，便于前端直接使用
+func (n Node) MarshalJSON() ([]byte, error) {
+	type plain Node
+	return json.Marshal(struct {
+		*plain
+		Effective Meta `json:"effective"`
+	}{(*plain)(&n), n.Effective()})
+}
+
+// Options 元数据存储参数
+type Options struct {
+	Path    string       // 持久化文件，留空只保存在内存
+	Targets []TargetRule // 按选择器分配的探测目标
+}
+
+// Store 节点元数据，同时作为 Sink 接收节点自报的部分
+type Store struct {
+	opts Options
+
+	mu    sync.RWMutex
+	nodes map[string]*Node
+}
+
+// NewStore 读取上次保存的元数据并校验探测目标规则
+func NewStore(opts Options) (*Store, error) {
+	for i := range opts.Targets {
+		if err := opts.Targets[i].validate(); err != nil {
+			return nil, fmt.Errorf("probe target rule #%d (%s): %w", i+1, opts.Targets[i].Name, err)
+		}
+	}
+	s := &Store{opts: opts, nodes: make(map[string]*Node)}
+	if opts.Path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*Node
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		s.nodes[n.NodeID] = n
+	}
+	return s, nil
+}
+
+// save 调用方持有写锁
+func (s *Store) save() error {
+	if s.opts.Path == "" {
+		return nil
+	}
+	nodes := make([]*Node, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		nodes = append(nodes, n)
+	}
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.opts.Path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.opts.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.opts.Path)
+}
+
+// Ingest 实现 storage.Sink：节点自报的元数据有变化时更新并落盘，不合法的条目丢弃
+func (s *Store) Ingest(req *pb.ReportRequest) error {
+	if req.Info == nil {
+		return nil
+	}
+	reported, invalid := fromProto(req.Info).clean()
+
+	s.mu.RLock()
+	n, ok := s.nodes[req.NodeId]
+	same := ok && n.Reported.equal(reported) || !ok && reported.empty()
+	s.mu.RUnlock()
+	if same {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok = s.nodes[req.NodeId]
+	if !ok {
+		n = &Node{NodeID: req.NodeId}
+		s.nodes[req.NodeId] = n
+	}
+	n.Reported = reported
+	n.UpdatedAt = time.Now().UnixMilli()
+	if invalid != nil {
+		log.Printf("[NodeMeta] node [%s] reported invalid metadata, ignored: %v", req.NodeId, invalid)
+	}
+	log.Printf("[NodeMeta] node [%s] reported labels %v, groups %v", req.NodeId, reported.Labels, reported.Groups)
+	return s.save()
+}
+
+// Get 节点生效的元数据，未知节点返回空
+func (s *Store) Get(nodeID string) Meta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if n, ok := s.nodes[nodeID]; ok {
+		return n.Effective()
+	}
+	return Meta{}
+}
+
+// Node 单个节点的两层元数据
+func (s *Store) Node(nodeID string) (Node, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n, ok := s.nodes[nodeID]
+	if !ok {
+		return Node{}, false
+	}
+	return *n, true
+}
+
+// HasNode 实现 storage.NodeKnower：节点是否有任何一层元数据
+func (s *Store) HasNode(nodeID string) bool {
+	_, ok := s.Node(nodeID)
+	return ok
+}
+
+// Nodes 全部有元数据的节点，按 ID 排序
+func (s *Store) Nodes() []Node {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Node, 0, len(s.nodes))
+	for _, n := range s.nodes {
+		out = append(out, *n)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NodeID < out[j].NodeID })
+	return out
+}
+
+// SetManual 整体替换节点的 API 层元数据，节点尚未上报过也可预先设置
+func (s *Store) SetManual(nodeID string, m Meta) (Node, error) {
+	if nodeID == "" {
+		return Node{}, fmt.Errorf("%w: missing node_id", ErrInvalid)
+	}
+	// API 层允许空值标签，用于删除节点自报的同名标签
+	m, err := m.clean()
+	if err != nil {
+		return Node{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[nodeID]
+	if !ok {
+		n = &Node{NodeID: nodeID}
+		s.nodes[nodeID] = n
+	}
+	n.Manual = m
+	n.UpdatedAt = time.Now().UnixMilli()
+	log.Printf("[NodeMeta] node [%s] metadata set: labels %v, groups %v", nodeID, m.Labels, m.Groups)
+	return *n, s.save()
+}
+
+// ClearManual 删除节点的 API 层元数据，恢复为节点自报的值
+func (s *Store) ClearManual(nodeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[nodeID]
+	if !ok {
+		return ErrNotFound
+	}
+	n.Manual = Meta{}
+	n.UpdatedAt = time.Now().UnixMilli()
+	if n.Reported.empty() {
+		delete(s.nodes, nodeID)
+	}
+	return s.save()
+}
+
+// DeleteNode 删除节点的两层元数据
+func (s *Store) DeleteNode(nodeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.nodes[nodeID]; !ok {
+		return nil
+	}
+	delete(s.nodes, nodeID)
+	return s.save()
+}
+
+// MergeNode into 没有 API 层元数据时沿用 from 的设置，节点自报部分以 into 的上报为准
+func (s *Store) MergeNode(from, into string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, ok := s.nodes[from]
+	if !ok {
+		return nil
+	}
+	delete(s.nodes, from)
+	dst, ok := s.nodes[into]
+	if !ok {
+		dst = &Node{NodeID: into, Reported: src.Reported}
+		s.nodes[into] = dst
+	}
+	if dst.Manual.empty() {
+		dst.Manual = src.Manual
+	}
+	dst.UpdatedAt = time.Now().UnixMilli()
+	if dst.Reported.empty() && dst.Manual.empty() {
+		delete(s.nodes, into)
+	}
+	return s.save()
+}
+
+// NodeGroups 节点所属分组，供静默的 group 匹配器使用
+func (s *Store) NodeGroups(nodeID string) []string {
+	return s.Get(nodeID).Groups
+}
+
+// Group 一个分组及其成员
+type Group struct {
+	Name  string   `json:"name"`
+	Nodes []string `json:"nodes"`
+}
+
+// Groups 全部分组，按名称排序
+func (s *Store) Groups() []Group {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	members := make(map[string][]string)
+	for id, n := range s.nodes {
+		for _, g := range n.Effective().Groups {
+			members[g] = append(members[g], id)
+		}
+	}
+	out := make([]Group, 0, len(members))
+	for name, nodes := range members {
+		sort.Strings(nodes)
+		out = append(out, Group{Name: name, Nodes: nodes})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
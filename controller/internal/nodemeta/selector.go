@@ -0,0 +1,60 @@
+package nodemeta
+
+import (
+	"fmt"
+	"path"
+)
+
+// Selector 按标签与分组挑选节点，值均为 path.Match 通配：
+// labels 中每一项都须满足 (节点须带有该标签)，groups 中任一项命中节点的任一分组即可；空选择器匹配全部节点
+type Selector struct {
+	Labels map[string]string `mapstructure:"labels" json:"labels,omitempty"`
+	Groups []string          `mapstructure:"groups" json:"groups,omitempty"`
+}
+
+// Empty 没有任何条件
+func (s Selector) Empty() bool {
+	return len(s.Labels) == 0 && len(s.Groups) == 0
+}
+
+// Validate 检查通配语法
+func (s Selector) Validate() error {
+	for k, v := range s.Labels {
+		if !labelKeyPattern.MatchString(k) {
+			return fmt.Errorf("bad label name %q", k)
+		}
+		if _, err := path.Match(v, ""); err != nil {
+			return fmt.Errorf("bad label pattern %q: %w", v, err)
+		}
+	}
+	for _, g := range s.Groups {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("bad group pattern %q: %w", g, err)
+		}
+	}
+	return nil
+}
+
+// Match 元数据是否满足选择器
+func (s Selector) Match(m Meta) bool {
+	for k, pattern := range s.Labels {
+		v, ok := m.Labels[k]
+		if !ok {
+			return false
+		}
+		if ok, _ := path.Match(pattern, v); !ok {
+			return false
+		}
+	}
+	if len(s.Groups) == 0 {
+		return true
+	}
+	for _, pattern := range s.Groups {
+		for _, g := range m.Groups {
+			if ok, _ := path.Match(pattern, g); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
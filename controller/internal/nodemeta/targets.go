@@ -0,0 +1,93 @@
+package nodemeta
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+// TargetRule 一组探测目标及其分配范围。nodes 为 node_id 通配，与选择器同时满足才分配；都为空时分配给全部节点
+type TargetRule struct {
+	Name     string
+	Nodes    []string
+	Selector Selector
+	Targets  []string // ip:port，IPv6 写作 [::1]:443
+	Type     string   // 默认 tcpping
+
+	parsed []*pb.ProbeTarget
+}
+
+func (r *TargetRule) validate() error {
+	if len(r.Targets) == 0 {
+		return errors.New("no targets")
+	}
+	if r.Type == "" {
+		r.Type = "tcpping"
+	}
+	for _, p := range r.Nodes {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("bad node pattern %q: %w", p, err)
+		}
+	}
+	if err := r.Selector.Validate(); err != nil {
+		return err
+	}
+	r.parsed = make([]*pb.ProbeTarget, 0, len(r.Targets))
+	for _, t := range r.Targets {
+		host, portStr, err := net.SplitHostPort(t)
+		if err != nil {
+			return fmt.Errorf("bad target %q: %w", t, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("bad port in target %q", t)
+		}
+		r.parsed = append(r.parsed, &pb.ProbeTarget{Ip: host, Port: int32(port), TargetType: r.Type})
+	}
+	return nil
+}
+
+func (r *TargetRule) match(nodeID string, m Meta) bool {
+	if len(r.Nodes) > 0 {
+		matched := false
+		for _, p := range r.Nodes {
+			if ok, _ := path.Match(p, nodeID); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return r.Selector.Match(m)
+}
+
+// ProbeTargets 按当前元数据分配给节点的探测目标，多条规则命中时按配置顺序合并去重；
+// 未配置规则或一条都未命中时返回空，节点沿用本地列表
+func (s *Store) ProbeTargets(nodeID string) []*pb.ProbeTarget {
+	if len(s.opts.Targets) == 0 {
+		return nil
+	}
+	m := s.Get(nodeID)
+	seen := make(map[string]bool)
+	var out []*pb.ProbeTarget
+	for i := range s.opts.Targets {
+		r := &s.opts.Targets[i]
+		if !r.match(nodeID, m) {
+			continue
+		}
+		for _, t := range r.parsed {
+			key := t.TargetType + "|" + net.JoinHostPort(t.Ip, strconv.Itoa(int(t.Port)))
+			if !seen[key] {
+				seen[key] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
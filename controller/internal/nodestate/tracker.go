@@ -0,0 +1,495 @@
+// Package nodestate 显式跟踪每个节点的连接状态 (connected / reporting / stale / offline)，
+// 记录带时间与原因的状态转换，供 API、告警与可用性统计使用
+package nodestate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+// State 节点连接状态
+//
+//	connected: 上报流已建立并识别出节点，尚未收到有效上报
+//	reporting: stale_after 内收到过上报
+//	stale:     超过 stale_after 没有上报 (流可能仍挂着)
+//	offline:   上报流断开，或超过 offline_after 没有上报
+type State string
+
+const (
+	StateConnected State = "connected"
+	StateReporting State = "reporting"
+	StateStale     State = "stale"
+	StateOffline   State = "offline"
+)
+
+// Up 状态对应的 node_up 取值：在线 1，stale 0.5，离线 0
+func (s State) Up() float64 {
+	switch s {
+	case StateConnected, StateReporting:
+		return 1
+	case StateStale:
+		return 0.5
+	}
+	return 0
+}
+
+// 转换原因
+const (
+	ReasonStreamOpened = "stream opened"
+	ReasonReport       = "report received"
+	ReasonStale        = "no report within stale_after"
+	ReasonTimeout      = "report timeout"
+	ReasonStreamClosed = "stream closed"
+	reasonStreamError  = "stream error: "
+)
+
+// Transition 一次状态转换
+type Transition struct {
+	NodeID string `json:"node_id"`
+	From   State  `json:"from"`
+	To     State  `json:"to"`
+	At     int64  `json:"at"` // Unix 毫秒
+	Reason string `json:"reason"`
+}
+
+// NodeState 节点当前状态
+type NodeState struct {
+	NodeID      string `json:"node_id"`
+	State       State  `json:"state"`
+	Since       int64  `json:"since"` // 进入当前状态的时间
+	Reason      string `json:"reason"`
+	LastReport  int64  `json:"last_report,omitempty"`
+	ConnectedAt int64  `json:"connected_at,omitempty"` // 当前上报流建立时间
+	Streams     int    `json:"streams"`                // 当前打开的上报流数量，重连交叠时可能大于 1
+	RemoteAddr  string `json:"remote_addr,omitempty"`
+}
+
+// Listener 状态转换回调，在跟踪器锁内调用，实现方只能做轻量操作
+type Listener func(t Transition)
+
+// Options 跟踪器参数
+type Options struct {
+	StaleAfter       time.Duration // 超过该时长没有上报即 stale
+	OfflineAfter     time.Duration // 超过该时长没有上报即 offline
+	StatePath        string        // 状态与转换历史持久化文件
+	HistoryLimit     int           // 每个节点最多保留的转换条数
+	HistoryRetention time.Duration // 转换历史保留时长
+}
+
+func (o *Options) applyDefaults() {
+	if o.StaleAfter <= 0 {
+		o.StaleAfter = 15 * time.Second
+	}
+	if o.OfflineAfter <= o.StaleAfter {
+		o.OfflineAfter = 4 * o.StaleAfter
+	}
+	if o.HistoryLimit <= 0 {
+		o.HistoryLimit = 5000
+	}
+	if o.HistoryRetention <= 0 {
+		o.HistoryRetention = 90 * 24 * time.Hour
+	}
+}
+
+// checkInterval 超时检查周期
+const checkInterval = time.Second
+
+type node struct {
+	NodeState
+	History []Transition `json:"history"`
+}
+
+// Tracker 节点状态机。作为 Sink 挂在 gRPC 出口上接收上报，同时由 gRPC 服务通知流的建立与断开
+type Tracker struct {
+	opts Options
+
+	mu        sync.Mutex
+	nodes     map[string]*node
+	listeners []Listener
+	dirty     bool
+	closed    bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewTracker 恢复上次保存的状态并启动超时检查。
+// 重启前在线的节点保持原状态与最后上报时间，重连即回到 reporting；停机期间已超时的立即补记
+// stale / offline，转换时间取超时到期的时刻，停机期间不计为在线
+func NewTracker(opts Options) (*Tracker, error) {
+	opts.applyDefaults()
+	t := &Tracker{opts: opts, nodes: make(map[string]*node), stop: make(chan struct{})}
+	if err := t.load(); err != nil {
+		return nil, fmt.Errorf("load node state: %w", err)
+	}
+	for _, n := range t.nodes {
+		n.Streams = 0
+		n.RemoteAddr = ""
+	}
+	t.checkTimeouts(time.Now().UnixMilli())
+	t.wg.Add(1)
+	go t.loop()
+	return t, nil
+}
+
+func (t *Tracker) load() error {
+	if t.opts.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(t.opts.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var nodes []*node
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		t.nodes[n.NodeID] = n
+	}
+	return nil
+}
+
+func (t *Tracker) save() error {
+	if t.opts.StatePath == "" {
+		return nil
+	}
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	nodes := make([]*node, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		nodes = append(nodes, n)
+	}
+	data, err := json.Marshal(nodes)
+	t.dirty = false
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.opts.StatePath), 0o755); err != nil {
+		return err
+	}
+	tmp := t.opts.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.opts.StatePath)
+}
+
+// Subscribe 注册转换回调，须在上报开始前调用
+func (t *Tracker) Subscribe(l Listener) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, l)
+}
+
+// node 查找或创建节点，新节点从 offline 开始。调用方持有 t.mu
+func (t *Tracker) node(nodeID string, now int64) *node {
+	n, ok := t.nodes[nodeID]
+	if !ok {
+		n = &node{NodeState: NodeState{NodeID: nodeID, State: StateOffline, Since: now, Reason: "first seen"}}
+		t.nodes[nodeID] = n
+		t.dirty = true
+	}
+	return n
+}
+
+// transition 切换状态并记录历史，调用方持有 t.mu
+func (t *Tracker) transition(n *node, to State, reason string, now int64) {
+	if n.State == to {
+		return
+	}
+	tr := Transition{NodeID: n.NodeID, From: n.State, To: to, At: now, Reason: reason}
+	n.State, n.Since, n.Reason = to, now, reason
+	n.History = append(n.History, tr)
+	t.prune(n, now)
+	t.dirty = true
+	log.Printf("[NodeState] Node [%s] %s -> %s (%s)", n.NodeID, tr.From, tr.To, reason)
+	for _, l := range t.listeners {
+		l(tr)
+	}
+}
+
+// prune 按条数与时长裁剪历史，保留最近一条以便推算保留期起点的状态
+func (t *Tracker) prune(n *node, now int64) {
+	cutoff := now - t.opts.HistoryRetention.Milliseconds()
+	drop := 0
+	for drop < len(n.History)-1 && n.History[drop].At < cutoff {
+		drop++
+	}
+	if over := len(n.History) - drop - t.opts.HistoryLimit; over > 0 {
+		drop += over
+	}
+	if drop > 0 {
+		n.History = append([]Transition(nil), n.History[drop:]...)
+	}
+}
+
+// StreamOpened 上报流收到首帧、识别出节点时调用
+func (t *Tracker) StreamOpened(nodeID, remoteAddr string) {
+	now := time.Now().UnixMilli()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	n := t.node(nodeID, now)
+	n.Streams++
+	n.ConnectedAt = now
+	n.RemoteAddr = remoteAddr
+	t.dirty = true
+	if n.State == StateOffline || n.State == StateStale {
+		t.transition(n, StateConnected, ReasonStreamOpened, now)
+	}
+}
+
+// StreamClosed 上报流结束时调用，err 为 nil 表示节点正常关闭。节点的最后一条流断开即 offline
+func (t *Tracker) StreamClosed(nodeID string, err error) {
+	now := time.Now().UnixMilli()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	n, ok := t.nodes[nodeID]
+	if !ok {
+		return
+	}
+	if n.Streams > 0 {
+		n.Streams--
+	}
+	t.dirty = true
+	if n.Streams > 0 {
+		return
+	}
+	n.ConnectedAt = 0
+	n.RemoteAddr = ""
+	reason := ReasonStreamClosed
+	if err != nil {
+		reason = reasonStreamError + err.Error()
+	}
+	t.transition(n, StateOffline, reason, now)
+}
+
+// Ingest 实现 storage.Sink：收到上报即回到 reporting
+func (t *Tracker) Ingest(req *pb.ReportRequest) error {
+	now := time.Now().UnixMilli()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	n := t.node(req.NodeId, now)
+	n.LastReport = now
+	t.transition(n, StateReporting, ReasonReport, now)
+	return nil
+}
+
+func (t *Tracker) loop() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	saveEvery := 0
+	for {
+		select {
+		case now := <-ticker.C:
+			t.checkTimeouts(now.UnixMilli())
+			// 转换本身不频繁，每 10 秒落盘一次足够
+			if saveEvery++; saveEvery%10 == 0 {
+				if err := t.save(); err != nil {
+					log.Printf("[NodeState] Save state error: %v", err)
+				}
+			}
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// checkTimeouts 按最后上报时间推进 stale / offline。转换时间记为超时到期的时刻而不是检查时刻，
+// 主控停机或检查滞后时区间依然准确；在线节点总是先经过 stale 再转为 offline
+func (t *Tracker) checkTimeouts(now int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stale := t.opts.StaleAfter.Milliseconds()
+	offline := t.opts.OfflineAfter.Milliseconds()
+	for _, n := range t.nodes {
+		// 从未上报的节点以流建立时间起算
+		last := max(n.LastReport, n.ConnectedAt)
+		if (n.State == StateConnected || n.State == StateReporting) && now-last >= stale {
+			t.transition(n, StateStale, ReasonStale, max(last+stale, n.Since))
+		}
+		if n.State == StateStale && now-last >= offline {
+			t.transition(n, StateOffline, ReasonTimeout, max(last+offline, n.Since))
+		}
+	}
+}
+
+// States 所有节点的当前状态，按节点 ID 排序
+func (t *Tracker) States() []NodeState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]NodeState, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		out = append(out, n.NodeState)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NodeID < out[j].NodeID })
+	return out
+}
+
+// State 单个节点的当前状态
+func (t *Tracker) State(nodeID string) (NodeState, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.nodes[nodeID]
+	if !ok {
+		return NodeState{}, false
+	}
+	return n.NodeState, true
+}
+
+// HasNode 实现 storage.NodeKnower：是否跟踪过该节点
+func (t *Tracker) HasNode(nodeID string) bool {
+	_, ok := t.State(nodeID)
+	return ok
+}
+
+// Events [from, to) 内的状态转换，nodeID 为空时返回全部节点，按时间倒序，最多 limit 条 (0 不限)
+func (t *Tracker) Events(nodeID string, from, to int64, limit int) []Transition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []Transition
+	for id, n := range t.nodes {
+		if nodeID != "" && id != nodeID {
+			continue
+		}
+		for _, tr := range n.History {
+			if tr.At >= from && tr.At < to {
+				out = append(out, tr)
+			}
+		}
+	}
+	// 同一毫秒内可能有多次转换 (建流后立即上报)，稳定排序后整体反转以保持先后关系
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At < out[j].At })
+	slices.Reverse(out)
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// Interval 一段连续处于同一状态的时间
+type Interval struct {
+	State  State  `json:"state"`
+	From   int64  `json:"from"`
+	To     int64  `json:"to"`
+	Reason string `json:"reason,omitempty"` // 进入该状态的原因
+}
+
+// Intervals 把节点在 [from, to) 内的转换历史展开为连续区间。
+// 早于首条记录的时间段状态未知，不产生区间
+func (t *Tracker) Intervals(nodeID string, from, to int64) []Interval {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.nodes[nodeID]
+	if !ok || from >= to {
+		return nil
+	}
+
+	var out []Interval
+	var cur State
+	var reason string
+	curFrom := from
+	for _, tr := range n.History {
+		if tr.At <= from {
+			cur, reason = tr.To, tr.Reason
+			continue
+		}
+		if tr.At >= to {
+			break
+		}
+		if cur != "" {
+			out = append(out, Interval{State: cur, From: curFrom, To: tr.At, Reason: reason})
+		}
+		cur, curFrom, reason = tr.To, tr.At, tr.Reason
+	}
+	if cur == "" && len(n.History) == 0 {
+		// 没有任何转换记录：节点自首次出现起一直处于当前状态
+		cur, curFrom, reason = n.State, max(from, n.Since), n.Reason
+	}
+	if cur != "" && curFrom < to {
+		out = append(out, Interval{State: cur, From: curFrom, To: to, Reason: reason})
+	}
+	return out
+}
+
+// DeleteNode 丢弃节点的状态与转换历史
+func (t *Tracker) DeleteNode(nodeID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.nodes[nodeID]; ok {
+		delete(t.nodes, nodeID)
+		t.dirty = true
+	}
+	return nil
+}
+
+// MergeNode 把 from 的转换历史改记到 into 名下并按时间合并，into 的当前状态不变；
+// into 尚无记录时 from 整体改名。适用于节点改名，两者的历史时段不应交叠
+func (t *Tracker) MergeNode(from, into string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	src, ok := t.nodes[from]
+	if !ok {
+		return nil
+	}
+	delete(t.nodes, from)
+	t.dirty = true
+	for i := range src.History {
+		src.History[i].NodeID = into
+	}
+
+	dst, ok := t.nodes[into]
+	if !ok {
+		src.NodeID = into
+		t.nodes[into] = src
+		return nil
+	}
+	dst.History = append(src.History, dst.History...)
+	sort.SliceStable(dst.History, func(i, j int) bool { return dst.History[i].At < dst.History[j].At })
+	dst.LastReport = max(dst.LastReport, src.LastReport)
+	t.prune(dst, time.Now().UnixMilli())
+	return nil
+}
+
+// Close 停止超时检查并保存状态。之后的流断开 (主控自身退出) 不再记为节点掉线
+func (t *Tracker) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.dirty = true
+	t.mu.Unlock()
+	close(t.stop)
+	t.wg.Wait()
+	return t.save()
+}
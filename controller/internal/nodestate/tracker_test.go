@@ -0,0 +1,109 @@
+package nodestate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+var testOpts = Options{StaleAfter: 15 * time.Second, OfflineAfter: time.Minute}
+
+func newTestTracker(t *testing.T, opts Options) *Tracker {
+	t.Helper()
+	tr, err := NewTracker(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tr.Close() })
+	return tr
+}
+
+func testReport(nodeID string) *pb.ReportRequest {
+	return &pb.ReportRequest{NodeId: nodeID, Timestamp: time.Now().UnixMilli()}
+}
+
+func expectHistory(t *testing.T, tr *Tracker, nodeID string, want []Transition) {
+	t.Helper()
+	got := tr.Events(nodeID, 0, 1<<62, 0)
+	if len(got) != len(want) {
+		t.Fatalf("%d transitions %+v, want %d", len(got), got, len(want))
+	}
+	for i, w := range want {
+		g := got[len(got)-1-i] // Events 按时间倒序
+		if g.From != w.From || g.To != w.To || g.At != w.At {
+			t.Fatalf("transition %d: %s -> %s at %d, want %s -> %s at %d", i, g.From, g.To, g.At, w.From, w.To, w.At)
+		}
+	}
+}
+
+// 主控停机期间节点没有上报：重启后按最后上报时间补记 stale / offline，停机时段不算在线
+func TestRestartAfterOutage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node_state.json")
+	last := time.Now().Add(-time.Hour).UnixMilli()
+	saved := []*node{{
+		NodeState: NodeState{NodeID: "hk-01", State: StateReporting, Since: last - 1000, LastReport: last, Streams: 1},
+		History:   []Transition{{NodeID: "hk-01", From: StateConnected, To: StateReporting, At: last - 1000}},
+	}}
+	data, _ := json.Marshal(saved)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := testOpts
+	opts.StatePath = path
+	tr := newTestTracker(t, opts)
+
+	st, _ := tr.State("hk-01")
+	if st.State != StateOffline || st.Since != last+time.Minute.Milliseconds() || st.Streams != 0 {
+		t.Fatalf("state after restart %+v", st)
+	}
+	expectHistory(t, tr, "hk-01", []Transition{
+		{From: StateConnected, To: StateReporting, At: last - 1000},
+		{From: StateReporting, To: StateStale, At: last + 15_000},
+		{From: StateStale, To: StateOffline, At: last + 60_000},
+	})
+	ivs := tr.Intervals("hk-01", last, last+2*time.Minute.Milliseconds())
+	if len(ivs) != 3 || ivs[0].State != StateReporting || ivs[0].To != last+15_000 || ivs[2].State != StateOffline {
+		t.Fatalf("intervals %+v", ivs)
+	}
+}
+
+// 短暂重启 (未超过 stale_after) 不产生任何转换，节点重连后照常上报
+func TestRestartWithinStaleAfter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node_state.json")
+	last := time.Now().Add(-5 * time.Second).UnixMilli()
+	data, _ := json.Marshal([]*node{{NodeState: NodeState{NodeID: "hk-01", State: StateReporting, Since: last, LastReport: last}}})
+	os.WriteFile(path, data, 0o644)
+	opts := testOpts
+	opts.StatePath = path
+	tr := newTestTracker(t, opts)
+	if st, _ := tr.State("hk-01"); st.State != StateReporting {
+		t.Fatalf("state %s, want reporting", st.State)
+	}
+}
+
+func TestTimeoutsAtDueTime(t *testing.T) {
+	tr := newTestTracker(t, testOpts)
+	tr.StreamOpened("hk-01", "192.0.2.1:5000")
+	if err := tr.Ingest(testReport("hk-01")); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := tr.State("hk-01")
+	last := st.LastReport
+
+	// 检查滞后 (如主控卡顿)：转换仍记在到期时刻，且先经过 stale
+	tr.checkTimeouts(last + 5*time.Minute.Milliseconds())
+	ev := tr.Events("hk-01", 0, 1<<62, 2)
+	if len(ev) != 2 || ev[1].To != StateStale || ev[1].At != last+15_000 || ev[0].To != StateOffline || ev[0].At != last+60_000 {
+		t.Fatalf("events %+v", ev)
+	}
+
+	// 流断开已经是 offline，不再重复记录
+	tr.StreamClosed("hk-01", nil)
+	if n := len(tr.Events("hk-01", 0, 1<<62, 0)); n != 4 {
+		t.Fatalf("%d transitions, want 4", n)
+	}
+}
@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// botFlavor 各家群机器人的差异：请求体格式、加签方式与业务错误码
+type botFlavor interface {
+	// build 生成请求地址与请求体，secret 为空表示未开启加签
+	build(rawURL, secret string, n *Notification) (string, any, error)
+	// check 解析响应体中的业务错误码 (HTTP 200 也可能失败)
+	check(body []byte) error
+}
+
+type botChannel struct {
+	url    string
+	secret string
+	flavor botFlavor
+	client *http.Client
+}
+
+func newBotChannel(cfg ChannelConfig, client *http.Client, flavor botFlavor) (Channel, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("%s channel needs url", cfg.Type)
+	}
+	return &botChannel{url: cfg.URL, secret: cfg.Secret, flavor: flavor, client: client}, nil
+}
+
+func (c *botChannel) Send(ctx context.Context, n *Notification) error {
+	target, payload, err := c.flavor.build(c.url, c.secret, n)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, c.client, target, nil, body)
+	if err != nil {
+		return err
+	}
+	return c.flavor.check(resp)
+}
+
+func hmacBase64(key, msg string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msg))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// dingtalkBot 钉钉自定义机器人：markdown 消息；加签参数 timestamp(毫秒)/sign 附加在 URL 上
+type dingtalkBot struct{}
+
+func (dingtalkBot) build(rawURL, secret string, n *Notification) (string, any, error) {
+	if secret != "" {
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		sign := hmacBase64(secret, ts+"\n"+secret)
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", nil, err
+		}
+		q := u.Query()
+		q.Set("timestamp", ts)
+		q.Set("sign", sign)
+		u.RawQuery = q.Encode()
+		rawURL = u.String()
+	}
+	return rawURL, map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": n.Title,
+			"text":  "### " + n.Title + "\n\n" + markdownLines(n.Message),
+		},
+	}, nil
+}
+
+func (dingtalkBot) check(body []byte) error {
+	var r struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("decode dingtalk response: %w", err)
+	}
+	if r.ErrCode != 0 {
+		return fmt.Errorf("dingtalk error %d: %s", r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
+
+// feishuBot 飞书自定义机器人：文本消息；加签以 timestamp(秒)+"\n"+secret 为密钥对空串做 HMAC，放在请求体中
+type feishuBot struct{}
+
+func (feishuBot) build(rawURL, secret string, n *Notification) (string, any, error) {
+	payload := map[string]any{
+		"msg_type": "text",
+		"content":  map[string]string{"text": n.Title + "\n" + n.Message},
+	}
+	if secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = ts
+		payload["sign"] = hmacBase64(ts+"\n"+secret, "")
+	}
+	return rawURL, payload, nil
+}
+
+func (feishuBot) check(body []byte) error {
+	var r struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("decode feishu response: %w", err)
+	}
+	if r.Code != 0 {
+		return fmt.Errorf("feishu error %d: %s", r.Code, r.Msg)
+	}
+	return nil
+}
+
+// wecomBot 企业微信群机器人：markdown 消息，无加签 (key 在 URL 中)
+type wecomBot struct{}
+
+func (wecomBot) build(rawURL, _ string, n *Notification) (string, any, error) {
+	return rawURL, map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": "**" + n.Title + "**\n" + n.Message,
+		},
+	}, nil
+}
+
+func (wecomBot) check(body []byte) error {
+	var r struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("decode wecom response: %w", err)
+	}
+	if r.ErrCode != 0 {
+		return fmt.Errorf("wecom error %d: %s", r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
+
+// markdownLines 钉钉 markdown 需要空行或行尾两个空格才换行
+func markdownLines(s string) string {
+	return strings.ReplaceAll(s, "\n", "  \n")
+}
+
+// telegramChannel Bot API sendMessage，纯文本以免告警内容中的符号破坏 Markdown 解析
+type telegramChannel struct {
+	url    string
+	chatID string
+	client *http.Client
+}
+
+func (c *telegramChannel) Send(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(map[string]any{
+		"chat_id":                  c.chatID,
+		"text":                     n.Title + "\n\n" + n.Message,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, c.client, c.url, nil, body)
+	if err != nil {
+		return err
+	}
+	var r struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(resp, &r); err != nil {
+		return fmt.Errorf("decode telegram response: %w", err)
+	}
+	if !r.OK {
+		return fmt.Errorf("telegram error: %s", r.Description)
+	}
+	return nil
+}
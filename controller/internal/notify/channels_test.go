@@ -0,0 +1,436 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+)
+
+// capture 测试服务端收到的一次请求
+type capture struct {
+	path   string
+	query  map[string][]string
+	header http.Header
+	body   []byte
+}
+
+// stubServer 依次按 statuses 返回状态码 (用完后一直返回 200) 并记录请求
+func stubServer(t *testing.T, reply string, statuses ...int) (*httptest.Server, func() []capture) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		reqs []capture
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		reqs = append(reqs, capture{path: r.URL.Path, query: r.URL.Query(), header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		mu.Unlock()
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []capture {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]capture(nil), reqs...)
+	}
+}
+
+func testNotification() *Notification {
+	a := testAlert("a", "HighCPU", "hk-01", alerting.StateFiring)
+	return &Notification{
+		GroupKey:    "rule=HighCPU",
+		GroupLabels: map[string]string{"rule": "HighCPU"},
+		Status:      StatusFiring,
+		Firing:      []alerting.Alert{a},
+		Resolved:    []alerting.Alert{},
+		Title:       "[FIRING:1] rule=HighCPU",
+		Message:     "[WARNING] HighCPU on hk-01\n  CPU 高",
+	}
+}
+
+func mustChannel(t *testing.T, cfg ChannelConfig) Channel {
+	t.Helper()
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	ch, err := newChannel(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+func hmacHex(key, msg string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func hmacB64(key, msg string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msg))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookSignature(t *testing.T) {
+	srv, reqs := stubServer(t, "")
+	ch := mustChannel(t, ChannelConfig{Name: "hook", Type: "webhook", URL: srv.URL, Secret: "s3cret", Headers: map[string]string{"X-Tenant": "acme"}})
+	if err := ch.Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	got := reqs()
+	if len(got) != 1 {
+		t.Fatalf("%d requests, want 1", len(got))
+	}
+	r := got[0]
+	ts := r.header.Get(HeaderTimestamp)
+	if sec, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sec, 0)).Abs() > time.Minute {
+		t.Fatalf("timestamp header %q", ts)
+	}
+	if want := "sha256=" + hmacHex("s3cret", ts+"."+string(r.body)); r.header.Get(HeaderSignature) != want {
+		t.Fatalf("signature %q, want %q", r.header.Get(HeaderSignature), want)
+	}
+	if r.header.Get("X-Tenant") != "acme" || r.header.Get("Content-Type") != "application/json" {
+		t.Fatalf("headers %v", r.header)
+	}
+	var payload struct {
+		Version  string           `json:"version"`
+		GroupKey string           `json:"group_key"`
+		Status   string           `json:"status"`
+		Firing   []alerting.Alert `json:"firing"`
+	}
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Version != "1" || payload.GroupKey != "rule=HighCPU" || payload.Status != StatusFiring || len(payload.Firing) != 1 {
+		t.Fatalf("payload %s", r.body)
+	}
+
+	// 未配置密钥时不带签名头
+	srv2, reqs2 := stubServer(t, "")
+	if err := mustChannel(t, ChannelConfig{Type: "webhook", URL: srv2.URL}).Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	if h := reqs2()[0].header; h.Get(HeaderSignature) != "" || h.Get(HeaderTimestamp) != "" {
+		t.Fatalf("unsigned webhook carries signature headers: %v", h)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	srv, reqs := stubServer(t, "", http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	cfg := ChannelConfig{Name: "hook", Type: "webhook", URL: srv.URL}
+	d, err := NewDispatcher(Options{
+		Channels:   []ChannelConfig{cfg},
+		MaxRetries: 3,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	c := d.channels[0]
+	n := testNotification()
+	d.deliver(context.Background(), c, n)
+	if len(reqs()) != 3 {
+		t.Fatalf("%d requests, want 3 (503, 429, 200)", len(reqs()))
+	}
+	if st := d.Channels()[0]; st.Sent != 1 || st.Failed != 0 {
+		t.Fatalf("status %+v", st)
+	}
+
+	// 4xx 视为配置错误，不重试
+	srv2, reqs2 := stubServer(t, "bad request", http.StatusBadRequest)
+	c.ch = mustChannel(t, ChannelConfig{Type: "webhook", URL: srv2.URL})
+	d.deliver(context.Background(), c, n)
+	if len(reqs2()) != 1 {
+		t.Fatalf("%d requests for a 400, want 1", len(reqs2()))
+	}
+	if st := d.Channels()[0]; st.Failed != 1 || !strings.Contains(st.LastError, "HTTP 400") {
+		t.Fatalf("status %+v", st)
+	}
+
+	// 重试用尽
+	srv3, reqs3 := stubServer(t, "", 502, 502, 502, 502, 502)
+	c.ch = mustChannel(t, ChannelConfig{Type: "webhook", URL: srv3.URL})
+	d.deliver(context.Background(), c, n)
+	if len(reqs3()) != 4 {
+		t.Fatalf("%d requests, want 1 + 3 retries", len(reqs3()))
+	}
+}
+
+func TestDingtalkBot(t *testing.T) {
+	srv, reqs := stubServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	ch := mustChannel(t, ChannelConfig{Type: "dingtalk", URL: srv.URL + "/robot/send?access_token=tok", Secret: "SECabc"})
+	if err := ch.Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	r := reqs()[0]
+	ts := r.query["timestamp"][0]
+	if r.query["access_token"][0] != "tok" || r.query["sign"][0] != hmacB64("SECabc", ts+"\nSECabc") {
+		t.Fatalf("query %v", r.query)
+	}
+	var body struct {
+		MsgType  string `json:"msgtype"`
+		Markdown struct {
+			Title string `json:"title"`
+			Text  string `json:"text"`
+		} `json:"markdown"`
+	}
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.MsgType != "markdown" || body.Markdown.Title != "[FIRING:1] rule=HighCPU" ||
+		body.Markdown.Text != "### [FIRING:1] rule=HighCPU\n\n[WARNING] HighCPU on hk-01  \n  CPU 高" {
+		t.Fatalf("body %s", r.body)
+	}
+
+	// HTTP 200 中的业务错误码
+	srv2, _ := stubServer(t, `{"errcode":310000,"errmsg":"sign not match"}`)
+	err := mustChannel(t, ChannelConfig{Type: "dingtalk", URL: srv2.URL}).Send(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "310000") {
+		t.Fatalf("err %v, want dingtalk error code", err)
+	}
+}
+
+func TestFeishuBot(t *testing.T) {
+	srv, reqs := stubServer(t, `{"code":0,"msg":"success"}`)
+	if err := mustChannel(t, ChannelConfig{Type: "feishu", URL: srv.URL, Secret: "fs"}).Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		MsgType   string            `json:"msg_type"`
+		Content   map[string]string `json:"content"`
+		Timestamp string            `json:"timestamp"`
+		Sign      string            `json:"sign"`
+	}
+	if err := json.Unmarshal(reqs()[0].body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.MsgType != "text" || body.Content["text"] != "[FIRING:1] rule=HighCPU\n[WARNING] HighCPU on hk-01\n  CPU 高" {
+		t.Fatalf("body %+v", body)
+	}
+	if body.Sign != hmacB64(body.Timestamp+"\nfs", "") {
+		t.Fatalf("sign %q for timestamp %q", body.Sign, body.Timestamp)
+	}
+
+	srv2, _ := stubServer(t, `{"code":19021,"msg":"sign match fail"}`)
+	if err := mustChannel(t, ChannelConfig{Type: "feishu", URL: srv2.URL}).Send(context.Background(), testNotification()); err == nil {
+		t.Fatal("feishu error code not reported")
+	}
+}
+
+func TestWecomBot(t *testing.T) {
+	srv, reqs := stubServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	if err := mustChannel(t, ChannelConfig{Type: "wecom", URL: srv.URL + "/cgi-bin/webhook/send?key=k"}).Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	r := reqs()[0]
+	var body struct {
+		MsgType  string            `json:"msgtype"`
+		Markdown map[string]string `json:"markdown"`
+	}
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if r.query["key"][0] != "k" || body.MsgType != "markdown" ||
+		body.Markdown["content"] != "**[FIRING:1] rule=HighCPU**\n[WARNING] HighCPU on hk-01\n  CPU 高" {
+		t.Fatalf("request %v %s", r.query, r.body)
+	}
+}
+
+func TestTelegramBot(t *testing.T) {
+	srv, reqs := stubServer(t, `{"ok":true}`)
+	ch := mustChannel(t, ChannelConfig{Type: "telegram", APIURL: srv.URL + "/", BotToken: "123:abc", ChatID: "-100"})
+	if err := ch.Send(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	r := reqs()[0]
+	var body map[string]any
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if r.path != "/bot123:abc/sendMessage" || body["chat_id"] != "-100" ||
+		body["text"] != "[FIRING:1] rule=HighCPU\n\n[WARNING] HighCPU on hk-01\n  CPU 高" {
+		t.Fatalf("request %s %s", r.path, r.body)
+	}
+
+	srv2, _ := stubServer(t, `{"ok":false,"description":"chat not found"}`)
+	err := mustChannel(t, ChannelConfig{Type: "telegram", APIURL: srv2.URL, BotToken: "t", ChatID: "1"}).Send(context.Background(), testNotification())
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("err %v", err)
+	}
+}
+
+// smtpStub 最小的 SMTP 服务端，记录收到的命令与邮件内容；rcptReply 为 RCPT 的应答
+type smtpStub struct {
+	addr string
+
+	mu   sync.Mutex
+	cmds []string
+	data string
+}
+
+func startSMTP(t *testing.T, rcptReply string) *smtpStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &smtpStub{addr: ln.Addr().String()}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c, rcptReply)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStub) serve(c net.Conn, rcptReply string) {
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(c)
+	reply := func(line string) { io.WriteString(c, line+"\r\n") }
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.cmds = append(s.cmds, line)
+		s.mu.Unlock()
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO":
+			reply("250-stub")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 2.7.0 accepted")
+		case "MAIL":
+			reply("250 ok")
+		case "RCPT":
+			reply(rcptReply)
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpStub) emailConfig(t *testing.T) ChannelConfig {
+	host, port, _ := net.SplitHostPort(s.addr)
+	p, _ := strconv.Atoi(port)
+	return ChannelConfig{
+		Name:     "mail",
+		Type:     "email",
+		SMTPHost: host,
+		SMTPPort: p,
+		Username: "alert",
+		Password: "pw",
+		From:     "geegee@example.com",
+		To:       []string{"ops@example.com", "noc@example.com"},
+	}
+}
+
+func TestEmail(t *testing.T) {
+	s := startSMTP(t, "250 ok")
+	ch := mustChannel(t, s.emailConfig(t))
+	n := testNotification()
+	n.Title = "告警 HighCPU"
+	if err := ch.Send(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00alert\x00pw"))
+	want := []string{auth, "MAIL FROM:<geegee@example.com>", "RCPT TO:<ops@example.com>", "RCPT TO:<noc@example.com>", "DATA", "QUIT"}
+	if got := s.cmds[1:]; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("commands %q, want %q", got, want)
+	}
+	for _, h := range []string{
+		"From: geegee@example.com\r\n",
+		"To: ops@example.com, noc@example.com\r\n",
+		"Subject: =?utf-8?q?=E5=91=8A=E8=AD=A6_HighCPU?=\r\n",
+		"X-GeeGee-Group: rule=HighCPU\r\n",
+		"\r\n\r\n[WARNING] HighCPU on hk-01\r\n  CPU 高\r\n",
+	} {
+		if !strings.Contains(s.data, h) {
+			t.Fatalf("message missing %q:\n%s", h, s.data)
+		}
+	}
+}
+
+func TestEmailErrors(t *testing.T) {
+	// 4xx 临时失败可重试，5xx 不重试
+	for reply, retryable := range map[string]bool{"451 try again later": true, "550 no such user": false} {
+		s := startSMTP(t, reply)
+		err := mustChannel(t, s.emailConfig(t)).Send(context.Background(), testNotification())
+		var rErr *retryableError
+		if err == nil || errors.As(err, &rErr) != retryable {
+			t.Fatalf("RCPT %q: err %v, retryable want %v", reply, err, retryable)
+		}
+	}
+
+	// 连接失败可重试
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+	err := mustChannel(t, ChannelConfig{Type: "email", SMTPHost: host, SMTPPort: p, From: "a@b", To: []string{"c@d"}}).Send(context.Background(), testNotification())
+	var rErr *retryableError
+	if !errors.As(err, &rErr) {
+		t.Fatalf("connection refused: err %v, want retryable", err)
+	}
+}
@@ -0,0 +1,495 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+)
+
+// Options 分发器参数，分组与重复发送的语义与 Alertmanager 相同
+type Options struct {
+	Channels       []ChannelConfig
+	GroupBy        []string      // 分组键：rule / node_id / target / severity / metric，其余取规则 labels 中同名标签
+	GroupWait      time.Duration // 新分组首次发送前的等待，让同时触发的告警合并到一条通知
+	GroupInterval  time.Duration // 分组内容变化后再次发送的最短间隔
+	RepeatInterval time.Duration // 分组无变化但仍在 firing 时的重复提醒间隔
+	MaxRetries     int           // 网络错误 / 5xx / 429 的最大重试次数
+	MinBackoff     time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration // 渠道未单独配置时的单次发送超时
+	// Muted 告警当前是否被静默 / 维护窗口屏蔽。被屏蔽的告警不推送，屏蔽结束时若仍在 firing 则补发
+	Muted func(a alerting.Alert) bool
+}
+
+func (o *Options) applyDefaults() {
+	if len(o.GroupBy) == 0 {
+		o.GroupBy = []string{"rule"}
+	}
+	if o.GroupWait < 0 {
+		o.GroupWait = 0
+	}
+	if o.GroupInterval <= 0 {
+		o.GroupInterval = 5 * time.Minute
+	}
+	if o.RepeatInterval <= 0 {
+		o.RepeatInterval = 4 * time.Hour
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = time.Second
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = time.Minute
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+}
+
+// tickInterval 检查分组是否到期的周期
+const tickInterval = time.Second
+
+// channel 已初始化的渠道及其发送统计
+type channel struct {
+	cfg     ChannelConfig
+	ch      Channel
+	tmpl    templates
+	minRank int
+
+	mu          sync.Mutex
+	sent        uint64
+	failed      uint64
+	lastSentAt  int64
+	lastError   string
+	lastErrorAt int64
+}
+
+// ChannelStatus 渠道配置摘要与发送统计，供 /api/notify/channels 展示
+type ChannelStatus struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	MinSeverity  string `json:"min_severity,omitempty"`
+	SendResolved bool   `json:"send_resolved"`
+	Sent         uint64 `json:"sent"`
+	Failed       uint64 `json:"failed"`
+	LastSentAt   int64  `json:"last_sent_at,omitempty"`
+	LastError    string `json:"last_error,omitempty"`
+	LastErrorAt  int64  `json:"last_error_at,omitempty"`
+}
+
+// group 一个告警分组
+type group struct {
+	key       string
+	labels    map[string]string
+	firing    map[string]alerting.Alert // 按告警 ID
+	resolved  map[string]alerting.Alert // 上次发送后恢复的告警
+	notified  map[string]bool           // 已推送过 firing 的告警，只有它们的恢复才需要通知
+	nextFlush time.Time
+	lastSent  time.Time
+}
+
+// Dispatcher 订阅告警引擎的状态变化，按分组合并后异步推送到各渠道
+type Dispatcher struct {
+	opts     Options
+	channels []*channel
+
+	mu     sync.Mutex
+	groups map[string]*group
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher 校验并初始化所有渠道，任一渠道配置有误则整体拒绝
+func NewDispatcher(opts Options) (*Dispatcher, error) {
+	opts.applyDefaults()
+	d := &Dispatcher{opts: opts, groups: make(map[string]*group)}
+
+	seen := make(map[string]bool, len(opts.Channels))
+	var errs []error
+	for i, cfg := range opts.Channels {
+		if cfg.Name == "" {
+			errs = append(errs, fmt.Errorf("channel #%d: missing name", i+1))
+			continue
+		}
+		if seen[cfg.Name] {
+			errs = append(errs, fmt.Errorf("channel #%d: duplicate name %q", i+1, cfg.Name))
+			continue
+		}
+		seen[cfg.Name] = true
+		c, err := newDispatchChannel(cfg, opts.Timeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", cfg.Name, err))
+			continue
+		}
+		d.channels = append(d.channels, c)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	d.wg.Add(1)
+	go d.loop()
+	return d, nil
+}
+
+func newDispatchChannel(cfg ChannelConfig, timeout time.Duration) (*channel, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = timeout
+	}
+	switch cfg.MinSeverity {
+	case "", alerting.SeverityInfo, alerting.SeverityWarning, alerting.SeverityCritical:
+	default:
+		return nil, fmt.Errorf("unknown min_severity %q", cfg.MinSeverity)
+	}
+	ch, err := newChannel(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := parseTemplates(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &channel{cfg: cfg, ch: ch, tmpl: tmpl, minRank: alerting.SeverityRank(cfg.MinSeverity)}, nil
+}
+
+// Notify 接收告警状态变化，作为 alerting.Listener 注册。只更新内存中的分组，不做任何 IO
+func (d *Dispatcher) Notify(a alerting.Alert) {
+	if len(d.channels) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	g := d.group(a, time.Now())
+	switch a.State {
+	case alerting.StateFiring:
+		delete(g.resolved, a.ID)
+		g.firing[a.ID] = a
+	case alerting.StateResolved:
+		if _, ok := g.firing[a.ID]; !ok {
+			return
+		}
+		delete(g.firing, a.ID)
+		g.resolved[a.ID] = a
+	default:
+		return
+	}
+	// 已发送过的分组发生变化时，不早于上次发送 + GroupInterval 再发
+	if !g.lastSent.IsZero() {
+		if next := g.lastSent.Add(d.opts.GroupInterval); next.After(g.nextFlush) {
+			g.nextFlush = next
+		}
+	}
+}
+
+// Seed 载入重启前已在 firing 的告警，视为已经通知过，只在 RepeatInterval 后重复提醒
+func (d *Dispatcher) Seed(alerts []alerting.Alert) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for _, a := range alerts {
+		if a.State != alerting.StateFiring {
+			continue
+		}
+		g := d.group(a, now)
+		g.firing[a.ID] = a
+		g.notified[a.ID] = true
+		g.lastSent = now
+	}
+}
+
+// group 查找或创建告警所属分组，调用方持有 d.mu
+func (d *Dispatcher) group(a alerting.Alert, now time.Time) *group {
+	labels := make(map[string]string, len(d.opts.GroupBy))
+	for _, k := range d.opts.GroupBy {
+		labels[k] = groupValue(a, k)
+	}
+	key := (&Notification{GroupLabels: labels}).Group()
+	g, ok := d.groups[key]
+	if !ok {
+		g = &group{
+			key:       key,
+			labels:    labels,
+			firing:    make(map[string]alerting.Alert),
+			resolved:  make(map[string]alerting.Alert),
+			notified:  make(map[string]bool),
+			nextFlush: now.Add(d.opts.GroupWait),
+		}
+		d.groups[key] = g
+	}
+	return g
+}
+
+func groupValue(a alerting.Alert, key string) string {
+	switch key {
+	case "rule":
+		return a.Rule
+	case "node_id":
+		return a.NodeID
+	case "target":
+		return a.Target
+	case "severity":
+		return a.Severity
+	case "metric":
+		return a.Metric
+	}
+	return a.Labels[key]
+}
+
+func (d *Dispatcher) loop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.flush(now)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// flush 取出到期的分组快照并异步发送。
+// 分组有未推送过的 firing 告警或已推送告警恢复即视为有变化；被屏蔽的告警既不推送也不计入变化
+func (d *Dispatcher) flush(now time.Time) {
+	var due []*Notification
+	d.mu.Lock()
+	for key, g := range d.groups {
+		n := d.snapshot(g)
+		fresh := len(n.Resolved) > 0
+		for _, a := range n.Firing {
+			fresh = fresh || !g.notified[a.ID]
+		}
+		repeat := len(n.Firing) > 0 && !g.lastSent.IsZero() && !now.Before(g.lastSent.Add(d.opts.RepeatInterval))
+		if !(fresh && !now.Before(g.nextFlush)) && !repeat {
+			// 待发送的恢复通知要等到 nextFlush，只有无可发内容时才丢弃分组
+			if len(g.firing) == 0 && len(n.Resolved) == 0 {
+				delete(d.groups, key)
+			}
+			continue
+		}
+		due = append(due, n)
+		g.resolved = make(map[string]alerting.Alert)
+		g.notified = make(map[string]bool, len(n.Firing))
+		for _, a := range n.Firing {
+			g.notified[a.ID] = true
+		}
+		g.lastSent = now
+		if len(g.firing) == 0 {
+			delete(d.groups, key)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, n := range due {
+		for _, c := range d.channels {
+			cn := c.filter(n)
+			if cn == nil {
+				continue
+			}
+			d.wg.Add(1)
+			go func(c *channel, n *Notification) {
+				defer d.wg.Done()
+				d.deliver(d.ctx, c, n)
+			}(c, cn)
+		}
+	}
+}
+
+// snapshot 分组当前可推送的内容：未被屏蔽的 firing 告警，以及推送过且未被屏蔽的恢复
+func (d *Dispatcher) snapshot(g *group) *Notification {
+	return &Notification{
+		GroupKey:    g.key,
+		GroupLabels: g.labels,
+		Firing: sortedAlerts(g.firing, func(a alerting.Alert) bool {
+			return !d.muted(a)
+		}),
+		Resolved: sortedAlerts(g.resolved, func(a alerting.Alert) bool {
+			return g.notified[a.ID] && !d.muted(a)
+		}),
+	}
+}
+
+func (d *Dispatcher) muted(a alerting.Alert) bool {
+	return d.opts.Muted != nil && d.opts.Muted(a)
+}
+
+func sortedAlerts(m map[string]alerting.Alert, keep func(alerting.Alert) bool) []alerting.Alert {
+	out := make([]alerting.Alert, 0, len(m))
+	for _, a := range m {
+		if keep(a) {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if si, sj := alerting.SeverityRank(out[i].Severity), alerting.SeverityRank(out[j].Severity); si != sj {
+			return si > sj
+		}
+		return out[i].ActiveAt < out[j].ActiveAt
+	})
+	return out
+}
+
+// filter 按渠道的级别下限与 send_resolved 裁剪通知；没有可发内容时返回 nil
+func (c *channel) filter(n *Notification) *Notification {
+	out := &Notification{GroupKey: n.GroupKey, GroupLabels: n.GroupLabels, Test: n.Test}
+	for _, a := range n.Firing {
+		if alerting.SeverityRank(a.Severity) >= c.minRank {
+			out.Firing = append(out.Firing, a)
+		}
+	}
+	if c.cfg.SendResolved {
+		for _, a := range n.Resolved {
+			if alerting.SeverityRank(a.Severity) >= c.minRank {
+				out.Resolved = append(out.Resolved, a)
+			}
+		}
+	}
+	if len(out.Firing) == 0 && len(out.Resolved) == 0 {
+		return nil
+	}
+	out.Status = StatusResolved
+	if len(out.Firing) > 0 {
+		out.Status = StatusFiring
+	}
+	return out
+}
+
+// deliver 渲染并发送，可重试的失败指数退避，其余失败直接放弃
+func (d *Dispatcher) deliver(ctx context.Context, c *channel, n *Notification) {
+	if err := c.tmpl.render(n); err != nil {
+		c.recordFailure(fmt.Errorf("render: %w", err))
+		log.Printf("[Notify] channel %s: render %s: %v", c.cfg.Name, n.GroupKey, err)
+		return
+	}
+
+	backoff := d.opts.MinBackoff
+	for attempt := 0; ; attempt++ {
+		err := c.ch.Send(ctx, n)
+		if err == nil {
+			c.recordSuccess()
+			return
+		}
+
+		var rErr *retryableError
+		if !errors.As(err, &rErr) {
+			c.recordFailure(err)
+			log.Printf("[Notify] channel %s: dropping notification for %s: %v", c.cfg.Name, n.GroupKey, err)
+			return
+		}
+		if attempt >= d.opts.MaxRetries {
+			c.recordFailure(err)
+			log.Printf("[Notify] channel %s: giving up after %d retries for %s: %v", c.cfg.Name, attempt, n.GroupKey, err)
+			return
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff *= 2
+		if backoff > d.opts.MaxBackoff {
+			backoff = d.opts.MaxBackoff
+		}
+	}
+}
+
+func (c *channel) recordSuccess() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent++
+	c.lastSentAt = time.Now().UnixMilli()
+}
+
+func (c *channel) recordFailure(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failed++
+	c.lastError = err.Error()
+	c.lastErrorAt = time.Now().UnixMilli()
+}
+
+// ErrUnknownChannel 测试发送时渠道名不存在
+var ErrUnknownChannel = errors.New("unknown notification channel")
+
+// Test 向指定渠道同步发送一条测试告警，只尝试一次，把渠道返回的错误原样交给调用方
+func (d *Dispatcher) Test(ctx context.Context, name string) error {
+	for _, c := range d.channels {
+		if c.cfg.Name != name {
+			continue
+		}
+		now := time.Now().UnixMilli()
+		a := alerting.Alert{
+			ID:          "test",
+			Rule:        "NotificationTest",
+			Severity:    alerting.SeverityInfo,
+			NodeID:      "test-node",
+			Metric:      "cpu_usage_percent",
+			Op:          ">",
+			Threshold:   90,
+			Value:       95,
+			State:       alerting.StateFiring,
+			Annotations: map[string]string{"summary": "GeeGee notification test for channel " + name},
+			ActiveAt:    now,
+			FiredAt:     now,
+			LastEvalAt:  now,
+		}
+		n := &Notification{
+			GroupKey:    "rule=NotificationTest",
+			GroupLabels: map[string]string{"rule": a.Rule},
+			Status:      StatusFiring,
+			Firing:      []alerting.Alert{a},
+			Resolved:    []alerting.Alert{},
+			Test:        true,
+		}
+		if err := c.tmpl.render(n); err != nil {
+			return fmt.Errorf("render: %w", err)
+		}
+		if err := c.ch.Send(ctx, n); err != nil {
+			c.recordFailure(err)
+			return err
+		}
+		c.recordSuccess()
+		return nil
+	}
+	return ErrUnknownChannel
+}
+
+// Channels 各渠道状态，按配置顺序
+func (d *Dispatcher) Channels() []ChannelStatus {
+	out := make([]ChannelStatus, 0, len(d.channels))
+	for _, c := range d.channels {
+		c.mu.Lock()
+		out = append(out, ChannelStatus{
+			Name:         c.cfg.Name,
+			Type:         c.cfg.Type,
+			MinSeverity:  c.cfg.MinSeverity,
+			SendResolved: c.cfg.SendResolved,
+			Sent:         c.sent,
+			Failed:       c.failed,
+			LastSentAt:   c.lastSentAt,
+			LastError:    c.lastError,
+			LastErrorAt:  c.lastErrorAt,
+		})
+		c.mu.Unlock()
+	}
+	return out
+}
+
+// Close 停止分发并放弃仍在重试中的发送
+func (d *Dispatcher) Close() error {
+	d.cancel()
+	d.wg.Wait()
+	return nil
+}
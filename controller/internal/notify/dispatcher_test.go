@@ -0,0 +1,262 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+)
+
+// recordChannel 记录收到的通知，errs 非空时依次返回其中的错误
+type recordChannel struct {
+	mu    sync.Mutex
+	got   []*Notification
+	errs  []error
+	calls int
+}
+
+func (c *recordChannel) Send(_ context.Context, n *Notification) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	c.got = append(c.got, n)
+	return nil
+}
+
+func (c *recordChannel) take() []*Notification {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	got := c.got
+	c.got = nil
+	return got
+}
+
+// newTestDispatcher 不启动定时循环，由测试以指定时刻调用 flush
+func newTestDispatcher(t *testing.T, opts Options, cfg ChannelConfig) (*Dispatcher, *recordChannel) {
+	t.Helper()
+	opts.applyDefaults()
+	tmpl, err := parseTemplates(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordChannel{}
+	d := &Dispatcher{
+		opts:     opts,
+		groups:   make(map[string]*group),
+		channels: []*channel{{cfg: cfg, ch: rec, tmpl: tmpl, minRank: alerting.SeverityRank(cfg.MinSeverity)}},
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	t.Cleanup(func() { d.Close() })
+	return d, rec
+}
+
+// flushAt 在 now 时刻检查分组并等待发送完成
+func (d *Dispatcher) flushAt(now time.Time) {
+	d.flush(now)
+	d.wg.Wait()
+}
+
+func testAlert(id, rule, node string, state alerting.State) alerting.Alert {
+	return alerting.Alert{
+		ID:       id,
+		Rule:     rule,
+		Severity: alerting.SeverityWarning,
+		NodeID:   node,
+		Metric:   "cpu_usage_percent",
+		Op:       ">",
+		State:    state,
+		ActiveAt: time.Now().UnixMilli(),
+	}
+}
+
+func alertIDs(as []alerting.Alert) []string {
+	ids := make([]string, len(as))
+	for i, a := range as {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+func expectSent(t *testing.T, rec *recordChannel, firing, resolved []string) *Notification {
+	t.Helper()
+	got := rec.take()
+	if len(got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(got))
+	}
+	n := got[0]
+	if f, r := alertIDs(n.Firing), alertIDs(n.Resolved); !equalIDs(f, firing) || !equalIDs(r, resolved) {
+		t.Fatalf("firing %v resolved %v, want %v / %v", f, r, firing, resolved)
+	}
+	return n
+}
+
+func expectNothing(t *testing.T, rec *recordChannel) {
+	t.Helper()
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("unexpected notification: %s firing %v resolved %v", got[0].GroupKey, alertIDs(got[0].Firing), alertIDs(got[0].Resolved))
+	}
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+var testOpts = Options{
+	GroupWait:      30 * time.Second,
+	GroupInterval:  5 * time.Minute,
+	RepeatInterval: time.Hour,
+}
+
+func TestDispatcherGroupWait(t *testing.T) {
+	d, rec := newTestDispatcher(t, testOpts, ChannelConfig{Name: "rec", SendResolved: true})
+	base := time.Now()
+	d.Notify(testAlert("a", "HighCPU", "hk-01", alerting.StateFiring))
+	d.Notify(testAlert("b", "HighCPU", "hk-02", alerting.StateFiring))
+	d.Notify(testAlert("c", "Loss", "hk-01", alerting.StateFiring))
+
+	d.flushAt(base.Add(10 * time.Second))
+	expectNothing(t, rec)
+
+	d.flushAt(base.Add(31 * time.Second))
+	got := rec.take()
+	if len(got) != 2 {
+		t.Fatalf("got %d notifications, want one per rule", len(got))
+	}
+	for _, n := range got {
+		want := map[string][]string{"rule=HighCPU": {"a", "b"}, "rule=Loss": {"c"}}[n.GroupKey]
+		if !equalIDs(alertIDs(n.Firing), want) || n.Status != StatusFiring {
+			t.Fatalf("%s: status %s firing %v, want %v", n.GroupKey, n.Status, alertIDs(n.Firing), want)
+		}
+		if n.Title == "" || n.Message == "" {
+			t.Fatalf("%s: notification not rendered", n.GroupKey)
+		}
+	}
+}
+
+func TestDispatcherGroupIntervalAndRepeat(t *testing.T) {
+	d, rec := newTestDispatcher(t, testOpts, ChannelConfig{Name: "rec", SendResolved: true})
+	base := time.Now()
+	d.Notify(testAlert("a", "HighCPU", "hk-01", alerting.StateFiring))
+	sent := base.Add(31 * time.Second)
+	d.flushAt(sent)
+	expectSent(t, rec, []string{"a"}, nil)
+
+	// 分组内容未变化，到 RepeatInterval 才重复提醒
+	d.flushAt(sent.Add(10 * time.Minute))
+	expectNothing(t, rec)
+
+	// 新告警加入已发送过的分组，不早于上次发送 + GroupInterval
+	d.Notify(testAlert("b", "HighCPU", "hk-02", alerting.StateFiring))
+	d.flushAt(sent.Add(11 * time.Minute))
+	sent = sent.Add(11 * time.Minute)
+	expectSent(t, rec, []string{"a", "b"}, nil)
+
+	d.flushAt(sent.Add(59 * time.Minute))
+	expectNothing(t, rec)
+	d.flushAt(sent.Add(time.Hour))
+	expectSent(t, rec, []string{"a", "b"}, nil)
+}
+
+func TestDispatcherResolvedWaitsForGroupInterval(t *testing.T) {
+	d, rec := newTestDispatcher(t, testOpts, ChannelConfig{Name: "rec", SendResolved: true})
+	base := time.Now()
+	d.Notify(testAlert("a", "HighCPU", "hk-01", alerting.StateFiring))
+	sent := base.Add(31 * time.Second)
+	d.flushAt(sent)
+	expectSent(t, rec, []string{"a"}, nil)
+
+	// 刚发送过就恢复：恢复通知要等到 GroupInterval，期间分组不能被丢弃
+	resolved := testAlert("a", "HighCPU", "hk-01", alerting.StateResolved)
+	resolved.ResolvedAt = time.Now().UnixMilli()
+	d.Notify(resolved)
+	d.flushAt(sent.Add(time.Minute))
+	expectNothing(t, rec)
+
+	d.flushAt(sent.Add(5*time.Minute + time.Second))
+	n := expectSent(t, rec, nil, []string{"a"})
+	if n.Status != StatusResolved {
+		t.Fatalf("status %s, want resolved", n.Status)
+	}
+	if len(d.groups) != 0 {
+		t.Fatalf("%d groups left after resolving everything", len(d.groups))
+	}
+}
+
+func TestDispatcherResolvedBeforeNotified(t *testing.T) {
+	d, rec := newTestDispatcher(t, testOpts, ChannelConfig{Name: "rec", SendResolved: true})
+	base := time.Now()
+	d.Notify(testAlert("a", "HighCPU", "hk-01", alerting.StateFiring))
+	d.Notify(testAlert("a", "HighCPU", "hk-01", alerting.StateResolved))
+
+	// 从未推送过 firing 的告警，恢复时不通知
+	d.flushAt(base.Add(31 * time.Second))
+	expectNothing(t, rec)
+	if len(d.groups) != 0 {
+		t.Fatalf("%d groups left, want the empty group dropped", len(d.groups))
+	}
+}
+
+func TestDispatcherChannelFilter(t *testing.T) {
+	d, rec := newTestDispatcher(t, testOpts, ChannelConfig{Name: "rec", MinSeverity: alerting.SeverityCritical})
+	base := time.Now()
+	d.Notify(testAlert("a", "HighCPU", "hk-01", alerting.StateFiring))
+	crit := testAlert("b", "HighCPU", "hk-02", alerting.StateFiring)
+	crit.Severity = alerting.SeverityCritical
+	d.Notify(crit)
+	sent := base.Add(31 * time.Second)
+	d.flushAt(sent)
+	expectSent(t, rec, []string{"b"}, nil)
+
+	// send_resolved 关闭时恢复不推送
+	d.Notify(testAlert("b", "HighCPU", "hk-02", alerting.StateResolved))
+	d.flushAt(sent.Add(6 * time.Minute))
+	expectNothing(t, rec)
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	opts := testOpts
+	opts.MaxRetries = 2
+	opts.MinBackoff = time.Millisecond
+	opts.MaxBackoff = time.Millisecond
+	d, rec := newTestDispatcher(t, opts, ChannelConfig{Name: "rec"})
+	rec.errs = []error{&retryableError{errors.New("503")}, &retryableError{errors.New("429")}}
+	base := time.Now()
+	d.Notify(testAlert("a", "HighCPU", "hk-01", alerting.StateFiring))
+	d.flushAt(base.Add(31 * time.Second))
+	expectSent(t, rec, []string{"a"}, nil)
+	if rec.calls != 3 {
+		t.Fatalf("%d send attempts, want 3", rec.calls)
+	}
+
+	// 不可重试的错误只尝试一次
+	rec.errs, rec.calls = []error{errors.New("400")}, 0
+	d.Notify(testAlert("b", "HighCPU", "hk-02", alerting.StateFiring))
+	d.flushAt(base.Add(10 * time.Minute))
+	expectNothing(t, rec)
+	st := d.Channels()[0]
+	if rec.calls != 1 || st.Sent != 1 || st.Failed != 1 || st.LastError != "400" {
+		t.Fatalf("calls %d, status %+v", rec.calls, st)
+	}
+}
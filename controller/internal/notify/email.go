@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailChannel 通过 SMTP 发送纯文本邮件。支持直接 TLS (465) 与 STARTTLS (587)，有用户名时使用 PLAIN 认证
+type emailChannel struct {
+	cfg ChannelConfig
+}
+
+func (c *emailChannel) Send(ctx context.Context, n *Notification) error {
+	port := c.cfg.SMTPPort
+	if port == 0 {
+		port = 25
+		if c.cfg.TLS {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(c.cfg.SMTPHost, strconv.Itoa(port))
+
+	dialer := &net.Dialer{Timeout: c.cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if c.cfg.TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: c.cfg.SMTPHost}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return &retryableError{err}
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else if c.cfg.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.cfg.Timeout))
+	}
+
+	if err := c.deliver(conn, n); err != nil {
+		return smtpError(err)
+	}
+	return nil
+}
+
+func (c *emailChannel) deliver(conn net.Conn, n *Notification) error {
+	client, err := smtp.NewClient(conn, c.cfg.SMTPHost)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if c.cfg.StartTLS && !c.cfg.TLS {
+		if err := client.StartTLS(&tls.Config{ServerName: c.cfg.SMTPHost}); err != nil {
+			return err
+		}
+	}
+	if c.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.SMTPHost)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range c.cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(c.message(n)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message 组装邮件头与正文，标题按 RFC 2047 编码以支持中文
+func (c *emailChannel) message(n *Notification) []byte {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		buf.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", c.cfg.From)
+	header("To", strings.Join(c.cfg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", n.Title))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	if n.GroupKey != "" {
+		header("X-GeeGee-Group", n.GroupKey)
+	}
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(n.Message, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// smtpError 网络错误与 4xx 临时性失败可重试，5xx (如收件人不存在、认证失败) 不重试
+func smtpError(err error) error {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		if tpErr.Code/100 == 4 {
+			return &retryableError{err}
+		}
+		return fmt.Errorf("smtp: %w", err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &retryableError{err}
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+// Package notify 将告警按分组合并后推送到各通知渠道：通用 Webhook、SMTP 邮件以及钉钉 / 飞书 / 企业微信 / Telegram 机器人
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+)
+
+// 通知状态：组内仍有 firing 告警即为 firing
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Notification 一次推送的内容，即一个分组在某一时刻的快照
+type Notification struct {
+	GroupKey    string            `json:"group_key"`
+	GroupLabels map[string]string `json:"group_labels"`
+	Status      string            `json:"status"`
+	Firing      []alerting.Alert  `json:"firing"`
+	Resolved    []alerting.Alert  `json:"resolved"`
+	Test        bool              `json:"test,omitempty"` // 测试发送
+	Title       string            `json:"title"`          // 按渠道模板渲染
+	Message     string            `json:"message"`
+}
+
+// Group 分组标签的文本形式，如 "rule=HighCPU node_id=hk-01"
+func (n *Notification) Group() string {
+	keys := make([]string, 0, len(n.GroupLabels))
+	for k := range n.GroupLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + n.GroupLabels[k]
+	}
+	return strings.Join(parts, " ")
+}
+
+// Channel 一个通知渠道。Send 返回 retryableError 时由分发器退避重试
+type Channel interface {
+	Send(ctx context.Context, n *Notification) error
+}
+
+// ChannelConfig 渠道配置，各类型只读取自己用到的字段
+type ChannelConfig struct {
+	Name         string
+	Type         string // webhook / email / dingtalk / feishu / wecom / telegram
+	MinSeverity  string // 低于该级别的告警不发给此渠道
+	SendResolved bool
+	Title        string // 标题模板，留空用默认
+	Message      string // 正文模板，留空用默认
+	Timeout      time.Duration
+
+	// webhook / 机器人
+	URL     string
+	Secret  string // webhook: HMAC-SHA256 签名密钥；钉钉 / 飞书: 机器人加签密钥
+	Headers map[string]string
+
+	// email
+	SMTPHost string
+	SMTPPort int
+	Username string
+	Password string
+	From     string
+	To       []string
+	StartTLS bool // 明文连接后升级 (587)
+	TLS      bool // 直接 TLS 连接 (465)
+
+	// telegram
+	BotToken string
+	ChatID   string
+	APIURL   string // 默认 https://api.telegram.org，测试或代理时可改
+}
+
+// newChannel 按类型创建渠道
+func newChannel(cfg ChannelConfig) (Channel, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+	switch cfg.Type {
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook channel needs url")
+		}
+		return &webhookChannel{url: cfg.URL, secret: cfg.Secret, headers: cfg.Headers, client: client}, nil
+	case "dingtalk":
+		return newBotChannel(cfg, client, dingtalkBot{})
+	case "feishu":
+		return newBotChannel(cfg, client, feishuBot{})
+	case "wecom":
+		return newBotChannel(cfg, client, wecomBot{})
+	case "telegram":
+		if cfg.BotToken == "" || cfg.ChatID == "" {
+			return nil, fmt.Errorf("telegram channel needs bot_token and chat_id")
+		}
+		api := cfg.APIURL
+		if api == "" {
+			api = "https://api.telegram.org"
+		}
+		return &telegramChannel{url: strings.TrimRight(api, "/") + "/bot" + cfg.BotToken + "/sendMessage", chatID: cfg.ChatID, client: client}, nil
+	case "email":
+		if cfg.SMTPHost == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("email channel needs smtp host, from and to")
+		}
+		return &emailChannel{cfg: cfg}, nil
+	}
+	return nil, fmt.Errorf("unknown channel type %q", cfg.Type)
+}
+
+// 默认模板。可用字段见 Notification，另有 upper / time (Unix 毫秒格式化) 两个函数
+const (
+	defaultTitle   = `[{{upper .Status}}{{if .Firing}}:{{len .Firing}}{{end}}] {{.Group}}`
+	defaultMessage = `{{range .Firing}}[{{upper .Severity}}] {{.Rule}} on {{.NodeID}}{{if .Target}} -> {{.Target}}{{end}}: {{.Metric}}={{printf "%.4g" .Value}} {{.Op}} {{.Threshold}} since {{time .FiredAt}}
+{{with .Annotations.summary}}  {{.}}
+{{end}}{{end}}{{range .Resolved}}[RESOLVED] {{.Rule}} on {{.NodeID}}{{if .Target}} -> {{.Target}}{{end}} at {{time .ResolvedAt}}
+{{end}}`
+)
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"time": func(ms int64) string {
+		if ms == 0 {
+			return "-"
+		}
+		return time.UnixMilli(ms).Format("2006-01-02 15:04:05")
+	},
+}
+
+// templates 渠道的标题与正文模板
+type templates struct {
+	title, message *template.Template
+}
+
+func parseTemplates(cfg ChannelConfig) (templates, error) {
+	title, message := cfg.Title, cfg.Message
+	if title == "" {
+		title = defaultTitle
+	}
+	if message == "" {
+		message = defaultMessage
+	}
+	var t templates
+	var err error
+	if t.title, err = template.New("title").Funcs(templateFuncs).Parse(title); err != nil {
+		return t, fmt.Errorf("title template: %w", err)
+	}
+	if t.message, err = template.New("message").Funcs(templateFuncs).Parse(message); err != nil {
+		return t, fmt.Errorf("message template: %w", err)
+	}
+	return t, nil
+}
+
+// render 渲染标题与正文，测试发送带 [TEST] 前缀
+func (t templates) render(n *Notification) error {
+	var buf bytes.Buffer
+	if err := t.title.Execute(&buf, n); err != nil {
+		return err
+	}
+	n.Title = strings.TrimSpace(buf.String())
+	if n.Test {
+		n.Title = "[TEST] " + n.Title
+	}
+	buf.Reset()
+	if err := t.message.Execute(&buf, n); err != nil {
+		return err
+	}
+	n.Message = strings.TrimSpace(buf.String())
+	return nil
+}
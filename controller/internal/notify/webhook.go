@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 通用 Webhook 的签名头。接收方用同一密钥计算 HMAC-SHA256(timestamp + "." + body) 比对，
+// 并拒绝时间戳偏差过大的请求以防重放
+const (
+	HeaderTimestamp = "X-GeeGee-Timestamp"
+	HeaderSignature = "X-GeeGee-Signature"
+)
+
+// webhookPayload 通用 Webhook 的请求体
+type webhookPayload struct {
+	Version string `json:"version"`
+	*Notification
+}
+
+type webhookChannel struct {
+	url     string
+	secret  string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (c *webhookChannel) Send(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(webhookPayload{Version: "1", Notification: n})
+	if err != nil {
+		return err
+	}
+	headers := make(map[string]string, len(c.headers)+2)
+	for k, v := range c.headers {
+		headers[k] = v
+	}
+	if c.secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		headers[HeaderTimestamp] = ts
+		headers[HeaderSignature] = "sha256=" + Sign(c.secret, ts, body)
+	}
+	_, err = postJSON(ctx, c.client, c.url, headers, body)
+	return err
+}
+
+// Sign 计算通用 Webhook 的签名 (十六进制)，接收方可直接复用
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryableError 标记可以重试的失败（网络错误、5xx、429）
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+// postJSON 发送一次请求并返回响应体。5xx/429 与网络错误可重试，其余 4xx 视为配置有误
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GeeGee-Controller")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &retryableError{err}
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode/100 == 2 {
+		return respBody, nil
+	}
+	err = fmt.Errorf("server returned HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(truncate(respBody, 512)))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &retryableError{err}
+	}
+	return nil, err
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
@@ -1,8 +1,13 @@
 package server
 
 import (
+	"fmt"
 	"io"
 	"log"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/peer"
 
 	pb "github.com/geelinx-ltd/geegee/api/proto"
 	"github.com/geelinx-ltd/geegee/controller/internal/storage"
@@ -11,21 +16,92 @@ import (
 // GrpcServer 实现了 geegeepb.ProbeServiceServer 接口
 type GrpcServer struct {
 	pb.UnimplementedProbeServiceServer
-	db    *storage.TSDB
 	cache storage.Persister
+	sinks []storage.Sink // 额外的只写出口：VictoriaMetrics、remote_write 等
+
+	observers []StreamObserver
+	targets   TargetSource
+	tasks     TaskBroker
+}
+
+// TargetSource 按节点分配探测目标，返回空表示节点沿用本地列表
+type TargetSource interface {
+	ProbeTargets(nodeID string) []*pb.ProbeTarget
+}
+
+// StreamObserver 关注上报流的生命周期。节点 ID 取自流上的首帧
+type StreamObserver interface {
+	StreamOpened(nodeID, remoteAddr string)
+	// StreamClosed err 为 nil 表示节点正常关闭 (EOF)
+	StreamClosed(nodeID string, err error)
+}
+
+// TaskBroker 向节点推送一次性诊断任务并接收结果
+type TaskBroker interface {
+	// Attach 节点的上报流建立，返回待下发任务的通道；detach 在流结束时调用，之后通道被关闭
+	Attach(nodeID string) (tasks <-chan *pb.Task, detach func())
+	Complete(nodeID string, r *pb.TaskResult)
 }
 
-func NewGrpcServer(db *storage.TSDB, cache storage.Persister) *GrpcServer {
+func NewGrpcServer(cache storage.Persister, sinks ...storage.Sink) *GrpcServer {
 	return &GrpcServer{
-		db:    db,
 		cache: cache,
+		sinks: sinks,
+	}
+}
+
+// Observe 注册流生命周期观察者，须在开始服务前调用
+func (s *GrpcServer) Observe(o StreamObserver) {
+	s.observers = append(s.observers, o)
+}
+
+// AssignTargets 接入探测目标分配，须在开始服务前调用。分配结果变化后随下一帧的响应下发
+func (s *GrpcServer) AssignTargets(src TargetSource) {
+	s.targets = src
+}
+
+// HandleTasks 接入诊断任务，须在开始服务前调用。任务不等上报帧，由单独的协程立即推给节点
+func (s *GrpcServer) HandleTasks(b TaskBroker) {
+	s.tasks = b
+}
+
+// forwardTasks 把任务推给节点，直到通道关闭
+func (s *GrpcServer) forwardTasks(nodeID string, tasks <-chan *pb.Task, send func(*pb.ReportResponse) error) {
+	for t := range tasks {
+		log.Printf("Dispatching task %s (%s %s) to node [%s]", t.Id, t.Type, t.Target, nodeID)
+		if err := send(&pb.ReportResponse{Success: true, Message: "task", Tasks: []*pb.Task{t}}); err != nil {
+			s.tasks.Complete(nodeID, &pb.TaskResult{Id: t.Id, Error: "send to node: " + err.Error()})
+		}
 	}
 }
 
 // ReportMetrics 接收并处理来自于 Node 端上报的高频汇算数据
-func (s *GrpcServer) ReportMetrics(stream pb.ProbeService_ReportMetricsServer) error {
+func (s *GrpcServer) ReportMetrics(stream pb.ProbeService_ReportMetricsServer) (err error) {
 	log.Printf("New streaming connection established from a probe node.")
 
+	remoteAddr := ""
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		remoteAddr = p.Addr.String()
+	}
+	// 流上识别出的节点，退出时通知观察者
+	nodeID := ""
+	// 本条流上最近一次下发的目标列表，未变化时不重复下发；新流的首个心跳总是下发，让节点与当前分配对齐
+	sentTargets, targetsSent := "", false
+	// 心跳与任务推送来自不同协程，gRPC 流不允许并发 Send
+	var sendMu sync.Mutex
+	send := func(resp *pb.ReportResponse) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(resp)
+	}
+	detachTasks := func() {}
+	defer func() {
+		detachTasks()
+		if nodeID != "" {
+			s.streamClosed(nodeID, err)
+		}
+	}()
+
 	for {
 		req, err := stream.Recv()
 		if err == io.EOF {
@@ -37,6 +113,33 @@ func (s *GrpcServer) ReportMetrics(stream pb.ProbeService_ReportMetricsServer) e
 			return err
 		}
 
+		if req.NodeId != nodeID {
+			if nodeID != "" {
+				s.streamClosed(nodeID, nil)
+			}
+			nodeID = req.NodeId
+			targetsSent = false
+			for _, o := range s.observers {
+				o.StreamOpened(nodeID, remoteAddr)
+			}
+			if s.tasks != nil {
+				detachTasks()
+				ch, detach := s.tasks.Attach(nodeID)
+				detachTasks = detach
+				go s.forwardTasks(nodeID, ch, send)
+			}
+		}
+
+		// 诊断任务结果；只回传结果的帧不带指标，不进入存储与各出口
+		if s.tasks != nil {
+			for _, r := range req.TaskResults {
+				s.tasks.Complete(nodeID, r)
+			}
+		}
+		if len(req.TaskResults) > 0 && req.Cpu == nil {
+			continue
+		}
+
 		// 这里处理数据，例如打印或写入时序数据库
 		pingCount := len(req.PingResults)
 		var avgRtt float64
@@ -47,27 +150,57 @@ func (s *GrpcServer) ReportMetrics(stream pb.ProbeService_ReportMetricsServer) e
 		log.Printf("Recv from Node [%s]: CPU Load1=%.2f, MEM Used=%.2f%%, NET Burst=%d, Pings=%d (Target1 Avg: %.2fms)",
 			req.NodeId, req.Cpu.Load1, req.Mem.UsedPercent, req.Net.MicroburstEvents, pingCount, avgRtt)
 
+		// 主存储队列满时这里会阻塞，从而放慢该节点的上报流
 		if s.cache != nil {
-			s.cache.Ingest(req)
+			if err := s.cache.Ingest(req); err != nil {
+				log.Printf("Storage ingestion failed for node [%s]: %v", req.NodeId, err)
+			}
 		}
 
-		// 异步吸入 TSDB (避免阻塞 gRPC 接收主流)
-		if s.db != nil {
-			go func(r *pb.ReportRequest) {
-				if err := s.db.Ingest(r); err != nil {
-					log.Printf("TSDB Ingestion failed: %v", err)
-				}
-			}(req)
+		// 推送至各只写出口。出口自身负责异步缓冲，这里按序投递以保证同一节点的时间顺序
+		for _, sink := range s.sinks {
+			if err := sink.Ingest(req); err != nil {
+				log.Printf("Sink ingestion failed: %v", err)
+			}
 		}
 
-		// 可选：发送下行指令心跳
-		err = stream.Send(&pb.ReportResponse{
+		// 下行心跳，分配的探测目标有变化时一并下发
+		resp := &pb.ReportResponse{
 			Success: true,
 			Message: "ok",
-		})
+		}
+		if s.targets != nil {
+			targets := s.targets.ProbeTargets(nodeID)
+			if key := targetsKey(targets); !targetsSent || key != sentTargets {
+				resp.ProbeTargets = targets
+				resp.TargetsAssigned = true
+				if len(targets) > 0 {
+					log.Printf("Assigning %d probe targets to node [%s]", len(targets), nodeID)
+				} else if sentTargets != "" {
+					log.Printf("Node [%s] no longer matches any probe rule, restoring its local targets", nodeID)
+				}
+				sentTargets, targetsSent = key, true
+			}
+		}
+		err = send(resp)
 		if err != nil {
 			log.Printf("Error sending response to stream: %v", err)
 			return err
 		}
 	}
 }
+
+func (s *GrpcServer) streamClosed(nodeID string, err error) {
+	for _, o := range s.observers {
+		o.StreamClosed(nodeID, err)
+	}
+}
+
+// targetsKey 目标列表的指纹，用于判断是否需要重新下发
+func targetsKey(targets []*pb.ProbeTarget) string {
+	var b strings.Builder
+	for _, t := range targets {
+		fmt.Fprintf(&b, "%s|%s|%d;", t.TargetType, t.Ip, t.Port)
+	}
+	return b.String()
+}
@@ -0,0 +1,410 @@
+// Package silence 管理静默与周期性维护窗口：命中的告警照常记录状态，只是不再推送通知
+package silence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/alerting"
+)
+
+// 匹配器可用的名字，其余名字取告警规则 labels 中的同名标签
+const (
+	LabelAlertName = "alertname"
+	LabelNodeID    = "node_id"
+	LabelGroup     = "group"
+	LabelTarget    = "target"
+	LabelSeverity  = "severity"
+	LabelMetric    = "metric"
+)
+
+// 静默状态，由起止时间推导
+const (
+	StatePending = "pending"
+	StateActive  = "active"
+	StateExpired = "expired"
+)
+
+// Matcher 一条匹配条件，value 为 path.Match 通配 (与告警规则的 nodes / targets 相同)
+type Matcher struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (m Matcher) validate() error {
+	if m.Name == "" {
+		return errors.New("matcher needs name")
+	}
+	if _, err := path.Match(m.Value, ""); err != nil {
+		return fmt.Errorf("bad pattern %q: %w", m.Value, err)
+	}
+	return nil
+}
+
+// nodeLevel 只约束节点本身的匹配器，全部为此类时视为整机维护
+func (m Matcher) nodeLevel() bool {
+	return m.Name == LabelNodeID || m.Name == LabelGroup
+}
+
+// matchAll 所有匹配器都满足才算命中；无匹配器的静默不命中任何告警
+func matchAll(ms []Matcher, labels map[string]string) bool {
+	if len(ms) == 0 {
+		return false
+	}
+	for _, m := range ms {
+		if ok, _ := path.Match(m.Value, labels[m.Name]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Labels 告警用于匹配的标签集：内置字段优先于规则上的同名 labels
+func Labels(a alerting.Alert) map[string]string {
+	out := make(map[string]string, len(a.Labels)+5)
+	for k, v := range a.Labels {
+		out[k] = v
+	}
+	out[LabelAlertName] = a.Rule
+	out[LabelNodeID] = a.NodeID
+	out[LabelTarget] = a.Target
+	out[LabelSeverity] = a.Severity
+	out[LabelMetric] = a.Metric
+	return out
+}
+
+// Silence 一次性静默
+type Silence struct {
+	ID        string    `json:"id"`
+	Matchers  []Matcher `json:"matchers"`
+	StartsAt  int64     `json:"starts_at"` // Unix 毫秒，创建时为 0 表示立即
+	EndsAt    int64     `json:"ends_at"`
+	CreatedBy string    `json:"created_by"`
+	Comment   string    `json:"comment"`
+	CreatedAt int64     `json:"created_at"`
+	UpdatedAt int64     `json:"updated_at"`
+}
+
+// State 按当前时间推导状态
+func (s *Silence) State(now int64) string {
+	switch {
+	case now < s.StartsAt:
+		return StatePending
+	case now < s.EndsAt:
+		return StateActive
+	}
+	return StateExpired
+}
+
+// MarshalJSON 附带推导出的 state，便于前端展示
+func (s *Silence) MarshalJSON() ([]byte, error) {
+	type plain Silence
+	return json.Marshal(struct {
+		*plain
+		State string `json:"state"`
+	}{(*plain)(s), s.State(time.Now().UnixMilli())})
+}
+
+func (s *Silence) validate() error {
+	if len(s.Matchers) == 0 {
+		return errors.New("silence needs at least one matcher")
+	}
+	for _, m := range s.Matchers {
+		if err := m.validate(); err != nil {
+			return err
+		}
+	}
+	if s.EndsAt <= s.StartsAt {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if s.CreatedBy == "" {
+		return errors.New("created_by is required")
+	}
+	return nil
+}
+
+// Options 静默存储参数
+type Options struct {
+	Path      string        // 持久化文件，留空只保存在内存
+	Retention time.Duration // 过期静默保留多久后清理
+}
+
+var (
+	// ErrNotFound 指定 ID 的静默或维护窗口不存在
+	ErrNotFound = errors.New("silence or maintenance window not found")
+	// ErrInvalid 提交的静默或维护窗口未通过校验
+	ErrInvalid = errors.New("invalid silence or maintenance window")
+)
+
+// Store 保存静默与维护窗口，并回答某条告警当前是否被屏蔽
+type Store struct {
+	opts Options
+
+	mu       sync.RWMutex
+	silences map[string]*Silence
+	windows  map[string]*Window
+	// groups 节点所属分组，由节点元数据提供；未设置时 group 匹配器只看告警 labels
+	groups func(nodeID string) []string
+}
+
+type persisted struct {
+	Silences []*Silence `json:"silences"`
+	Windows  []*Window  `json:"windows"`
+}
+
+// NewStore 读取上次保存的静默与维护窗口
+func NewStore(opts Options) (*Store, error) {
+	if opts.Retention <= 0 {
+		opts.Retention = 7 * 24 * time.Hour
+	}
+	s := &Store{opts: opts, silences: make(map[string]*Silence), windows: make(map[string]*Window)}
+	if opts.Path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p persisted
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	for _, sil := range p.Silences {
+		s.silences[sil.ID] = sil
+	}
+	for _, w := range p.Windows {
+		if err := w.validate(); err != nil {
+			log.Printf("[Silence] dropping invalid maintenance window %s: %v", w.ID, err)
+			continue
+		}
+		s.windows[w.ID] = w
+	}
+	return s, nil
+}
+
+// save 调用方持有写锁
+func (s *Store) save() error {
+	if s.opts.Path == "" {
+		return nil
+	}
+	p := persisted{Silences: make([]*Silence, 0, len(s.silences)), Windows: make([]*Window, 0, len(s.windows))}
+	for _, sil := range s.silences {
+		p.Silences = append(p.Silences, sil)
+	}
+	for _, w := range s.windows {
+		p.Windows = append(p.Windows, w)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.opts.Path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.opts.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.opts.Path)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SetGroups 接入节点分组查询，供 group 匹配器使用
+func (s *Store) SetGroups(f func(nodeID string) []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups = f
+}
+
+// PutSilence 创建或更新静默 (ID 为空时创建)。StartsAt 为 0 表示立即生效
+func (s *Store) PutSilence(sil Silence) (*Silence, error) {
+	now := time.Now().UnixMilli()
+	if sil.StartsAt == 0 {
+		sil.StartsAt = now
+	}
+	if err := sil.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gc(now)
+	if sil.ID == "" {
+		sil.ID = newID()
+		sil.CreatedAt = now
+	} else {
+		old, ok := s.silences[sil.ID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		sil.CreatedAt = old.CreatedAt
+	}
+	sil.UpdatedAt = now
+	s.silences[sil.ID] = &sil
+	log.Printf("[Silence] %s saved by %s until %s: %s", sil.ID, sil.CreatedBy, time.UnixMilli(sil.EndsAt).Format(time.RFC3339), sil.Comment)
+	return &sil, s.save()
+}
+
+// ExpireSilence 立即结束静默，保留记录直到超过保留期
+func (s *Store) ExpireSilence(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sil, ok := s.silences[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now().UnixMilli()
+	if sil.State(now) == StateExpired {
+		return nil
+	}
+	if sil.StartsAt > now {
+		sil.StartsAt = now
+	}
+	sil.EndsAt = now
+	sil.UpdatedAt = now
+	return s.save()
+}
+
+// Silences 全部静默，按结束时间倒序
+func (s *Store) Silences() []*Silence {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Silence, 0, len(s.silences))
+	for _, sil := range s.silences {
+		c := *sil
+		out = append(out, &c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].EndsAt > out[j].EndsAt })
+	return out
+}
+
+// gc 清理超过保留期的过期静默，调用方持有写锁
+func (s *Store) gc(now int64) {
+	cutoff := now - s.opts.Retention.Milliseconds()
+	for id, sil := range s.silences {
+		if sil.EndsAt < cutoff {
+			delete(s.silences, id)
+		}
+	}
+}
+
+// labels 告警标签集，附带节点所属分组；调用方持有读锁
+func (s *Store) labels(a alerting.Alert) []map[string]string {
+	base := Labels(a)
+	if s.groups == nil {
+		return []map[string]string{base}
+	}
+	groups := s.groups(a.NodeID)
+	if len(groups) == 0 {
+		return []map[string]string{base}
+	}
+	// 节点属于多个分组时，任一分组满足即可
+	out := make([]map[string]string, 0, len(groups))
+	for _, g := range groups {
+		l := make(map[string]string, len(base)+1)
+		for k, v := range base {
+			l[k] = v
+		}
+		l[LabelGroup] = g
+		out = append(out, l)
+	}
+	return out
+}
+
+// Muted 告警当前是否被某条静默或维护窗口屏蔽，返回命中者的 ID
+func (s *Store) Muted(a alerting.Alert) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	var ids []string
+	for _, l := range s.labels(a) {
+		for _, sil := range s.silences {
+			if sil.State(now.UnixMilli()) == StateActive && matchAll(sil.Matchers, l) {
+				ids = appendUnique(ids, sil.ID)
+			}
+		}
+		for _, w := range s.windows {
+			if _, ok := w.activeAt(now); ok && matchAll(w.Matchers, l) {
+				ids = appendUnique(ids, w.ID)
+			}
+		}
+	}
+	return ids
+}
+
+func appendUnique(ids []string, id string) []string {
+	for _, v := range ids {
+		if v == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+// Maintenance 节点当前所处的维护：只含节点级匹配器 (node_id / group) 的静默或窗口
+type Maintenance struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"` // silence / window
+	Name    string `json:"name,omitempty"`
+	Comment string `json:"comment,omitempty"`
+	By      string `json:"created_by"`
+	EndsAt  int64  `json:"ends_at"`
+}
+
+// NodeMaintenance 节点正在进行的维护，用于节点卡片展示
+func (s *Store) NodeMaintenance(nodeID string) []Maintenance {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	nodeLabels := []map[string]string{{LabelNodeID: nodeID}}
+	if s.groups != nil {
+		for _, g := range s.groups(nodeID) {
+			nodeLabels = append(nodeLabels, map[string]string{LabelNodeID: nodeID, LabelGroup: g})
+		}
+	}
+	covers := func(ms []Matcher) bool {
+		for _, m := range ms {
+			if !m.nodeLevel() {
+				return false
+			}
+		}
+		for _, l := range nodeLabels {
+			if matchAll(ms, l) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var out []Maintenance
+	for _, sil := range s.silences {
+		if sil.State(now.UnixMilli()) == StateActive && covers(sil.Matchers) {
+			out = append(out, Maintenance{ID: sil.ID, Kind: "silence", Comment: sil.Comment, By: sil.CreatedBy, EndsAt: sil.EndsAt})
+		}
+	}
+	for _, w := range s.windows {
+		if end, ok := w.activeAt(now); ok && covers(w.Matchers) {
+			out = append(out, Maintenance{ID: w.ID, Kind: "window", Name: w.Name, Comment: w.Comment, By: w.CreatedBy, EndsAt: end.UnixMilli()})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].EndsAt > out[j].EndsAt })
+	return out
+}
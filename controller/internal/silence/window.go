@@ -0,0 +1,208 @@
+package silence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // 主机缺少 zoneinfo 时窗口的 timezone 仍可解析
+)
+
+// maxWindowDuration 单次维护时长上限，超过一周的需求用一次性静默表达
+const maxWindowDuration = 7 * 24 * time.Hour
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Window 周期性维护窗口：在 weekdays 的 start 时刻开始，持续 duration
+//
+//	weekdays: mon..sun，空表示每天
+//	start:    "HH:MM"，按 timezone 解释 (IANA 名称，默认主控本地时区)
+//	duration: 如 "2h30m"，可跨零点
+//	from/until: 可选的生效区间 (Unix 毫秒)，0 表示不限
+type Window struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Matchers  []Matcher `json:"matchers"`
+	Weekdays  []string  `json:"weekdays,omitempty"`
+	Start     string    `json:"start"`
+	Duration  string    `json:"duration"`
+	Timezone  string    `json:"timezone,omitempty"`
+	From      int64     `json:"from,omitempty"`
+	Until     int64     `json:"until,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt int64     `json:"created_at"`
+
+	days     map[time.Weekday]bool
+	hour     int
+	minute   int
+	duration time.Duration
+	loc      *time.Location
+}
+
+func (w *Window) validate() error {
+	if w.Name == "" {
+		return errors.New("window needs a name")
+	}
+	if len(w.Matchers) == 0 {
+		return errors.New("window needs at least one matcher")
+	}
+	for _, m := range w.Matchers {
+		if err := m.validate(); err != nil {
+			return err
+		}
+	}
+	if w.CreatedBy == "" {
+		return errors.New("created_by is required")
+	}
+
+	w.days = make(map[time.Weekday]bool, len(w.Weekdays))
+	for _, d := range w.Weekdays {
+		wd, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q (want mon..sun)", d)
+		}
+		w.days[wd] = true
+	}
+	t, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return fmt.Errorf("start must be HH:MM: %w", err)
+	}
+	w.hour, w.minute = t.Hour(), t.Minute()
+	if w.duration, err = time.ParseDuration(w.Duration); err != nil {
+		return fmt.Errorf("duration: %w", err)
+	}
+	if w.duration <= 0 || w.duration > maxWindowDuration {
+		return fmt.Errorf("duration must be between 0 and %v", maxWindowDuration)
+	}
+	w.loc = time.Local
+	if w.Timezone != "" {
+		if w.loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("timezone: %w", err)
+		}
+	}
+	if w.Until != 0 && w.Until <= w.From {
+		return errors.New("until must be after from")
+	}
+	return nil
+}
+
+// occurrence 某天的开始时刻；该天不在 weekdays 中时返回 false
+func (w *Window) occurrence(day time.Time) (time.Time, bool) {
+	if len(w.days) > 0 && !w.days[day.Weekday()] {
+		return time.Time{}, false
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), w.hour, w.minute, 0, 0, w.loc), true
+}
+
+func (w *Window) inRange(t time.Time) bool {
+	ms := t.UnixMilli()
+	return (w.From == 0 || ms >= w.From) && (w.Until == 0 || ms < w.Until)
+}
+
+// activeAt t 时刻是否处于某次维护中，返回该次的结束时间
+func (w *Window) activeAt(t time.Time) (time.Time, bool) {
+	if !w.inRange(t) {
+		return time.Time{}, false
+	}
+	local := t.In(w.loc)
+	// 时长不超过一周，往前看 8 天足以覆盖所有可能仍在进行的一次
+	for i := 0; i <= 8; i++ {
+		start, ok := w.occurrence(local.AddDate(0, 0, -i))
+		if !ok || start.After(t) {
+			continue
+		}
+		if end := start.Add(w.duration); t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// next t 之后下一次开始的时间，超出生效区间时返回零值
+func (w *Window) next(t time.Time) time.Time {
+	local := t.In(w.loc)
+	for i := 0; i <= 8; i++ {
+		start, ok := w.occurrence(local.AddDate(0, 0, i))
+		if !ok || !start.After(t) {
+			continue
+		}
+		if w.Until != 0 && start.UnixMilli() >= w.Until {
+			return time.Time{}
+		}
+		return start
+	}
+	return time.Time{}
+}
+
+// MarshalJSON 附带当前是否生效与下一次开始时间
+func (w *Window) MarshalJSON() ([]byte, error) {
+	type plain Window
+	now := time.Now()
+	end, active := w.activeAt(now)
+	out := struct {
+		*plain
+		Active    bool  `json:"active"`
+		EndsAt    int64 `json:"ends_at,omitempty"`
+		NextStart int64 `json:"next_start,omitempty"`
+	}{plain: (*plain)(w), Active: active}
+	if active {
+		out.EndsAt = end.UnixMilli()
+	}
+	if next := w.next(now); !next.IsZero() {
+		out.NextStart = next.UnixMilli()
+	}
+	return json.Marshal(out)
+}
+
+// PutWindow 创建或更新维护窗口 (ID 为空时创建)
+func (s *Store) PutWindow(w Window) (*Window, error) {
+	if err := w.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w.ID == "" {
+		w.ID = newID()
+		w.CreatedAt = time.Now().UnixMilli()
+	} else {
+		old, ok := s.windows[w.ID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		w.CreatedAt = old.CreatedAt
+	}
+	s.windows[w.ID] = &w
+	log.Printf("[Silence] maintenance window %s (%s) saved by %s", w.ID, w.Name, w.CreatedBy)
+	return &w, s.save()
+}
+
+// DeleteWindow 删除维护窗口
+func (s *Store) DeleteWindow(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.windows[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.windows, id)
+	return s.save()
+}
+
+// Windows 全部维护窗口，按名称排序
+func (s *Store) Windows() []*Window {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Window, 0, len(s.windows))
+	for _, w := range s.windows {
+		c := *w
+		out = append(out, &c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,59 @@
+package sla
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+//go:embed report.html
+var reportHTML string
+
+var reportTmpl = template.Must(template.New("sla").Funcs(template.FuncMap{
+	"pct":      formatPercent,
+	"duration": formatDuration,
+	// 依赖报表时区，渲染时替换
+	"time":   func(int64) string { return "" },
+	"period": func(int64) string { return "" },
+}).Parse(reportHTML))
+
+// WriteHTML 输出可直接打印 (或由浏览器另存为 PDF) 的 HTML 报表
+func (rep *Report) WriteHTML(w io.Writer) error {
+	loc := rep.loc
+	if loc == nil {
+		loc = time.Local
+	}
+	layout := "2006-01-02 15:04"
+	periodLayout := "2006-01-02"
+	if rep.Granularity == Month {
+		periodLayout = "2006-01"
+	}
+	tmpl, err := reportTmpl.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl.Funcs(template.FuncMap{
+		"time":   func(ms int64) string { return time.UnixMilli(ms).In(loc).Format(layout) },
+		"period": func(ms int64) string { return time.UnixMilli(ms).In(loc).Format(periodLayout) },
+	})
+	return tmpl.Execute(w, rep)
+}
+
+func formatPercent(v *float64) string {
+	if v == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f%%", *v)
+}
+
+// formatDuration 精确到秒，超过一天时带天数
+func formatDuration(ms int64) string {
+	d := (time.Duration(ms) * time.Millisecond).Round(time.Second)
+	if d < 24*time.Hour {
+		return d.String()
+	}
+	days := d / (24 * time.Hour)
+	return fmt.Sprintf("%dd%s", days, d-days*24*time.Hour)
+}
@@ -0,0 +1,363 @@
+// Package sla 按节点计算可用性 (可达性来自节点状态机，逐目标成功率来自 Ping 丢包)，
+// 给出日 / 月汇总与宕机事件列表，供 /api/sla 与可打印报表使用
+package sla
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/nodestate"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+// Granularity 汇总粒度
+type Granularity string
+
+const (
+	Day   Granularity = "day"
+	Month Granularity = "month"
+)
+
+// maxSpan 单次报表允许的最长时间范围
+const maxSpan = 400 * 24 * time.Hour
+
+// pingStep 逐目标成功率按小时桶查询，再归入所在的日 / 月
+const pingStep = time.Hour
+
+// ErrInvalid 查询参数不合法
+var ErrInvalid = errors.New("invalid sla query")
+
+// Options 报表参数
+type Options struct {
+	Target      float64        // 可用性目标，百分比，如 99.9
+	Location    *time.Location // 日 / 月边界所在时区，默认主控本地时区
+	StaleAsDown bool           // stale 计为不可用，默认只有 offline 计为不可用
+}
+
+// Query 一次报表请求
+type Query struct {
+	Nodes       []string // 空表示全部已知节点
+	From, To    int64    // Unix 毫秒，[From, To)
+	Granularity Granularity
+	Target      float64 // 0 表示使用默认目标
+}
+
+// Summary 一段时间内的可达性统计。状态未知 (节点尚未出现、早于保留期或未来) 的时间不计入分母
+type Summary struct {
+	Availability *float64 `json:"availability"` // 百分比，没有任何已知时间时为 null
+	UptimeMs     int64    `json:"uptime_ms"`
+	DowntimeMs   int64    `json:"downtime_ms"`
+	UnknownMs    int64    `json:"unknown_ms"`
+	Incidents    int      `json:"incidents"` // 在此期间开始的宕机次数
+}
+
+// Period 一个日 / 月汇总
+type Period struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Summary
+}
+
+// Incident 一次连续不可用
+type Incident struct {
+	From       int64           `json:"from"`
+	To         int64           `json:"to"`
+	DurationMs int64           `json:"duration_ms"`
+	State      nodestate.State `json:"state"`
+	Reason     string          `json:"reason"`
+	Ongoing    bool            `json:"ongoing,omitempty"` // 截至报表生成时仍未恢复
+}
+
+// TargetPeriod 探测目标在一个日 / 月内的成功率
+type TargetPeriod struct {
+	Start       int64    `json:"start"`
+	End         int64    `json:"end"`
+	SuccessRate *float64 `json:"success_rate"` // 百分比，1 - 平均丢包率；没有样本时为 null
+	Samples     int64    `json:"samples"`
+}
+
+// TargetReport 单个探测目标的成功率
+type TargetReport struct {
+	Target      string         `json:"target"`
+	TargetType  string         `json:"target_type"`
+	SuccessRate *float64       `json:"success_rate"`
+	Samples     int64          `json:"samples"`
+	MetTarget   bool           `json:"met_target"`
+	Periods     []TargetPeriod `json:"periods"`
+}
+
+// NodeReport 单个节点的报表
+type NodeReport struct {
+	NodeID    string         `json:"node_id"`
+	MetTarget bool           `json:"met_target"`
+	Summary   Summary        `json:"summary"`
+	Periods   []Period       `json:"periods"`
+	Incidents []Incident     `json:"incidents"`
+	Targets   []TargetReport `json:"targets"`
+}
+
+// Report 报表
+type Report struct {
+	From        int64        `json:"from"`
+	To          int64        `json:"to"`
+	Granularity Granularity  `json:"granularity"`
+	Target      float64      `json:"target"`
+	Timezone    string       `json:"timezone"`
+	GeneratedAt int64        `json:"generated_at"`
+	Nodes       []NodeReport `json:"nodes"`
+
+	loc *time.Location
+}
+
+// Reporter 报表生成器，只读节点状态机与主存储
+type Reporter struct {
+	opts   Options
+	states *nodestate.Tracker
+	store  storage.Persister
+}
+
+func NewReporter(states *nodestate.Tracker, store storage.Persister, opts Options) *Reporter {
+	if opts.Target <= 0 || opts.Target > 100 {
+		opts.Target = 99.9
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	return &Reporter{opts: opts, states: states, store: store}
+}
+
+// Location 日 / 月边界所在时区
+func (r *Reporter) Location() *time.Location {
+	return r.opts.Location
+}
+
+// Report 生成报表
+func (r *Reporter) Report(q Query) (*Report, error) {
+	if q.To <= q.From {
+		return nil, fmt.Errorf("%w: to must be after from", ErrInvalid)
+	}
+	if q.To-q.From > maxSpan.Milliseconds() {
+		return nil, fmt.Errorf("%w: range exceeds %v", ErrInvalid, maxSpan)
+	}
+	switch q.Granularity {
+	case Day, Month:
+	case "":
+		q.Granularity = Day
+		if q.To-q.From > (62 * 24 * time.Hour).Milliseconds() {
+			q.Granularity = Month
+		}
+	default:
+		return nil, fmt.Errorf("%w: granularity must be day or month", ErrInvalid)
+	}
+	if q.Target == 0 {
+		q.Target = r.opts.Target
+	}
+	if q.Target < 0 || q.Target > 100 {
+		return nil, fmt.Errorf("%w: target must be a percentage", ErrInvalid)
+	}
+
+	nodes := q.Nodes
+	if len(nodes) == 0 {
+		var err error
+		if nodes, err = r.knownNodes(); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	rep := &Report{
+		From:        q.From,
+		To:          q.To,
+		Granularity: q.Granularity,
+		Target:      q.Target,
+		Timezone:    r.opts.Location.String(),
+		GeneratedAt: now,
+		Nodes:       make([]NodeReport, 0, len(nodes)),
+		loc:         r.opts.Location,
+	}
+	periods := r.periods(q.From, q.To, q.Granularity)
+	for _, nodeID := range nodes {
+		nr, err := r.nodeReport(nodeID, q, periods, now)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", nodeID, err)
+		}
+		rep.Nodes = append(rep.Nodes, nr)
+	}
+	return rep, nil
+}
+
+// knownNodes 状态机与主存储中出现过的全部节点
+func (r *Reporter) knownNodes() ([]string, error) {
+	seen := make(map[string]bool)
+	for _, st := range r.states.States() {
+		seen[st.NodeID] = true
+	}
+	list, err := r.store.GetNodes()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range list {
+		seen[n.NodeID] = true
+	}
+	out := make([]string, 0, len(seen))
+	for id := range seen {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// periods 按时区切出的日 / 月区间，首尾截断到 [from, to)
+func (r *Reporter) periods(from, to int64, g Granularity) []Period {
+	t := time.UnixMilli(from).In(r.opts.Location)
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, r.opts.Location)
+	if g == Month {
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, r.opts.Location)
+	}
+	var out []Period
+	for start.UnixMilli() < to {
+		next := start.AddDate(0, 0, 1)
+		if g == Month {
+			next = start.AddDate(0, 1, 0)
+		}
+		out = append(out, Period{Start: max(start.UnixMilli(), from), End: min(next.UnixMilli(), to)})
+		start = next
+	}
+	return out
+}
+
+func (r *Reporter) isDown(s nodestate.State) bool {
+	return s == nodestate.StateOffline || (r.opts.StaleAsDown && s == nodestate.StateStale)
+}
+
+func (r *Reporter) nodeReport(nodeID string, q Query, periods []Period, now int64) (NodeReport, error) {
+	nr := NodeReport{
+		NodeID:    nodeID,
+		Periods:   append([]Period(nil), periods...),
+		Incidents: []Incident{},
+		Targets:   []TargetReport{},
+	}
+
+	// 未来的时间状态未知
+	end := min(q.To, now)
+	var intervals []nodestate.Interval
+	if end > q.From {
+		intervals = r.states.Intervals(nodeID, q.From, end)
+	}
+	for _, iv := range intervals {
+		down := r.isDown(iv.State)
+		for i := range nr.Periods {
+			p := &nr.Periods[i]
+			overlap := min(iv.To, p.End) - max(iv.From, p.Start)
+			if overlap <= 0 {
+				continue
+			}
+			if down {
+				p.DowntimeMs += overlap
+			} else {
+				p.UptimeMs += overlap
+			}
+		}
+		if !down {
+			continue
+		}
+		// 相邻的不可用区间 (如 stale -> offline) 合并为一次事件
+		if n := len(nr.Incidents); n > 0 && nr.Incidents[n-1].To == iv.From {
+			nr.Incidents[n-1].To = iv.To
+			nr.Incidents[n-1].State = iv.State
+			continue
+		}
+		nr.Incidents = append(nr.Incidents, Incident{From: iv.From, To: iv.To, State: iv.State, Reason: iv.Reason})
+	}
+	for i := range nr.Incidents {
+		inc := &nr.Incidents[i]
+		inc.DurationMs = inc.To - inc.From
+		inc.Ongoing = inc.To == end && end == now
+		for j := range nr.Periods {
+			if p := &nr.Periods[j]; inc.From >= p.Start && inc.From < p.End {
+				p.Incidents++
+			}
+		}
+	}
+
+	for i := range nr.Periods {
+		p := &nr.Periods[i]
+		p.UnknownMs = p.End - p.Start - p.UptimeMs - p.DowntimeMs
+		p.Availability = percent(p.UptimeMs, p.UptimeMs+p.DowntimeMs)
+		nr.Summary.UptimeMs += p.UptimeMs
+		nr.Summary.DowntimeMs += p.DowntimeMs
+		nr.Summary.UnknownMs += p.UnknownMs
+		nr.Summary.Incidents += p.Incidents
+	}
+	nr.Summary.Availability = percent(nr.Summary.UptimeMs, nr.Summary.UptimeMs+nr.Summary.DowntimeMs)
+	nr.MetTarget = nr.Summary.Availability != nil && *nr.Summary.Availability >= q.Target
+
+	targets, err := r.store.GetPingTargets(nodeID)
+	if err != nil {
+		return nr, err
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Target < targets[j].Target })
+	for _, t := range targets {
+		tr, err := r.targetReport(nodeID, t, q, periods)
+		if err != nil {
+			return nr, err
+		}
+		nr.Targets = append(nr.Targets, tr)
+	}
+	return nr, nil
+}
+
+// targetReport 按小时桶取丢包率，按样本数加权得到成功率。
+// 桶按 Unix 纪元对齐，非整点偏移的时区在日界附近会有不足一小时的归属误差
+func (r *Reporter) targetReport(nodeID string, t storage.PingSnapshot, q Query, periods []Period) (TargetReport, error) {
+	tr := TargetReport{Target: t.Target, TargetType: t.TargetType, Periods: make([]TargetPeriod, len(periods))}
+	success := make([]float64, len(periods))
+	for i, p := range periods {
+		tr.Periods[i] = TargetPeriod{Start: p.Start, End: p.End}
+	}
+
+	// 单次查询桶数有上限，长范围分段查询
+	chunk := int64(storage.MaxBuckets) * pingStep.Milliseconds()
+	var total float64
+	for from := q.From; from < q.To; from += chunk {
+		rq := storage.RangeQuery{From: from, To: min(from+chunk, q.To) - 1, Step: pingStep.Milliseconds()}
+		if err := rq.Normalize(); err != nil {
+			return tr, err
+		}
+		buckets, err := r.store.QueryPingHistory(nodeID, t.Target, rq)
+		if err != nil {
+			return tr, err
+		}
+		for _, b := range buckets {
+			i := sort.Search(len(periods), func(i int) bool { return periods[i].End > b.Timestamp })
+			if i == len(periods) {
+				continue
+			}
+			ok := float64(b.Count) * (1 - b.Loss)
+			tr.Periods[i].Samples += b.Count
+			success[i] += ok
+			tr.Samples += b.Count
+			total += ok
+		}
+	}
+	for i := range tr.Periods {
+		tr.Periods[i].SuccessRate = ratio(success[i], tr.Periods[i].Samples)
+	}
+	tr.SuccessRate = ratio(total, tr.Samples)
+	tr.MetTarget = tr.SuccessRate != nil && *tr.SuccessRate >= q.Target
+	return tr, nil
+}
+
+func percent(part, whole int64) *float64 {
+	return ratio(float64(part), whole)
+}
+
+func ratio(part float64, whole int64) *float64 {
+	if whole <= 0 {
+		return nil
+	}
+	v := part / float64(whole) * 100
+	return &v
+}
@@ -0,0 +1,193 @@
+package sla
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/nodestate"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+const hour = int64(time.Hour / time.Millisecond)
+
+// noTargets 节点没有探测目标，报表只涉及可达性
+type noTargets struct{ storage.Persister }
+
+func (noTargets) GetPingTargets(string) ([]storage.PingSnapshot, error) { return nil, nil }
+
+// newTestReporter 由持久化的转换历史恢复状态机。最后一条转换必须是 offline，恢复时不会再补记超时
+func newTestReporter(t *testing.T, opts Options, history map[string][]nodestate.Transition) *Reporter {
+	t.Helper()
+	type saved struct {
+		nodestate.NodeState
+		History []nodestate.Transition `json:"history"`
+	}
+	var nodes []saved
+	for id, h := range history {
+		last := h[len(h)-1]
+		for i := range h {
+			h[i].NodeID = id
+		}
+		nodes = append(nodes, saved{
+			NodeState: nodestate.NodeState{NodeID: id, State: last.To, Since: last.At, Reason: last.Reason, LastReport: last.At},
+			History:   h,
+		})
+	}
+	data, _ := json.Marshal(nodes)
+	path := filepath.Join(t.TempDir(), "node_state.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	states, err := nodestate.NewTracker(nodestate.Options{StatePath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { states.Close() })
+	opts.Location = time.UTC
+	return NewReporter(states, noTargets{}, opts)
+}
+
+// testHistory 以 base 为起点 (UTC 0 点) 的两天：
+// 6h 首次上线，23h stale，23h30m offline，次日 1h 恢复，16h stale 10 分钟，20h 流断开
+func testHistory(base int64) []nodestate.Transition {
+	at := func(h float64) int64 { return base + int64(h*float64(hour)) }
+	return []nodestate.Transition{
+		{From: nodestate.StateOffline, To: nodestate.StateConnected, At: at(6), Reason: nodestate.ReasonStreamOpened},
+		{From: nodestate.StateConnected, To: nodestate.StateReporting, At: at(6), Reason: nodestate.ReasonReport},
+		{From: nodestate.StateReporting, To: nodestate.StateStale, At: at(23), Reason: nodestate.ReasonStale},
+		{From: nodestate.StateStale, To: nodestate.StateOffline, At: at(23.5), Reason: nodestate.ReasonTimeout},
+		{From: nodestate.StateOffline, To: nodestate.StateConnected, At: at(25), Reason: nodestate.ReasonStreamOpened},
+		{From: nodestate.StateConnected, To: nodestate.StateReporting, At: at(25), Reason: nodestate.ReasonReport},
+		{From: nodestate.StateReporting, To: nodestate.StateStale, At: at(40), Reason: nodestate.ReasonStale},
+		{From: nodestate.StateStale, To: nodestate.StateReporting, At: at(40) + hour/6, Reason: nodestate.ReasonReport},
+		{From: nodestate.StateReporting, To: nodestate.StateOffline, At: at(44), Reason: nodestate.ReasonStreamClosed},
+	}
+}
+
+func TestNodeReport(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	base := today.AddDate(0, 0, -3).UnixMilli()
+	const min10 = hour / 6
+
+	for _, tc := range []struct {
+		name        string
+		staleAsDown bool
+		periods     []Summary // 每天的 uptime / downtime / unknown / incidents
+		incidents   []Incident
+	}{
+		{
+			name: "offline only",
+			periods: []Summary{
+				{UptimeMs: 17*hour + hour/2, DowntimeMs: hour / 2, UnknownMs: 6 * hour, Incidents: 1},
+				{UptimeMs: 19 * hour, DowntimeMs: 5 * hour, Incidents: 1},
+			},
+			incidents: []Incident{
+				// 跨日的宕机只算在开始的那一天
+				{From: base + 23*hour + hour/2, To: base + 25*hour, State: nodestate.StateOffline, Reason: nodestate.ReasonTimeout},
+				{From: base + 44*hour, To: base + 48*hour, State: nodestate.StateOffline, Reason: nodestate.ReasonStreamClosed},
+			},
+		},
+		{
+			name:        "stale as down",
+			staleAsDown: true,
+			periods: []Summary{
+				{UptimeMs: 17 * hour, DowntimeMs: hour, UnknownMs: 6 * hour, Incidents: 1},
+				{UptimeMs: 19*hour - min10, DowntimeMs: 5*hour + min10, Incidents: 2},
+			},
+			incidents: []Incident{
+				// stale -> offline 合并为一次事件：起点与原因取 stale，状态取最后的 offline
+				{From: base + 23*hour, To: base + 25*hour, State: nodestate.StateOffline, Reason: nodestate.ReasonStale},
+				{From: base + 40*hour, To: base + 40*hour + min10, State: nodestate.StateStale, Reason: nodestate.ReasonStale},
+				{From: base + 44*hour, To: base + 48*hour, State: nodestate.StateOffline, Reason: nodestate.ReasonStreamClosed},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestReporter(t, Options{StaleAsDown: tc.staleAsDown}, map[string][]nodestate.Transition{"hk-01": testHistory(base)})
+			rep, err := r.Report(Query{Nodes: []string{"hk-01"}, From: base, To: base + 48*hour, Granularity: Day})
+			if err != nil {
+				t.Fatal(err)
+			}
+			nr := rep.Nodes[0]
+			if len(nr.Periods) != len(tc.periods) {
+				t.Fatalf("%d periods, want %d", len(nr.Periods), len(tc.periods))
+			}
+			var up, down, unknown int64
+			for i, w := range tc.periods {
+				p := nr.Periods[i]
+				if p.Start != base+int64(i)*24*hour || p.End != p.Start+24*hour {
+					t.Fatalf("period %d [%d, %d)", i, p.Start, p.End)
+				}
+				if p.UptimeMs != w.UptimeMs || p.DowntimeMs != w.DowntimeMs || p.UnknownMs != w.UnknownMs || p.Incidents != w.Incidents {
+					t.Fatalf("period %d: up %d down %d unknown %d incidents %d, want %d / %d / %d / %d", i,
+						p.UptimeMs, p.DowntimeMs, p.UnknownMs, p.Incidents, w.UptimeMs, w.DowntimeMs, w.UnknownMs, w.Incidents)
+				}
+				// 未知时间不计入分母
+				if want := float64(w.UptimeMs) / float64(w.UptimeMs+w.DowntimeMs) * 100; p.Availability == nil || !approx(*p.Availability, want) {
+					t.Fatalf("period %d availability %v, want %v", i, p.Availability, want)
+				}
+				up, down, unknown = up+w.UptimeMs, down+w.DowntimeMs, unknown+w.UnknownMs
+			}
+			s := nr.Summary
+			if s.UptimeMs != up || s.DowntimeMs != down || s.UnknownMs != unknown || s.Incidents != len(tc.incidents) {
+				t.Fatalf("summary %+v", s)
+			}
+			if nr.MetTarget {
+				t.Fatal("met target with hours of downtime")
+			}
+
+			if len(nr.Incidents) != len(tc.incidents) {
+				t.Fatalf("incidents %+v", nr.Incidents)
+			}
+			for i, w := range tc.incidents {
+				g := nr.Incidents[i]
+				if g.From != w.From || g.To != w.To || g.DurationMs != w.To-w.From || g.State != w.State || g.Reason != w.Reason || g.Ongoing {
+					t.Fatalf("incident %d: %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+// 报表延伸到未来：未来的时间计为未知，截至生成时仍未恢复的宕机标记为 ongoing
+func TestNodeReportOngoing(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	base := today.AddDate(0, 0, -2).UnixMilli()
+	r := newTestReporter(t, Options{}, map[string][]nodestate.Transition{"hk-01": testHistory(base)})
+	from, to := today.UnixMilli(), today.UnixMilli()+24*hour
+	rep, err := r.Report(Query{Nodes: []string{"hk-01", "never-seen"}, From: from, To: to, Granularity: Day})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := rep.GeneratedAt
+
+	nr := rep.Nodes[0]
+	if len(nr.Incidents) != 1 {
+		t.Fatalf("incidents %+v", nr.Incidents)
+	}
+	// 流在前一天 20h 断开，本报表只从 0 点算起
+	if inc := nr.Incidents[0]; inc.From != from || inc.To != now || inc.DurationMs != now-from || !inc.Ongoing {
+		t.Fatalf("incident %+v, want ongoing from %d to %d", inc, from, now)
+	}
+	s := nr.Summary
+	if s.UptimeMs != 0 || s.DowntimeMs != now-from || s.UnknownMs != to-now {
+		t.Fatalf("summary %+v", s)
+	}
+	if s.Availability == nil || *s.Availability != 0 {
+		t.Fatalf("availability %v, want 0", s.Availability)
+	}
+
+	// 从未出现过的节点没有任何已知时间，可用性为 null 而不是 0 或 100
+	unseen := rep.Nodes[1]
+	if unseen.Summary.Availability != nil || unseen.Summary.UnknownMs != to-from || unseen.MetTarget || len(unseen.Incidents) != 0 {
+		t.Fatalf("unseen node %+v", unseen)
+	}
+}
+
+func approx(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// ErrMergeUnsupported 后端无法把一个节点的历史并入另一个节点 (如内置时序引擎的块不可改写)
+var ErrMergeUnsupported = errors.New("storage backend cannot merge node history")
+
+// NodeAdmin 支持删除节点全部数据、把一个节点的历史并入另一个节点的后端，供节点生命周期管理调用
+type NodeAdmin interface {
+	DeleteNode(nodeID string) error
+	MergeNode(from, into string) error
+}
+
+// NodeKnower 可选接口：组件自身是否持有节点的数据 (如尚未上报就预先设置的元数据)，
+// 生命周期管理据此拒绝删除不存在的节点
+type NodeKnower interface {
+	HasNode(nodeID string) bool
+}
+
+// DeleteNode 依次删除各成员中的节点数据，只写成员 (VictoriaMetrics 等) 无法删除，跳过并记日志
+func (f *FanoutPersister) DeleteNode(nodeID string) error {
+	var errs []error
+	for _, m := range f.members {
+		a, ok := m.Writer.(NodeAdmin)
+		if !ok {
+			log.Printf("[Fanout] backend %s cannot delete node data, node [%s] left as is", m.Name, nodeID)
+			continue
+		}
+		if err := a.DeleteNode(nodeID); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MergeNode 依次在各成员中把 from 的历史并入 into
+func (f *FanoutPersister) MergeNode(from, into string) error {
+	var errs []error
+	for _, m := range f.members {
+		a, ok := m.Writer.(NodeAdmin)
+		if !ok {
+			log.Printf("[Fanout] backend %s cannot merge node data, node [%s] left as is", m.Name, from)
+			continue
+		}
+		if err := a.MergeNode(from, into); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteNode 丢弃节点的状态与全部环
+func (m *MemoryCache) DeleteNode(nodeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.nodes, nodeID)
+	delete(m.pings, nodeID)
+	return nil
+}
+
+// MergeNode 按时间戳合并两个节点的环，超出长度的最老部分丢弃
+func (m *MemoryCache) MergeNode(from, into string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if src, ok := m.nodes[from]; ok {
+		dst, ok := m.nodes[into]
+		if !ok {
+			dst = &NodeStatus{NodeID: into}
+			m.nodes[into] = dst
+		}
+		if src.LastSeen > dst.LastSeen {
+			dst.LastSeen = src.LastSeen
+			dst.IsOnline = src.IsOnline
+		}
+		if dst.CPUModel == "" {
+			dst.CPUModel = src.CPUModel
+		}
+		history := append(append(make([]MetricSnapshot, 0, len(src.HistoryFlow)+len(dst.HistoryFlow)), src.HistoryFlow...), dst.HistoryFlow...)
+		sort.SliceStable(history, func(i, j int) bool { return history[i].Timestamp < history[j].Timestamp })
+		if len(history) > m.limit {
+			history = history[len(history)-m.limit:]
+		}
+		dst.HistoryFlow = history
+	}
+
+	if src, ok := m.pings[from]; ok {
+		dst, ok := m.pings[into]
+		if !ok {
+			dst = make(map[string][]PingSnapshot, len(src))
+			m.pings[into] = dst
+		}
+		for target, ring := range src {
+			merged := append(append(make([]PingSnapshot, 0, len(ring)+len(dst[target])), ring...), dst[target]...)
+			sort.SliceStable(merged, func(i, j int) bool { return merged[i].Timestamp < merged[j].Timestamp })
+			if len(merged) > m.limit {
+				merged = merged[len(merged)-m.limit:]
+			}
+			dst[target] = merged
+		}
+	}
+
+	delete(m.nodes, from)
+	delete(m.pings, from)
+	return nil
+}
+
+// DeleteNode 丢弃节点最新一帧，/metrics 不再导出该节点
+func (l *LatestStore) DeleteNode(nodeID string) error {
+	l.mu.Lock()
+	delete(l.nodes, nodeID)
+	l.mu.Unlock()
+	return nil
+}
+
+// MergeNode 最新一帧只属于当前在报的节点，合并时直接丢弃 from
+func (l *LatestStore) MergeNode(from, into string) error {
+	return l.DeleteNode(from)
+}
@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBackupUnsupported 当前存储中没有可做一致性快照的后端
+var ErrBackupUnsupported = errors.New("no storage backend supports online backup")
+
+// Backuper 支持在运行中生成一致性快照的后端
+type Backuper interface {
+	Backup(path string) error
+}
+
+// BackupInfo 备份目录中的一个快照文件
+type BackupInfo struct {
+	Name       string `json:"name"`
+	SizeBytes  int64  `json:"size_bytes"`
+	CreatedAt  int64  `json:"created_at"` // Unix 毫秒
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+const (
+	backupPrefix = "geegee-"
+	backupSuffix = ".db"
+)
+
+// BackupToDir 在 dir 下生成带时间戳的快照，并只保留最新的 keep 份 (keep<=0 不清理)
+func BackupToDir(b Backuper, dir string, keep int) (BackupInfo, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return BackupInfo{}, err
+	}
+	start := time.Now()
+	name := backupPrefix + start.Format("20060102-150405") + backupSuffix
+	path := filepath.Join(dir, name)
+	if err := b.Backup(path); err != nil {
+		return BackupInfo{}, err
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	info := BackupInfo{
+		Name:       name,
+		SizeBytes:  st.Size(),
+		CreatedAt:  start.UnixMilli(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	log.Printf("[Backup] Snapshot %s written (%d bytes) in %dms", name, info.SizeBytes, info.DurationMs)
+
+	if keep > 0 {
+		list, err := ListBackups(dir)
+		if err != nil {
+			return info, err
+		}
+		for _, old := range list[min(keep, len(list)):] {
+			if err := os.Remove(filepath.Join(dir, old.Name)); err != nil {
+				log.Printf("[Backup] Failed to prune %s: %v", old.Name, err)
+			}
+		}
+	}
+	return info, nil
+}
+
+// ListBackups 列出 dir 下的快照，最新的在前；目录不存在时返回空
+func ListBackups(dir string) ([]BackupInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := []BackupInfo{}
+	for _, e := range entries {
+		if !IsBackupName(e.Name()) {
+			continue
+		}
+		st, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, BackupInfo{Name: e.Name(), SizeBytes: st.Size(), CreatedAt: st.ModTime().UnixMilli()})
+	}
+	// 文件名中的时间戳按字典序即时间序
+	sort.Slice(list, func(i, j int) bool { return list[i].Name > list[j].Name })
+	return list, nil
+}
+
+// IsBackupName 校验是否为 BackupToDir 生成的文件名，下载接口据此拒绝路径穿越
+func IsBackupName(name string) bool {
+	return name == filepath.Base(name) &&
+		strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix)
+}
+
+// Backup 用 VACUUM INTO 生成一致性快照，期间写入照常进行。
+// 已入队但尚未落盘的上报不在快照内
+func (s *SqliteStore) Backup(path string) error {
+	return vacuumInto(s.db, path)
+}
+
+// BackupSqlite 在控制器运行时由另一个进程对同一库文件做快照 (WAL 模式下读写互不阻塞)
+func BackupSqlite(dsn, path string) error {
+	if _, err := os.Stat(sqlitePath(dsn)); err != nil {
+		return fmt.Errorf("source database: %w", err)
+	}
+	db, err := openSqlite(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return vacuumInto(db, path)
+}
+
+// vacuumInto 先写临时文件再改名，中途失败不会留下半个快照
+func vacuumInto(db *sql.DB, path string) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if _, err := db.Exec(`VACUUM INTO ?`, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("vacuum into %s: %w", tmp, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// RestoreSqlite 用快照替换 dsn 指向的库文件，须在控制器停止时执行。
+// 原库文件 (连同 -wal/-shm) 改名保留，返回其路径；快照版本较旧时随后补齐迁移
+func RestoreSqlite(dsn, src string) (previous string, err error) {
+	if err := checkBackup(src); err != nil {
+		return "", fmt.Errorf("invalid backup %s: %w", src, err)
+	}
+
+	target := sqlitePath(dsn)
+	if _, err := os.Stat(target); err == nil {
+		previous = target + ".pre-restore-" + time.Now().Format("20060102-150405")
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			if err := os.Rename(target+suffix, previous+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+		}
+	}
+
+	tmp := target + ".restore.tmp"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return previous, err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return previous, err
+	}
+
+	if _, _, err := MigrateSqlite(dsn); err != nil {
+		return previous, err
+	}
+	return previous, nil
+}
+
+// checkBackup 只读打开快照，确认文件完整且结构版本不高于当前程序
+func checkBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+
+	var version sql.NullInt64
+	err = db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version)
+	if err != nil && !strings.Contains(err.Error(), "no such table") {
+		return err
+	}
+	if int(version.Int64) > LatestSchemaVersion() {
+		return fmt.Errorf("%w: backup is at version %d", ErrSchemaTooNew, version.Int64)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// sqlitePath 去掉 DSN 中的 file: 前缀与连接参数，得到库文件路径
+func sqlitePath(dsn string) string {
+	p := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(p, '?'); i >= 0 {
+		p = p[:i]
+	}
+	return p
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+// FanoutBackend 扇出中的一个成员。Reader 为 nil 表示只写 (如 VictoriaMetrics)
+type FanoutBackend struct {
+	Name   string
+	Writer Sink
+	Reader Persister
+}
+
+// FanoutPrimary 各类查询指定从哪个成员读取，值为成员 Name
+type FanoutPrimary struct {
+	Nodes   string // GetNodes
+	History string // GetNodeHistory / QueryNodeHistory
+	Ping    string // GetPingTargets / GetPingHistory / QueryPingHistory
+}
+
+// BackendStatus 单个成员的写入健康度
+type BackendStatus struct {
+	Name        string `json:"name"`
+	Readable    bool   `json:"readable"`
+	Written     uint64 `json:"written"`
+	Failed      uint64 `json:"failed"`
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt int64  `json:"last_error_at,omitempty"`
+}
+
+// fanoutMember 成员及其写入计数
+type fanoutMember struct {
+	FanoutBackend
+
+	mu          sync.Mutex
+	written     uint64
+	failed      uint64
+	lastError   string
+	lastErrorAt int64
+	lastLogged  time.Time // 持续失败时限制日志频率
+}
+
+// FanoutPersister 同时写入多个后端，读请求按查询类型路由到指定的主成员
+// 单个成员写入失败或 panic 不影响其余成员，也不会让上报流报错中断
+type FanoutPersister struct {
+	members []*fanoutMember
+	nodes   *fanoutMember
+	history *fanoutMember
+	ping    *fanoutMember
+}
+
+func NewFanoutPersister(backends []FanoutBackend, primary FanoutPrimary) (*FanoutPersister, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("fanout: no backends configured")
+	}
+
+	f := &FanoutPersister{}
+	byName := make(map[string]*fanoutMember, len(backends))
+	var firstReader *fanoutMember
+	for _, b := range backends {
+		if b.Writer == nil {
+			return nil, fmt.Errorf("fanout: backend %q has no writer", b.Name)
+		}
+		if _, dup := byName[b.Name]; dup {
+			return nil, fmt.Errorf("fanout: duplicate backend name %q", b.Name)
+		}
+		m := &fanoutMember{FanoutBackend: b}
+		f.members = append(f.members, m)
+		byName[b.Name] = m
+		if firstReader == nil && b.Reader != nil {
+			firstReader = m
+		}
+	}
+	if firstReader == nil {
+		return nil, fmt.Errorf("fanout: at least one readable backend (memory / sqlite) is required")
+	}
+
+	pick := func(kind, name string) (*fanoutMember, error) {
+		if name == "" {
+			return firstReader, nil
+		}
+		m, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("fanout: primary %s backend %q not configured", kind, name)
+		}
+		if m.Reader == nil {
+			return nil, fmt.Errorf("fanout: backend %q is write-only and cannot serve %s queries", name, kind)
+		}
+		return m, nil
+	}
+	var err error
+	if f.nodes, err = pick("nodes", primary.Nodes); err != nil {
+		return nil, err
+	}
+	if f.history, err = pick("history", primary.History); err != nil {
+		return nil, err
+	}
+	if f.ping, err = pick("ping", primary.Ping); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Fanout] %d backends, primary nodes=%s history=%s ping=%s",
+		len(f.members), f.nodes.Name, f.history.Name, f.ping.Name)
+	return f, nil
+}
+
+// Ingest 并发写入所有成员并等全部返回：某个成员的背压 (如 SQLite 队列满) 只推迟该节点的下一帧，
+// 不会耽误本帧写入其余成员。返回的错误仅供调用方记录
+func (f *FanoutPersister) Ingest(req *pb.ReportRequest) error {
+	errs := make([]error, len(f.members))
+	var wg sync.WaitGroup
+	for i, m := range f.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.ingest(req); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", m.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (m *fanoutMember) ingest(req *pb.ReportRequest) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		m.record(err)
+	}()
+	return m.Writer.Ingest(req)
+}
+
+func (m *fanoutMember) record(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		m.written++
+		return
+	}
+	m.failed++
+	m.lastError = err.Error()
+	m.lastErrorAt = time.Now().UnixMilli()
+	if time.Since(m.lastLogged) > time.Minute {
+		m.lastLogged = time.Now()
+		log.Printf("[Fanout] backend %s ingest failed (%d failures so far): %v", m.Name, m.failed, err)
+	}
+}
+
+// Backends 各成员写入情况，供 API 展示
+func (f *FanoutPersister) Backends() []BackendStatus {
+	list := make([]BackendStatus, 0, len(f.members))
+	for _, m := range f.members {
+		m.mu.Lock()
+		list = append(list, BackendStatus{
+			Name:        m.Name,
+			Readable:    m.Reader != nil,
+			Written:     m.written,
+			Failed:      m.failed,
+			LastError:   m.lastError,
+			LastErrorAt: m.lastErrorAt,
+		})
+		m.mu.Unlock()
+	}
+	return list
+}
+
+// IngestStats 取历史查询主成员的写入队列状态
+func (f *FanoutPersister) IngestStats() IngestStats {
+	if p, ok := f.history.Reader.(IngestStatsProvider); ok {
+		return p.IngestStats()
+	}
+	for _, m := range f.members {
+		if p, ok := m.Reader.(IngestStatsProvider); ok {
+			return p.IngestStats()
+		}
+	}
+	return IngestStats{}
+}
+
+// Backup 由第一个支持快照的成员 (SQLite) 生成备份
+func (f *FanoutPersister) Backup(path string) error {
+	for _, m := range f.members {
+		if b, ok := m.Writer.(Backuper); ok {
+			return b.Backup(path)
+		}
+	}
+	return ErrBackupUnsupported
+}
+
+// Close 关闭所有实现了 io.Closer 的成员，各自把缓冲中的数据落盘或发出
+func (f *FanoutPersister) Close() error {
+	var errs []error
+	for _, m := range f.members {
+		if c, ok := m.Writer.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", m.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f *FanoutPersister) GetNodes() ([]NodeStatus, error) {
+	return f.nodes.Reader.GetNodes()
+}
+
+func (f *FanoutPersister) GetNodeHistory(nodeID string, limit int) ([]MetricSnapshot, error) {
+	return f.history.Reader.GetNodeHistory(nodeID, limit)
+}
+
+func (f *FanoutPersister) QueryNodeHistory(nodeID string, q RangeQuery) ([]MetricBucket, error) {
+	return f.history.Reader.QueryNodeHistory(nodeID, q)
+}
+
+func (f *FanoutPersister) GetPingTargets(nodeID string) ([]PingSnapshot, error) {
+	return f.ping.Reader.GetPingTargets(nodeID)
+}
+
+func (f *FanoutPersister) GetPingHistory(nodeID, target string, limit int) ([]PingSnapshot, error) {
+	return f.ping.Reader.GetPingHistory(nodeID, target, limit)
+}
+
+func (f *FanoutPersister) QueryPingHistory(nodeID, target string, q RangeQuery) ([]PingBucket, error) {
+	return f.ping.Reader.QueryPingHistory(nodeID, target, q)
+}
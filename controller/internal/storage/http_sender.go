@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// closeDrainTimeout Close 等待队列发送完毕的上限。远端不可用时重试与退避可能持续很久，
+// 到时取消进行中的请求，剩余数据计入失败
+const closeDrainTimeout = 10 * time.Second
+
+// drain 等待发送协程处理完剩余队列，超过 timeout 后调用 cancel 并等待其退出；
+// 返回是否在时限内发送完毕。调用方须已关闭队列
+func drain(wg *sync.WaitGroup, cancel context.CancelFunc, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	defer cancel()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		cancel()
+		<-done
+		return false
+	}
+}
+
+// httpSender remote_write 与 VictoriaMetrics 共用的推送：网络错误、5xx、429 按指数退避重试
+// (服务端给出 Retry-After 时取其与退避的较大值)，其余 4xx 视为数据本身有问题直接放弃
+type httpSender struct {
+	url        string
+	headers    map[string]string
+	client     *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// retryableError 标记可以重试的失败（网络错误、5xx、429）
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+// send 发送直到成功、遇到不可重试的错误、重试用尽或 ctx 结束。ctx 已结束 (Close 等待超时) 时不再发请求
+func (s *httpSender) send(ctx context.Context, body []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	backoff := s.minBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		var rErr *retryableError
+		if !errors.As(err, &rErr) {
+			return err
+		}
+		if attempt >= s.maxRetries {
+			return fmt.Errorf("giving up after %d retries: %w", attempt, err)
+		}
+
+		wait := min(max(backoff, rErr.retryAfter), s.maxBackoff)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+		backoff = min(backoff*2, s.maxBackoff)
+	}
+}
+
+// post 发送一次请求
+func (s *httpSender) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("User-Agent", "GeeGee-Controller")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &retryableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		rErr := &retryableError{err: err}
+		if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && secs > 0 {
+			rErr.retryAfter = time.Duration(secs) * time.Second
+		}
+		return rErr
+	}
+	return err
+}
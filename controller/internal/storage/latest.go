@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+// OnlineTimeoutMs 节点超过该时长未上报即判定掉线
+const OnlineTimeoutMs = 15000
+
+// LatestReport 某节点最近一次上报展开后的全部指标
+type LatestReport struct {
+	NodeID    string
+	Timestamp int64 // 节点侧采集时间 (Unix 毫秒)
+	LastSeen  int64 // 主控收到时间 (Unix 毫秒)
+	Samples   []Sample
+}
+
+// LatestStore 只保留每个节点最新一帧的全量指标，供 /metrics 抓取等"当前值"类出口读取
+// 实现 Sink 接口，挂在 gRPC 服务的出口列表上即可
+type LatestStore struct {
+	mu    sync.RWMutex
+	nodes map[string]*LatestReport
+}
+
+func NewLatestStore() *LatestStore {
+	return &LatestStore{
+		nodes: make(map[string]*LatestReport),
+	}
+}
+
+func (l *LatestStore) Ingest(req *pb.ReportRequest) error {
+	report := &LatestReport{
+		NodeID:    req.NodeId,
+		Timestamp: req.Timestamp,
+		LastSeen:  time.Now().UnixMilli(),
+		Samples:   FlattenReport(req),
+	}
+
+	l.mu.Lock()
+	l.nodes[req.NodeId] = report
+	l.mu.Unlock()
+	return nil
+}
+
+// Snapshot 返回按节点 ID 排序的最新上报列表。Samples 在写入后不再修改，可直接共享
+func (l *LatestStore) Snapshot() []LatestReport {
+	l.mu.RLock()
+	list := make([]LatestReport, 0, len(l.nodes))
+	for _, r := range l.nodes {
+		list = append(list, *r)
+	}
+	l.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].NodeID < list[j].NodeID })
+	return list
+}
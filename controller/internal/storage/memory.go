@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -11,12 +12,14 @@ import (
 type MemoryCache struct {
 	mu    sync.RWMutex
 	nodes map[string]*NodeStatus
+	pings map[string]map[string][]PingSnapshot // node_id -> target -> 环形历史
 	limit int
 }
 
 func NewMemoryCache(limit int) *MemoryCache {
 	return &MemoryCache{
 		nodes: make(map[string]*NodeStatus),
+		pings: make(map[string]map[string][]PingSnapshot),
 		limit: limit,
 	}
 }
@@ -36,19 +39,11 @@ func (m *MemoryCache) Ingest(req *pb.ReportRequest) error {
 
 	node.LastSeen = time.Now().UnixMilli()
 	node.IsOnline = true
-
-	var avgRtt float64
-	if len(req.PingResults) > 0 {
-		avgRtt = req.PingResults[0].AvgRttMs
+	if model := req.GetCpu().GetModelName(); model != "" {
+		node.CPUModel = model
 	}
 
-	snap := MetricSnapshot{
-		Timestamp:  req.Timestamp,
-		CPULoad1:   req.Cpu.Load1,
-		MemUsed:    req.Mem.UsedPercent,
-		NetBurst:   req.Net.MicroburstEvents,
-		PingAvgRTT: avgRtt,
-	}
+	snap := NewMetricSnapshot(req)
 
 	// 环式追加
 	node.HistoryFlow = append(node.HistoryFlow, snap)
@@ -56,6 +51,20 @@ func (m *MemoryCache) Ingest(req *pb.ReportRequest) error {
 		// 移除最老的一条
 		node.HistoryFlow = node.HistoryFlow[1:]
 	}
+
+	// 每个探测目标各自一条环
+	targets, ok := m.pings[req.NodeId]
+	if !ok {
+		targets = make(map[string][]PingSnapshot)
+		m.pings[req.NodeId] = targets
+	}
+	for _, p := range NewPingSnapshots(req) {
+		ring := append(targets[p.Target], p)
+		if len(ring) > m.limit {
+			ring = ring[1:]
+		}
+		targets[p.Target] = ring
+	}
 	return nil
 }
 
@@ -68,9 +77,10 @@ func (m *MemoryCache) GetNodes() ([]NodeStatus, error) {
 	list := make([]NodeStatus, 0, len(m.nodes))
 
 	for _, n := range m.nodes {
-		// 若 15 秒未上报则当做掉线
-		n.IsOnline = (now - n.LastSeen) < 15000
-		list = append(list, *n)
+		// 在副本上判定在线，读锁下不能改共享状态。若 15 秒未上报则当做掉线
+		cp := *n
+		cp.IsOnline = (now - n.LastSeen) < OnlineTimeoutMs
+		list = append(list, cp)
 	}
 	return list, nil
 }
@@ -88,3 +98,64 @@ func (m *MemoryCache) GetNodeHistory(nodeID string, limit int) ([]MetricSnapshot
 	}
 	return nil, nil
 }
+
+// GetPingTargets 列出节点所有探测目标的最新结果，按目标名排序
+func (m *MemoryCache) GetPingTargets(nodeID string) ([]PingSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]PingSnapshot, 0, len(m.pings[nodeID]))
+	for _, ring := range m.pings[nodeID] {
+		if len(ring) > 0 {
+			list = append(list, ring[len(ring)-1])
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Target < list[j].Target })
+	return list, nil
+}
+
+// GetPingHistory 获取节点对某一目标的最近 N 次测算
+func (m *MemoryCache) GetPingHistory(nodeID, target string, limit int) ([]PingSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ring := m.pings[nodeID][target]
+	if limit > 0 && len(ring) > limit {
+		ring = ring[len(ring)-limit:]
+	}
+	cp := make([]PingSnapshot, len(ring))
+	copy(cp, ring)
+	return cp, nil
+}
+
+// QueryNodeHistory 内存版只覆盖环内的时间窗，超出部分自然为空
+func (m *MemoryCache) QueryNodeHistory(nodeID string, q RangeQuery) ([]MetricBucket, error) {
+	history, _ := m.GetNodeHistory(nodeID, 0)
+	return downsampleMetrics(history, q), nil
+}
+
+func (m *MemoryCache) QueryPingHistory(nodeID, target string, q RangeQuery) ([]PingBucket, error) {
+	history, _ := m.GetPingHistory(nodeID, target, 0)
+	return downsamplePings(history, q), nil
+}
+
+// restore 以持久层中的状态预填一个节点，供 SQLite 热层启动预热
+func (m *MemoryCache) restore(status NodeStatus, history []MetricSnapshot, pings map[string][]PingSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(history) > m.limit {
+		history = history[len(history)-m.limit:]
+	}
+	status.HistoryFlow = append(make([]MetricSnapshot, 0, m.limit), history...)
+	m.nodes[status.NodeID] = &status
+
+	targets := make(map[string][]PingSnapshot, len(pings))
+	for target, ring := range pings {
+		if len(ring) > m.limit {
+			ring = ring[len(ring)-m.limit:]
+		}
+		targets[target] = append([]PingSnapshot(nil), ring...)
+	}
+	m.pings[status.NodeID] = targets
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// DefaultBuckets 未指定 step 时按此点数自动推算桶宽，足够画一张折线图
+	DefaultBuckets = 300
+	// MaxBuckets 单次查询允许返回的最大桶数，超出时自动放大 step
+	MaxBuckets = 5000
+	// MinStepMs 最小桶宽，与节点 5 秒上报周期同量级
+	MinStepMs = 1000
+)
+
+// RangeQuery 时间范围查询：[From, To] 内按 Step 分桶聚合
+type RangeQuery struct {
+	From   int64    // Unix 毫秒（含）
+	To     int64    // Unix 毫秒（含）
+	Step   int64    // 桶宽，毫秒；0 表示按 DefaultBuckets 自动推算
+	Fields []string // 只聚合这些序列，空表示全部
+}
+
+// Normalize 校验参数并补齐缺省 Step，同时限制桶数上限
+func (q *RangeQuery) Normalize() error {
+	if q.To < q.From {
+		return fmt.Errorf("invalid range: to (%d) is before from (%d)", q.To, q.From)
+	}
+	span := q.To - q.From + 1
+	if q.Step <= 0 {
+		q.Step = span / DefaultBuckets
+	}
+	if q.Step < MinStepMs {
+		q.Step = MinStepMs
+	}
+	if span/q.Step > MaxBuckets {
+		q.Step = (span + MaxBuckets - 1) / MaxBuckets
+	}
+	// 起点向下对齐到桶边界，首个桶不会只含半截数据
+	q.From = q.bucketOf(q.From)
+	_, err := q.selectedFields()
+	return err
+}
+
+// bucketOf 桶起点按 Unix 纪元对齐，保证不同查询、不同后端的桶边界一致
+func (q *RangeQuery) bucketOf(ts int64) int64 {
+	return ts - ts%q.Step
+}
+
+func (q *RangeQuery) selectedFields() ([]snapshotField, error) {
+	if len(q.Fields) == 0 {
+		return snapshotFields, nil
+	}
+	selected := make([]snapshotField, 0, len(q.Fields))
+	for _, name := range q.Fields {
+		f, ok := lookupField(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		selected = append(selected, f)
+	}
+	return selected, nil
+}
+
+func lookupField(name string) (snapshotField, bool) {
+	for _, f := range snapshotFields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return snapshotField{}, false
+}
+
+// Aggregate 单个序列在一个桶内的统计值
+type Aggregate struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+// MetricBucket 一个时间桶内各序列的 min/avg/max
+type MetricBucket struct {
+	Timestamp int64                `json:"timestamp"` // 桶起点
+	Count     int64                `json:"count"`     // 桶内原始点数
+	Values    map[string]Aggregate `json:"values"`
+}
+
+// PingBucket 单个探测目标在一个时间桶内的统计
+type PingBucket struct {
+	Timestamp int64   `json:"timestamp"`
+	Count     int64   `json:"count"`
+	MinRTT    float64 `json:"min_rtt_ms"` // 桶内最小的 min_rtt
+	AvgRTT    float64 `json:"avg_rtt_ms"` // avg_rtt 的均值
+	MaxRTT    float64 `json:"max_rtt_ms"` // 桶内最大的 max_rtt
+	Loss      float64 `json:"loss"`       // 平均丢包率
+	MaxLoss   float64 `json:"max_loss"`   // 桶内最差一次的丢包率
+}
+
+// downsampleMetrics 在内存中对已按时间升序的原始点分桶，供不支持 SQL 聚合的后端使用
+func downsampleMetrics(points []MetricSnapshot, q RangeQuery) []MetricBucket {
+	fields, _ := q.selectedFields()
+
+	type acc struct {
+		count    int64
+		min, max []float64
+		sum      []float64
+	}
+	buckets := make(map[int64]*acc)
+	for i := range points {
+		p := &points[i]
+		if p.Timestamp < q.From || p.Timestamp > q.To {
+			continue
+		}
+		key := q.bucketOf(p.Timestamp)
+		a, ok := buckets[key]
+		if !ok {
+			a = &acc{
+				min: make([]float64, len(fields)),
+				max: make([]float64, len(fields)),
+				sum: make([]float64, len(fields)),
+			}
+			for j := range fields {
+				a.min[j] = math.Inf(1)
+				a.max[j] = math.Inf(-1)
+			}
+			buckets[key] = a
+		}
+		a.count++
+		for j, f := range fields {
+			v := fieldValue(f.ptr(p))
+			a.sum[j] += v
+			a.min[j] = math.Min(a.min[j], v)
+			a.max[j] = math.Max(a.max[j], v)
+		}
+	}
+
+	result := make([]MetricBucket, 0, len(buckets))
+	for ts, a := range buckets {
+		b := MetricBucket{Timestamp: ts, Count: a.count, Values: make(map[string]Aggregate, len(fields))}
+		for j, f := range fields {
+			b.Values[f.Name] = Aggregate{Min: a.min[j], Avg: a.sum[j] / float64(a.count), Max: a.max[j]}
+		}
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Timestamp < result[j].Timestamp })
+	return result
+}
+
+// downsamplePings 同上，针对单个目标的 Ping 序列
+func downsamplePings(points []PingSnapshot, q RangeQuery) []PingBucket {
+	buckets := make(map[int64]*PingBucket)
+	for _, p := range points {
+		if p.Timestamp < q.From || p.Timestamp > q.To {
+			continue
+		}
+		key := q.bucketOf(p.Timestamp)
+		b, ok := buckets[key]
+		if !ok {
+			b = &PingBucket{Timestamp: key, MinRTT: p.MinRTT, MaxRTT: p.MaxRTT}
+			buckets[key] = b
+		}
+		b.Count++
+		b.MinRTT = math.Min(b.MinRTT, p.MinRTT)
+		b.MaxRTT = math.Max(b.MaxRTT, p.MaxRTT)
+		b.AvgRTT += p.AvgRTT
+		b.Loss += p.Loss
+		b.MaxLoss = math.Max(b.MaxLoss, p.Loss)
+	}
+
+	result := make([]PingBucket, 0, len(buckets))
+	for _, b := range buckets {
+		b.AvgRTT /= float64(b.Count)
+		b.Loss /= float64(b.Count)
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Timestamp < result[j].Timestamp })
+	return result
+}
@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWriteOptions 描述一个 Prometheus remote_write 接收端 (Prometheus / Mimir / VictoriaMetrics 等)
+type RemoteWriteOptions struct {
+	URL            string
+	ExternalLabels map[string]string // 附加到每条序列上的全局标签，如 cluster="cn-east"
+	Shards         int               // 并发发送协程数，同一节点的数据总落在同一分片以保证时间序
+	QueueSize      int               // 每个分片的待发送序列缓冲上限，满了直接丢弃
+	BatchSize      int               // 单个 WriteRequest 最多携带的序列条数
+	BatchInterval  time.Duration     // 不足一批时的最长等待时间
+	Timeout        time.Duration     // 单次 HTTP 请求超时
+	MaxRetries     int               // 5xx / 429 的最大重试次数
+	MinBackoff     time.Duration
+	MaxBackoff     time.Duration
+}
+
+func (o *RemoteWriteOptions) applyDefaults() {
+	if o.Shards <= 0 {
+		o.Shards = 4
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 10000
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 500
+	}
+	if o.BatchInterval <= 0 {
+		o.BatchInterval = 5 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = 10 * time.Second
+	}
+}
+
+// rwLabel / rwSeries 对应 prompb.Label 与只含单个样本的 prompb.TimeSeries
+type rwLabel struct {
+	name, value string
+}
+
+type rwSeries struct {
+	labels    []rwLabel
+	value     float64
+	timestamp int64 // Unix 毫秒
+}
+
+// RemoteWriter 将节点上报编码为 snappy 压缩的 WriteRequest 并推送给任意 remote_write 端点
+type RemoteWriter struct {
+	opts   RemoteWriteOptions
+	sender *httpSender
+	shards []chan rwSeries
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// 运行统计，便于排查远端异常
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+func NewRemoteWriter(opts RemoteWriteOptions) (*RemoteWriter, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("remote_write url is empty")
+	}
+	opts.applyDefaults()
+
+	sender := &httpSender{
+		url: opts.URL,
+		headers: map[string]string{
+			"Content-Encoding":                  "snappy",
+			"Content-Type":                      "application/x-protobuf",
+			"X-Prometheus-Remote-Write-Version": "0.1.0",
+		},
+		client:     &http.Client{Timeout: opts.Timeout},
+		maxRetries: opts.MaxRetries,
+		minBackoff: opts.MinBackoff,
+		maxBackoff: opts.MaxBackoff,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &RemoteWriter{
+		opts:   opts,
+		sender: sender,
+		shards: make([]chan rwSeries, opts.Shards),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for i := range w.shards {
+		w.shards[i] = make(chan rwSeries, opts.QueueSize)
+		w.wg.Add(1)
+		go w.runShard(i, w.shards[i])
+	}
+	return w, nil
+}
+
+// Ingest 展开上报并投递到节点对应的分片，永不阻塞 gRPC 接收流
+func (w *RemoteWriter) Ingest(req *pb.ReportRequest) error {
+	shard := w.shards[w.shardFor(req.NodeId)]
+
+	var dropped int
+	for _, s := range FlattenReport(req) {
+		series := rwSeries{
+			labels:    w.buildLabels(req.NodeId, s),
+			value:     s.Value,
+			timestamp: req.Timestamp,
+		}
+		select {
+		case shard <- series:
+		default:
+			dropped++
+		}
+	}
+
+	if dropped > 0 {
+		w.dropped.Add(uint64(dropped))
+		return fmt.Errorf("remote_write queue full, dropped %d series of node [%s]", dropped, req.NodeId)
+	}
+	return nil
+}
+
+// Close 停止接收并将队列中剩余的数据尽量发送完毕，最多等待 closeDrainTimeout
+func (w *RemoteWriter) Close() error {
+	for _, ch := range w.shards {
+		close(ch)
+	}
+	if !drain(&w.wg, w.cancel, closeDrainTimeout) {
+		log.Printf("[RemoteWrite] Queue not drained within %v, remaining series dropped", closeDrainTimeout)
+	}
+	log.Printf("[RemoteWrite] Closed. sent=%d dropped=%d failed=%d", w.sent.Load(), w.dropped.Load(), w.failed.Load())
+	return nil
+}
+
+func (w *RemoteWriter) shardFor(nodeID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(nodeID))
+	return int(h.Sum32() % uint32(len(w.shards)))
+}
+
+// buildLabels 拼装完整标签集并按名称排序（remote_write 协议要求）
+// 外部标签不会覆盖同名的序列自有标签
+func (w *RemoteWriter) buildLabels(nodeID string, s Sample) []rwLabel {
+	labels := make([]rwLabel, 0, len(s.Labels)+len(w.opts.ExternalLabels)+2)
+	labels = append(labels,
+		rwLabel{"__name__", "geegee_" + s.Name},
+		rwLabel{"node_id", nodeID},
+	)
+	for k, v := range s.Labels {
+		labels = append(labels, rwLabel{k, v})
+	}
+	for k, v := range w.opts.ExternalLabels {
+		if _, own := s.Labels[k]; own || k == "node_id" || k == "__name__" {
+			continue
+		}
+		labels = append(labels, rwLabel{k, v})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+// runShard 单个分片的攒批发送循环：满 BatchSize 或到 BatchInterval 即发送
+func (w *RemoteWriter) runShard(idx int, queue <-chan rwSeries) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.opts.BatchInterval)
+	defer ticker.Stop()
+
+	batch := make([]rwSeries, 0, w.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		w.sendBatch(idx, batch)
+		batch = make([]rwSeries, 0, w.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case s, ok := <-queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= w.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// sendBatch 发送一批数据，重试策略见 httpSender
+func (w *RemoteWriter) sendBatch(idx int, batch []rwSeries) {
+	if err := w.sender.send(w.ctx, snappy.Encode(nil, encodeWriteRequest(batch))); err != nil {
+		w.failed.Add(uint64(len(batch)))
+		if w.ctx.Err() == nil { // Close 等待超时后剩余的批次不再逐条记录
+			log.Printf("[RemoteWrite] shard %d: dropping %d series: %v", idx, len(batch), err)
+		}
+		return
+	}
+	w.sent.Add(uint64(len(batch)))
+}
+
+// encodeWriteRequest 手工编码 prompb.WriteRequest，避免为几个字段引入整套 Prometheus 依赖
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(batch []rwSeries) []byte {
+	var out, ts, tmp []byte
+	for _, s := range batch {
+		ts = ts[:0]
+		for _, l := range s.labels {
+			tmp = tmp[:0]
+			tmp = protowire.AppendTag(tmp, 1, protowire.BytesType)
+			tmp = protowire.AppendString(tmp, l.name)
+			tmp = protowire.AppendTag(tmp, 2, protowire.BytesType)
+			tmp = protowire.AppendString(tmp, l.value)
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, tmp)
+		}
+
+		tmp = tmp[:0]
+		tmp = protowire.AppendTag(tmp, 1, protowire.Fixed64Type)
+		tmp = protowire.AppendFixed64(tmp, math.Float64bits(s.value))
+		tmp = protowire.AppendTag(tmp, 2, protowire.VarintType)
+		tmp = protowire.AppendVarint(tmp, uint64(s.timestamp))
+
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, tmp)
+
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, ts)
+	}
+	return out
+}
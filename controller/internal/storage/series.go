@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"net"
+	"strconv"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+// Sample 是单次上报展开后的一个扁平时序点
+// 供 remote_write、Prometheus 抓取等对外出口共用同一套指标命名
+type Sample struct {
+	Name   string            // 不带前缀的指标名，如 cpu_load1
+	Labels map[string]string // 除 node_id 外的附加维度，如 ping 的 target
+	Value  float64
+}
+
+// FlattenReport 将一次 ReportRequest 展开为指标列表
+// 缺失的子结构（例如旧版探针没有 KVM 数据）经 pb 的 Get 方法自动取零值
+func FlattenReport(req *pb.ReportRequest) []Sample {
+	cpu, mem, disk, nw, kvm := req.GetCpu(), req.GetMem(), req.GetDisk(), req.GetNet(), req.GetKvm()
+
+	samples := []Sample{
+		{Name: "cpu_cores", Value: float64(cpu.GetCores())},
+		{Name: "cpu_mhz", Value: cpu.GetMhz()},
+		{Name: "cpu_load1", Value: cpu.GetLoad1()},
+		{Name: "cpu_load5", Value: cpu.GetLoad5()},
+		{Name: "cpu_load15", Value: cpu.GetLoad15()},
+
+		{Name: "mem_total_bytes", Value: float64(mem.GetTotal())},
+		{Name: "mem_available_bytes", Value: float64(mem.GetAvailable())},
+		{Name: "mem_used_bytes", Value: float64(mem.GetUsed())},
+		{Name: "mem_used_percent", Value: mem.GetUsedPercent()},
+		{Name: "swap_total_bytes", Value: float64(mem.GetSwapTotal())},
+		{Name: "swap_free_bytes", Value: float64(mem.GetSwapFree())},
+
+		{Name: "disk_read_bytes", Value: float64(disk.GetReadBytes())},
+		{Name: "disk_write_bytes", Value: float64(disk.GetWriteBytes())},
+		{Name: "disk_read_count", Value: float64(disk.GetReadCount())},
+		{Name: "disk_write_count", Value: float64(disk.GetWriteCount())},
+		{Name: "disk_iops_in_progress", Value: float64(disk.GetIopsInProgress())},
+
+		{Name: "net_bytes_recv", Value: float64(nw.GetBytesRecv())},
+		{Name: "net_bytes_sent", Value: float64(nw.GetBytesSent())},
+		{Name: "net_packets_recv", Value: float64(nw.GetPacketsRecv())},
+		{Name: "net_packets_sent", Value: float64(nw.GetPacketsSent())},
+		{Name: "net_microburst_events", Value: float64(nw.GetMicroburstEvents())},
+		{Name: "net_burst_p95_rate", Value: nw.GetBurstP95Rate()},
+
+		{Name: "kvm_total_vms", Value: float64(kvm.GetTotalVms())},
+		{Name: "kvm_active_vms", Value: float64(kvm.GetActiveVms())},
+		{Name: "kvm_alloc_vcpu", Value: float64(kvm.GetTotalAllocVcpu())},
+		{Name: "kvm_alloc_mem_bytes", Value: float64(kvm.GetTotalAllocMem())},
+	}
+
+	// 每个核心一条序列
+	for i, u := range cpu.GetUsagePerc() {
+		samples = append(samples, Sample{
+			Name:   "cpu_usage_percent",
+			Labels: map[string]string{"core": strconv.Itoa(i)},
+			Value:  u,
+		})
+	}
+
+	// 每个探测目标一组序列
+	for _, p := range req.GetPingResults() {
+		labels := PingLabels(p)
+		samples = append(samples,
+			Sample{Name: "ping_min_rtt_ms", Labels: labels, Value: p.GetMinRttMs()},
+			Sample{Name: "ping_avg_rtt_ms", Labels: labels, Value: p.GetAvgRttMs()},
+			Sample{Name: "ping_max_rtt_ms", Labels: labels, Value: p.GetMaxRttMs()},
+			Sample{Name: "ping_loss_ratio", Labels: labels, Value: p.GetPacketLossRate()},
+		)
+		if p.GetTargetType() == "udp" {
+			samples = append(samples,
+				Sample{Name: "ping_jitter_ms", Labels: labels, Value: p.GetJitterMs()},
+				Sample{Name: "ping_reordered", Labels: labels, Value: float64(p.GetReordered())},
+				Sample{Name: "ping_duplicates", Labels: labels, Value: float64(p.GetDuplicates())},
+				Sample{Name: "ping_loss_forward_ratio", Labels: labels, Value: p.GetLossForwardRate()},
+				Sample{Name: "ping_loss_reverse_ratio", Labels: labels, Value: p.GetLossReverseRate()},
+				Sample{Name: "ping_mos", Labels: labels, Value: p.GetMos()},
+			)
+		}
+	}
+	return samples
+}
+
+// PingLabels 生成单个探测目标的标准标签集
+func PingLabels(p *pb.PingResult) map[string]string {
+	return map[string]string{
+		"target": PingTargetKey(p.GetTargetIp(), p.GetTargetPort()),
+		"type":   p.GetTargetType(),
+	}
+}
+
+// PingTargetKey 统一的目标标识 "ip:port"（IPv6 自动加方括号）
+func PingTargetKey(ip string, port int32) string {
+	if port == 0 {
+		return ip
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(int(port)))
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+// snapshotField 描述 MetricSnapshot 中的一个数值序列
+// Name 为 API/JSON 字段名，Column 为 SQLite metrics 表中的列名（沿用旧列名以兼容已有库文件）
+type snapshotField struct {
+	Name   string
+	Column string
+	ptr    func(m *MetricSnapshot) any // *float64 或 *uint64，供 SQL 扫描与取值共用
+}
+
+// snapshotFields 是所有可查询序列的唯一登记处，新增字段在此追加一行，
+// 并在 sqlite_migrate.go 中追加为 metrics 与汇总表补列的迁移
+var snapshotFields = []snapshotField{
+	{"cpu_load1", "cpu_load1", func(m *MetricSnapshot) any { return &m.CPULoad1 }},
+	{"cpu_load5", "cpu_load5", func(m *MetricSnapshot) any { return &m.CPULoad5 }},
+	{"cpu_load15", "cpu_load15", func(m *MetricSnapshot) any { return &m.CPULoad15 }},
+	{"cpu_usage_percent", "cpu_usage_percent", func(m *MetricSnapshot) any { return &m.CPUUsage }},
+	{"cpu_cores", "cpu_cores", func(m *MetricSnapshot) any { return &m.CPUCores }},
+	{"cpu_mhz", "cpu_mhz", func(m *MetricSnapshot) any { return &m.CPUMhz }},
+
+	{"mem_used_percent", "mem_used", func(m *MetricSnapshot) any { return &m.MemUsed }},
+	{"mem_total_bytes", "mem_total_bytes", func(m *MetricSnapshot) any { return &m.MemTotal }},
+	{"mem_available_bytes", "mem_available_bytes", func(m *MetricSnapshot) any { return &m.MemAvailable }},
+	{"mem_used_bytes", "mem_used_bytes", func(m *MetricSnapshot) any { return &m.MemUsedBytes }},
+	{"swap_total_bytes", "swap_total_bytes", func(m *MetricSnapshot) any { return &m.SwapTotal }},
+	{"swap_free_bytes", "swap_free_bytes", func(m *MetricSnapshot) any { return &m.SwapFree }},
+
+	{"disk_read_bytes", "disk_read_bytes", func(m *MetricSnapshot) any { return &m.DiskReadBytes }},
+	{"disk_write_bytes", "disk_write_bytes", func(m *MetricSnapshot) any { return &m.DiskWriteBytes }},
+	{"disk_read_count", "disk_read_count", func(m *MetricSnapshot) any { return &m.DiskReadCount }},
+	{"disk_write_count", "disk_write_count", func(m *MetricSnapshot) any { return &m.DiskWriteCount }},
+	{"disk_iops_in_progress", "disk_iops_in_progress", func(m *MetricSnapshot) any { return &m.DiskIopsInProg }},
+
+	{"net_bytes_recv", "net_bytes_recv", func(m *MetricSnapshot) any { return &m.NetBytesRecv }},
+	{"net_bytes_sent", "net_bytes_sent", func(m *MetricSnapshot) any { return &m.NetBytesSent }},
+	{"net_packets_recv", "net_packets_recv", func(m *MetricSnapshot) any { return &m.NetPacketsRecv }},
+	{"net_packets_sent", "net_packets_sent", func(m *MetricSnapshot) any { return &m.NetPacketsSent }},
+	{"net_burst", "net_burst", func(m *MetricSnapshot) any { return &m.NetBurst }},
+	{"net_burst_p95_rate", "net_burst_p95_rate", func(m *MetricSnapshot) any { return &m.NetBurstP95 }},
+
+	{"kvm_total_vms", "kvm_total_vms", func(m *MetricSnapshot) any { return &m.KVMTotalVMs }},
+	{"kvm_active_vms", "kvm_active_vms", func(m *MetricSnapshot) any { return &m.KVMActiveVMs }},
+	{"kvm_alloc_vcpu", "kvm_alloc_vcpu", func(m *MetricSnapshot) any { return &m.KVMAllocVcpu }},
+	{"kvm_alloc_mem_bytes", "kvm_alloc_mem_bytes", func(m *MetricSnapshot) any { return &m.KVMAllocMem }},
+
+	{"ping_avg_rtt", "ping_avg_rtt", func(m *MetricSnapshot) any { return &m.PingAvgRTT }},
+}
+
+// SnapshotFieldNames 返回全部可查询的序列名 (API 的 fields 参数取值范围)
+func SnapshotFieldNames() []string {
+	names := make([]string, len(snapshotFields))
+	for i, f := range snapshotFields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Field 按序列名取值，未知名称返回 false
+func (m *MetricSnapshot) Field(name string) (float64, bool) {
+	f, ok := lookupField(name)
+	if !ok {
+		return 0, false
+	}
+	return fieldValue(f.ptr(m)), true
+}
+
+func fieldValue(p any) float64 {
+	switch v := p.(type) {
+	case *float64:
+		return *v
+	case *uint64:
+		return float64(*v)
+	}
+	return 0
+}
+
+// NewMetricSnapshot 将一次上报转为扁平快照，内存与 SQLite 后端共用
+func NewMetricSnapshot(req *pb.ReportRequest) MetricSnapshot {
+	cpu, mem, disk, nw, kvm := req.GetCpu(), req.GetMem(), req.GetDisk(), req.GetNet(), req.GetKvm()
+
+	snap := MetricSnapshot{
+		Timestamp: req.Timestamp,
+
+		CPULoad1:        cpu.GetLoad1(),
+		CPULoad5:        cpu.GetLoad5(),
+		CPULoad15:       cpu.GetLoad15(),
+		CPUUsagePerCore: cpu.GetUsagePerc(),
+		CPUCores:        float64(cpu.GetCores()),
+		CPUMhz:          cpu.GetMhz(),
+
+		MemUsed:      mem.GetUsedPercent(),
+		MemTotal:     float64(mem.GetTotal()),
+		MemAvailable: float64(mem.GetAvailable()),
+		MemUsedBytes: float64(mem.GetUsed()),
+		SwapTotal:    float64(mem.GetSwapTotal()),
+		SwapFree:     float64(mem.GetSwapFree()),
+
+		DiskReadBytes:  float64(disk.GetReadBytes()),
+		DiskWriteBytes: float64(disk.GetWriteBytes()),
+		DiskReadCount:  float64(disk.GetReadCount()),
+		DiskWriteCount: float64(disk.GetWriteCount()),
+		DiskIopsInProg: float64(disk.GetIopsInProgress()),
+
+		NetBytesRecv:   float64(nw.GetBytesRecv()),
+		NetBytesSent:   float64(nw.GetBytesSent()),
+		NetPacketsRecv: float64(nw.GetPacketsRecv()),
+		NetPacketsSent: float64(nw.GetPacketsSent()),
+		NetBurst:       nw.GetMicroburstEvents(),
+		NetBurstP95:    nw.GetBurstP95Rate(),
+
+		KVMTotalVMs:  float64(kvm.GetTotalVms()),
+		KVMActiveVMs: float64(kvm.GetActiveVms()),
+		KVMAllocVcpu: float64(kvm.GetTotalAllocVcpu()),
+		KVMAllocMem:  float64(kvm.GetTotalAllocMem()),
+	}
+
+	if usage := cpu.GetUsagePerc(); len(usage) > 0 {
+		var sum float64
+		for _, u := range usage {
+			sum += u
+		}
+		snap.CPUUsage = sum / float64(len(usage))
+	}
+
+	if len(req.PingResults) > 0 {
+		snap.PingAvgRTT = req.PingResults[0].AvgRttMs
+	}
+	return snap
+}
+
+// NewPingSnapshots 拆出上报中每个探测目标的结果
+func NewPingSnapshots(req *pb.ReportRequest) []PingSnapshot {
+	list := make([]PingSnapshot, 0, len(req.PingResults))
+	for _, p := range req.PingResults {
+		list = append(list, PingSnapshot{
+			Timestamp:  req.Timestamp,
+			Target:     PingTargetKey(p.TargetIp, p.TargetPort),
+			TargetIP:   p.TargetIp,
+			TargetPort: p.TargetPort,
+			TargetType: p.TargetType,
+			MinRTT:     p.MinRttMs,
+			AvgRTT:     p.AvgRttMs,
+			MaxRTT:     p.MaxRttMs,
+			Loss:       p.PacketLossRate,
+
+			Jitter:      p.JitterMs,
+			Reordered:   p.Reordered,
+			Duplicates:  p.Duplicates,
+			LossForward: p.LossForwardRate,
+			LossReverse: p.LossReverseRate,
+			MOS:         p.Mos,
+		})
+	}
+	return list
+}
@@ -2,8 +2,11 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	pb "github.com/geelinx-ltd/geegee/api/proto"
@@ -11,96 +14,146 @@ import (
 )
 
 type SqliteStore struct {
-	db            *sql.DB
-	retentionDays int
+	db        *sql.DB
+	retention SqliteRetention
+	rollups   *rollupState
+	writer    *sqliteWriter
+	stop      chan struct{}  // 关闭后通知汇总与清理协程退出
+	bg        sync.WaitGroup // 汇总与清理协程，关库前等其退出
+
+	// hot 内存热层：节点状态与每个节点最近 hotWindow 个点，大屏轮询无需访问数据库
+	hot       *MemoryCache
+	hotWindow int
+}
+
+// SqliteOptions SQLite 后端的分层保留与批量写入参数
+type SqliteOptions struct {
+	Retention SqliteRetention
+	Writer    SqliteWriterOptions
+	HotWindow int // 热层每个节点保留的点数，<= 0 取 DefaultHotWindow
 }
 
 // NewSqliteStore 挂载单文件数据库。并自动建表
-func NewSqliteStore(dsn string, retentionDays int) (*SqliteStore, error) {
-	db, err := sql.Open("sqlite", dsn)
+func NewSqliteStore(dsn string, opts SqliteOptions) (*SqliteStore, error) {
+	db, err := openSqlite(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	// 强制启用 WAL 模式提高并发写入性能
-	if _, err := db.Exec(`PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL;`); err != nil {
-		return nil, fmt.Errorf("failed to enable WAL: %w", err)
-	}
-
 	store := &SqliteStore{
-		db:            db,
-		retentionDays: retentionDays,
+		db:        db,
+		retention: opts.Retention,
+		stop:      make(chan struct{}),
+		hotWindow: opts.HotWindow,
 	}
+	if store.hotWindow <= 0 {
+		store.hotWindow = DefaultHotWindow
+	}
+	store.hot = NewMemoryCache(store.hotWindow)
 
-	// 初始化核心表结构
-	if err := store.initSchema(); err != nil {
+	// 按版本号依次执行尚未应用的迁移，库文件版本比程序新时拒绝启动
+	if _, _, err := migrate(db); err != nil {
+		db.Close()
 		return nil, err
 	}
+	if err := checkMetricColumns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := store.loadRollupState(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 重启后大屏立即有数据可画
+	if err := store.warmHotTier(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to warm hot tier: %w", err)
+	}
 
-	// 启动后台超期清理协程 (每小时清理一次旧数据)
+	// 上报经队列攒批写入
+	store.writer = newSqliteWriter(db, opts.Writer)
+
+	// 启动后台汇总协程 (每分钟推进) 与分层超期清理协程 (每小时清理一次旧数据)
+	store.bg.Add(2)
+	go store.rollupRoutine()
 	go store.cleanupRoutine()
 
 	return store, nil
 }
 
-func (s *SqliteStore) initSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS nodes (
-		node_id TEXT PRIMARY KEY,
-		last_seen INTEGER
-	);
-
-	CREATE TABLE IF NOT EXISTS metrics (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		node_id TEXT,
-		timestamp INTEGER,
-		cpu_load1 REAL,
-		mem_used REAL,
-		net_burst INTEGER,
-		ping_avg_rtt REAL
-	);
-	
-	-- 聚合索引以加速前端点图渲染
-	CREATE INDEX IF NOT EXISTS idx_metrics_node_time ON metrics(node_id, timestamp);
-	CREATE INDEX IF NOT EXISTS idx_metrics_time ON metrics(timestamp);
-	`
-	_, err := s.db.Exec(schema)
-	return err
-}
+func openSqlite(dsn string) (*sql.DB, error) {
+	// 连接级 PRAGMA 必须随 DSN 下发，database/sql 连接池里的每个连接才都会生效
+	db, err := sql.Open("sqlite", withPragmas(dsn))
+	if err != nil {
+		return nil, err
+	}
 
-func (s *SqliteStore) Ingest(req *pb.ReportRequest) error {
-	now := time.Now().UnixMilli()
+	// 强制启用 WAL 模式提高并发写入性能
+	if _, err := db.Exec(`PRAGMA journal_mode=WAL; PRAGMA synchronous=NORMAL;`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable WAL: %w", err)
+	}
+	return db, nil
+}
 
-	// 1. 更新 Nodes 库表状态 (采用 SQLite Upsert: INSERT ... ON CONFLICT)
-	_, err := s.db.Exec(`
-		INSERT INTO nodes (node_id, last_seen) 
-		VALUES (?, ?) 
-		ON CONFLICT(node_id) DO UPDATE SET last_seen=excluded.last_seen;
-	`, req.NodeId, now)
-	if err != nil {
-		return err
+// withPragmas 为 DSN 追加 busy_timeout 等连接参数：后台汇总与上报写入并发时排队等待而不是直接报 SQLITE_BUSY
+func withPragmas(dsn string) string {
+	if strings.Contains(dsn, "_pragma=busy_timeout") {
+		return dsn
 	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+}
 
-	// 2. 解析 Ping
-	var avgRtt float64
-	if len(req.PingResults) > 0 {
-		avgRtt = req.PingResults[0].AvgRttMs
+// metricColumns 按 snapshotFields 顺序拼出的列清单，插入与查询共用
+var metricColumns = func() string {
+	cols := make([]string, len(snapshotFields))
+	for i, f := range snapshotFields {
+		cols[i] = f.Column
 	}
+	return strings.Join(cols, ", ")
+}()
 
-	// 3. 落点库表
-	_, err = s.db.Exec(`
-		INSERT INTO metrics (node_id, timestamp, cpu_load1, mem_used, net_burst, ping_avg_rtt)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`,
-		req.NodeId, req.Timestamp,
-		req.Cpu.Load1, req.Mem.UsedPercent,
-		req.Net.MicroburstEvents, avgRtt)
+// Ingest 先写热层再入队，由后台写入协程攒批落盘。队列满时阻塞调用方形成背压
+func (s *SqliteStore) Ingest(req *pb.ReportRequest) error {
+	s.hot.Ingest(req)
+	return s.writer.enqueue(req)
+}
 
-	return err
+// IngestStats 写入队列深度与批量提交耗时
+func (s *SqliteStore) IngestStats() IngestStats {
+	return s.writer.stats()
 }
 
+// Close 先把队列中剩余的上报落盘，再停止后台协程并关闭数据库
+func (s *SqliteStore) Close() error {
+	s.writer.close()
+	close(s.stop)
+	s.bg.Wait()
+	st := s.writer.stats()
+	log.Printf("[SQLite Store] Closed. written=%d failed=%d rejected=%d", st.Written, st.Failed, st.Rejected)
+	return s.db.Close()
+}
+
+// GetNodes 节点状态由热层提供 (启动时已从 nodes 表回填全部节点)
 func (s *SqliteStore) GetNodes() ([]NodeStatus, error) {
-	rows, err := s.db.Query(`SELECT node_id, last_seen FROM nodes`)
+	nodes, err := s.hot.GetNodes()
+	if err != nil {
+		return nil, err
+	}
+	// 与直接查库时的返回保持一致，不附带图表缓冲
+	for i := range nodes {
+		nodes[i].HistoryFlow = nil
+	}
+	return nodes, nil
+}
+
+func (s *SqliteStore) queryNodes() ([]NodeStatus, error) {
+	rows, err := s.db.Query(`SELECT node_id, last_seen, COALESCE(cpu_model, '') FROM nodes`)
 	if err != nil {
 		return nil, err
 	}
@@ -110,23 +163,36 @@ func (s *SqliteStore) GetNodes() ([]NodeStatus, error) {
 	now := time.Now().UnixMilli()
 	for rows.Next() {
 		var n NodeStatus
-		if err := rows.Scan(&n.NodeID, &n.LastSeen); err != nil {
+		if err := rows.Scan(&n.NodeID, &n.LastSeen, &n.CPUModel); err != nil {
 			log.Printf("Sqlite scan node err: %v", err)
 			continue
 		}
 		// 若最近 15 秒存活过则判定 Online
-		n.IsOnline = (now - n.LastSeen) < 15000
+		n.IsOnline = (now - n.LastSeen) < OnlineTimeoutMs
 		list = append(list, n)
 	}
 	return list, nil
 }
 
+// GetNodeHistory 热层总是持有最近 hotWindow 个点，不超过该数量的请求直接从内存返回
 func (s *SqliteStore) GetNodeHistory(nodeID string, limit int) ([]MetricSnapshot, error) {
+	if limit > 0 && limit <= s.hotWindow {
+		history, err := s.hot.GetNodeHistory(nodeID, limit)
+		if len(history) > limit {
+			history = history[len(history)-limit:]
+		}
+		return history, err
+	}
+	return s.queryNodeHistory(nodeID, limit)
+}
+
+func (s *SqliteStore) queryNodeHistory(nodeID string, limit int) ([]MetricSnapshot, error) {
 	// 从数据库抽取属于他的过去 N 个流水
 	// 若在大单体环境里时间跨度很长，这里我们可以按 ORDER BY DESC 取回再将其 Reverse
 	// 但这只是最简单的拉取
+	// 旧版本写入的行没有新增列，统一以 0 填充
 	query := `
-		SELECT timestamp, cpu_load1, mem_used, net_burst, ping_avg_rtt 
+		SELECT timestamp, COALESCE(cpu_usage_perc, ''), ` + coalescedMetricColumns + `
 		FROM metrics 
 		WHERE node_id = ? 
 		ORDER BY timestamp DESC 
@@ -140,10 +206,21 @@ func (s *SqliteStore) GetNodeHistory(nodeID string, limit int) ([]MetricSnapshot
 
 	var result []MetricSnapshot
 	for rows.Next() {
-		var m MetricSnapshot
-		if err := rows.Scan(&m.Timestamp, &m.CPULoad1, &m.MemUsed, &m.NetBurst, &m.PingAvgRTT); err != nil {
+		var (
+			m       MetricSnapshot
+			perCore string
+		)
+		dest := make([]any, 0, len(snapshotFields)+2)
+		dest = append(dest, &m.Timestamp, &perCore)
+		for _, f := range snapshotFields {
+			dest = append(dest, f.ptr(&m))
+		}
+		if err := rows.Scan(dest...); err != nil {
 			continue
 		}
+		if perCore != "" {
+			_ = json.Unmarshal([]byte(perCore), &m.CPUUsagePerCore)
+		}
 		result = append(result, m)
 	}
 
@@ -152,29 +229,68 @@ func (s *SqliteStore) GetNodeHistory(nodeID string, limit int) ([]MetricSnapshot
 	return result, nil
 }
 
-// reverse 切片反转辅助函数
-func reverse(s []MetricSnapshot) {
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
+var coalescedMetricColumns = func() string {
+	cols := make([]string, len(snapshotFields))
+	for i, f := range snapshotFields {
+		cols[i] = "COALESCE(" + f.Column + ", 0)"
 	}
+	return strings.Join(cols, ", ")
+}()
+
+// GetPingTargets 每个目标取最新一行，由热层提供
+func (s *SqliteStore) GetPingTargets(nodeID string) ([]PingSnapshot, error) {
+	return s.hot.GetPingTargets(nodeID)
 }
 
-// cleanupRoutine 自动蒸发老旧纪元
-func (s *SqliteStore) cleanupRoutine() {
-	if s.retentionDays <= 0 {
-		return
+func (s *SqliteStore) GetPingHistory(nodeID, target string, limit int) ([]PingSnapshot, error) {
+	if limit > 0 && limit <= s.hotWindow {
+		return s.hot.GetPingHistory(nodeID, target, limit)
+	}
+	return s.queryPingHistory(nodeID, target, limit)
+}
+
+func (s *SqliteStore) queryPingHistory(nodeID, target string, limit int) ([]PingSnapshot, error) {
+	rows, err := s.db.Query(`
+		SELECT timestamp, target, target_ip, target_port, target_type, min_rtt, avg_rtt, max_rtt, loss,
+			COALESCE(jitter, 0), COALESCE(reordered, 0), COALESCE(duplicates, 0),
+			COALESCE(loss_forward, 0), COALESCE(loss_reverse, 0), COALESCE(mos, 0)
+		FROM ping_results
+		WHERE node_id = ? AND target = ?
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`, nodeID, target, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result, err := scanPingRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
 	}
-	ticker := time.NewTicker(1 * time.Hour)
-	for range ticker.C {
-		cutoff := time.Now().AddDate(0, 0, -s.retentionDays).UnixMilli()
-		res, err := s.db.Exec(`DELETE FROM metrics WHERE timestamp < ?`, cutoff)
-		if err == nil {
-			affected, _ := res.RowsAffected()
-			if affected > 0 {
-				log.Printf("[SQLite Store] Cleaned up %d outdated metric rows (older than %d days)", affected, s.retentionDays)
-			}
-		} else {
-			log.Printf("[SQLite Store] Cleanup error: %v", err)
+	return result, nil
+}
+
+func scanPingRows(rows *sql.Rows) ([]PingSnapshot, error) {
+	var result []PingSnapshot
+	for rows.Next() {
+		var p PingSnapshot
+		if err := rows.Scan(&p.Timestamp, &p.Target, &p.TargetIP, &p.TargetPort, &p.TargetType,
+			&p.MinRTT, &p.AvgRTT, &p.MaxRTT, &p.Loss,
+			&p.Jitter, &p.Reordered, &p.Duplicates, &p.LossForward, &p.LossReverse, &p.MOS); err != nil {
+			continue
 		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+// reverse 切片反转辅助函数
+func reverse(s []MetricSnapshot) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
 	}
 }
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// DeleteNode 在一个事务内删除节点在各层级的全部数据，随后清掉热层
+func (s *SqliteStore) DeleteNode(nodeID string) error {
+	s.writer.sync()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var rows int64
+	for _, t := range sqliteTiers {
+		for _, table := range []string{t.metrics, t.pings} {
+			res, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE node_id = ?`, table), nodeID)
+			if err != nil {
+				return fmt.Errorf("delete from %s: %w", table, err)
+			}
+			n, _ := res.RowsAffected()
+			rows += n
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM nodes WHERE node_id = ?`, nodeID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.hot.DeleteNode(nodeID)
+	log.Printf("[SQLite Store] Deleted node [%s]: %d rows", nodeID, rows)
+	return nil
+}
+
+// MergeNode 把 from 的原始数据改挂到 into 名下；汇总表中两者落在同一桶的行按 min/sum/max/count 合并
+func (s *SqliteStore) MergeNode(from, into string) error {
+	s.writer.sync()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var rows int64
+	exec := func(query string, args ...any) error {
+		res, err := tx.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		rows += n
+		return nil
+	}
+
+	for _, t := range sqliteTiers {
+		if !t.isRollup() {
+			for _, table := range []string{t.metrics, t.pings} {
+				if err := exec(fmt.Sprintf(`UPDATE %s SET node_id = ? WHERE node_id = ?`, table), into, from); err != nil {
+					return fmt.Errorf("merge %s: %w", table, err)
+				}
+			}
+			continue
+		}
+		if err := exec(mergeMetricRollupSQL(t), into, from); err != nil {
+			return fmt.Errorf("merge %s: %w", t.metrics, err)
+		}
+		if err := exec(mergePingRollupSQL(t), into, from); err != nil {
+			return fmt.Errorf("merge %s: %w", t.pings, err)
+		}
+		for _, table := range []string{t.metrics, t.pings} {
+			if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE node_id = ?`, table), from); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO nodes (node_id, last_seen, cpu_model)
+		SELECT ?, last_seen, cpu_model FROM nodes WHERE node_id = ?
+		ON CONFLICT(node_id) DO UPDATE SET
+			last_seen = MAX(last_seen, excluded.last_seen),
+			cpu_model = COALESCE(NULLIF(cpu_model, ''), excluded.cpu_model)`, into, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM nodes WHERE node_id = ?`, from); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.hot.MergeNode(from, into)
+	log.Printf("[SQLite Store] Merged node [%s] into [%s]: %d rows", from, into, rows)
+	return nil
+}
+
+// 合并汇总行：任一侧为 NULL 时取另一侧
+func mergeMin(col string) string {
+	return fmt.Sprintf("%[1]s = COALESCE(MIN(%[1]s, excluded.%[1]s), %[1]s, excluded.%[1]s)", col)
+}
+
+func mergeMax(col string) string {
+	return fmt.Sprintf("%[1]s = COALESCE(MAX(%[1]s, excluded.%[1]s), %[1]s, excluded.%[1]s)", col)
+}
+
+func mergeSum(col string) string {
+	return fmt.Sprintf("%[1]s = COALESCE(%[1]s + excluded.%[1]s, %[1]s, excluded.%[1]s)", col)
+}
+
+func mergeMetricRollupSQL(t *sqliteTier) string {
+	cols := make([]string, 0, len(snapshotFields)*3)
+	sets := []string{mergeSum("count")}
+	for _, f := range snapshotFields {
+		cols = append(cols, f.Column+"_min", f.Column+"_sum", f.Column+"_max")
+		sets = append(sets, mergeMin(f.Column+"_min"), mergeSum(f.Column+"_sum"), mergeMax(f.Column+"_max"))
+	}
+	list := strings.Join(cols, ", ")
+	return fmt.Sprintf(`INSERT INTO %[1]s (node_id, bucket, count, %[2]s)
+		SELECT ?, bucket, count, %[2]s FROM %[1]s WHERE node_id = ?
+		ON CONFLICT(node_id, bucket) DO UPDATE SET %[3]s`, t.metrics, list, strings.Join(sets, ", "))
+}
+
+func mergePingRollupSQL(t *sqliteTier) string {
+	return fmt.Sprintf(`INSERT INTO %s (node_id, target, bucket, count, min_rtt, sum_avg_rtt, max_rtt, sum_loss, max_loss)
+		SELECT ?, target, bucket, count, min_rtt, sum_avg_rtt, max_rtt, sum_loss, max_loss FROM %s WHERE node_id = ?
+		ON CONFLICT(node_id, target, bucket) DO UPDATE SET %s`, t.pings, t.pings, strings.Join([]string{
+		mergeSum("count"), mergeMin("min_rtt"), mergeSum("sum_avg_rtt"), mergeMax("max_rtt"), mergeSum("sum_loss"), mergeMax("max_loss"),
+	}, ", "))
+}
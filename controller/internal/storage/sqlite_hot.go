@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultHotWindow 热层每个节点 (及每个探测目标) 保留的最近点数，5 秒一点约 1 小时
+const DefaultHotWindow = 720
+
+// warmHotTier 启动时从库中回填热层：全部节点状态 + 每个节点与目标最近 hotWindow 个点
+func (s *SqliteStore) warmHotTier() error {
+	start := time.Now()
+	nodes, err := s.queryNodes()
+	if err != nil {
+		return err
+	}
+
+	var points int
+	for _, n := range nodes {
+		history, err := s.queryNodeHistory(n.NodeID, s.hotWindow)
+		if err != nil {
+			return err
+		}
+		targets, err := s.queryPingTargetNames(n.NodeID)
+		if err != nil {
+			return err
+		}
+		pings := make(map[string][]PingSnapshot, len(targets))
+		for _, target := range targets {
+			if pings[target], err = s.queryPingHistory(n.NodeID, target, s.hotWindow); err != nil {
+				return err
+			}
+		}
+		s.hot.restore(n, history, pings)
+		points += len(history)
+	}
+	log.Printf("[SQLite Store] Hot tier warmed with %d nodes / %d points in %v", len(nodes), points, time.Since(start))
+	return nil
+}
+
+func (s *SqliteStore) queryPingTargetNames(nodeID string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT target FROM ping_results WHERE node_id = ?`, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// hotCovers 热层保存的是最近的连续一段，最早一点不晚于 from 时整个查询都能由热层回答
+func hotCovers(oldest, from int64) bool {
+	return oldest <= from
+}
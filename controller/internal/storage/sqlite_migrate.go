@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrSchemaTooNew 库文件由更新版本的程序写入，当前程序不认识其结构
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// sqliteMigration 一个只进不退的结构变更。up 在事务内执行，与版本记录一同提交
+//
+// 已发布的迁移不可修改，新增列、表、索引一律追加新的版本号。
+// 迁移中引用的列清单须写成字面量，不能引用会随代码变化的 snapshotFields
+type sqliteMigration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// metricColumnsV2 版本 2 时 metrics 表的数值列，汇总表 (版本 4) 按同一清单建列
+var metricColumnsV2 = []string{
+	"cpu_load1", "cpu_load5", "cpu_load15", "cpu_usage_percent", "cpu_cores", "cpu_mhz",
+	"mem_used", "mem_total_bytes", "mem_available_bytes", "mem_used_bytes", "swap_total_bytes", "swap_free_bytes",
+	"disk_read_bytes", "disk_write_bytes", "disk_read_count", "disk_write_count", "disk_iops_in_progress",
+	"net_bytes_recv", "net_bytes_sent", "net_packets_recv", "net_packets_sent", "net_burst", "net_burst_p95_rate",
+	"kvm_total_vms", "kvm_active_vms", "kvm_alloc_vcpu", "kvm_alloc_mem_bytes",
+	"ping_avg_rtt",
+}
+
+var sqliteMigrations = []sqliteMigration{
+	{1, "baseline nodes and metrics", func(tx *sql.Tx) error {
+		// 早期版本没有版本表，但已经用 IF NOT EXISTS 建过这两张表
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS nodes (
+				node_id TEXT PRIMARY KEY,
+				last_seen INTEGER
+			)`,
+			`CREATE TABLE IF NOT EXISTS metrics (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				node_id TEXT,
+				timestamp INTEGER,
+				cpu_load1 REAL,
+				mem_used REAL,
+				net_burst INTEGER,
+				ping_avg_rtt REAL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_metrics_node_time ON metrics(node_id, timestamp)`,
+		)
+	}},
+	{2, "store every reported field", func(tx *sql.Tx) error {
+		if err := addColumns(tx, "nodes", []string{"cpu_model"}, "TEXT"); err != nil {
+			return err
+		}
+		if err := addColumns(tx, "metrics", []string{"cpu_usage_perc"}, "TEXT"); err != nil {
+			return err
+		}
+		return addColumns(tx, "metrics", metricColumnsV2, "REAL")
+	}},
+	{3, "per-target ping results", func(tx *sql.Tx) error {
+		return execAll(tx,
+			// 每个探测目标一行，便于按目标画多条折线
+			`CREATE TABLE IF NOT EXISTS ping_results (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				node_id TEXT,
+				target TEXT,
+				target_ip TEXT,
+				target_port INTEGER,
+				target_type TEXT,
+				timestamp INTEGER,
+				min_rtt REAL,
+				avg_rtt REAL,
+				max_rtt REAL,
+				loss REAL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_metrics_time ON metrics(timestamp)`,
+			`CREATE INDEX IF NOT EXISTS idx_ping_node_target_time ON ping_results(node_id, target, timestamp)`,
+			`CREATE INDEX IF NOT EXISTS idx_ping_time ON ping_results(timestamp)`,
+		)
+	}},
+	{4, "1m and 1h rollups", func(tx *sql.Tx) error {
+		stmts := []string{`CREATE TABLE IF NOT EXISTS rollup_state (
+			tier TEXT PRIMARY KEY,
+			watermark INTEGER
+		)`}
+		for _, t := range []string{"1m", "1h"} {
+			// 每个序列保存 min/sum/max，配合 count 可以在任意更粗粒度上再聚合
+			cols := make([]string, 0, len(metricColumnsV2)*3)
+			for _, c := range metricColumnsV2 {
+				cols = append(cols, c+"_min REAL", c+"_sum REAL", c+"_max REAL")
+			}
+			stmts = append(stmts,
+				fmt.Sprintf(`CREATE TABLE IF NOT EXISTS metrics_%s (
+					node_id TEXT,
+					bucket INTEGER,
+					count INTEGER,
+					%s,
+					PRIMARY KEY (node_id, bucket)
+				)`, t, strings.Join(cols, ",\n\t\t\t\t\t")),
+				fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_metrics_%s_bucket ON metrics_%s(bucket)`, t, t),
+				fmt.Sprintf(`CREATE TABLE IF NOT EXISTS ping_results_%s (
+					node_id TEXT,
+					target TEXT,
+					bucket INTEGER,
+					count INTEGER,
+					min_rtt REAL,
+					sum_avg_rtt REAL,
+					max_rtt REAL,
+					sum_loss REAL,
+					max_loss REAL,
+					PRIMARY KEY (node_id, target, bucket)
+				)`, t),
+				fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_ping_results_%s_bucket ON ping_results_%s(bucket)`, t, t),
+			)
+		}
+		return execAll(tx, stmts...)
+	}},
+	{5, "udp probe quality", func(tx *sql.Tx) error {
+		// 只有原始表保存，汇总表仍只有延迟与丢包
+		if err := addColumns(tx, "ping_results", []string{"jitter", "loss_forward", "loss_reverse", "mos"}, "REAL"); err != nil {
+			return err
+		}
+		return addColumns(tx, "ping_results", []string{"reordered", "duplicates"}, "INTEGER")
+	}},
+}
+
+// LatestSchemaVersion 当前程序支持的最高结构版本
+func LatestSchemaVersion() int {
+	return sqliteMigrations[len(sqliteMigrations)-1].version
+}
+
+// MigrateSqlite 只执行迁移不启动存储，供 --migrate-only 使用
+func MigrateSqlite(dsn string) (from, to int, err error) {
+	db, err := openSqlite(dsn)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+	return migrate(db)
+}
+
+// migrate 依次执行尚未应用的迁移，每个迁移一个事务
+func migrate(db *sql.DB) (from, to int, err error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		applied_at INTEGER
+	)`); err != nil {
+		return 0, 0, err
+	}
+
+	var current sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&current); err != nil {
+		return 0, 0, err
+	}
+	from = int(current.Int64)
+	latest := LatestSchemaVersion()
+	if from > latest {
+		return from, from, fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrSchemaTooNew, from, latest)
+	}
+
+	to = from
+	for _, m := range sqliteMigrations {
+		if m.version <= from {
+			continue
+		}
+		start := time.Now()
+		if err := applyMigration(db, m); err != nil {
+			return from, to, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		to = m.version
+		log.Printf("[SQLite Store] Applied migration %d (%s) in %v", m.version, m.name, time.Since(start))
+	}
+	return from, to, nil
+}
+
+func applyMigration(db *sql.DB, m sqliteMigration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now().UnixMilli()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execAll(tx *sql.Tx, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumns 追加缺失列 (SQLite 不支持 ADD COLUMN IF NOT EXISTS)。
+// 引入迁移之前的版本会在启动时自行补列，这些库里部分列可能已经存在
+func addColumns(tx *sql.Tx, table string, cols []string, typ string) error {
+	existing, err := tableColumns(tx, table)
+	if err != nil {
+		return err
+	}
+	for _, name := range cols {
+		if existing[name] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, name, typ)); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", table, name, err)
+		}
+	}
+	return nil
+}
+
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func tableColumns(q queryer, table string) (map[string]bool, error) {
+	rows, err := q.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		existing[name] = true
+	}
+	return existing, rows.Err()
+}
+
+// checkMetricColumns 在 snapshotFields 新增了序列却忘记补迁移时尽早报错，而不是在写入时才失败
+func checkMetricColumns(db *sql.DB) error {
+	for _, t := range sqliteTiers {
+		existing, err := tableColumns(db, t.metrics)
+		if err != nil {
+			return err
+		}
+		for _, f := range snapshotFields {
+			col := f.Column
+			if t.isRollup() {
+				col += "_sum"
+			}
+			if !existing[col] {
+				return fmt.Errorf("table %s has no column %s: add a schema migration for field %q", t.metrics, col, f.Name)
+			}
+		}
+	}
+	return nil
+}
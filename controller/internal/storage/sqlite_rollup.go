@@ -0,0 +1,465 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SqliteRetention 各层级数据的保留天数，<= 0 表示永久保留
+type SqliteRetention struct {
+	RawDays    int // 原始 5 秒粒度
+	MinuteDays int // 1 分钟汇总
+	HourDays   int // 1 小时汇总
+}
+
+// rollupGraceMs 汇总滞后于当前时间的量，给迟到的上报留出余地
+const rollupGraceMs = 2 * 60 * 1000
+
+// sqliteTier 描述一个存储层级。raw 层直接读 metrics / ping_results，其余层读对应汇总表
+type sqliteTier struct {
+	name       string
+	resolution int64 // 毫秒；raw 层为 0
+	metrics    string
+	pings      string
+	source     *sqliteTier // 汇总数据来源层
+}
+
+var (
+	tierRaw    = &sqliteTier{name: "raw", metrics: "metrics", pings: "ping_results"}
+	tierMinute = &sqliteTier{name: "1m", resolution: 60 * 1000, metrics: "metrics_1m", pings: "ping_results_1m", source: tierRaw}
+	tierHour   = &sqliteTier{name: "1h", resolution: 60 * 60 * 1000, metrics: "metrics_1h", pings: "ping_results_1h", source: tierMinute}
+
+	// sqliteTiers 由细到粗排列，查询路由依赖该顺序
+	sqliteTiers = []*sqliteTier{tierRaw, tierMinute, tierHour}
+)
+
+func (t *sqliteTier) isRollup() bool { return t.resolution > 0 }
+
+// rollupState 记录每个汇总层已完成到的时间点 (不含)，该点之前的数据已可从汇总表读取
+type rollupState struct {
+	mu         sync.RWMutex
+	watermarks map[string]int64
+}
+
+func (r *rollupState) get(t *sqliteTier) int64 {
+	if !t.isRollup() {
+		return math.MaxInt64
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.watermarks[t.name]
+}
+
+func (r *rollupState) set(t *sqliteTier, wm int64) {
+	r.mu.Lock()
+	r.watermarks[t.name] = wm
+	r.mu.Unlock()
+}
+
+func (s *SqliteStore) loadRollupState() error {
+	s.rollups = &rollupState{watermarks: make(map[string]int64)}
+	rows, err := s.db.Query(`SELECT tier, watermark FROM rollup_state`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			name string
+			wm   int64
+		)
+		if err := rows.Scan(&name, &wm); err != nil {
+			return err
+		}
+		s.rollups.watermarks[name] = wm
+	}
+	return rows.Err()
+}
+
+// rollupRoutine 每分钟推进一次各汇总层
+func (s *SqliteStore) rollupRoutine() {
+	defer s.bg.Done()
+	s.runRollups()
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.runRollups()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *SqliteStore) runRollups() {
+	upto := time.Now().UnixMilli() - rollupGraceMs
+	for _, t := range sqliteTiers {
+		if !t.isRollup() {
+			continue
+		}
+		// 上层只能汇总下层已完成的部分
+		if t.source.isRollup() {
+			upto = s.rollups.get(t.source)
+		}
+		upto -= upto % t.resolution
+		if err := s.rollupTier(t, upto); err != nil {
+			log.Printf("[SQLite Store] Rollup %s error: %v", t.name, err)
+		}
+	}
+}
+
+// rollupTier 将 [watermark, upto) 内的数据从来源层汇总进 t，按天分段提交避免长事务
+func (s *SqliteStore) rollupTier(t *sqliteTier, upto int64) error {
+	wm := s.rollups.get(t)
+	if wm == 0 {
+		// 首次运行：从来源层最早的数据开始
+		var earliest sql.NullInt64
+		col := "timestamp"
+		if t.source.isRollup() {
+			col = "bucket"
+		}
+		if err := s.db.QueryRow(fmt.Sprintf(`SELECT MIN(%s) FROM %s`, col, t.source.metrics)).Scan(&earliest); err != nil {
+			return err
+		}
+		if !earliest.Valid {
+			return nil
+		}
+		wm = earliest.Int64 - earliest.Int64%t.resolution
+	}
+
+	const chunk = 24 * 60 * 60 * 1000
+	for wm < upto {
+		end := wm + chunk
+		if end > upto {
+			end = upto
+		}
+		if err := s.rollupRange(t, wm, end); err != nil {
+			return err
+		}
+		wm = end
+		s.rollups.set(t, wm)
+	}
+	return nil
+}
+
+func (s *SqliteStore) rollupRange(t *sqliteTier, from, to int64) error {
+	var (
+		metricSQL, pingSQL string
+		insertCols         = make([]string, 0, len(snapshotFields)*3)
+		selectCols         = make([]string, 0, len(snapshotFields)*3)
+	)
+	for _, f := range snapshotFields {
+		insertCols = append(insertCols, f.Column+"_min", f.Column+"_sum", f.Column+"_max")
+		if t.source.isRollup() {
+			selectCols = append(selectCols,
+				fmt.Sprintf("MIN(%s_min)", f.Column), fmt.Sprintf("SUM(%s_sum)", f.Column), fmt.Sprintf("MAX(%s_max)", f.Column))
+		} else {
+			selectCols = append(selectCols,
+				fmt.Sprintf("MIN(%s)", f.Column), fmt.Sprintf("SUM(%s)", f.Column), fmt.Sprintf("MAX(%s)", f.Column))
+		}
+	}
+
+	if t.source.isRollup() {
+		metricSQL = fmt.Sprintf(`INSERT OR REPLACE INTO %s (node_id, bucket, count, %s)
+			SELECT node_id, (bucket / %d) * %d AS b, SUM(count), %s
+			FROM %s WHERE bucket >= ? AND bucket < ?
+			GROUP BY node_id, b`,
+			t.metrics, strings.Join(insertCols, ", "), t.resolution, t.resolution, strings.Join(selectCols, ", "), t.source.metrics)
+		pingSQL = fmt.Sprintf(`INSERT OR REPLACE INTO %s (node_id, target, bucket, count, min_rtt, sum_avg_rtt, max_rtt, sum_loss, max_loss)
+			SELECT node_id, target, (bucket / %d) * %d AS b, SUM(count), MIN(min_rtt), SUM(sum_avg_rtt), MAX(max_rtt), SUM(sum_loss), MAX(max_loss)
+			FROM %s WHERE bucket >= ? AND bucket < ?
+			GROUP BY node_id, target, b`,
+			t.pings, t.resolution, t.resolution, t.source.pings)
+	} else {
+		metricSQL = fmt.Sprintf(`INSERT OR REPLACE INTO %s (node_id, bucket, count, %s)
+			SELECT node_id, (timestamp / %d) * %d AS b, COUNT(*), %s
+			FROM %s WHERE timestamp >= ? AND timestamp < ?
+			GROUP BY node_id, b`,
+			t.metrics, strings.Join(insertCols, ", "), t.resolution, t.resolution, strings.Join(selectCols, ", "), t.source.metrics)
+		pingSQL = fmt.Sprintf(`INSERT OR REPLACE INTO %s (node_id, target, bucket, count, min_rtt, sum_avg_rtt, max_rtt, sum_loss, max_loss)
+			SELECT node_id, target, (timestamp / %d) * %d AS b, COUNT(*), MIN(min_rtt), SUM(avg_rtt), MAX(max_rtt), SUM(loss), MAX(loss)
+			FROM %s WHERE timestamp >= ? AND timestamp < ?
+			GROUP BY node_id, target, b`,
+			t.pings, t.resolution, t.resolution, t.source.pings)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(metricSQL, from, to); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(pingSQL, from, to); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO rollup_state (tier, watermark) VALUES (?, ?)
+		ON CONFLICT(tier) DO UPDATE SET watermark=excluded.watermark`, t.name, to); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// retentionFor 返回层级的保留天数
+func (s *SqliteStore) retentionFor(t *sqliteTier) int {
+	switch t {
+	case tierMinute:
+		return s.retention.MinuteDays
+	case tierHour:
+		return s.retention.HourDays
+	}
+	return s.retention.RawDays
+}
+
+// planTiers 为查询挑选起始层级：分辨率不粗于 step、且保留期覆盖 From 的最细层级
+// 若没有层级能同时满足，则退而选保留期能覆盖 From 的层级，再不行用最粗层
+func (s *SqliteStore) planTiers(q RangeQuery) int {
+	now := time.Now()
+	covers := func(t *sqliteTier) bool {
+		days := s.retentionFor(t)
+		return days <= 0 || q.From >= now.AddDate(0, 0, -days).UnixMilli()
+	}
+	for i, t := range sqliteTiers {
+		if t.resolution <= q.Step && covers(t) {
+			// 再往上找一层：分辨率仍不粗于 step 时用更粗的层级，读的行更少
+			for i+1 < len(sqliteTiers) && sqliteTiers[i+1].resolution <= q.Step && covers(sqliteTiers[i+1]) {
+				i++
+			}
+			return i
+		}
+	}
+	for i, t := range sqliteTiers {
+		if covers(t) {
+			return i
+		}
+	}
+	return len(sqliteTiers) - 1
+}
+
+// aggRow 跨层级合并用的中间结果，保存 sum 以便按点数加权
+type aggRow struct {
+	ts    int64
+	count int64
+	min   []float64
+	sum   []float64
+	max   []float64
+}
+
+func (a *aggRow) merge(b *aggRow) {
+	a.count += b.count
+	for i := range a.min {
+		a.min[i] = math.Min(a.min[i], b.min[i])
+		a.max[i] = math.Max(a.max[i], b.max[i])
+		a.sum[i] += b.sum[i]
+	}
+}
+
+// queryAggs 从选中的层级开始查询；该层级汇总尚未覆盖的尾部时间段依次交给更细的层级补齐
+func (s *SqliteStore) queryAggs(q RangeQuery, build func(t *sqliteTier, from, to int64) (string, []any), width int) ([]*aggRow, error) {
+	merged := make(map[int64]*aggRow)
+	from := q.From
+	for i := s.planTiers(q); i >= 0 && from <= q.To; i-- {
+		t := sqliteTiers[i]
+		to := q.To
+		if wm := s.rollups.get(t); wm-1 < to {
+			to = wm - 1
+		}
+		if to < from {
+			continue
+		}
+
+		// 汇总行以桶起点为时间戳，起点需对齐到该层分辨率才不会漏掉首个桶
+		queryFrom := from
+		if t.isRollup() {
+			queryFrom -= queryFrom % t.resolution
+		}
+		query, args := build(t, queryFrom, to)
+		rows, err := s.db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			r := &aggRow{min: make([]float64, width), sum: make([]float64, width), max: make([]float64, width)}
+			dest := make([]any, 0, width*3+2)
+			dest = append(dest, &r.ts, &r.count)
+			for j := 0; j < width; j++ {
+				dest = append(dest, &r.min[j], &r.sum[j], &r.max[j])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if r.count == 0 {
+				continue
+			}
+			if existing, ok := merged[r.ts]; ok {
+				existing.merge(r)
+			} else {
+				merged[r.ts] = r
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		from = to + 1
+	}
+
+	result := make([]*aggRow, 0, len(merged))
+	for _, r := range merged {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ts < result[j].ts })
+	return result, nil
+}
+
+// QueryNodeHistory 根据时间跨度与 step 自动选择原始表或汇总表
+func (s *SqliteStore) QueryNodeHistory(nodeID string, q RangeQuery) ([]MetricBucket, error) {
+	fields, err := q.selectedFields()
+	if err != nil {
+		return nil, err
+	}
+
+	// 热层完整覆盖查询窗口时不必访问数据库
+	if history, _ := s.hot.GetNodeHistory(nodeID, 0); len(history) > 0 && hotCovers(history[0].Timestamp, q.From) {
+		return downsampleMetrics(history, q), nil
+	}
+
+	build := func(t *sqliteTier, from, to int64) (string, []any) {
+		exprs := make([]string, 0, len(fields)*3)
+		timeCol, countExpr := "timestamp", "COUNT(*)"
+		for _, f := range fields {
+			if t.isRollup() {
+				exprs = append(exprs,
+					fmt.Sprintf("COALESCE(MIN(%s_min), 0)", f.Column),
+					fmt.Sprintf("COALESCE(SUM(%s_sum), 0)", f.Column),
+					fmt.Sprintf("COALESCE(MAX(%s_max), 0)", f.Column))
+			} else {
+				exprs = append(exprs,
+					fmt.Sprintf("COALESCE(MIN(%s), 0)", f.Column),
+					fmt.Sprintf("COALESCE(SUM(%s), 0)", f.Column),
+					fmt.Sprintf("COALESCE(MAX(%s), 0)", f.Column))
+			}
+		}
+		if t.isRollup() {
+			timeCol, countExpr = "bucket", "SUM(count)"
+		}
+		query := fmt.Sprintf(`
+			SELECT (%s / ?) * ? AS b, %s, %s
+			FROM %s
+			WHERE node_id = ? AND %s BETWEEN ? AND ?
+			GROUP BY b
+		`, timeCol, countExpr, strings.Join(exprs, ", "), t.metrics, timeCol)
+		return query, []any{q.Step, q.Step, nodeID, from, to}
+	}
+
+	rows, err := s.queryAggs(q, build, len(fields))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MetricBucket, 0, len(rows))
+	for _, r := range rows {
+		b := MetricBucket{Timestamp: r.ts, Count: r.count, Values: make(map[string]Aggregate, len(fields))}
+		for i, f := range fields {
+			b.Values[f.Name] = Aggregate{Min: r.min[i], Avg: r.sum[i] / float64(r.count), Max: r.max[i]}
+		}
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+func (s *SqliteStore) QueryPingHistory(nodeID, target string, q RangeQuery) ([]PingBucket, error) {
+	if history, _ := s.hot.GetPingHistory(nodeID, target, 0); len(history) > 0 && hotCovers(history[0].Timestamp, q.From) {
+		return downsamplePings(history, q), nil
+	}
+
+	// 三组 min/sum/max：RTT 的 min 与 max、avg_rtt 的和、loss 的和与最大值
+	build := func(t *sqliteTier, from, to int64) (string, []any) {
+		query := `
+			SELECT (timestamp / ?) * ? AS b, COUNT(*),
+				MIN(min_rtt), SUM(avg_rtt), MAX(max_rtt),
+				0, SUM(loss), MAX(loss)
+			FROM ping_results
+			WHERE node_id = ? AND target = ? AND timestamp BETWEEN ? AND ?
+			GROUP BY b
+		`
+		if t.isRollup() {
+			query = fmt.Sprintf(`
+				SELECT (bucket / ?) * ? AS b, SUM(count),
+					MIN(min_rtt), SUM(sum_avg_rtt), MAX(max_rtt),
+					0, SUM(sum_loss), MAX(max_loss)
+				FROM %s
+				WHERE node_id = ? AND target = ? AND bucket BETWEEN ? AND ?
+				GROUP BY b
+			`, t.pings)
+		}
+		return query, []any{q.Step, q.Step, nodeID, target, from, to}
+	}
+
+	rows, err := s.queryAggs(q, build, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]PingBucket, 0, len(rows))
+	for _, r := range rows {
+		n := float64(r.count)
+		result = append(result, PingBucket{
+			Timestamp: r.ts,
+			Count:     r.count,
+			MinRTT:    r.min[0],
+			AvgRTT:    r.sum[0] / n,
+			MaxRTT:    r.max[0],
+			Loss:      r.sum[1] / n,
+			MaxLoss:   r.max[1],
+		})
+	}
+	return result, nil
+}
+
+// cleanupRoutine 自动蒸发老旧纪元：每个层级按自己的保留期删除
+func (s *SqliteStore) cleanupRoutine() {
+	defer s.bg.Done()
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+		for _, t := range sqliteTiers {
+			days := s.retentionFor(t)
+			if days <= 0 {
+				continue
+			}
+			cutoff := time.Now().AddDate(0, 0, -days).UnixMilli()
+			timeCol := "timestamp"
+			if t.isRollup() {
+				timeCol = "bucket"
+			}
+
+			res, err := s.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s < ?`, t.metrics, timeCol), cutoff)
+			if err != nil {
+				log.Printf("[SQLite Store] Cleanup %s error: %v", t.name, err)
+				continue
+			}
+			if _, err := s.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s < ?`, t.pings, timeCol), cutoff); err != nil {
+				log.Printf("[SQLite Store] Ping cleanup %s error: %v", t.name, err)
+			}
+			if affected, _ := res.RowsAffected(); affected > 0 {
+				log.Printf("[SQLite Store] Cleaned up %d outdated %s rows (older than %d days)", affected, t.name, days)
+			}
+		}
+	}
+}
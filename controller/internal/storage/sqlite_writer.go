@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+// ErrIngestQueueFull 写入队列持续满载，超过等待时间仍无法入队
+var ErrIngestQueueFull = errors.New("sqlite ingest queue full")
+
+// ErrStoreClosed 存储已关闭，不再接收写入
+var ErrStoreClosed = errors.New("sqlite store closed")
+
+// SqliteWriterOptions 批量写入队列参数
+type SqliteWriterOptions struct {
+	QueueSize      int           // 待落盘上报的缓冲上限
+	BatchSize      int           // 单个事务最多包含的上报条数
+	FlushInterval  time.Duration // 不足一批时的最长等待时间
+	EnqueueTimeout time.Duration // 队列满时 Ingest 最长阻塞时间，借此对上报流施加背压
+}
+
+func (o *SqliteWriterOptions) applyDefaults() {
+	if o.QueueSize <= 0 {
+		o.QueueSize = 10000
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 500
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = time.Second
+	}
+	if o.EnqueueTimeout <= 0 {
+		o.EnqueueTimeout = 5 * time.Second
+	}
+}
+
+// IngestStats 写入管道的运行状态
+type IngestStats struct {
+	QueueDepth    int     `json:"queue_depth"`
+	QueueCapacity int     `json:"queue_capacity"`
+	Enqueued      uint64  `json:"enqueued"`
+	Rejected      uint64  `json:"rejected"` // 因队列满或已关闭被拒绝的上报
+	Written       uint64  `json:"written"`
+	Failed        uint64  `json:"failed"` // 所在事务提交失败而丢失的上报
+	Batches       uint64  `json:"batches"`
+	LastBatchSize int     `json:"last_batch_size"`
+	LastFlushMs   float64 `json:"last_flush_ms"` // 最近一次事务耗时
+	AvgFlushMs    float64 `json:"avg_flush_ms"`
+	MaxFlushMs    float64 `json:"max_flush_ms"`
+	LastLagMs     float64 `json:"last_lag_ms"` // 最近一批中最早入队的上报从入队到提交的耗时
+}
+
+// IngestStatsProvider 由带写入队列的后端实现，供 API 暴露队列深度与写入延迟
+type IngestStatsProvider interface {
+	IngestStats() IngestStats
+}
+
+// queuedReport 入队时记下接收时间，nodes.last_seen 以此为准而非落盘时间
+type queuedReport struct {
+	req        *pb.ReportRequest
+	receivedAt int64
+}
+
+// sqliteWriter 把上报攒成批，以单个事务 + 预编译语句落盘，避免每条上报一次 fsync
+type sqliteWriter struct {
+	db    *sql.DB
+	opts  SqliteWriterOptions
+	queue chan queuedReport
+
+	mu     sync.RWMutex // 保护 closed；Ingest 持读锁入队，Close 持写锁确保之后不再有人入队
+	closed bool
+	done   chan struct{}
+	syncCh chan chan struct{} // 请求把已入队的上报立即落盘
+
+	enqueued, rejected, written, failed, batches atomic.Uint64
+
+	statsMu       sync.Mutex
+	lastBatchSize int
+	lastFlush     time.Duration
+	totalFlush    time.Duration
+	maxFlush      time.Duration
+	lastLag       time.Duration
+}
+
+func newSqliteWriter(db *sql.DB, opts SqliteWriterOptions) *sqliteWriter {
+	opts.applyDefaults()
+	w := &sqliteWriter{
+		db:     db,
+		opts:   opts,
+		queue:  make(chan queuedReport, opts.QueueSize),
+		done:   make(chan struct{}),
+		syncCh: make(chan chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue 队列有空位时立即返回；满载时阻塞至多 EnqueueTimeout，让 gRPC 流自然放慢
+func (w *sqliteWriter) enqueue(req *pb.ReportRequest) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		w.rejected.Add(1)
+		return ErrStoreClosed
+	}
+
+	item := queuedReport{req: req, receivedAt: time.Now().UnixMilli()}
+	select {
+	case w.queue <- item:
+		w.enqueued.Add(1)
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(w.opts.EnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case w.queue <- item:
+		w.enqueued.Add(1)
+		return nil
+	case <-timer.C:
+		w.rejected.Add(1)
+		return ErrIngestQueueFull
+	}
+}
+
+// close 停止接收并把队列中剩余的上报全部落盘后返回
+func (w *sqliteWriter) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+}
+
+// sync 等待此前已入队的上报全部落盘，用于删除 / 合并节点前排空队列
+func (w *sqliteWriter) sync() {
+	ack := make(chan struct{})
+	select {
+	case w.syncCh <- ack:
+		<-ack
+	case <-w.done:
+	}
+}
+
+func (w *sqliteWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]queuedReport, 0, w.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		w.flush(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case item, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= w.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case ack := <-w.syncCh:
+			// 把队列里剩余的也取出来一起提交
+			for drained := false; !drained; {
+				select {
+				case item, ok := <-w.queue:
+					if !ok {
+						drained = true
+						break
+					}
+					batch = append(batch, item)
+				default:
+					drained = true
+				}
+			}
+			flush()
+			close(ack)
+		}
+	}
+}
+
+func (w *sqliteWriter) flush(batch []queuedReport) {
+	start := time.Now()
+	written, failed := len(batch), 0
+	if err := w.writeBatch(batch); err != nil {
+		// 整批回滚后逐条重写，只丢弃确实写不进去的那几条
+		log.Printf("[SQLite Store] Batch write of %d reports failed, retrying one by one: %v", len(batch), err)
+		written = 0
+		var lastErr error
+		for i := range batch {
+			if err := w.writeBatch(batch[i : i+1]); err != nil {
+				failed++
+				lastErr = err
+			} else {
+				written++
+			}
+		}
+		if failed > 0 {
+			log.Printf("[SQLite Store] Dropped %d of %d reports: %v", failed, len(batch), lastErr)
+		}
+	}
+	elapsed := time.Since(start)
+
+	w.batches.Add(1)
+	w.written.Add(uint64(written))
+	w.failed.Add(uint64(failed))
+
+	w.statsMu.Lock()
+	w.lastBatchSize = len(batch)
+	w.lastFlush = elapsed
+	w.totalFlush += elapsed
+	if elapsed > w.maxFlush {
+		w.maxFlush = elapsed
+	}
+	w.lastLag = time.Since(time.UnixMilli(batch[0].receivedAt))
+	w.statsMu.Unlock()
+}
+
+// writeBatch 整批上报共用一个事务与三条预编译语句
+func (w *sqliteWriter) writeBatch(batch []queuedReport) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 1. 更新 Nodes 库表状态 (采用 SQLite Upsert: INSERT ... ON CONFLICT)
+	nodeStmt, err := tx.Prepare(`
+		INSERT INTO nodes (node_id, last_seen, cpu_model)
+		VALUES (?, ?, ?)
+		ON CONFLICT(node_id) DO UPDATE SET last_seen=MAX(nodes.last_seen, excluded.last_seen),
+			cpu_model=COALESCE(NULLIF(excluded.cpu_model, ''), nodes.cpu_model);
+	`)
+	if err != nil {
+		return err
+	}
+	defer nodeStmt.Close()
+
+	// 2. 全量指标落点库表
+	metricStmt, err := tx.Prepare(`INSERT INTO metrics (node_id, timestamp, cpu_usage_perc, ` + metricColumns + `)
+		VALUES (?, ?, ?` + strings.Repeat(", ?", len(snapshotFields)) + `)`)
+	if err != nil {
+		return err
+	}
+	defer metricStmt.Close()
+
+	// 3. 逐目标的 Ping 结果
+	pingStmt, err := tx.Prepare(`
+		INSERT INTO ping_results (node_id, target, target_ip, target_port, target_type, timestamp, min_rtt, avg_rtt, max_rtt, loss,
+			jitter, reordered, duplicates, loss_forward, loss_reverse, mos)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer pingStmt.Close()
+
+	args := make([]any, 0, len(snapshotFields)+3)
+	for _, item := range batch {
+		req := item.req
+		if _, err := nodeStmt.Exec(req.NodeId, item.receivedAt, req.GetCpu().GetModelName()); err != nil {
+			return err
+		}
+
+		snap := NewMetricSnapshot(req)
+		perCore, _ := json.Marshal(snap.CPUUsagePerCore)
+		args = append(args[:0], req.NodeId, req.Timestamp, string(perCore))
+		for _, f := range snapshotFields {
+			args = append(args, f.ptr(&snap))
+		}
+		if _, err := metricStmt.Exec(args...); err != nil {
+			return err
+		}
+
+		for _, p := range NewPingSnapshots(req) {
+			if _, err := pingStmt.Exec(req.NodeId, p.Target, p.TargetIP, p.TargetPort, p.TargetType,
+				p.Timestamp, p.MinRTT, p.AvgRTT, p.MaxRTT, p.Loss,
+				p.Jitter, p.Reordered, p.Duplicates, p.LossForward, p.LossReverse, p.MOS); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (w *sqliteWriter) stats() IngestStats {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+
+	st := IngestStats{
+		QueueDepth:    len(w.queue),
+		QueueCapacity: cap(w.queue),
+		Enqueued:      w.enqueued.Load(),
+		Rejected:      w.rejected.Load(),
+		Written:       w.written.Load(),
+		Failed:        w.failed.Load(),
+		Batches:       w.batches.Load(),
+		LastBatchSize: w.lastBatchSize,
+		LastFlushMs:   durationMs(w.lastFlush),
+		MaxFlushMs:    durationMs(w.maxFlush),
+		LastLagMs:     durationMs(w.lastLag),
+	}
+	if st.Batches > 0 {
+		st.AvgFlushMs = durationMs(w.totalFlush) / float64(st.Batches)
+	}
+	return st
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -6,18 +6,78 @@ import (
 
 // MetricSnapshot 这是通用吐出给 API/外部图表的时序折线扁平结构
 // (这里为了兼容将 MemoryCache 中的同名声明抽出转移至此)
+// 覆盖 ReportRequest 中的全部数值字段；逐目标的 Ping 结果另见 PingSnapshot
 type MetricSnapshot struct {
 	Timestamp  int64   `json:"timestamp"`
 	CPULoad1   float64 `json:"cpu_load1"`
 	MemUsed    float64 `json:"mem_used_percent"`
 	NetBurst   uint64  `json:"net_burst"`
-	PingAvgRTT float64 `json:"ping_avg_rtt"`
+	PingAvgRTT float64 `json:"ping_avg_rtt"` // 第一个探测目标的平均延迟，兼容旧版大屏
+
+	// CPU
+	CPULoad5        float64   `json:"cpu_load5"`
+	CPULoad15       float64   `json:"cpu_load15"`
+	CPUUsage        float64   `json:"cpu_usage_percent"` // 各核使用率均值
+	CPUUsagePerCore []float64 `json:"cpu_usage_perc,omitempty"`
+	CPUCores        float64   `json:"cpu_cores"`
+	CPUMhz          float64   `json:"cpu_mhz"`
+
+	// 内存 / Swap
+	MemTotal     float64 `json:"mem_total_bytes"`
+	MemAvailable float64 `json:"mem_available_bytes"`
+	MemUsedBytes float64 `json:"mem_used_bytes"`
+	SwapTotal    float64 `json:"swap_total_bytes"`
+	SwapFree     float64 `json:"swap_free_bytes"`
+
+	// 磁盘 (累计计数)
+	DiskReadBytes  float64 `json:"disk_read_bytes"`
+	DiskWriteBytes float64 `json:"disk_write_bytes"`
+	DiskReadCount  float64 `json:"disk_read_count"`
+	DiskWriteCount float64 `json:"disk_write_count"`
+	DiskIopsInProg float64 `json:"disk_iops_in_progress"`
+
+	// 网络 (累计计数 + 突发特征)
+	NetBytesRecv   float64 `json:"net_bytes_recv"`
+	NetBytesSent   float64 `json:"net_bytes_sent"`
+	NetPacketsRecv float64 `json:"net_packets_recv"`
+	NetPacketsSent float64 `json:"net_packets_sent"`
+	NetBurstP95    float64 `json:"net_burst_p95_rate"`
+
+	// KVM
+	KVMTotalVMs  float64 `json:"kvm_total_vms"`
+	KVMActiveVMs float64 `json:"kvm_active_vms"`
+	KVMAllocVcpu float64 `json:"kvm_alloc_vcpu"`
+	KVMAllocMem  float64 `json:"kvm_alloc_mem_bytes"`
+}
+
+// PingSnapshot 单个探测目标在某一时刻的测算结果
+type PingSnapshot struct {
+	Timestamp  int64   `json:"timestamp"`
+	Target     string  `json:"target"` // ip:port，见 PingTargetKey
+	TargetIP   string  `json:"target_ip"`
+	TargetPort int32   `json:"target_port"`
+	TargetType string  `json:"target_type"`
+	MinRTT     float64 `json:"min_rtt_ms"`
+	AvgRTT     float64 `json:"avg_rtt_ms"`
+	MaxRTT     float64 `json:"max_rtt_ms"`
+	Loss       float64 `json:"loss"` // 0.0 - 1.0
+
+	// 以下仅 udp 探测有值
+	Jitter      float64 `json:"jitter_ms,omitempty"`
+	Reordered   uint32  `json:"reordered,omitempty"`
+	Duplicates  uint32  `json:"duplicates,omitempty"`
+	LossForward float64 `json:"loss_forward,omitempty"`
+	LossReverse float64 `json:"loss_reverse,omitempty"`
+	MOS         float64 `json:"mos,omitempty"`
 }
 
 type NodeStatus struct {
 	NodeID      string           `json:"node_id"`
 	LastSeen    int64            `json:"last_seen"` // Unix milli
 	IsOnline    bool             `json:"is_online"`
+	State       string           `json:"state,omitempty"`     // 启用节点状态跟踪时由 API 层填充，见 nodestate
+	Lifecycle   string           `json:"lifecycle,omitempty"` // 启用生命周期管理时由 API 层填充，见 lifecycle
+	CPUModel    string           `json:"cpu_model,omitempty"`
 	HistoryFlow []MetricSnapshot `json:"history"` // 图表缓冲数据
 }
 
@@ -31,4 +91,20 @@ type Persister interface {
 
 	// API 层获取指定节点最近 N 个时间切片用于画图
 	GetNodeHistory(nodeID string, limit int) ([]MetricSnapshot, error)
+
+	// API 层列出节点的全部探测目标及各自最新一次结果
+	GetPingTargets(nodeID string) ([]PingSnapshot, error)
+
+	// API 层获取指定节点对某个目标 (ip:port) 最近 N 次测算结果
+	GetPingHistory(nodeID, target string, limit int) ([]PingSnapshot, error)
+
+	// 按时间范围分桶聚合，q 需先经 Normalize
+	QueryNodeHistory(nodeID string, q RangeQuery) ([]MetricBucket, error)
+	QueryPingHistory(nodeID, target string, q RangeQuery) ([]PingBucket, error)
+}
+
+// Sink 只写出口（如远端时序库、remote_write 接收端），上报到达后推送一份，不参与 API 读取
+// 实现方需自行缓冲，Ingest 不应阻塞 gRPC 接收流
+type Sink interface {
+	Ingest(req *pb.ReportRequest) error
 }
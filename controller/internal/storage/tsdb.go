@@ -0,0 +1,521 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	"github.com/geelinx-ltd/geegee/controller/internal/tsdb"
+)
+
+// TsdbOptions 内置时序引擎参数
+type TsdbOptions struct {
+	Path            string
+	Retention       time.Duration // <= 0 永久保留
+	BlockDuration   time.Duration
+	CompactDuration time.Duration
+}
+
+// 序列键：<类型>\x1f<node_id>\x1f<...>，分隔符不会出现在节点名与目标地址中
+const keySep = "\x1f"
+
+func metricKey(nodeID, field string) string { return "m" + keySep + nodeID + keySep + field }
+func coreKey(nodeID string, core int) string {
+	return "c" + keySep + nodeID + keySep + strconv.Itoa(core)
+}
+func pingKey(nodeID, target, stat string) string {
+	return "p" + keySep + nodeID + keySep + target + keySep + stat
+}
+
+// tsdbNodeMeta 不适合放进时序引擎的节点属性，单独存为 nodes.json
+type tsdbNodeMeta struct {
+	LastSeen int64                     `json:"last_seen"`
+	CPUModel string                    `json:"cpu_model,omitempty"`
+	Targets  map[string]tsdbPingTarget `json:"targets,omitempty"`
+}
+
+type tsdbPingTarget struct {
+	IP   string `json:"ip"`
+	Port int32  `json:"port"`
+	Type string `json:"type"`
+}
+
+// TsdbStore 基于内置压缩时序引擎的 Persister 实现
+type TsdbStore struct {
+	db          *tsdb.DB
+	metaPath    string
+	deletedPath string
+	keepDeleted time.Duration // 删除记录的保留时长，<= 0 永久保留
+
+	// saveMu 串行化 nodes.json 与 deleted.json 的写入 (定期保存与删除节点共用临时文件)
+	saveMu sync.Mutex
+
+	mu      sync.RWMutex
+	nodes   map[string]*tsdbNodeMeta
+	deleted map[string]int64 // 已删除节点 -> 删除时间，之前的样本在读取时过滤，由保留期自然清除
+	dirty   bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewTsdbStore(opts TsdbOptions) (*TsdbStore, error) {
+	if err := os.MkdirAll(opts.Path, 0o755); err != nil {
+		return nil, err
+	}
+	db, err := tsdb.Open(opts.Path, tsdb.Options{
+		BlockDuration:   opts.BlockDuration,
+		CompactDuration: opts.CompactDuration,
+		Retention:       opts.Retention,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open tsdb: %w", err)
+	}
+
+	s := &TsdbStore{
+		db:          db,
+		metaPath:    filepath.Join(opts.Path, "nodes.json"),
+		deletedPath: filepath.Join(opts.Path, "deleted.json"),
+		keepDeleted: deletedRetention(opts),
+		nodes:       make(map[string]*tsdbNodeMeta),
+		deleted:     make(map[string]int64),
+		stop:        make(chan struct{}),
+	}
+	if data, err := os.ReadFile(s.metaPath); err == nil {
+		if err := json.Unmarshal(data, &s.nodes); err != nil {
+			log.Printf("[TSDB Store] Ignoring corrupted %s: %v", s.metaPath, err)
+		}
+	}
+	if data, err := os.ReadFile(s.deletedPath); err == nil {
+		if err := json.Unmarshal(data, &s.deleted); err != nil {
+			return nil, fmt.Errorf("read %s: %w", s.deletedPath, err)
+		}
+	}
+
+	st := db.Stats()
+	log.Printf("[TSDB Store] Opened %s: %d blocks (%d bytes), %d head samples, %d nodes",
+		opts.Path, st.Blocks, st.BlockBytes, st.HeadSamples, len(s.nodes))
+
+	s.wg.Add(1)
+	go s.metaRoutine()
+	return s, nil
+}
+
+func (s *TsdbStore) Ingest(req *pb.ReportRequest) error {
+	snap := NewMetricSnapshot(req)
+	pings := NewPingSnapshots(req)
+
+	points := make([]tsdb.Point, 0, len(snapshotFields)+len(snap.CPUUsagePerCore)+len(pings)*4)
+	for _, f := range snapshotFields {
+		points = append(points, tsdb.Point{Key: metricKey(req.NodeId, f.Name), T: req.Timestamp, V: fieldValue(f.ptr(&snap))})
+	}
+	for i, u := range snap.CPUUsagePerCore {
+		points = append(points, tsdb.Point{Key: coreKey(req.NodeId, i), T: req.Timestamp, V: u})
+	}
+	for _, p := range pings {
+		points = append(points,
+			tsdb.Point{Key: pingKey(req.NodeId, p.Target, "min"), T: p.Timestamp, V: p.MinRTT},
+			tsdb.Point{Key: pingKey(req.NodeId, p.Target, "avg"), T: p.Timestamp, V: p.AvgRTT},
+			tsdb.Point{Key: pingKey(req.NodeId, p.Target, "max"), T: p.Timestamp, V: p.MaxRTT},
+			tsdb.Point{Key: pingKey(req.NodeId, p.Target, "loss"), T: p.Timestamp, V: p.Loss},
+		)
+		if p.TargetType == "udp" {
+			for stat, get := range udpPingStats {
+				points = append(points, tsdb.Point{Key: pingKey(req.NodeId, p.Target, stat), T: p.Timestamp, V: get(&p)})
+			}
+		}
+	}
+
+	s.mu.Lock()
+	meta, ok := s.nodes[req.NodeId]
+	if !ok {
+		meta = &tsdbNodeMeta{Targets: make(map[string]tsdbPingTarget)}
+		s.nodes[req.NodeId] = meta
+	}
+	meta.LastSeen = time.Now().UnixMilli()
+	if model := req.GetCpu().GetModelName(); model != "" {
+		meta.CPUModel = model
+	}
+	for _, p := range pings {
+		if meta.Targets == nil {
+			meta.Targets = make(map[string]tsdbPingTarget)
+		}
+		meta.Targets[p.Target] = tsdbPingTarget{IP: p.TargetIP, Port: p.TargetPort, Type: p.TargetType}
+	}
+	s.dirty = true
+	s.mu.Unlock()
+
+	return s.db.Append(points)
+}
+
+func (s *TsdbStore) GetNodes() ([]NodeStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UnixMilli()
+	list := make([]NodeStatus, 0, len(s.nodes))
+	for id, meta := range s.nodes {
+		list = append(list, NodeStatus{
+			NodeID:   id,
+			LastSeen: meta.LastSeen,
+			IsOnline: (now - meta.LastSeen) < OnlineTimeoutMs,
+			CPUModel: meta.CPUModel,
+		})
+	}
+	return list, nil
+}
+
+// GetNodeHistory 以 cpu_load1 的最近 limit 个点确定时间窗，再取出该窗口内的全部序列
+func (s *TsdbStore) GetNodeHistory(nodeID string, limit int) ([]MetricSnapshot, error) {
+	anchor, err := s.db.Last(metricKey(nodeID, snapshotFields[0].Name), limit)
+	if err != nil || len(anchor) == 0 {
+		return nil, err
+	}
+	return s.loadSnapshots(nodeID, anchor[0].T, anchor[len(anchor)-1].T, snapshotFields, true)
+}
+
+func (s *TsdbStore) QueryNodeHistory(nodeID string, q RangeQuery) ([]MetricBucket, error) {
+	fields, err := q.selectedFields()
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := s.loadSnapshots(nodeID, q.From, q.To, fields, false)
+	if err != nil {
+		return nil, err
+	}
+	return downsampleMetrics(snaps, q), nil
+}
+
+// loadSnapshots 按时间戳把各序列拼回 MetricSnapshot，时间轴取第一个字段
+func (s *TsdbStore) loadSnapshots(nodeID string, mint, maxt int64, fields []snapshotField, perCore bool) ([]MetricSnapshot, error) {
+	mint = s.visibleFrom(nodeID, mint)
+	var (
+		snaps []MetricSnapshot
+		index map[int64]int
+	)
+	for i, f := range fields {
+		samples, err := s.db.Select(metricKey(nodeID, f.Name), mint, maxt)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			snaps = make([]MetricSnapshot, len(samples))
+			index = make(map[int64]int, len(samples))
+			for j, smp := range samples {
+				snaps[j].Timestamp = smp.T
+				index[smp.T] = j
+			}
+		}
+		for _, smp := range samples {
+			if j, ok := index[smp.T]; ok {
+				setField(f.ptr(&snaps[j]), smp.V)
+			}
+		}
+	}
+
+	if perCore && len(snaps) > 0 {
+		prefix := "c" + keySep + nodeID + keySep
+		keys := s.db.Keys(prefix)
+		cores := make([]int, 0, len(keys))
+		for _, k := range keys {
+			if n, err := strconv.Atoi(strings.TrimPrefix(k, prefix)); err == nil {
+				cores = append(cores, n)
+			}
+		}
+		sort.Ints(cores)
+		for _, core := range cores {
+			samples, err := s.db.Select(coreKey(nodeID, core), mint, maxt)
+			if err != nil {
+				return nil, err
+			}
+			for _, smp := range samples {
+				j, ok := index[smp.T]
+				if !ok {
+					continue
+				}
+				for len(snaps[j].CPUUsagePerCore) <= core {
+					snaps[j].CPUUsagePerCore = append(snaps[j].CPUUsagePerCore, 0)
+				}
+				snaps[j].CPUUsagePerCore[core] = smp.V
+			}
+		}
+	}
+	return snaps, nil
+}
+
+func setField(p any, v float64) {
+	switch ptr := p.(type) {
+	case *float64:
+		*ptr = v
+	case *uint64:
+		*ptr = uint64(v)
+	}
+}
+
+func (s *TsdbStore) GetPingTargets(nodeID string) ([]PingSnapshot, error) {
+	s.mu.RLock()
+	meta := s.nodes[nodeID]
+	targets := make(map[string]tsdbPingTarget)
+	if meta != nil {
+		for k, v := range meta.Targets {
+			targets[k] = v
+		}
+	}
+	s.mu.RUnlock()
+
+	list := make([]PingSnapshot, 0, len(targets))
+	for target := range targets {
+		history, err := s.GetPingHistory(nodeID, target, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(history) > 0 {
+			list = append(list, history[0])
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Target < list[j].Target })
+	return list, nil
+}
+
+func (s *TsdbStore) GetPingHistory(nodeID, target string, limit int) ([]PingSnapshot, error) {
+	anchor, err := s.db.Last(pingKey(nodeID, target, "avg"), limit)
+	if err != nil || len(anchor) == 0 {
+		return nil, err
+	}
+	return s.loadPings(nodeID, target, anchor[0].T, anchor[len(anchor)-1].T)
+}
+
+func (s *TsdbStore) QueryPingHistory(nodeID, target string, q RangeQuery) ([]PingBucket, error) {
+	pings, err := s.loadPings(nodeID, target, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	return downsamplePings(pings, q), nil
+}
+
+func (s *TsdbStore) loadPings(nodeID, target string, mint, maxt int64) ([]PingSnapshot, error) {
+	s.mu.RLock()
+	var info tsdbPingTarget
+	if meta := s.nodes[nodeID]; meta != nil {
+		info = meta.Targets[target]
+	}
+	s.mu.RUnlock()
+	mint = s.visibleFrom(nodeID, mint)
+
+	avg, err := s.db.Select(pingKey(nodeID, target, "avg"), mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]PingSnapshot, len(avg))
+	index := make(map[int64]int, len(avg))
+	for i, smp := range avg {
+		list[i] = PingSnapshot{
+			Timestamp:  smp.T,
+			Target:     target,
+			TargetIP:   info.IP,
+			TargetPort: info.Port,
+			TargetType: info.Type,
+			AvgRTT:     smp.V,
+		}
+		index[smp.T] = i
+	}
+
+	stats := map[string]func(*PingSnapshot, float64){
+		"min":  func(p *PingSnapshot, v float64) { p.MinRTT = v },
+		"max":  func(p *PingSnapshot, v float64) { p.MaxRTT = v },
+		"loss": func(p *PingSnapshot, v float64) { p.Loss = v },
+	}
+	if info.Type == "udp" {
+		for stat, set := range udpPingSetters {
+			stats[stat] = set
+		}
+	}
+	for stat, set := range stats {
+		samples, err := s.db.Select(pingKey(nodeID, target, stat), mint, maxt)
+		if err != nil {
+			return nil, err
+		}
+		for _, smp := range samples {
+			if i, ok := index[smp.T]; ok {
+				set(&list[i], smp.V)
+			}
+		}
+	}
+	return list, nil
+}
+
+// udp 探测额外的逐目标序列，只为 udp 目标写入与读取
+var (
+	udpPingStats = map[string]func(*PingSnapshot) float64{
+		"jitter":     func(p *PingSnapshot) float64 { return p.Jitter },
+		"reordered":  func(p *PingSnapshot) float64 { return float64(p.Reordered) },
+		"duplicates": func(p *PingSnapshot) float64 { return float64(p.Duplicates) },
+		"loss_fwd":   func(p *PingSnapshot) float64 { return p.LossForward },
+		"loss_rev":   func(p *PingSnapshot) float64 { return p.LossReverse },
+		"mos":        func(p *PingSnapshot) float64 { return p.MOS },
+	}
+	udpPingSetters = map[string]func(*PingSnapshot, float64){
+		"jitter":     func(p *PingSnapshot, v float64) { p.Jitter = v },
+		"reordered":  func(p *PingSnapshot, v float64) { p.Reordered = uint32(v) },
+		"duplicates": func(p *PingSnapshot, v float64) { p.Duplicates = uint32(v) },
+		"loss_fwd":   func(p *PingSnapshot, v float64) { p.LossForward = v },
+		"loss_rev":   func(p *PingSnapshot, v float64) { p.LossReverse = v },
+		"mos":        func(p *PingSnapshot, v float64) { p.MOS = v },
+	}
+)
+
+// visibleFrom 节点被删除过时，查询起点不早于删除时间
+func (s *TsdbStore) visibleFrom(nodeID string, mint int64) int64 {
+	s.mu.RLock()
+	at, ok := s.deleted[nodeID]
+	s.mu.RUnlock()
+	if ok && mint <= at {
+		return at + 1
+	}
+	return mint
+}
+
+// deletedRetention 删除时间之前的样本最晚落在跨度为 CompactDuration 的合并块中，
+// 该块整块超出保留期后删除记录才可丢弃，否则旧样本会重新可见
+func deletedRetention(opts TsdbOptions) time.Duration {
+	if opts.Retention <= 0 {
+		return 0
+	}
+	return opts.Retention + max(opts.CompactDuration, 24*time.Hour)
+}
+
+// DeleteNode 时序块不可改写，记下删除时间，此前的样本不再可见，待保留期到期后随块一起清除
+func (s *TsdbStore) DeleteNode(nodeID string) error {
+	s.mu.Lock()
+	delete(s.nodes, nodeID)
+	s.deleted[nodeID] = time.Now().UnixMilli()
+	s.dirty = true
+	s.mu.Unlock()
+
+	if err := s.saveDeleted(); err != nil {
+		return err
+	}
+	return s.saveMeta()
+}
+
+// saveDeleted 清理已超出保留期的删除记录并写回 deleted.json
+func (s *TsdbStore) saveDeleted() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if s.keepDeleted > 0 {
+		cutoff := time.Now().Add(-s.keepDeleted).UnixMilli()
+		for id, at := range s.deleted {
+			if at < cutoff {
+				delete(s.deleted, id)
+			}
+		}
+	}
+	data, err := json.Marshal(s.deleted)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.deletedPath, data)
+}
+
+// MergeNode 内置时序引擎不支持改写历史序列
+func (s *TsdbStore) MergeNode(from, into string) error {
+	return ErrMergeUnsupported
+}
+
+// metaRoutine 定期把节点属性写回 nodes.json
+func (s *TsdbStore) metaRoutine() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.saveMeta(); err != nil {
+				log.Printf("[TSDB Store] Save node meta error: %v", err)
+			}
+			if s.expiredDeletes() {
+				if err := s.saveDeleted(); err != nil {
+					log.Printf("[TSDB Store] Save deleted nodes error: %v", err)
+				}
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// expiredDeletes 是否有可以丢弃的删除记录
+func (s *TsdbStore) expiredDeletes() bool {
+	if s.keepDeleted <= 0 {
+		return false
+	}
+	cutoff := time.Now().Add(-s.keepDeleted).UnixMilli()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, at := range s.deleted {
+		if at < cutoff {
+			return true
+		}
+	}
+	return false
+}
+
+// saveMeta 写回 nodes.json。写入失败时重新标记 dirty，下个周期重试
+func (s *TsdbStore) saveMeta() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.nodes)
+	// 先清除标记：写文件期间的新上报会重新置位，不会被随后的清除吞掉
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(s.metaPath, data)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Stats 引擎块与 head 概况
+func (s *TsdbStore) Stats() tsdb.Stats {
+	return s.db.Stats()
+}
+
+// Close 保存节点属性并关闭引擎，head 中的数据由 WAL 在下次启动时回放
+func (s *TsdbStore) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	if err := s.saveMeta(); err != nil {
+		log.Printf("[TSDB Store] Save node meta error: %v", err)
+	}
+	return s.db.Close()
+}
+
+// writeFileAtomic 先写临时文件再改名，避免进程中断留下半截文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -1,33 +1,206 @@
 package storage
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	pb "github.com/geelinx-ltd/geegee/api/proto"
 )
 
-// TSDB 封装对时序存储库接口。当前我们仅保留打桩与结构，以便日后轻松填入 HTTP Client。
-type TSDB struct {
-	addr string
+// VictoriaOptions VictoriaMetrics 文本导入端点 (/api/v1/import/prometheus)
+type VictoriaOptions struct {
+	URL           string
+	QueueSize     int           // 待发送行数上限，满了直接丢弃
+	BatchSize     int           // 单次 POST 最多携带的行数
+	BatchInterval time.Duration // 不足一批时的最长等待时间
+	Timeout       time.Duration
+	MaxRetries    int
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
 }
 
-func NewTSDB(addr string) *TSDB {
-	return &TSDB{
-		addr: addr,
+func (o *VictoriaOptions) applyDefaults() {
+	if o.QueueSize <= 0 {
+		o.QueueSize = 50000
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 5000
+	}
+	if o.BatchInterval <= 0 {
+		o.BatchInterval = 5 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = 10 * time.Second
 	}
 }
 
-// Ingest 用于将探针产生的 protobuf struct 转化为 Prometheus Text 协议存起留用
-func (t *TSDB) Ingest(req *pb.ReportRequest) error {
-	// 组装格式样例：
-	// metric_name{label1="val1", label2="val2"} value timestamp
-	// 例如：node_cpu_usage{node="test-node"} 42.1 1680000000000
+// VictoriaWriter 将上报展开为 Prometheus 文本行 (带毫秒时间戳) 后批量 gzip 推送
+type VictoriaWriter struct {
+	opts   VictoriaOptions
+	sender *httpSender
+	queue  chan string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 
-	// 在没有实际部署 VictoriaMetrics 环境下，我们此处将接收到的结构抽象化，并不真实发包
-	metricCount := 1 + 1 + len(req.PingResults) + 1 // CPU + MEM + PING*n + DISK 粗估项
-	log.Printf("[TSDB Ingestion Stub] Prepared %d PromText lines for node [%s] to write locally/remotely.", metricCount, req.NodeId)
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+func NewVictoriaWriter(opts VictoriaOptions) (*VictoriaWriter, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("victoria url is empty")
+	}
+	opts.applyDefaults()
 
-	// TODO: 使用 strings.Builder 拼接文本并使用 http.Post 发布到 t.addr (通常是 http://VM_IP:8428/api/v1/import/prometheus)
+	sender := &httpSender{
+		url:        opts.URL,
+		headers:    map[string]string{"Content-Encoding": "gzip", "Content-Type": "text/plain"},
+		client:     &http.Client{Timeout: opts.Timeout},
+		maxRetries: opts.MaxRetries,
+		minBackoff: opts.MinBackoff,
+		maxBackoff: opts.MaxBackoff,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &VictoriaWriter{
+		opts:   opts,
+		sender: sender,
+		queue:  make(chan string, opts.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// Ingest 非阻塞入队，远端不可用时只会丢弃自己的数据而不拖慢上报流
+func (w *VictoriaWriter) Ingest(req *pb.ReportRequest) error {
+	var dropped int
+	for _, s := range FlattenReport(req) {
+		select {
+		case w.queue <- formatImportLine(req.NodeId, s, req.Timestamp):
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		w.dropped.Add(uint64(dropped))
+		return fmt.Errorf("victoria queue full, dropped %d series of node [%s]", dropped, req.NodeId)
+	}
+	return nil
+}
 
+// Close 停止接收并将队列中剩余的数据尽量发送完毕，最多等待 closeDrainTimeout
+func (w *VictoriaWriter) Close() error {
+	close(w.queue)
+	if !drain(&w.wg, w.cancel, closeDrainTimeout) {
+		log.Printf("[Victoria] Queue not drained within %v, remaining series dropped", closeDrainTimeout)
+	}
+	log.Printf("[Victoria] Closed. sent=%d dropped=%d failed=%d", w.sent.Load(), w.dropped.Load(), w.failed.Load())
 	return nil
 }
+
+// formatImportLine 生成 geegee_xxx{node_id="..",k="v"} value timestamp_ms
+func formatImportLine(nodeID string, s Sample, ts int64) string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString("geegee_")
+	sb.WriteString(s.Name)
+	sb.WriteString(`{node_id="`)
+	sb.WriteString(importEscaper.Replace(nodeID))
+	sb.WriteByte('"')
+	for _, k := range keys {
+		sb.WriteByte(',')
+		sb.WriteString(k)
+		sb.WriteString(`="`)
+		sb.WriteString(importEscaper.Replace(s.Labels[k]))
+		sb.WriteByte('"')
+	}
+	sb.WriteString("} ")
+	sb.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.FormatInt(ts, 10))
+	return sb.String()
+}
+
+var importEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (w *VictoriaWriter) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.opts.BatchInterval)
+	defer ticker.Stop()
+
+	batch := make([]string, 0, w.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		w.sendBatch(batch)
+		batch = make([]string, 0, w.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case line, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) >= w.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// sendBatch gzip 压缩后发送，重试策略与 remote_write 相同 (见 httpSender)
+func (w *VictoriaWriter) sendBatch(batch []string) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, line := range batch {
+		zw.Write([]byte(line))
+		zw.Write([]byte{'\n'})
+	}
+	zw.Close()
+
+	if err := w.sender.send(w.ctx, buf.Bytes()); err != nil {
+		w.failed.Add(uint64(len(batch)))
+		if w.ctx.Err() == nil { // Close 等待超时后剩余的批次不再逐条记录
+			log.Printf("[Victoria] dropping %d series: %v", len(batch), err)
+		}
+		return
+	}
+	w.sent.Add(uint64(len(batch)))
+}
@@ -0,0 +1,544 @@
+// Package tasks 把一次性诊断任务 (ping / traceroute / HTTP 检查等) 经上报流推给指定节点并收集结果。
+// 每个节点同时执行的任务数有上限，超出的排队，队列满时拒绝；结果只保存在内存中
+package tasks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	"github.com/geelinx-ltd/geegee/controller/internal/storage"
+)
+
+var (
+	// ErrNotFound 任务不存在或已被清理
+	ErrNotFound = errors.New("task not found")
+	// ErrInvalid 任务参数不合法
+	ErrInvalid = errors.New("invalid task")
+	// ErrNotConnected 节点当前没有上报流，任务无法下发
+	ErrNotConnected = errors.New("node is not connected")
+	// ErrBusy 节点的任务队列已满
+	ErrBusy = errors.New("too many tasks queued for node")
+)
+
+// 任务类型
+const (
+	TypeTCPPing    = "tcpping"
+	TypeUDP        = "udp"
+	TypePing       = "ping"
+	TypeTraceroute = "traceroute"
+	TypeHTTP       = "http"
+	// 吞吐测试的两端，由 throughput 包成对提交
+	TypeThroughputServer = "throughput_server"
+	TypeThroughputClient = "throughput_client"
+)
+
+// maxCount 各类型允许的最大探测次数 / 跳数
+var maxCount = map[string]int{
+	TypeTCPPing:    100,
+	TypeUDP:        1000,
+	TypePing:       100,
+	TypeTraceroute: 64,
+	TypeHTTP:       0,
+
+	TypeThroughputServer: 0,
+	TypeThroughputClient: 0,
+}
+
+// State 任务状态
+type State string
+
+const (
+	StatePending State = "pending" // 排队等待节点空出执行槽
+	StateRunning State = "running" // 已下发，等待结果
+	StateDone    State = "done"
+	StateFailed  State = "failed"  // 节点报告执行失败，或下发前节点断开
+	StateTimeout State = "timeout" // 超过时限仍未收到结果
+)
+
+// Finished 任务已结束
+func (s State) Finished() bool {
+	return s == StateDone || s == StateFailed || s == StateTimeout
+}
+
+// Spec 提交任务的参数
+type Spec struct {
+	NodeID  string        `json:"node_id"`
+	Type    string        `json:"type"`
+	Target  string        `json:"target"`
+	Count   int           `json:"count,omitempty"`
+	Timeout time.Duration `json:"-"`
+	By      string        `json:"by,omitempty"`
+
+	Throughput *ThroughputParams `json:"-"` // 仅吞吐测试
+}
+
+// ThroughputParams 吞吐测试参数，两端相同
+type ThroughputParams struct {
+	Protocol   string `json:"protocol"`
+	DurationMs int64  `json:"duration_ms"`
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+	RateBps    int64  `json:"rate_bps,omitempty"`
+	Token      string `json:"-"`
+}
+
+// ThroughputStats 吞吐测试一端的统计
+type ThroughputStats struct {
+	Bytes           int64   `json:"bytes"`
+	DurationMs      int64   `json:"duration_ms"`
+	BitsPerSecond   float64 `json:"bits_per_second"`
+	Retransmits     uint32  `json:"retransmits,omitempty"`
+	PacketsSent     uint64  `json:"packets_sent,omitempty"`
+	PacketsReceived uint64  `json:"packets_received,omitempty"`
+	JitterMs        float64 `json:"jitter_ms,omitempty"`
+}
+
+// Hop traceroute 的一跳，Addr 为空表示超时
+type Hop struct {
+	TTL     int     `json:"ttl"`
+	Addr    string  `json:"addr,omitempty"`
+	RTT     float64 `json:"rtt_ms,omitempty"`
+	Reached bool    `json:"reached,omitempty"`
+}
+
+// HTTPCheck HTTP 检查的状态码与各阶段耗时
+type HTTPCheck struct {
+	StatusCode    int     `json:"status_code"`
+	RemoteAddr    string  `json:"remote_addr,omitempty"`
+	DNS           float64 `json:"dns_ms"`
+	Connect       float64 `json:"connect_ms"`
+	TLS           float64 `json:"tls_ms"`
+	TTFB          float64 `json:"ttfb_ms"`
+	Total         float64 `json:"total_ms"`
+	BodyBytes     int64   `json:"body_bytes"`
+	CertExpiresAt int64   `json:"cert_expires_at,omitempty"`
+}
+
+// Result 节点回传的结果，按任务类型填充其一
+type Result struct {
+	StartedAt  int64                 `json:"started_at"`
+	FinishedAt int64                 `json:"finished_at"`
+	Ping       *storage.PingSnapshot `json:"ping,omitempty"`
+	Hops       []Hop                 `json:"hops,omitempty"`
+	HTTP       *HTTPCheck            `json:"http,omitempty"`
+	Throughput *ThroughputStats      `json:"throughput,omitempty"`
+}
+
+// Task 一个诊断任务及其结果
+type Task struct {
+	ID         string  `json:"id"`
+	NodeID     string  `json:"node_id"`
+	Type       string  `json:"type"`
+	Target     string  `json:"target"`
+	Count      int     `json:"count,omitempty"`
+	TimeoutMs  int64   `json:"timeout_ms"`
+	By         string  `json:"by,omitempty"`
+	State      State   `json:"state"`
+	Error      string  `json:"error,omitempty"`
+	CreatedAt  int64   `json:"created_at"`
+	SentAt     int64   `json:"sent_at,omitempty"`
+	FinishedAt int64   `json:"finished_at,omitempty"`
+	Result     *Result `json:"result,omitempty"`
+
+	Throughput *ThroughputParams `json:"throughput,omitempty"`
+
+	done chan struct{}
+}
+
+// Options 任务调度参数
+type Options struct {
+	PerNode        int           // 每个节点同时执行的任务数
+	QueuePerNode   int           // 每个节点排队的任务数上限
+	DefaultTimeout time.Duration // 未指定时限时使用
+	MaxTimeout     time.Duration // 单个任务的时限上限
+	Grace          time.Duration // 时限之外等待结果回传的余量
+	HistoryLimit   int           // 内存中保留的已结束任务数
+}
+
+func (o *Options) applyDefaults() {
+	if o.PerNode <= 0 {
+		o.PerNode = 2
+	}
+	if o.QueuePerNode <= 0 {
+		o.QueuePerNode = 10
+	}
+	if o.DefaultTimeout <= 0 {
+		o.DefaultTimeout = 15 * time.Second
+	}
+	if o.MaxTimeout <= 0 {
+		o.MaxTimeout = 2 * time.Minute
+	}
+	if o.Grace <= 0 {
+		o.Grace = 10 * time.Second
+	}
+	if o.HistoryLimit <= 0 {
+		o.HistoryLimit = 500
+	}
+}
+
+// nodeQueue 一个在线节点的下发通道与排队中的任务
+type nodeQueue struct {
+	ch      chan *pb.Task
+	pending []*Task
+	running int
+}
+
+// Manager 实现 server.TaskBroker，节点上报流建立时接入，断开时其未完成的任务判为失败
+type Manager struct {
+	opts Options
+
+	mu    sync.Mutex
+	tasks map[string]*Task
+	order []*Task // 按创建时间排列
+	nodes map[string]*nodeQueue
+}
+
+func NewManager(opts Options) *Manager {
+	opts.applyDefaults()
+	return &Manager{
+		opts:  opts,
+		tasks: make(map[string]*Task),
+		nodes: make(map[string]*nodeQueue),
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validate 检查目标格式并补齐时限
+func (m *Manager) validate(s *Spec) error {
+	s.NodeID, s.Target = strings.TrimSpace(s.NodeID), strings.TrimSpace(s.Target)
+	if s.NodeID == "" {
+		return fmt.Errorf("%w: missing node_id", ErrInvalid)
+	}
+	limit, ok := maxCount[s.Type]
+	if !ok {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalid, s.Type)
+	}
+	if s.Count < 0 || s.Count > limit {
+		return fmt.Errorf("%w: count must be between 0 and %d for %s", ErrInvalid, limit, s.Type)
+	}
+	switch s.Type {
+	case TypeTCPPing, TypeUDP:
+		_, port, err := net.SplitHostPort(s.Target)
+		if err != nil {
+			return fmt.Errorf("%w: target must be host:port: %v", ErrInvalid, err)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("%w: bad port %q", ErrInvalid, port)
+		}
+	case TypePing, TypeTraceroute:
+		if s.Target == "" || (strings.ContainsAny(s.Target, " /:") && net.ParseIP(s.Target) == nil) {
+			return fmt.Errorf("%w: target must be a host name or IP", ErrInvalid)
+		}
+	case TypeHTTP:
+		u, err := url.Parse(s.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: target must be an http(s) URL", ErrInvalid)
+		}
+	case TypeThroughputServer, TypeThroughputClient:
+		if s.Throughput == nil {
+			return fmt.Errorf("%w: throughput tests are started via /api/throughput", ErrInvalid)
+		}
+		if _, _, err := net.SplitHostPort(s.Target); err != nil {
+			return fmt.Errorf("%w: target must be host:port: %v", ErrInvalid, err)
+		}
+	}
+	if s.Timeout == 0 {
+		s.Timeout = m.opts.DefaultTimeout
+	}
+	if s.Timeout < 0 || s.Timeout > m.opts.MaxTimeout {
+		return fmt.Errorf("%w: timeout must be at most %v", ErrInvalid, m.opts.MaxTimeout)
+	}
+	return nil
+}
+
+// Submit 提交任务。节点有空闲执行槽时立即下发，否则排队
+func (m *Manager) Submit(s Spec) (Task, error) {
+	if err := m.validate(&s); err != nil {
+		return Task{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.nodes[s.NodeID]
+	if !ok {
+		return Task{}, fmt.Errorf("%w: %s", ErrNotConnected, s.NodeID)
+	}
+	if q.running >= m.opts.PerNode && len(q.pending) >= m.opts.QueuePerNode {
+		return Task{}, fmt.Errorf("%w: %s", ErrBusy, s.NodeID)
+	}
+
+	t := &Task{
+		ID:        newID(),
+		NodeID:    s.NodeID,
+		Type:      s.Type,
+		Target:    s.Target,
+		Count:     s.Count,
+		TimeoutMs: s.Timeout.Milliseconds(),
+		By:        s.By,
+		State:     StatePending,
+		CreatedAt: time.Now().UnixMilli(),
+		done:      make(chan struct{}),
+
+		Throughput: s.Throughput,
+	}
+	m.tasks[t.ID] = t
+	m.order = append(m.order, t)
+	q.pending = append(q.pending, t)
+	m.dispatch(q)
+	m.trim()
+	return *t, nil
+}
+
+// dispatch 把排队的任务放入下发通道，直到占满执行槽。调用方持有 m.mu
+func (m *Manager) dispatch(q *nodeQueue) {
+	for q.running < m.opts.PerNode && len(q.pending) > 0 {
+		t := q.pending[0]
+		q.pending = q.pending[1:]
+		pt := &pb.Task{Id: t.ID, Type: t.Type, Target: t.Target, Count: int32(t.Count), TimeoutMs: int32(t.TimeoutMs)}
+		if p := t.Throughput; p != nil {
+			pt.Throughput = &pb.ThroughputParams{
+				Protocol:   p.Protocol,
+				DurationMs: p.DurationMs,
+				MaxBytes:   p.MaxBytes,
+				RateBps:    p.RateBps,
+				Token:      p.Token,
+			}
+		}
+		select {
+		case q.ch <- pt:
+		default:
+			// 通道容量等于执行槽数，正常不会满
+			m.finish(t, StateFailed, "dispatch queue full", nil)
+			continue
+		}
+		q.running++
+		t.State, t.SentAt = StateRunning, time.Now().UnixMilli()
+		id := t.ID
+		time.AfterFunc(time.Duration(t.TimeoutMs)*time.Millisecond+m.opts.Grace, func() { m.expire(id) })
+	}
+}
+
+// finish 结束任务。调用方持有 m.mu
+func (m *Manager) finish(t *Task, state State, msg string, res *Result) {
+	if t.State.Finished() {
+		return
+	}
+	t.State, t.Error, t.Result = state, msg, res
+	t.FinishedAt = time.Now().UnixMilli()
+	close(t.done)
+}
+
+// release 运行中的任务结束后让出执行槽。调用方持有 m.mu
+func (m *Manager) release(t *Task) {
+	if q, ok := m.nodes[t.NodeID]; ok && q.running > 0 {
+		q.running--
+		m.dispatch(q)
+	}
+}
+
+// expire 超过时限仍未收到结果
+func (m *Manager) expire(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	if !ok || t.State != StateRunning {
+		return
+	}
+	log.Printf("[Tasks] Task %s on node [%s] timed out", id, t.NodeID)
+	m.finish(t, StateTimeout, "no result from node within the time limit", nil)
+	m.release(t)
+}
+
+// trim 丢弃超出保留数的最早的已结束任务。调用方持有 m.mu
+func (m *Manager) trim() {
+	excess := len(m.order) - m.opts.HistoryLimit
+	if excess <= 0 {
+		return
+	}
+	kept := m.order[:0]
+	for _, t := range m.order {
+		if excess > 0 && t.State.Finished() {
+			delete(m.tasks, t.ID)
+			excess--
+			continue
+		}
+		kept = append(kept, t)
+	}
+	m.order = kept
+}
+
+// Attach 实现 server.TaskBroker：节点上报流建立。同一节点重连时替换旧通道
+func (m *Manager) Attach(nodeID string) (<-chan *pb.Task, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q := &nodeQueue{ch: make(chan *pb.Task, m.opts.PerNode)}
+	if old, ok := m.nodes[nodeID]; ok {
+		close(old.ch)
+		q.pending, q.running = old.pending, old.running
+	}
+	m.nodes[nodeID] = q
+	m.dispatch(q)
+	return q.ch, func() { m.detach(nodeID, q) }
+}
+
+// detach 节点断开：排队与执行中的任务判为失败
+func (m *Manager) detach(nodeID string, q *nodeQueue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nodes[nodeID] != q {
+		return // 已被新的流替换
+	}
+	delete(m.nodes, nodeID)
+	close(q.ch)
+	for _, t := range m.order {
+		if t.NodeID == nodeID && !t.State.Finished() {
+			m.finish(t, StateFailed, "node disconnected", nil)
+		}
+	}
+}
+
+// Complete 实现 server.TaskBroker：收到节点回传的结果
+func (m *Manager) Complete(nodeID string, r *pb.TaskResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[r.GetId()]
+	if !ok || t.NodeID != nodeID {
+		log.Printf("[Tasks] Ignoring result for unknown task %s from node [%s]", r.GetId(), nodeID)
+		return
+	}
+	if t.State != StateRunning {
+		return // 已超时或节点曾断开
+	}
+	state := StateDone
+	if r.GetError() != "" {
+		state = StateFailed
+	}
+	m.finish(t, state, r.GetError(), convert(t, r))
+	m.release(t)
+}
+
+func convert(t *Task, r *pb.TaskResult) *Result {
+	res := &Result{StartedAt: r.GetStartedAt(), FinishedAt: r.GetFinishedAt()}
+	if p := r.GetPing(); p != nil {
+		res.Ping = &storage.PingSnapshot{
+			Timestamp:   r.GetFinishedAt(),
+			Target:      t.Target,
+			TargetIP:    p.GetTargetIp(),
+			TargetPort:  p.GetTargetPort(),
+			TargetType:  p.GetTargetType(),
+			MinRTT:      p.GetMinRttMs(),
+			AvgRTT:      p.GetAvgRttMs(),
+			MaxRTT:      p.GetMaxRttMs(),
+			Loss:        p.GetPacketLossRate(),
+			Jitter:      p.GetJitterMs(),
+			Reordered:   p.GetReordered(),
+			Duplicates:  p.GetDuplicates(),
+			LossForward: p.GetLossForwardRate(),
+			LossReverse: p.GetLossReverseRate(),
+			MOS:         p.GetMos(),
+		}
+	}
+	for _, h := range r.GetHops() {
+		res.Hops = append(res.Hops, Hop{TTL: int(h.GetTtl()), Addr: h.GetAddr(), RTT: h.GetRttMs(), Reached: h.GetReached()})
+	}
+	if h := r.GetHttp(); h != nil {
+		res.HTTP = &HTTPCheck{
+			StatusCode:    int(h.GetStatusCode()),
+			RemoteAddr:    h.GetRemoteAddr(),
+			DNS:           h.GetDnsMs(),
+			Connect:       h.GetConnectMs(),
+			TLS:           h.GetTlsMs(),
+			TTFB:          h.GetTtfbMs(),
+			Total:         h.GetTotalMs(),
+			BodyBytes:     h.GetBodyBytes(),
+			CertExpiresAt: h.GetCertExpiresAt(),
+		}
+	}
+	if p := r.GetThroughput(); p != nil {
+		res.Throughput = &ThroughputStats{
+			Bytes:           p.GetBytes(),
+			DurationMs:      p.GetDurationMs(),
+			BitsPerSecond:   p.GetBitsPerSecond(),
+			Retransmits:     p.GetRetransmits(),
+			PacketsSent:     p.GetPacketsSent(),
+			PacketsReceived: p.GetPacketsReceived(),
+			JitterMs:        p.GetJitterMs(),
+		}
+	}
+	return res
+}
+
+// Get 单个任务的当前状态
+func (m *Manager) Get(id string) (Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	if !ok {
+		return Task{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return *t, nil
+}
+
+// Wait 等待任务结束或 ctx 到期，返回任务此时的状态
+func (m *Manager) Wait(ctx context.Context, id string) (Task, error) {
+	m.mu.Lock()
+	t, ok := m.tasks[id]
+	m.mu.Unlock()
+	if !ok {
+		return Task{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+	}
+	return m.Get(id)
+}
+
+// Tasks 最近的任务，新的在前，node_id 为空表示全部节点
+func (m *Manager) Tasks(nodeID string, limit int) []Task {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []Task{}
+	for i := len(m.order) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if t := m.order[i]; nodeID == "" || t.NodeID == nodeID {
+			out = append(out, *t)
+		}
+	}
+	return out
+}
+
+// Connected 节点当前是否可接收任务
+func (m *Manager) Connected(nodeID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.nodes[nodeID]
+	return ok
+}
+
+// Nodes 当前可接收任务的节点，按 ID 排序
+func (m *Manager) Nodes() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, 0, len(m.nodes))
+	for id := range m.nodes {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
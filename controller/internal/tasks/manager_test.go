@@ -0,0 +1,229 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+)
+
+func testManager() *Manager {
+	return NewManager(Options{PerNode: 2, QueuePerNode: 1, Grace: time.Millisecond})
+}
+
+func pingSpec(node string) Spec {
+	return Spec{NodeID: node, Type: TypeTCPPing, Target: "192.0.2.1:443", Count: 3}
+}
+
+// receive 从节点的下发通道读出 n 个任务，通道中不应再有更多
+func receive(t *testing.T, ch <-chan *pb.Task, n int) []*pb.Task {
+	t.Helper()
+	var got []*pb.Task
+	for range n {
+		select {
+		case pt, ok := <-ch:
+			if !ok {
+				t.Fatalf("channel closed after %d tasks, want %d", len(got), n)
+			}
+			got = append(got, pt)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d tasks, want %d", len(got), n)
+		}
+	}
+	select {
+	case pt, ok := <-ch:
+		if ok {
+			t.Fatalf("unexpected task %s", pt.Id)
+		}
+	default:
+	}
+	return got
+}
+
+func mustState(t *testing.T, m *Manager, id string, want State) Task {
+	t.Helper()
+	task, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.State != want {
+		t.Fatalf("task %s state %s (%s), want %s", id, task.State, task.Error, want)
+	}
+	return task
+}
+
+func TestSubmitValidation(t *testing.T) {
+	m := testManager()
+	m.Attach("hk-01")
+	for _, s := range []Spec{
+		{NodeID: "hk-01", Type: "nmap", Target: "192.0.2.1"},
+		{NodeID: "hk-01", Type: TypeTCPPing, Target: "192.0.2.1"},
+		{NodeID: "hk-01", Type: TypeTCPPing, Target: "192.0.2.1:0"},
+		{NodeID: "hk-01", Type: TypePing, Target: "a b"},
+		{NodeID: "hk-01", Type: TypeHTTP, Target: "file:///etc/passwd"},
+		{NodeID: "hk-01", Type: TypePing, Target: "192.0.2.1", Count: 101},
+		{NodeID: "hk-01", Type: TypePing, Target: "192.0.2.1", Timeout: time.Hour},
+		{NodeID: "hk-01", Type: TypeThroughputClient, Target: "192.0.2.1:5201"},
+		{Type: TypePing, Target: "192.0.2.1"},
+	} {
+		if _, err := m.Submit(s); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%+v: err %v, want ErrInvalid", s, err)
+		}
+	}
+	if _, err := m.Submit(pingSpec("hk-02")); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("offline node: err %v, want ErrNotConnected", err)
+	}
+}
+
+func TestPerNodeLimitAndQueue(t *testing.T) {
+	m := testManager()
+	ch, _ := m.Attach("hk-01")
+
+	var ids []string
+	for range 3 {
+		task, err := m.Submit(pingSpec("hk-01"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, task.ID)
+	}
+	// 两个执行槽占满，第三个排队，队列也满后拒绝
+	if _, err := m.Submit(pingSpec("hk-01")); !errors.Is(err, ErrBusy) {
+		t.Fatalf("err %v, want ErrBusy", err)
+	}
+	sent := receive(t, ch, 2)
+	if sent[0].Id != ids[0] || sent[1].Id != ids[1] {
+		t.Fatalf("dispatched %s %s, want %s %s", sent[0].Id, sent[1].Id, ids[0], ids[1])
+	}
+	mustState(t, m, ids[2], StatePending)
+
+	// 其它节点不受影响
+	other, _ := m.Attach("hk-02")
+	if _, err := m.Submit(pingSpec("hk-02")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, other, 1)
+
+	// 结果回传后让出执行槽，排队的任务随即下发
+	m.Complete("hk-01", &pb.TaskResult{Id: ids[0], Ping: &pb.PingResult{AvgRttMs: 12}})
+	task := mustState(t, m, ids[0], StateDone)
+	if task.Result == nil || task.Result.Ping == nil || task.Result.Ping.AvgRTT != 12 {
+		t.Fatalf("result %+v", task.Result)
+	}
+	if got := receive(t, ch, 1); got[0].Id != ids[2] {
+		t.Fatalf("dispatched %s, want queued %s", got[0].Id, ids[2])
+	}
+
+	m.Complete("hk-01", &pb.TaskResult{Id: ids[1], Error: "connection refused"})
+	if task := mustState(t, m, ids[1], StateFailed); task.Error != "connection refused" {
+		t.Fatalf("error %q", task.Error)
+	}
+	// 别的节点冒名回传的结果被忽略
+	m.Complete("hk-02", &pb.TaskResult{Id: ids[2]})
+	mustState(t, m, ids[2], StateRunning)
+}
+
+func TestExpire(t *testing.T) {
+	m := testManager()
+	ch, _ := m.Attach("hk-01")
+	s := pingSpec("hk-01")
+	s.Timeout = 10 * time.Millisecond
+	first, _ := m.Submit(s)
+	second, _ := m.Submit(s)
+	queued, _ := m.Submit(pingSpec("hk-01"))
+	receive(t, ch, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, id := range []string{first.ID, second.ID} {
+		if task, _ := m.Wait(ctx, id); task.State != StateTimeout {
+			t.Fatalf("task %s state %s, want timeout", id, task.State)
+		}
+	}
+	// 超时的任务让出执行槽，迟到的结果不再改写状态
+	if got := receive(t, ch, 1); got[0].Id != queued.ID {
+		t.Fatalf("dispatched %s, want %s", got[0].Id, queued.ID)
+	}
+	m.Complete("hk-01", &pb.TaskResult{Id: first.ID})
+	mustState(t, m, first.ID, StateTimeout)
+}
+
+func TestDetach(t *testing.T) {
+	m := testManager()
+	ch, detach := m.Attach("hk-01")
+	running, _ := m.Submit(pingSpec("hk-01"))
+	m.Submit(pingSpec("hk-01"))
+	queued, _ := m.Submit(pingSpec("hk-01"))
+	receive(t, ch, 2)
+
+	detach()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel still open after detach")
+	}
+	mustState(t, m, running.ID, StateFailed)
+	mustState(t, m, queued.ID, StateFailed)
+	if m.Connected("hk-01") {
+		t.Fatal("node still connected")
+	}
+	if _, err := m.Submit(pingSpec("hk-01")); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("err %v, want ErrNotConnected", err)
+	}
+}
+
+// 节点重连时旧流的断开晚于新流建立：任务交给新通道，旧流的 detach 不影响新流
+func TestReattachHandOver(t *testing.T) {
+	m := testManager()
+	oldCh, oldDetach := m.Attach("hk-01")
+	first, _ := m.Submit(pingSpec("hk-01"))
+	second, _ := m.Submit(pingSpec("hk-01"))
+	queued, _ := m.Submit(pingSpec("hk-01"))
+	receive(t, oldCh, 2)
+
+	newCh, _ := m.Attach("hk-01")
+	if _, ok := <-oldCh; ok {
+		t.Fatal("old channel still open after reconnect")
+	}
+	oldDetach()
+	if !m.Connected("hk-01") {
+		t.Fatal("stale detach dropped the new stream")
+	}
+	mustState(t, m, first.ID, StateRunning)
+	mustState(t, m, queued.ID, StatePending)
+
+	// 执行槽计数随之转移：结果回传后排队的任务经新通道下发
+	m.Complete("hk-01", &pb.TaskResult{Id: first.ID})
+	mustState(t, m, first.ID, StateDone)
+	if got := receive(t, newCh, 1); got[0].Id != queued.ID {
+		t.Fatalf("dispatched %s, want %s", got[0].Id, queued.ID)
+	}
+	m.Complete("hk-01", &pb.TaskResult{Id: second.ID})
+	mustState(t, m, second.ID, StateDone)
+}
+
+func TestTrimKeepsUnfinished(t *testing.T) {
+	m := NewManager(Options{PerNode: 1, QueuePerNode: 10, HistoryLimit: 2, Grace: time.Millisecond})
+	ch, _ := m.Attach("hk-01")
+	var ids []string
+	for range 4 {
+		task, err := m.Submit(pingSpec("hk-01"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, task.ID)
+	}
+	// 全部未结束时不丢弃
+	if n := len(m.Tasks("", 0)); n != 4 {
+		t.Fatalf("%d tasks kept, want 4", n)
+	}
+	for _, id := range ids[:3] {
+		receive(t, ch, 1)
+		m.Complete("hk-01", &pb.TaskResult{Id: id})
+	}
+	m.Submit(pingSpec("hk-01"))
+	if _, err := m.Get(ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("oldest finished task kept: %v", err)
+	}
+	mustState(t, m, ids[3], StateRunning)
+}
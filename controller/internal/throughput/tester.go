@@ -0,0 +1,610 @@
+// Package throughput 在两个节点间做 iperf 式的吞吐测试：经诊断任务通道先让服务端节点监听，
+// 再让客户端节点在限定的时长与字节数内发送，汇总接收端吞吐、发送端重传与 udp 丢包，结果保存为历史
+package throughput
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geelinx-ltd/geegee/controller/internal/nodemeta"
+	"github.com/geelinx-ltd/geegee/controller/internal/tasks"
+)
+
+var (
+	// ErrNotFound 测试不存在或已被清理
+	ErrNotFound = errors.New("throughput test not found")
+	// ErrInvalid 测试参数不合法
+	ErrInvalid = errors.New("invalid throughput test")
+	// ErrBusy 节点正在进行另一个吞吐测试
+	ErrBusy = errors.New("node is already in a throughput test")
+)
+
+const (
+	// setupWait 服务端任务下发后等待其开始监听的时间；客户端连不上时还会自行重试几秒
+	setupWait = 500 * time.Millisecond
+	// taskSlack 任务时限在测试时长之外的余量，覆盖服务端等待客户端与客户端重连
+	taskSlack = 15 * time.Second
+)
+
+// Options 吞吐测试参数
+type Options struct {
+	Port            int           // 服务端节点监听的端口 (TCP 与 UDP)
+	AddressLabel    string        // 节点标签中的公网地址 (host 或 host:port)，缺省取上报流的来源地址
+	DefaultDuration time.Duration // 未指定时长时使用
+	MaxDuration     time.Duration
+	MaxBytes        int64 // 单次测试的发送字节上限，未指定字节预算时即为预算
+	DefaultRateBps  int64 // udp 未指定速率时使用
+	MaxRateBps      int64
+	Path            string // 历史记录文件
+	HistoryLimit    int
+}
+
+func (o *Options) applyDefaults() {
+	if o.Port <= 0 {
+		o.Port = 8621
+	}
+	if o.AddressLabel == "" {
+		o.AddressLabel = "mesh_address"
+	}
+	if o.DefaultDuration <= 0 {
+		o.DefaultDuration = 10 * time.Second
+	}
+	if o.MaxDuration <= 0 {
+		o.MaxDuration = time.Minute
+	}
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = 5 << 30
+	}
+	if o.DefaultRateBps <= 0 {
+		o.DefaultRateBps = 100e6
+	}
+	if o.MaxRateBps <= 0 {
+		o.MaxRateBps = 1e9
+	}
+	if o.HistoryLimit <= 0 {
+		o.HistoryLimit = 500
+	}
+}
+
+// Spec 发起测试的参数
+type Spec struct {
+	Server   string        // 接收端节点
+	Client   string        // 发送端节点
+	Protocol string        // tcp (默认) / udp
+	Duration time.Duration // 0 取默认值
+	MaxBytes int64         // 字节预算，0 取上限
+	RateBps  int64         // 仅 udp
+	By       string
+}
+
+// State 测试状态
+type State string
+
+const (
+	StateRunning State = "running"
+	StateDone    State = "done"
+	StateFailed  State = "failed"
+)
+
+// Result 一次测试的汇总，吞吐以接收端为准
+type Result struct {
+	Bytes           int64   `json:"bytes"` // 接收端收到的字节数
+	DurationMs      int64   `json:"duration_ms"`
+	BitsPerSecond   float64 `json:"bits_per_second"`
+	SentBytes       int64   `json:"sent_bytes"`
+	Retransmits     uint32  `json:"retransmits,omitempty"` // tcp
+	PacketsSent     uint64  `json:"packets_sent,omitempty"`
+	PacketsReceived uint64  `json:"packets_received,omitempty"`
+	Loss            float64 `json:"loss,omitempty"` // udp 丢包率
+	JitterMs        float64 `json:"jitter_ms,omitempty"`
+}
+
+// Test 一次吞吐测试
+type Test struct {
+	ID         string  `json:"id"`
+	Server     string  `json:"server"`
+	Client     string  `json:"client"`
+	Address    string  `json:"address"` // 客户端连接的 host:port
+	Protocol   string  `json:"protocol"`
+	DurationMs int64   `json:"duration_ms"`
+	MaxBytes   int64   `json:"max_bytes"`
+	RateBps    int64   `json:"rate_bps,omitempty"`
+	By         string  `json:"by,omitempty"`
+	State      State   `json:"state"`
+	Error      string  `json:"error,omitempty"`
+	StartedAt  int64   `json:"started_at"`
+	FinishedAt int64   `json:"finished_at,omitempty"`
+	Result     *Result `json:"result,omitempty"`
+	ServerTask string  `json:"server_task,omitempty"`
+	ClientTask string  `json:"client_task,omitempty"`
+}
+
+// Tester 协调节点两端的任务并保存测试历史；作为 StreamObserver 记下节点的来源地址
+type Tester struct {
+	opts  Options
+	tasks *tasks.Manager
+
+	mu      sync.Mutex
+	tests   []*Test // 按开始时间排列
+	busy    map[string]string
+	done    map[string]chan struct{} // 进行中测试的结束通知
+	addrs   map[string]string        // node_id -> 最近一次上报流的来源 IP
+	meta    func(nodeID string) nodemeta.Meta
+	closing bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 恢复历史记录，上次未结束的测试判为失败
+func New(opts Options, m *tasks.Manager) (*Tester, error) {
+	opts.applyDefaults()
+	t := &Tester{
+		opts:  opts,
+		tasks: m,
+		busy:  make(map[string]string),
+		done:  make(map[string]chan struct{}),
+		addrs: make(map[string]string),
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (t *Tester) load() error {
+	if t.opts.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(t.opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &t.tests); err != nil {
+		return err
+	}
+	for _, x := range t.tests {
+		if x.State == StateRunning {
+			x.State, x.Error = StateFailed, "interrupted by controller restart"
+		}
+	}
+	return nil
+}
+
+// save 调用方持有 t.mu
+func (t *Tester) save() {
+	if t.opts.Path == "" {
+		return
+	}
+	err := func() error {
+		data, err := json.Marshal(t.tests)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(t.opts.Path), 0o755); err != nil {
+			return err
+		}
+		tmp := t.opts.Path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, t.opts.Path)
+	}()
+	if err != nil {
+		log.Printf("[Throughput] Failed to save history: %v", err)
+	}
+}
+
+// SetNodeMeta 接入节点元数据，用于读取地址标签，须在开始测试前调用
+func (t *Tester) SetNodeMeta(f func(nodeID string) nodemeta.Meta) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.meta = f
+}
+
+// StreamOpened 实现 server.StreamObserver：记下节点的来源地址
+func (t *Tester) StreamOpened(nodeID, remoteAddr string) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil || host == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.addrs[nodeID] = host
+}
+
+// StreamClosed 地址保留，节点重连前仍可作为服务端
+func (t *Tester) StreamClosed(nodeID string, err error) {}
+
+// address 客户端连接服务端节点的 host:port，依次取地址标签、来源地址。
+// 不接受请求中指定的地址，测试流量只能发往已知属于服务端节点的地址。调用方持有 t.mu
+func (t *Tester) address(s Spec) (string, error) {
+	var v string
+	if t.meta != nil {
+		v = t.meta(s.Server).Labels[t.opts.AddressLabel]
+	}
+	if v == "" {
+		v = t.addrs[s.Server]
+	}
+	if v == "" {
+		return "", fmt.Errorf("%w: no known address for node %s", ErrInvalid, s.Server)
+	}
+	if h, p, err := net.SplitHostPort(v); err == nil {
+		if n, err := strconv.Atoi(p); err != nil || n <= 0 || n > 65535 {
+			return "", fmt.Errorf("%w: bad port in address %q", ErrInvalid, v)
+		}
+		return net.JoinHostPort(h, p), nil
+	}
+	return net.JoinHostPort(strings.Trim(v, "[]"), strconv.Itoa(t.opts.Port)), nil
+}
+
+// validate 补齐默认值并检查上限
+func (t *Tester) validate(s *Spec) error {
+	s.Server, s.Client = strings.TrimSpace(s.Server), strings.TrimSpace(s.Client)
+	if s.Server == "" || s.Client == "" {
+		return fmt.Errorf("%w: server and client are required", ErrInvalid)
+	}
+	if s.Server == s.Client {
+		return fmt.Errorf("%w: server and client must be different nodes", ErrInvalid)
+	}
+	if s.Duration == 0 {
+		s.Duration = t.opts.DefaultDuration
+	}
+	if s.Duration < time.Second || s.Duration > t.opts.MaxDuration {
+		return fmt.Errorf("%w: duration must be between 1s and %v", ErrInvalid, t.opts.MaxDuration)
+	}
+	if s.MaxBytes == 0 {
+		s.MaxBytes = t.opts.MaxBytes
+	}
+	if s.MaxBytes < 0 || s.MaxBytes > t.opts.MaxBytes {
+		return fmt.Errorf("%w: bytes must be at most %d", ErrInvalid, t.opts.MaxBytes)
+	}
+	switch s.Protocol {
+	case "", "tcp":
+		s.Protocol, s.RateBps = "tcp", 0
+	case "udp":
+		if s.RateBps == 0 {
+			s.RateBps = t.opts.DefaultRateBps
+		}
+		if s.RateBps < 0 || s.RateBps > t.opts.MaxRateBps {
+			return fmt.Errorf("%w: rate must be at most %d bit/s", ErrInvalid, t.opts.MaxRateBps)
+		}
+	default:
+		return fmt.Errorf("%w: unknown protocol %q", ErrInvalid, s.Protocol)
+	}
+	return nil
+}
+
+// Start 下发服务端任务并在后台完成测试，立即返回
+func (t *Tester) Start(s Spec) (Test, error) {
+	if err := t.validate(&s); err != nil {
+		return Test{}, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return Test{}, errors.New("throughput tester is shutting down")
+	}
+	for _, id := range []string{s.Server, s.Client} {
+		if other, ok := t.busy[id]; ok {
+			return Test{}, fmt.Errorf("%w: %s (test %s)", ErrBusy, id, other)
+		}
+		// 两端都在线才下发，避免服务端空等
+		if !t.tasks.Connected(id) {
+			return Test{}, fmt.Errorf("%w: %s", tasks.ErrNotConnected, id)
+		}
+	}
+	addr, err := t.address(s)
+	if err != nil {
+		return Test{}, err
+	}
+	_, port, _ := net.SplitHostPort(addr)
+
+	x := &Test{
+		ID:         newID(),
+		Server:     s.Server,
+		Client:     s.Client,
+		Address:    addr,
+		Protocol:   s.Protocol,
+		DurationMs: s.Duration.Milliseconds(),
+		MaxBytes:   s.MaxBytes,
+		RateBps:    s.RateBps,
+		By:         s.By,
+		State:      StateRunning,
+		StartedAt:  time.Now().UnixMilli(),
+	}
+	params := &tasks.ThroughputParams{
+		Protocol:   s.Protocol,
+		DurationMs: x.DurationMs,
+		MaxBytes:   s.MaxBytes,
+		RateBps:    s.RateBps,
+		Token:      newID(),
+	}
+	srv, err := t.tasks.Submit(tasks.Spec{
+		NodeID:     s.Server,
+		Type:       tasks.TypeThroughputServer,
+		Target:     ":" + port,
+		Timeout:    s.Duration + taskSlack,
+		By:         s.By,
+		Throughput: params,
+	})
+	if err != nil {
+		return Test{}, err
+	}
+	x.ServerTask = srv.ID
+
+	t.tests = append(t.tests, x)
+	t.trim()
+	t.busy[s.Server], t.busy[s.Client] = x.ID, x.ID
+	t.done[x.ID] = make(chan struct{})
+	t.save()
+	log.Printf("[Throughput] Test %s started: %s -> %s (%s), %s for up to %v", x.ID, s.Client, s.Server, addr, s.Protocol, s.Duration)
+
+	t.wg.Add(1)
+	go t.run(*x, s, params)
+	return *x, nil
+}
+
+// run 等服务端就绪后下发客户端任务，等两端结果汇总
+func (t *Tester) run(x Test, s Spec, params *tasks.ThroughputParams) {
+	defer t.wg.Done()
+	srv, err := t.waitSent(x.ServerTask)
+	if err != nil {
+		t.finish(x.ID, nil, fmt.Errorf("server: %w", err))
+		return
+	}
+	if srv.State.Finished() {
+		t.finish(x.ID, nil, fmt.Errorf("server: %s", srv.Error))
+		return
+	}
+	select {
+	case <-time.After(setupWait):
+	case <-t.ctx.Done():
+		t.finish(x.ID, nil, errors.New("interrupted by controller shutdown"))
+		return
+	}
+
+	cli, err := t.tasks.Submit(tasks.Spec{
+		NodeID:     s.Client,
+		Type:       tasks.TypeThroughputClient,
+		Target:     x.Address,
+		Timeout:    s.Duration + taskSlack,
+		By:         s.By,
+		Throughput: params,
+	})
+	if err != nil {
+		t.finish(x.ID, nil, fmt.Errorf("client: %w", err))
+		return
+	}
+	t.mu.Lock()
+	for _, y := range t.tests {
+		if y.ID == x.ID {
+			y.ClientTask = cli.ID
+		}
+	}
+	t.mu.Unlock()
+
+	// 任务管理器保证两个任务都会结束 (结果、超时或节点断开)
+	if cli, err = t.tasks.Wait(t.ctx, cli.ID); err == nil {
+		srv, err = t.tasks.Wait(t.ctx, x.ServerTask)
+	}
+	if err != nil || !cli.State.Finished() || !srv.State.Finished() {
+		t.finish(x.ID, nil, errors.New("interrupted by controller shutdown"))
+		return
+	}
+	t.finish(x.ID, summarize(x.Protocol, srv, cli), taskError(srv, cli))
+}
+
+// waitSent 等到服务端任务被下发 (节点的任务槽可能被占用) 或结束
+func (t *Tester) waitSent(id string) (tasks.Task, error) {
+	for {
+		task, err := t.tasks.Get(id)
+		if err != nil || task.SentAt > 0 || task.State.Finished() {
+			return task, err
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-t.ctx.Done():
+			return task, errors.New("interrupted by controller shutdown")
+		}
+	}
+}
+
+// taskError 两端任务的错误，都成功时为 nil
+func taskError(srv, cli tasks.Task) error {
+	var msgs []string
+	if srv.State != tasks.StateDone {
+		msgs = append(msgs, "server: "+srv.Error)
+	}
+	if cli.State != tasks.StateDone {
+		msgs = append(msgs, "client: "+cli.Error)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// summarize 吞吐取接收端，重传取发送端；udp 发送数优先取接收端收到的结束报文中声明的值
+func summarize(protocol string, srv, cli tasks.Task) *Result {
+	var recv, send tasks.ThroughputStats
+	if srv.Result != nil && srv.Result.Throughput != nil {
+		recv = *srv.Result.Throughput
+	}
+	if cli.Result != nil && cli.Result.Throughput != nil {
+		send = *cli.Result.Throughput
+	}
+	if recv.Bytes == 0 && send.Bytes == 0 {
+		return nil
+	}
+	r := &Result{
+		Bytes:         recv.Bytes,
+		DurationMs:    recv.DurationMs,
+		BitsPerSecond: recv.BitsPerSecond,
+		SentBytes:     send.Bytes,
+		Retransmits:   send.Retransmits,
+	}
+	if protocol == "udp" {
+		r.PacketsSent = recv.PacketsSent
+		if r.PacketsSent == 0 {
+			r.PacketsSent = send.PacketsSent
+		}
+		r.PacketsReceived = recv.PacketsReceived
+		if r.PacketsSent > 0 && r.PacketsReceived < r.PacketsSent {
+			r.Loss = 1 - float64(r.PacketsReceived)/float64(r.PacketsSent)
+		}
+		r.JitterMs = recv.JitterMs
+	}
+	return r
+}
+
+func (t *Tester) finish(id string, res *Result, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, x := range t.tests {
+		if x.ID != id {
+			continue
+		}
+		x.State, x.Result, x.FinishedAt = StateDone, res, time.Now().UnixMilli()
+		if err != nil {
+			x.State, x.Error = StateFailed, err.Error()
+			log.Printf("[Throughput] Test %s failed: %v", id, err)
+		} else if res != nil {
+			log.Printf("[Throughput] Test %s done: %.1f Mbit/s", id, res.BitsPerSecond/1e6)
+		}
+		if t.busy[x.Server] == id {
+			delete(t.busy, x.Server)
+		}
+		if t.busy[x.Client] == id {
+			delete(t.busy, x.Client)
+		}
+	}
+	if ch, ok := t.done[id]; ok {
+		close(ch)
+		delete(t.done, id)
+	}
+	t.save()
+}
+
+// trim 丢弃超出保留数的最早的已结束测试。调用方持有 t.mu
+func (t *Tester) trim() {
+	excess := len(t.tests) - t.opts.HistoryLimit
+	if excess <= 0 {
+		return
+	}
+	kept := t.tests[:0]
+	for _, x := range t.tests {
+		if excess > 0 && x.State != StateRunning {
+			excess--
+			continue
+		}
+		kept = append(kept, x)
+	}
+	t.tests = kept
+}
+
+// Get 单个测试
+func (t *Tester) Get(id string) (Test, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, x := range t.tests {
+		if x.ID == id {
+			return *x, nil
+		}
+	}
+	return Test{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+// Wait 等待测试结束或 ctx 到期，返回测试此时的状态
+func (t *Tester) Wait(ctx context.Context, id string) (Test, error) {
+	t.mu.Lock()
+	ch, ok := t.done[id]
+	t.mu.Unlock()
+	if ok {
+		select {
+		case <-ch:
+		case <-ctx.Done():
+		}
+	}
+	return t.Get(id)
+}
+
+// Tests 最近的测试，新的在前；nodeID 非空时只含以其为任一端的测试
+func (t *Tester) Tests(nodeID string, limit int) []Test {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := []Test{}
+	for i := len(t.tests) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if x := t.tests[i]; nodeID == "" || x.Server == nodeID || x.Client == nodeID {
+			out = append(out, *x)
+		}
+	}
+	return out
+}
+
+// DeleteNode 删除以该节点为任一端的已结束测试
+func (t *Tester) DeleteNode(nodeID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	kept := t.tests[:0]
+	for _, x := range t.tests {
+		if x.State != StateRunning && (x.Server == nodeID || x.Client == nodeID) {
+			continue
+		}
+		kept = append(kept, x)
+	}
+	t.tests = kept
+	delete(t.addrs, nodeID)
+	t.save()
+	return nil
+}
+
+// MergeNode 历史中的 from 改记为 into
+func (t *Tester) MergeNode(from, into string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, x := range t.tests {
+		if x.Server == from {
+			x.Server = into
+		}
+		if x.Client == from {
+			x.Client = into
+		}
+	}
+	delete(t.addrs, from)
+	t.save()
+	return nil
+}
+
+// Close 中止进行中的测试并保存
+func (t *Tester) Close() error {
+	t.mu.Lock()
+	t.closing = true
+	t.mu.Unlock()
+	t.cancel()
+	t.wg.Wait()
+	return nil
+}
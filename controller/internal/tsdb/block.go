@@ -0,0 +1,264 @@
+package tsdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// 块目录结构：
+//
+//	blocks/<minT>-<maxT>/
+//	  meta.json   时间范围、层级、统计与合并来源
+//	  index.json  序列 -> chunk 列表 (文件偏移、长度、时间范围、校验和)
+//	  chunks      所有 chunk 原样拼接，只追加、不修改
+const (
+	blockMetaFile   = "meta.json"
+	blockIndexFile  = "index.json"
+	blockChunksFile = "chunks"
+)
+
+// BlockMeta 块的元信息。MaxT 不含，即块覆盖 [MinT, MaxT)
+type BlockMeta struct {
+	MinT       int64 `json:"min_t"`
+	MaxT       int64 `json:"max_t"`
+	Level      int   `json:"level"` // 1 为 head 直接落盘，合并后 +1
+	NumSeries  int   `json:"num_series"`
+	NumChunks  int   `json:"num_chunks"`
+	NumSamples int64 `json:"num_samples"`
+	SizeBytes  int64 `json:"size_bytes"`
+	// Sources 合并产生的块记录来源块的目录名。来源块在新块落盘后才删除，
+	// 删除前崩溃时由下次打开时清理，避免同一段数据出现两次
+	Sources []string `json:"sources,omitempty"`
+}
+
+type chunkRef struct {
+	MinT int64  `json:"min_t"`
+	MaxT int64  `json:"max_t"`
+	Off  int64  `json:"off"`
+	Len  int    `json:"len"`
+	CRC  uint32 `json:"crc"`
+}
+
+// block 已打开的只读块
+type block struct {
+	dir    string
+	meta   BlockMeta
+	index  map[string][]chunkRef
+	chunks *os.File
+}
+
+func blockDirName(minT, maxT int64) string {
+	return fmt.Sprintf("%013d-%013d", minT, maxT)
+}
+
+func openBlock(dir string) (*block, error) {
+	b := &block{dir: dir}
+	if err := readJSON(filepath.Join(dir, blockMetaFile), &b.meta); err != nil {
+		return nil, err
+	}
+	if err := readJSON(filepath.Join(dir, blockIndexFile), &b.index); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, blockChunksFile))
+	if err != nil {
+		return nil, err
+	}
+	b.chunks = f
+	return b, nil
+}
+
+func (b *block) close() error {
+	return b.chunks.Close()
+}
+
+// readChunk 读出并校验一个 chunk
+func (b *block) readChunk(ref chunkRef) (*Chunk, error) {
+	buf := make([]byte, ref.Len)
+	if _, err := b.chunks.ReadAt(buf, ref.Off); err != nil {
+		return nil, fmt.Errorf("read chunk in %s: %w", filepath.Base(b.dir), err)
+	}
+	if crc32.ChecksumIEEE(buf) != ref.CRC {
+		return nil, fmt.Errorf("chunk checksum mismatch in %s at offset %d", filepath.Base(b.dir), ref.Off)
+	}
+	return chunkFromBytes(buf), nil
+}
+
+// blockWriter 先写入临时目录，全部落盘后再原子改名，崩溃时不会留下半个块
+type blockWriter struct {
+	parent string
+	tmp    string
+	f      *os.File
+	w      *bufio.Writer
+	off    int64
+	meta   BlockMeta
+	index  map[string][]chunkRef
+}
+
+func newBlockWriter(parent string, minT, maxT int64, level int) (*blockWriter, error) {
+	tmp := filepath.Join(parent, blockDirName(minT, maxT)+".tmp")
+	os.RemoveAll(tmp)
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(tmp, blockChunksFile))
+	if err != nil {
+		return nil, err
+	}
+	return &blockWriter{
+		parent: parent,
+		tmp:    tmp,
+		f:      f,
+		w:      bufio.NewWriterSize(f, 256*1024),
+		meta:   BlockMeta{MinT: minT, MaxT: maxT, Level: level},
+		index:  make(map[string][]chunkRef),
+	}, nil
+}
+
+// addChunk 追加序列的一个 chunk，同一序列须按时间顺序调用
+func (bw *blockWriter) addChunk(key string, minT, maxT int64, numSamples int, data []byte) error {
+	if _, err := bw.w.Write(data); err != nil {
+		return err
+	}
+	if _, ok := bw.index[key]; !ok {
+		bw.meta.NumSeries++
+	}
+	bw.index[key] = append(bw.index[key], chunkRef{
+		MinT: minT,
+		MaxT: maxT,
+		Off:  bw.off,
+		Len:  len(data),
+		CRC:  crc32.ChecksumIEEE(data),
+	})
+	bw.off += int64(len(data))
+	bw.meta.NumChunks++
+	bw.meta.NumSamples += int64(numSamples)
+	return nil
+}
+
+// commit 刷盘并改名为正式块目录，返回打开后的块
+func (bw *blockWriter) commit() (*block, error) {
+	if err := bw.w.Flush(); err != nil {
+		bw.abort()
+		return nil, err
+	}
+	if err := bw.f.Sync(); err != nil {
+		bw.abort()
+		return nil, err
+	}
+	bw.f.Close()
+
+	bw.meta.SizeBytes = bw.off
+	if err := writeJSON(filepath.Join(bw.tmp, blockIndexFile), bw.index); err != nil {
+		bw.abort()
+		return nil, err
+	}
+	if err := writeJSON(filepath.Join(bw.tmp, blockMetaFile), bw.meta); err != nil {
+		bw.abort()
+		return nil, err
+	}
+
+	final := filepath.Join(bw.parent, blockDirName(bw.meta.MinT, bw.meta.MaxT))
+	os.RemoveAll(final)
+	if err := os.Rename(bw.tmp, final); err != nil {
+		bw.abort()
+		return nil, err
+	}
+	syncDir(bw.parent)
+	return openBlock(final)
+}
+
+func (bw *blockWriter) abort() {
+	bw.f.Close()
+	os.RemoveAll(bw.tmp)
+}
+
+// loadBlocks 打开目录下全部块，清理残留的临时目录与已被合并的来源块；按 MinT 升序返回
+func loadBlocks(dir string) ([]*block, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	merged := make(map[string]string) // 来源块 -> 合并后的块
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if filepath.Ext(e.Name()) == ".tmp" {
+			os.RemoveAll(filepath.Join(dir, e.Name()))
+			continue
+		}
+		names = append(names, e.Name())
+		// 来源块可能已删除一半，读不到 meta.json 的块不提供合并记录
+		var meta BlockMeta
+		if readJSON(filepath.Join(dir, e.Name(), blockMetaFile), &meta) == nil {
+			for _, src := range meta.Sources {
+				merged[src] = e.Name()
+			}
+		}
+	}
+
+	var blocks []*block
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if into, ok := merged[name]; ok {
+			if err := os.RemoveAll(path); err != nil {
+				log.Printf("[TSDB] Failed to delete block %s already compacted into %s: %v", name, into, err)
+			} else {
+				log.Printf("[TSDB] Deleted block %s already compacted into %s", name, into)
+			}
+			continue
+		}
+		b, err := openBlock(path)
+		if err != nil {
+			for _, opened := range blocks {
+				opened.close()
+			}
+			return nil, fmt.Errorf("open block %s: %w", name, err)
+		}
+		blocks = append(blocks, b)
+	}
+	sortBlocks(blocks)
+	return blocks, nil
+}
+
+func sortBlocks(blocks []*block) {
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].meta.MinT < blocks[j].meta.MinT })
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(path string, v any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package tsdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestBlock 写一个 level 1 块，每个序列一个 chunk
+func writeTestBlock(t *testing.T, dir string, minT, maxT int64, keys ...string) *block {
+	t.Helper()
+	bw, err := newBlockWriter(dir, minT, maxT, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		c := appendAll(t, []sample{{minT, 1}, {minT + 1000, 2}})
+		if err := bw.addChunk(k, c.MinTime(), c.MaxTime(), c.NumSamples(), c.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := bw.commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func blockNames(blocks []*block) []string {
+	names := make([]string, len(blocks))
+	for i, b := range blocks {
+		names[i] = filepath.Base(b.dir)
+	}
+	return names
+}
+
+// 合并块已落盘、来源块删除前崩溃：重新打开时来源块被清理，数据只出现一次
+func TestLoadBlocksAfterInterruptedCompaction(t *testing.T) {
+	dir := t.TempDir()
+	const hour = 3600_000
+	a := writeTestBlock(t, dir, 0, 2*hour, "cpu")
+	b := writeTestBlock(t, dir, 2*hour, 4*hour, "cpu", "mem")
+	c := writeTestBlock(t, dir, 4*hour, 6*hour, "cpu")
+	other := writeTestBlock(t, dir, 24*hour, 26*hour, "cpu")
+
+	db := &DB{blocksDir: dir}
+	merged, err := db.mergeBlocks([]*block{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := merged.meta.Sources; len(got) != 3 || got[0] != filepath.Base(a.dir) || got[2] != filepath.Base(c.dir) {
+		t.Fatalf("sources %v", got)
+	}
+	if merged.meta.NumChunks != 4 || merged.meta.Level != 2 {
+		t.Fatalf("merged meta %+v", merged.meta)
+	}
+	for _, x := range []*block{a, b, c, other, merged} {
+		x.close()
+	}
+	// 删到一半：b 只剩 chunks 文件，c 完整保留
+	os.RemoveAll(a.dir)
+	os.Remove(filepath.Join(b.dir, blockMetaFile))
+	os.Remove(filepath.Join(b.dir, blockIndexFile))
+
+	blocks, err := loadBlocks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, x := range blocks {
+			x.close()
+		}
+	}()
+	names := blockNames(blocks)
+	if len(names) != 2 || names[0] != filepath.Base(merged.dir) || names[1] != filepath.Base(other.dir) {
+		t.Fatalf("loaded %v, want the merged block and the untouched one", names)
+	}
+	for _, x := range []*block{b, c} {
+		if _, err := os.Stat(x.dir); !os.IsNotExist(err) {
+			t.Fatalf("source block %s left on disk", filepath.Base(x.dir))
+		}
+	}
+}
+
+// 没有合并记录时，读不出的块仍然报错而不是被悄悄删除
+func TestLoadBlocksCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	b := writeTestBlock(t, dir, 0, 7200_000, "cpu")
+	b.close()
+	os.Remove(filepath.Join(b.dir, blockIndexFile))
+	if _, err := loadBlocks(dir); err == nil {
+		t.Fatal("corrupted block loaded without error")
+	}
+	if _, err := os.Stat(b.dir); err != nil {
+		t.Fatalf("corrupted block removed: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package tsdb
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// bstream 按位追加的字节流，count 为最后一个字节中尚未使用的位数
+type bstream struct {
+	stream []byte
+	count  uint8
+}
+
+func (b *bstream) bytes() []byte {
+	return b.stream
+}
+
+func (b *bstream) writeBit(bit bool) {
+	if b.count == 0 {
+		b.stream = append(b.stream, 0)
+		b.count = 8
+	}
+	i := len(b.stream) - 1
+	if bit {
+		b.stream[i] |= 1 << (b.count - 1)
+	}
+	b.count--
+}
+
+func (b *bstream) writeByte(byt byte) {
+	if b.count == 0 {
+		b.stream = append(b.stream, 0)
+		b.count = 8
+	}
+	i := len(b.stream) - 1
+	// 高位补进当前字节剩余的位，低位另起一个字节
+	b.stream[i] |= byt >> (8 - b.count)
+	b.stream = append(b.stream, 0)
+	i++
+	b.stream[i] = byt << b.count
+}
+
+// writeBits 写入 u 的低 nbits 位，高位在前
+func (b *bstream) writeBits(u uint64, nbits int) {
+	u <<= 64 - uint(nbits)
+	for nbits >= 8 {
+		b.writeByte(byte(u >> 56))
+		u <<= 8
+		nbits -= 8
+	}
+	for nbits > 0 {
+		b.writeBit((u >> 63) == 1)
+		u <<= 1
+		nbits--
+	}
+}
+
+func (b *bstream) writeVarint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	for _, c := range buf[:n] {
+		b.writeByte(c)
+	}
+}
+
+func (b *bstream) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	for _, c := range buf[:n] {
+		b.writeByte(c)
+	}
+}
+
+// bstreamReader 顺序读取 bstream
+type bstreamReader struct {
+	stream []byte
+	pos    int   // 当前字节下标
+	count  uint8 // 当前字节剩余未读位数
+}
+
+func newBReader(b []byte) *bstreamReader {
+	return &bstreamReader{stream: b, count: 8}
+}
+
+func (r *bstreamReader) readBit() (bool, error) {
+	if r.pos >= len(r.stream) {
+		return false, io.EOF
+	}
+	if r.count == 0 {
+		r.pos++
+		r.count = 8
+		if r.pos >= len(r.stream) {
+			return false, io.EOF
+		}
+	}
+	r.count--
+	return (r.stream[r.pos]>>r.count)&1 == 1, nil
+}
+
+func (r *bstreamReader) readBits(nbits int) (uint64, error) {
+	var u uint64
+	for i := 0; i < nbits; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		u <<= 1
+		if bit {
+			u |= 1
+		}
+	}
+	return u, nil
+}
+
+func (r *bstreamReader) ReadByte() (byte, error) {
+	v, err := r.readBits(8)
+	return byte(v), err
+}
@@ -0,0 +1,281 @@
+package tsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// maxSamplesPerChunk 单个 chunk 的样本上限，越大压缩率越高但查询时需解码的冗余越多
+const maxSamplesPerChunk = 120
+
+// ErrOutOfOrder 同一序列的样本时间戳必须严格递增
+var ErrOutOfOrder = errors.New("tsdb: out of order sample")
+
+// Chunk Gorilla 压缩的样本块：时间戳取二阶差分 (delta-of-delta)，数值与前值做 XOR
+//
+//	头部 2 字节为样本数，之后是位流：
+//	第 1 个样本：varint 时间戳 + 64 位原始浮点
+//	第 2 个样本：uvarint 时间差 + XOR 编码
+//	之后：dod 变长编码 + XOR 编码
+type Chunk struct {
+	b bstream
+
+	num      uint16
+	t        int64
+	tDelta   uint64
+	v        float64
+	leading  uint8
+	trailing uint8
+	minT     int64
+}
+
+func NewChunk() *Chunk {
+	c := &Chunk{leading: 0xff}
+	c.b.stream = make([]byte, 2, 128)
+	return c
+}
+
+// chunkFromBytes 以只读方式包装持久化的 chunk
+func chunkFromBytes(b []byte) *Chunk {
+	return &Chunk{b: bstream{stream: b}, num: binary.BigEndian.Uint16(b)}
+}
+
+func (c *Chunk) Bytes() []byte   { return c.b.bytes() }
+func (c *Chunk) NumSamples() int { return int(c.num) }
+func (c *Chunk) MinTime() int64  { return c.minT }
+func (c *Chunk) MaxTime() int64  { return c.t }
+func (c *Chunk) Full() bool      { return c.num >= maxSamplesPerChunk }
+
+// Append 追加一个样本，时间戳不大于上一个样本时返回 ErrOutOfOrder
+func (c *Chunk) Append(t int64, v float64) error {
+	switch c.num {
+	case 0:
+		c.b.writeVarint(t)
+		c.b.writeBits(math.Float64bits(v), 64)
+		c.minT = t
+	case 1:
+		if t <= c.t {
+			return ErrOutOfOrder
+		}
+		tDelta := uint64(t - c.t)
+		c.b.writeUvarint(tDelta)
+		c.writeXOR(v)
+		c.tDelta = tDelta
+	default:
+		if t <= c.t {
+			return ErrOutOfOrder
+		}
+		tDelta := uint64(t - c.t)
+		dod := int64(tDelta - c.tDelta)
+
+		// 5 秒一点的规律上报下 dod 几乎总是 0，只占 1 位
+		switch {
+		case dod == 0:
+			c.b.writeBit(false)
+		case bitRange(dod, 14):
+			c.b.writeBits(0b10, 2)
+			c.b.writeBits(uint64(dod), 14)
+		case bitRange(dod, 17):
+			c.b.writeBits(0b110, 3)
+			c.b.writeBits(uint64(dod), 17)
+		case bitRange(dod, 20):
+			c.b.writeBits(0b1110, 4)
+			c.b.writeBits(uint64(dod), 20)
+		default:
+			c.b.writeBits(0b1111, 4)
+			c.b.writeBits(uint64(dod), 64)
+		}
+		c.writeXOR(v)
+		c.tDelta = tDelta
+	}
+
+	c.t = t
+	c.v = v
+	c.num++
+	binary.BigEndian.PutUint16(c.b.stream, c.num)
+	return nil
+}
+
+// bitRange 判断 x 能否用 nbits 位的二进制补码表示，即 [-2^(n-1), 2^(n-1))。
+// 解码时最高位为 1 即做符号扩展，+2^(n-1) 必须落到更宽的编码里
+func bitRange(x int64, nbits uint8) bool {
+	return -(1<<(nbits-1)) <= x && x < 1<<(nbits-1)
+}
+
+func (c *Chunk) writeXOR(v float64) {
+	delta := math.Float64bits(v) ^ math.Float64bits(c.v)
+	if delta == 0 {
+		c.b.writeBit(false)
+		return
+	}
+	c.b.writeBit(true)
+
+	leading := uint8(bits.LeadingZeros64(delta))
+	trailing := uint8(bits.TrailingZeros64(delta))
+	// 前导零用 5 位存储，最多表示 31
+	if leading >= 32 {
+		leading = 31
+	}
+
+	// 有效位落在上一次的窗口内时复用窗口，省去 11 位头部
+	if c.leading != 0xff && leading >= c.leading && trailing >= c.trailing {
+		c.b.writeBit(false)
+		c.b.writeBits(delta>>c.trailing, 64-int(c.leading)-int(c.trailing))
+		return
+	}
+
+	c.leading, c.trailing = leading, trailing
+	c.b.writeBit(true)
+	c.b.writeBits(uint64(leading), 5)
+	// 有效位数为 64 时写 0，解码时还原
+	sigbits := 64 - leading - trailing
+	c.b.writeBits(uint64(sigbits), 6)
+	c.b.writeBits(delta>>trailing, int(sigbits))
+}
+
+// Iterator 解码 chunk 中的样本
+func (c *Chunk) Iterator() *ChunkIterator {
+	return &ChunkIterator{br: newBReader(c.b.bytes()[2:]), total: c.num}
+}
+
+// ChunkIterator 按时间顺序逐个返回样本
+type ChunkIterator struct {
+	br    *bstreamReader
+	total uint16
+	read  uint16
+
+	t        int64
+	tDelta   uint64
+	v        float64
+	leading  uint8
+	trailing uint8
+	err      error
+}
+
+func (it *ChunkIterator) At() (int64, float64) { return it.t, it.v }
+func (it *ChunkIterator) Err() error           { return it.err }
+
+func (it *ChunkIterator) Next() bool {
+	if it.err != nil || it.read == it.total {
+		return false
+	}
+
+	switch it.read {
+	case 0:
+		t, err := binary.ReadVarint(it.br)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		v, err := it.br.readBits(64)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.t, it.v = t, math.Float64frombits(v)
+	case 1:
+		tDelta, err := binary.ReadUvarint(it.br)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.tDelta = tDelta
+		it.t += int64(tDelta)
+		if !it.readValue() {
+			return false
+		}
+	default:
+		// 读出前缀 0 / 10 / 110 / 1110 / 1111
+		var prefix int
+		for prefix < 4 {
+			bit, err := it.br.readBit()
+			if err != nil {
+				it.err = err
+				return false
+			}
+			if !bit {
+				break
+			}
+			prefix++
+		}
+
+		var dod int64
+		var nbits int
+		switch prefix {
+		case 1:
+			nbits = 14
+		case 2:
+			nbits = 17
+		case 3:
+			nbits = 20
+		case 4:
+			nbits = 64
+		}
+		if nbits > 0 {
+			raw, err := it.br.readBits(nbits)
+			if err != nil {
+				it.err = err
+				return false
+			}
+			// 符号扩展
+			if nbits < 64 && raw&(1<<(nbits-1)) != 0 {
+				raw |= ^uint64(0) << nbits
+			}
+			dod = int64(raw)
+		}
+
+		it.tDelta = uint64(int64(it.tDelta) + dod)
+		it.t += int64(it.tDelta)
+		if !it.readValue() {
+			return false
+		}
+	}
+
+	it.read++
+	return true
+}
+
+func (it *ChunkIterator) readValue() bool {
+	bit, err := it.br.readBit()
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if !bit {
+		return true
+	}
+
+	bit, err = it.br.readBit()
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if bit {
+		leading, err := it.br.readBits(5)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		sigbits, err := it.br.readBits(6)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if sigbits == 0 {
+			sigbits = 64
+		}
+		it.leading = uint8(leading)
+		it.trailing = 64 - it.leading - uint8(sigbits)
+	}
+
+	sigbits := 64 - int(it.leading) - int(it.trailing)
+	raw, err := it.br.readBits(sigbits)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.v = math.Float64frombits(math.Float64bits(it.v) ^ (raw << it.trailing))
+	return true
+}
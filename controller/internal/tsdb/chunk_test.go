@@ -0,0 +1,120 @@
+package tsdb
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+type sample struct {
+	t int64
+	v float64
+}
+
+func appendAll(t *testing.T, samples []sample) *Chunk {
+	t.Helper()
+	c := NewChunk()
+	for _, s := range samples {
+		if err := c.Append(s.t, s.v); err != nil {
+			t.Fatalf("append %v: %v", s, err)
+		}
+	}
+	return c
+}
+
+func readAll(t *testing.T, c *Chunk) []sample {
+	t.Helper()
+	var out []sample
+	it := c.Iterator()
+	for it.Next() {
+		ts, v := it.At()
+		out = append(out, sample{ts, v})
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterate: %v", err)
+	}
+	return out
+}
+
+func checkRoundTrip(t *testing.T, samples []sample) {
+	t.Helper()
+	c := appendAll(t, samples)
+	// 内存中的 chunk 与持久化后重新包装的 chunk 都要能原样解出
+	for name, ch := range map[string]*Chunk{"head": c, "bytes": chunkFromBytes(c.Bytes())} {
+		got := readAll(t, ch)
+		if len(got) != len(samples) {
+			t.Fatalf("%s: got %d samples, want %d", name, len(got), len(samples))
+		}
+		for i := range samples {
+			if got[i].t != samples[i].t || math.Float64bits(got[i].v) != math.Float64bits(samples[i].v) {
+				t.Fatalf("%s: sample %d = %v, want %v", name, i, got[i], samples[i])
+			}
+		}
+	}
+}
+
+// TestChunkDodBoundaries 二阶差分落在各档编码的边界上
+func TestChunkDodBoundaries(t *testing.T) {
+	// 基础间隔取得足够大，负的 dod 也不会让时间倒退
+	const base = int64(1) << 22
+	var dods []int64
+	for _, n := range []uint{14, 17, 20} {
+		edge := int64(1) << (n - 1)
+		dods = append(dods, edge-1, edge, edge+1, -edge+1, -edge, -edge-1)
+	}
+	dods = append(dods, 0, 1, -1, base, 1<<40)
+
+	for _, dod := range dods {
+		samples := []sample{{0, 1}, {base, 2}, {2*base + dod, 3}, {3*base + 2*dod, 4}, {4*base + 2*dod, 5}}
+		c := appendAll(t, samples)
+		got := readAll(t, c)
+		for i := range samples {
+			if got[i].t != samples[i].t {
+				t.Fatalf("dod=%d: timestamps %v, want %v", dod, got, samples)
+			}
+		}
+	}
+}
+
+// TestChunkGap 上报中断后的整数秒间隔，曾把 8192ms 的 dod 解码成负数
+func TestChunkGap(t *testing.T) {
+	for _, gap := range []int64{8192, 65536, 524288} {
+		checkRoundTrip(t, []sample{{0, 0}, {5000, 1}, {10000, 2}, {15000 + gap, 3}, {20000 + gap, 4}})
+	}
+}
+
+func TestChunkValues(t *testing.T) {
+	samples := []sample{}
+	values := []float64{0, 0, 1.5, 1.5, -3.25, 1e300, math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), math.NaN(), 42, 42.000001, 0}
+	for i, v := range values {
+		samples = append(samples, sample{int64(1700000000000 + i*5000), v})
+	}
+	checkRoundTrip(t, samples)
+}
+
+func TestChunkFull(t *testing.T) {
+	var samples []sample
+	ts := int64(1700000000000)
+	for i := 0; i < maxSamplesPerChunk; i++ {
+		ts += 5000 + int64(i%7)*13 - 20
+		samples = append(samples, sample{ts, float64(i%10) * 0.1})
+	}
+	c := appendAll(t, samples)
+	if !c.Full() || c.NumSamples() != maxSamplesPerChunk {
+		t.Fatalf("full=%v num=%d", c.Full(), c.NumSamples())
+	}
+	if c.MinTime() != samples[0].t || c.MaxTime() != samples[len(samples)-1].t {
+		t.Fatalf("min/max = %d/%d", c.MinTime(), c.MaxTime())
+	}
+	checkRoundTrip(t, samples)
+}
+
+func TestChunkOutOfOrder(t *testing.T) {
+	c := appendAll(t, []sample{{1000, 1}, {2000, 2}})
+	for _, ts := range []int64{2000, 1500} {
+		if err := c.Append(ts, 3); !errors.Is(err, ErrOutOfOrder) {
+			t.Fatalf("append %d: err = %v, want ErrOutOfOrder", ts, err)
+		}
+	}
+	checkRoundTrip(t, []sample{{1000, 1}, {2000, 2}})
+}
@@ -0,0 +1,654 @@
+// Package tsdb 一个面向 GeeGee 上报规模的嵌入式时序存储引擎
+//
+// 写入先进入按时间窗划分的内存 head (Gorilla 压缩 chunk)，同时追加到该时间窗的 WAL；
+// 时间窗结束且过了宽限期后整窗落盘为只读块，随后删除对应 WAL。
+// 后台定期把同一 CompactDuration 内的小块合并为大块，并按整块删除超出保留期的数据。
+package tsdb
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Point 待写入的一个样本，Key 唯一标识一条序列
+type Point struct {
+	Key string
+	T   int64 // Unix 毫秒
+	V   float64
+}
+
+// Sample 查询返回的样本
+type Sample struct {
+	T int64
+	V float64
+}
+
+// Options 引擎参数
+type Options struct {
+	BlockDuration   time.Duration // head 时间窗与 level 1 块的跨度
+	CompactDuration time.Duration // 合并后块的跨度，须为 BlockDuration 的整数倍
+	Retention       time.Duration // 超出保留期的块整块删除，<= 0 表示永久保留
+	// FutureTolerance 拒绝时间戳超前当前时间太多的样本，避免节点时钟错误把 head 提前落盘
+	FutureTolerance time.Duration
+}
+
+func (o *Options) applyDefaults() {
+	if o.BlockDuration <= 0 {
+		o.BlockDuration = 2 * time.Hour
+	}
+	if o.CompactDuration < o.BlockDuration {
+		o.CompactDuration = 24 * time.Hour
+	}
+	if o.CompactDuration%o.BlockDuration != 0 {
+		o.CompactDuration = o.CompactDuration / o.BlockDuration * o.BlockDuration
+	}
+	if o.FutureTolerance <= 0 {
+		o.FutureTolerance = 10 * time.Minute
+	}
+}
+
+// Stats 引擎运行状态
+type Stats struct {
+	Blocks       int    `json:"blocks"`
+	BlockBytes   int64  `json:"block_bytes"`
+	BlockSamples int64  `json:"block_samples"`
+	HeadWindows  int    `json:"head_windows"`
+	HeadSeries   int    `json:"head_series"`
+	HeadSamples  int64  `json:"head_samples"`
+	OutOfOrder   uint64 `json:"out_of_order"`  // 时间戳不递增被丢弃的样本
+	OutOfBounds  uint64 `json:"out_of_bounds"` // 早于已落盘时间窗或超前当前时间被丢弃的样本
+	MinT         int64  `json:"min_t"`
+}
+
+// DB 引擎实例，并发安全
+type DB struct {
+	dir       string
+	walDir    string
+	blocksDir string
+	opts      Options
+	blockMs   int64
+	graceMs   int64
+
+	mu             sync.RWMutex
+	windows        map[int64]*headWindow
+	blocks         []*block // 按 MinT 升序，互不重叠
+	persistedUntil int64    // 早于此时间的数据都已在块中，新样本不得再写入
+	maxT           int64
+
+	outOfOrder  atomic.Uint64
+	outOfBounds atomic.Uint64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open 打开 (或新建) 数据目录，加载已有块并回放 WAL
+func Open(dir string, opts Options) (*DB, error) {
+	opts.applyDefaults()
+	db := &DB{
+		dir:       dir,
+		walDir:    filepath.Join(dir, "wal"),
+		blocksDir: filepath.Join(dir, "blocks"),
+		opts:      opts,
+		blockMs:   opts.BlockDuration.Milliseconds(),
+		graceMs:   opts.BlockDuration.Milliseconds() / 2,
+		windows:   make(map[int64]*headWindow),
+		stop:      make(chan struct{}),
+	}
+	for _, d := range []string{db.walDir, db.blocksDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	blocks, err := loadBlocks(db.blocksDir)
+	if err != nil {
+		return nil, err
+	}
+	db.blocks = blocks
+	for _, b := range blocks {
+		if b.meta.MaxT > db.persistedUntil {
+			db.persistedUntil = b.meta.MaxT
+		}
+	}
+
+	if err := db.replay(); err != nil {
+		db.closeFiles()
+		return nil, err
+	}
+
+	// 停机期间已经到期的时间窗直接落盘
+	db.mu.Lock()
+	err = db.persistDue(time.Now().UnixMilli())
+	db.mu.Unlock()
+	if err != nil {
+		db.closeFiles()
+		return nil, err
+	}
+
+	db.wg.Add(1)
+	go db.background()
+	return db, nil
+}
+
+// replay 按时间窗回放 WAL。块已落盘但 WAL 尚未删除 (落盘后崩溃) 的时间窗直接丢弃其 WAL
+func (db *DB) replay() error {
+	windows, err := listWALSegments(db.walDir)
+	if err != nil {
+		return err
+	}
+
+	var replayed int64
+	for _, start := range windows {
+		path := walPath(db.walDir, start)
+		if start+db.blockMs <= db.persistedUntil {
+			os.Remove(path)
+			continue
+		}
+
+		w := newHeadWindow(start, nil)
+		err := replayWAL(path, func(points []Point) {
+			for _, p := range points {
+				if w.append(p) && p.T > db.maxT {
+					db.maxT = p.T
+				}
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("replay %s: %w", filepath.Base(path), err)
+		}
+		if w.wal, err = openWALSegment(db.walDir, start); err != nil {
+			return err
+		}
+		db.windows[start] = w
+		replayed += w.samples
+	}
+	if len(windows) > 0 {
+		log.Printf("[TSDB] Replayed %d samples from %d WAL segments", replayed, len(windows))
+	}
+	return nil
+}
+
+// Append 写入一批样本。乱序、过旧或超前的样本被丢弃并计数，不视为错误
+func (db *DB) Append(points []Point) error {
+	futureLimit := time.Now().Add(db.opts.FutureTolerance).UnixMilli()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	accepted := make(map[*headWindow][]Point)
+	for _, p := range points {
+		if p.T < db.persistedUntil || p.T > futureLimit {
+			db.outOfBounds.Add(1)
+			continue
+		}
+		start := p.T - p.T%db.blockMs
+		w, ok := db.windows[start]
+		if !ok {
+			seg, err := openWALSegment(db.walDir, start)
+			if err != nil {
+				return err
+			}
+			w = newHeadWindow(start, seg)
+			db.windows[start] = w
+		}
+		if !w.append(p) {
+			db.outOfOrder.Add(1)
+			continue
+		}
+		accepted[w] = append(accepted[w], p)
+		if p.T > db.maxT {
+			db.maxT = p.T
+		}
+	}
+
+	var errs []error
+	for w, list := range accepted {
+		if err := w.wal.log(list); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := db.persistDue(db.maxT); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// persistDue 将结束时间 + 宽限期已过的时间窗按时间顺序落盘，调用方持有写锁
+func (db *DB) persistDue(now int64) error {
+	starts := make([]int64, 0, len(db.windows))
+	for start := range db.windows {
+		if start+db.blockMs+db.graceMs <= now {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	for _, start := range starts {
+		if err := db.persistWindow(db.windows[start]); err != nil {
+			return fmt.Errorf("persist head window %d: %w", start, err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) persistWindow(w *headWindow) error {
+	if w.samples > 0 {
+		bw, err := newBlockWriter(db.blocksDir, w.start, w.start+db.blockMs, 1)
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(w.series))
+		for k := range w.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, c := range w.series[k].chunks {
+				if err := bw.addChunk(k, c.MinTime(), c.MaxTime(), c.NumSamples(), c.Bytes()); err != nil {
+					bw.abort()
+					return err
+				}
+			}
+		}
+		b, err := bw.commit()
+		if err != nil {
+			return err
+		}
+		db.blocks = append(db.blocks, b)
+		sortBlocks(db.blocks)
+	}
+
+	// 块已经安全落盘，WAL 可以删除
+	w.wal.close()
+	os.Remove(w.wal.path)
+	delete(db.windows, w.start)
+	if end := w.start + db.blockMs; end > db.persistedUntil {
+		db.persistedUntil = end
+	}
+	return nil
+}
+
+// Select 返回序列在 [mint, maxt] 内的样本，按时间升序
+func (db *DB) Select(key string, mint, maxt int64) ([]Sample, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var out []Sample
+	for _, b := range db.blocks {
+		if b.meta.MaxT <= mint || b.meta.MinT > maxt {
+			continue
+		}
+		for _, ref := range b.index[key] {
+			if ref.MaxT < mint || ref.MinT > maxt {
+				continue
+			}
+			c, err := b.readChunk(ref)
+			if err != nil {
+				return nil, err
+			}
+			if out, err = appendChunk(out, c, mint, maxt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, w := range db.sortedWindows() {
+		s, ok := w.series[key]
+		if !ok {
+			continue
+		}
+		for _, c := range s.chunks {
+			if c.MaxTime() < mint || c.MinTime() > maxt {
+				continue
+			}
+			var err error
+			if out, err = appendChunk(out, c, mint, maxt); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// Last 返回序列最近的 n 个样本，按时间升序
+func (db *DB) Last(key string, n int) ([]Sample, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// 从新到旧逐个 chunk 解码，凑够 n 个为止
+	var parts [][]Sample
+	total := 0
+	take := func(c *Chunk) error {
+		samples, err := appendChunk(nil, c, minInt64, maxInt64)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, samples)
+		total += len(samples)
+		return nil
+	}
+
+	windows := db.sortedWindows()
+	for i := len(windows) - 1; i >= 0 && total < n; i-- {
+		s, ok := windows[i].series[key]
+		if !ok {
+			continue
+		}
+		for j := len(s.chunks) - 1; j >= 0 && total < n; j-- {
+			if err := take(s.chunks[j]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i := len(db.blocks) - 1; i >= 0 && total < n; i-- {
+		b := db.blocks[i]
+		refs := b.index[key]
+		for j := len(refs) - 1; j >= 0 && total < n; j-- {
+			c, err := b.readChunk(refs[j])
+			if err != nil {
+				return nil, err
+			}
+			if err := take(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := make([]Sample, 0, total)
+	for i := len(parts) - 1; i >= 0; i-- {
+		out = append(out, parts[i]...)
+	}
+	if len(out) > n {
+		out = out[len(out)-n:]
+	}
+	return out, nil
+}
+
+// Keys 列出以 prefix 开头的全部序列，升序
+func (db *DB) Keys(prefix string) []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	set := make(map[string]struct{})
+	for _, b := range db.blocks {
+		for k := range b.index {
+			if strings.HasPrefix(k, prefix) {
+				set[k] = struct{}{}
+			}
+		}
+	}
+	for _, w := range db.windows {
+		for k := range w.series {
+			if strings.HasPrefix(k, prefix) {
+				set[k] = struct{}{}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Stats 当前块与 head 的概况
+func (db *DB) Stats() Stats {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	st := Stats{
+		Blocks:      len(db.blocks),
+		HeadWindows: len(db.windows),
+		OutOfOrder:  db.outOfOrder.Load(),
+		OutOfBounds: db.outOfBounds.Load(),
+	}
+	for _, b := range db.blocks {
+		st.BlockBytes += b.meta.SizeBytes
+		st.BlockSamples += b.meta.NumSamples
+	}
+	if len(db.blocks) > 0 {
+		st.MinT = db.blocks[0].meta.MinT
+	}
+	for _, w := range db.windows {
+		st.HeadSeries += len(w.series)
+		st.HeadSamples += w.samples
+		if st.MinT == 0 || w.start < st.MinT {
+			st.MinT = w.start
+		}
+	}
+	return st
+}
+
+func (db *DB) sortedWindows() []*headWindow {
+	list := make([]*headWindow, 0, len(db.windows))
+	for _, w := range db.windows {
+		list = append(list, w)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].start < list[j].start })
+	return list
+}
+
+const (
+	minInt64 = -1 << 63
+	maxInt64 = 1<<63 - 1
+)
+
+func appendChunk(out []Sample, c *Chunk, mint, maxt int64) ([]Sample, error) {
+	it := c.Iterator()
+	for it.Next() {
+		t, v := it.At()
+		if t < mint {
+			continue
+		}
+		if t > maxt {
+			break
+		}
+		out = append(out, Sample{T: t, V: v})
+	}
+	return out, it.Err()
+}
+
+// background 每秒 fsync WAL，每分钟执行一次合并与保留期清理
+func (db *DB) background() {
+	defer db.wg.Done()
+
+	syncTicker := time.NewTicker(time.Second)
+	defer syncTicker.Stop()
+	maintTicker := time.NewTicker(time.Minute)
+	defer maintTicker.Stop()
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-syncTicker.C:
+			db.syncWAL()
+		case <-maintTicker.C:
+			// 没有新上报时也要让到期的时间窗落盘
+			db.mu.Lock()
+			if err := db.persistDue(time.Now().UnixMilli()); err != nil {
+				log.Printf("[TSDB] %v", err)
+			}
+			db.mu.Unlock()
+			if err := db.compact(); err != nil {
+				log.Printf("[TSDB] Compaction error: %v", err)
+			}
+			db.applyRetention()
+		}
+	}
+}
+
+func (db *DB) syncWAL() {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, w := range db.windows {
+		if err := w.wal.f.Sync(); err != nil {
+			log.Printf("[TSDB] WAL sync error: %v", err)
+		}
+	}
+}
+
+// compact 把同一 CompactDuration 对齐窗口内的多个块合并为一个。块之间互不重叠，chunk 可原样拷贝
+// 新块先落盘再删除来源块，中途崩溃留下的来源块由 loadBlocks 按新块记录的 Sources 清理
+func (db *DB) compact() error {
+	compactMs := db.opts.CompactDuration.Milliseconds()
+
+	db.mu.RLock()
+	groups := make(map[int64][]*block)
+	for _, b := range db.blocks {
+		start := b.meta.MinT - b.meta.MinT%compactMs
+		// 只合并已经完整的窗口，之后不会再有新块落入
+		if start+compactMs > db.persistedUntil || b.meta.MaxT > start+compactMs {
+			continue
+		}
+		groups[start] = append(groups[start], b)
+	}
+	db.mu.RUnlock()
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		merged, err := db.mergeBlocks(group)
+		if err != nil {
+			return err
+		}
+
+		db.mu.Lock()
+		kept := db.blocks[:0]
+		for _, b := range db.blocks {
+			if !containsBlock(group, b) {
+				kept = append(kept, b)
+			}
+		}
+		db.blocks = append(kept, merged)
+		sortBlocks(db.blocks)
+		db.mu.Unlock()
+
+		for _, b := range group {
+			b.close()
+			os.RemoveAll(b.dir)
+		}
+		log.Printf("[TSDB] Compacted %d blocks into %s (%d samples, %d bytes)",
+			len(group), filepath.Base(merged.dir), merged.meta.NumSamples, merged.meta.SizeBytes)
+	}
+	return nil
+}
+
+func (db *DB) mergeBlocks(group []*block) (*block, error) {
+	sortBlocks(group)
+	level := 0
+	keySet := make(map[string]struct{})
+	for _, b := range group {
+		if b.meta.Level > level {
+			level = b.meta.Level
+		}
+		for k := range b.index {
+			keySet[k] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for k := range keySet {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	bw, err := newBlockWriter(db.blocksDir, group[0].meta.MinT, group[len(group)-1].meta.MaxT, level+1)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range group {
+		bw.meta.Sources = append(bw.meta.Sources, filepath.Base(b.dir))
+	}
+	for _, k := range keys {
+		for _, b := range group {
+			for _, ref := range b.index[k] {
+				c, err := b.readChunk(ref)
+				if err != nil {
+					bw.abort()
+					return nil, err
+				}
+				if err := bw.addChunk(k, ref.MinT, ref.MaxT, c.NumSamples(), c.Bytes()); err != nil {
+					bw.abort()
+					return nil, err
+				}
+			}
+		}
+	}
+	return bw.commit()
+}
+
+func containsBlock(list []*block, b *block) bool {
+	for _, x := range list {
+		if x == b {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRetention 整块删除完全超出保留期的块
+func (db *DB) applyRetention() {
+	if db.opts.Retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-db.opts.Retention).UnixMilli()
+
+	db.mu.Lock()
+	var expired []*block
+	kept := db.blocks[:0]
+	for _, b := range db.blocks {
+		if b.meta.MaxT <= cutoff {
+			expired = append(expired, b)
+		} else {
+			kept = append(kept, b)
+		}
+	}
+	db.blocks = kept
+	db.mu.Unlock()
+
+	for _, b := range expired {
+		b.close()
+		if err := os.RemoveAll(b.dir); err != nil {
+			log.Printf("[TSDB] Failed to delete expired block %s: %v", filepath.Base(b.dir), err)
+			continue
+		}
+		log.Printf("[TSDB] Deleted expired block %s", filepath.Base(b.dir))
+	}
+}
+
+// Close 停止后台任务并把 WAL 刷盘。head 不在此处落盘，下次启动时由 WAL 回放
+func (db *DB) Close() error {
+	close(db.stop)
+	db.wg.Wait()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.closeFiles()
+}
+
+func (db *DB) closeFiles() error {
+	var errs []error
+	for _, w := range db.windows {
+		if w.wal != nil {
+			if err := w.wal.close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, b := range db.blocks {
+		b.close()
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package tsdb
+
+// memSeries head 中的一条序列，由若干已压缩的 chunk 组成，最后一个仍在追加
+type memSeries struct {
+	chunks []*Chunk
+	lastT  int64
+}
+
+func (s *memSeries) append(t int64, v float64) error {
+	if len(s.chunks) > 0 && t <= s.lastT {
+		return ErrOutOfOrder
+	}
+	if len(s.chunks) == 0 || s.chunks[len(s.chunks)-1].Full() {
+		s.chunks = append(s.chunks, NewChunk())
+	}
+	if err := s.chunks[len(s.chunks)-1].Append(t, v); err != nil {
+		return err
+	}
+	s.lastT = t
+	return nil
+}
+
+// headWindow head 中一个 BlockDuration 宽的时间窗，落盘后即成为一个 level 1 块
+type headWindow struct {
+	start   int64
+	series  map[string]*memSeries
+	wal     *walSegment
+	samples int64
+}
+
+func newHeadWindow(start int64, wal *walSegment) *headWindow {
+	return &headWindow{
+		start:  start,
+		series: make(map[string]*memSeries),
+		wal:    wal,
+	}
+}
+
+// append 写入单个样本，返回是否被接受 (乱序样本被丢弃)
+func (w *headWindow) append(p Point) bool {
+	s, ok := w.series[p.Key]
+	if !ok {
+		s = &memSeries{}
+		w.series[p.Key] = s
+	}
+	if err := s.append(p.T, p.V); err != nil {
+		return false
+	}
+	w.samples++
+	return true
+}
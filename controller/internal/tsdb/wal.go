@@ -0,0 +1,173 @@
+package tsdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// walSegment 一个 head 时间窗对应一个 WAL 文件，时间窗落盘成块后整个文件删除，无需 checkpoint
+//
+//	记录格式：uvarint(payload 长度) | crc32(payload) | payload
+//	payload：uvarint(点数) 后接若干 {uvarint(key 长度) key varint(t) float64}
+type walSegment struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+}
+
+func walPath(dir string, window int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%013d.wal", window))
+}
+
+func openWALSegment(dir string, window int64) (*walSegment, error) {
+	path := walPath(dir, window)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &walSegment{path: path, f: f, w: bufio.NewWriterSize(f, 64*1024)}, nil
+}
+
+// log 写入一条记录。写入 OS 缓冲即返回，fsync 由 DB 后台定期执行
+func (s *walSegment) log(points []Point) error {
+	var payload []byte
+	payload = binary.AppendUvarint(payload, uint64(len(points)))
+	for _, p := range points {
+		payload = binary.AppendUvarint(payload, uint64(len(p.Key)))
+		payload = append(payload, p.Key...)
+		payload = binary.AppendVarint(payload, p.T)
+		payload = binary.BigEndian.AppendUint64(payload, math.Float64bits(p.V))
+	}
+
+	var hdr [binary.MaxVarintLen64 + 4]byte
+	n := binary.PutUvarint(hdr[:], uint64(len(payload)))
+	binary.BigEndian.PutUint32(hdr[n:], crc32.ChecksumIEEE(payload))
+	if _, err := s.w.Write(hdr[:n+4]); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(payload); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+func (s *walSegment) sync() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *walSegment) close() error {
+	if err := s.sync(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+// listWALSegments 返回目录下所有 WAL 的时间窗起点，升序
+func listWALSegments(dir string) ([]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var windows []int64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".wal")
+		if !ok || e.IsDir() {
+			continue
+		}
+		w, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+	return windows, nil
+}
+
+// replayWAL 逐条读出记录交给 fn。遇到写了一半的尾部记录 (进程崩溃) 时截断文件并正常返回
+func replayWAL(path string, fn func([]Point)) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		size, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		}
+		var crc [4]byte
+		if err == nil {
+			_, err = io.ReadFull(r, crc[:])
+		}
+		var payload []byte
+		if err == nil {
+			payload = make([]byte, size)
+			_, err = io.ReadFull(r, payload)
+		}
+		if err == nil && crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(crc[:]) {
+			err = errors.New("checksum mismatch")
+		}
+		var points []Point
+		if err == nil {
+			points, err = decodeWALPayload(payload)
+		}
+		if err != nil {
+			log.Printf("[TSDB] WAL %s corrupted at offset %d (%v), truncating", filepath.Base(path), offset, err)
+			return f.Truncate(offset)
+		}
+
+		fn(points)
+		offset += int64(uvarintLen(size)) + 4 + int64(size)
+	}
+}
+
+func decodeWALPayload(b []byte) ([]Point, error) {
+	n, k := binary.Uvarint(b)
+	if k <= 0 {
+		return nil, errors.New("bad point count")
+	}
+	b = b[k:]
+	points := make([]Point, 0, n)
+	for i := uint64(0); i < n; i++ {
+		keyLen, k := binary.Uvarint(b)
+		if k <= 0 || uint64(len(b)-k) < keyLen {
+			return nil, errors.New("bad key")
+		}
+		b = b[k:]
+		key := string(b[:keyLen])
+		b = b[keyLen:]
+		t, k := binary.Varint(b)
+		if k <= 0 || len(b)-k < 8 {
+			return nil, errors.New("bad sample")
+		}
+		b = b[k:]
+		v := math.Float64frombits(binary.BigEndian.Uint64(b))
+		b = b[8:]
+		points = append(points, Point{Key: key, T: t, V: v})
+	}
+	return points, nil
+}
+
+func uvarintLen(v uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], v)
+}
@@ -13,6 +13,10 @@ let charts = {
 // 当前状态
 let activeNodeId = null;
 let pollInterval = null;
+let activeRange = 'live'; // live 或 5m/1h/24h/7d/30d
+let maintenance = {}; // node_id -> 当前生效的静默 / 维护窗口
+let nodeNames = {}; // node_id -> 显示名
+let nodeLifecycle = {}; // node_id -> active / decommissioned / archived
 
 // 初始化 ECharts
 function initCharts() {
@@ -31,11 +35,52 @@ function initCharts() {
     });
 }
 
+// 侧边栏筛选：分组下拉与 name=value 标签输入，拼成 /api/nodes 的查询参数
+const nodeFilterEl = document.getElementById('node-filter');
+const groupFilterEl = document.getElementById('group-filter');
+const labelFilterEl = document.getElementById('label-filter');
+const showRetiredEl = document.getElementById('show-retired');
+
+function nodeFilterParams() {
+    const params = new URLSearchParams();
+    if (groupFilterEl.value) params.append('group', groupFilterEl.value);
+    labelFilterEl.value.split(',').map(s => s.trim()).filter(s => s.includes('=')).forEach(s => params.append('label', s));
+    if (showRetiredEl.checked) params.append('include', 'all');
+    const q = params.toString();
+    return q ? `?${q}` : '';
+}
+
+// 分组列表，未启用节点元数据时接口不存在，隐藏筛选栏
+async function fetchGroups() {
+    try {
+        const res = await fetch('/api/groups');
+        if (!res.ok) return;
+        const groups = await res.json();
+        const current = groupFilterEl.value;
+        groupFilterEl.innerHTML = '<option value="">All groups</option>' +
+            groups.map(g => `<option value="${g.name}">${g.name} (${g.nodes.length})</option>`).join('');
+        groupFilterEl.value = groups.some(g => g.name === current) ? current : '';
+        nodeFilterEl.hidden = false;
+    } catch (e) {
+        // 保持隐藏
+    }
+}
+
+groupFilterEl.addEventListener('focus', fetchGroups);
+groupFilterEl.addEventListener('change', fetchNodes);
+labelFilterEl.addEventListener('change', fetchNodes);
+showRetiredEl.addEventListener('change', fetchNodes);
+
 // 获取全部探针节点
 async function fetchNodes() {
     try {
-        const res = await fetch('/api/nodes');
+        const res = await fetch(`/api/nodes${nodeFilterParams()}`);
+        if (!res.ok) {
+            nodeListEl.innerHTML = `<div class="loading-text" style="color:#ff3366">${await res.text()}</div>`;
+            return;
+        }
         const nodes = await res.json();
+        await fetchMaintenance();
         renderNodeList(nodes);
     } catch (e) {
         console.error("Failed to fetch nodes", e);
@@ -55,22 +100,33 @@ function renderNodeList(nodes) {
         const isActive = n.node_id === activeNodeId ? 'active' : '';
         const statusClass = n.is_online ? 'online' : 'offline';
         const lastSeen = new Date(n.last_seen).toLocaleTimeString();
+        const maint = maintenance[n.node_id] || [];
+        const maintClass = maint.length > 0 ? 'maintenance' : '';
+        nodeNames[n.node_id] = n.display_name || n.node_id;
+        nodeLifecycle[n.node_id] = n.lifecycle || 'active';
+        const retired = n.lifecycle && n.lifecycle !== 'active';
+        const labels = Object.entries(n.labels || {}).map(([k, v]) => `<span class="node-label">${k}=${v}</span>`)
+            .concat((n.groups || []).map(g => `<span class="node-label group">${g}</span>`));
 
         html += `
-            <div class="node-card ${isActive}" onclick="selectNode('${n.node_id}')">
+            <div class="node-card ${isActive} ${maintClass} ${retired ? 'retired' : ''}" onclick="selectNode('${n.node_id}')">
                 <div class="node-header">
-                    <span class="node-id">${n.node_id}</span>
+                    <span class="node-id" title="${n.description || n.node_id}">${n.display_name || n.node_id}</span>
                     <span class="node-status ${statusClass}"></span>
                 </div>
                 <div class="node-meta">
                     Last Seen: ${lastSeen} <br>
                     Points: ${n.history ? n.history.length : 0}
                 </div>
+                ${labels.length > 0 ? `<div class="node-labels">${labels.join('')}</div>` : ''}
+                ${maint.map(renderMaintBadge).join('')}
+                ${retired ? `<div class="lifecycle-badge">${n.lifecycle}</div>` : ''}
             </div>
         `;
     });
 
     nodeListEl.innerHTML = html;
+    updateRetireButton();
 
     // 默认选中第一个
     if (!activeNodeId && nodes.length > 0) {
@@ -78,10 +134,331 @@ function renderNodeList(nodes) {
     }
 }
 
+// 各节点当前的维护状态，未启用静默时接口不存在，按无维护处理
+async function fetchMaintenance() {
+    try {
+        const res = await fetch('/api/maintenance');
+        maintenance = res.ok ? await res.json() : {};
+    } catch (e) {
+        maintenance = {};
+    }
+}
+
+// 维护徽标：一次性静默可直接结束，周期窗口只展示本次结束时间
+function renderMaintBadge(m) {
+    const until = new Date(m.ends_at).toLocaleString();
+    const title = `${m.name || m.comment || ''} by ${m.created_by}`;
+    const end = m.kind === 'silence'
+        ? `<span class="maint-end" title="End now" onclick="event.stopPropagation(); endSilence('${m.id}')">✕</span>`
+        : '';
+    return `<div class="maint-badge" title="${title}">MAINT until ${until}${end}</div>`;
+}
+
+async function endSilence(id) {
+    await fetch(`/api/silences/${id}`, { method: 'DELETE' });
+    fetchNodes();
+}
+
+// 维护表单
+const maintDialog = document.getElementById('maint-dialog');
+const maintForm = document.getElementById('maint-form');
+const maintError = document.getElementById('maint-error');
+
+document.getElementById('maint-btn').addEventListener('click', () => {
+    if (!activeNodeId) return;
+    document.getElementById('maint-node').innerText = activeNodeId;
+    maintError.innerText = '';
+    maintDialog.showModal();
+});
+
+document.getElementById('sla-btn').addEventListener('click', () => {
+    if (!activeNodeId) return;
+    const now = new Date();
+    const month = `${now.getFullYear()}-${String(now.getMonth() + 1).padStart(2, '0')}`;
+    window.open(`/api/sla/report?node_id=${encodeURIComponent(activeNodeId)}&month=${month}`, '_blank');
+});
+
+// 节点生命周期：未启用时接口不存在，相关按钮与筛选保持隐藏
+async function detectLifecycle() {
+    try {
+        const res = await fetch('/api/nodes/lifecycle?limit=0');
+        if (!res.ok) return;
+        document.querySelectorAll('.lifecycle-only').forEach(el => el.hidden = false);
+    } catch (e) {
+        // 保持隐藏
+    }
+}
+
+// 当前节点已退役 / 归档时按钮改为恢复
+function updateRetireButton() {
+    const retired = activeNodeId && (nodeLifecycle[activeNodeId] || 'active') !== 'active';
+    document.getElementById('retire-btn').innerText = retired ? 'Reactivate' : 'Decommission';
+}
+
+// 删除 / 合并需要 http.api_token：401 时询问令牌并在本标签页内记住
+async function lifecycleAction(body) {
+    const send = () => fetch('/api/nodes/lifecycle', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+            ...(sessionStorage.apiToken ? { 'Authorization': `Bearer ${sessionStorage.apiToken}` } : {})
+        },
+        body: JSON.stringify(body)
+    });
+    let res = await send();
+    if (res.status === 401) {
+        const token = prompt('API token (http.api_token):');
+        if (!token) return false;
+        sessionStorage.apiToken = token;
+        res = await send();
+    }
+    if (!res.ok) {
+        // 删除 / 合并部分失败时响应体为各步骤结果
+        const text = await res.text();
+        try {
+            const ev = JSON.parse(text);
+            alert(`${body.action} incomplete:\n` + ev.steps.filter(s => s.error).map(s => `${s.name}: ${s.error}`).join('\n'));
+        } catch (e) {
+            alert(`${body.action} failed: ${text}`);
+        }
+        return false;
+    }
+    return true;
+}
+
+document.getElementById('retire-btn').addEventListener('click', async () => {
+    if (!activeNodeId) return;
+    const retired = (nodeLifecycle[activeNodeId] || 'active') !== 'active';
+    const action = retired ? 'reactivate' : 'decommission';
+    const reason = prompt(retired
+        ? `Reactivate ${activeNodeId}? Optional reason:`
+        : `Decommission ${activeNodeId}? It will be hidden and stop alerting; history is kept. Optional reason:`);
+    if (reason === null) return;
+    if (await lifecycleAction({ action, node_id: activeNodeId, reason, by: 'dashboard' })) {
+        if (!retired && !showRetiredEl.checked) activeNodeId = null;
+        fetchNodes();
+    }
+});
+
+document.getElementById('merge-btn').addEventListener('click', async () => {
+    if (!activeNodeId) return;
+    const into = prompt(`Merge the history of ${activeNodeId} into which node ID? ${activeNodeId} will disappear afterwards.`);
+    if (!into || into.trim() === activeNodeId) return;
+    if (await lifecycleAction({ action: 'merge', node_id: activeNodeId, into: into.trim(), by: 'dashboard' })) {
+        selectNode(into.trim());
+    }
+});
+
+document.getElementById('delete-btn').addEventListener('click', async () => {
+    if (!activeNodeId) return;
+    const typed = prompt(`Permanently delete ${activeNodeId} and all of its metrics? This cannot be undone.\nType the node ID to confirm:`);
+    if (typed !== activeNodeId) return;
+    if (await lifecycleAction({ action: 'delete', node_id: activeNodeId, by: 'dashboard' })) {
+        activeNodeId = null;
+        nodeTitleEl.innerText = 'Select a node to view metrics';
+        fetchNodes();
+    }
+});
+
+// 全网格：未启用时接口不存在，按钮保持隐藏
+const meshDialog = document.getElementById('mesh-dialog');
+const meshAllEl = document.getElementById('mesh-all');
+let meshRange = '24h';
+let meshCharts = null;
+
+async function detectMesh() {
+    try {
+        const res = await fetch('/api/mesh');
+        if (res.ok) document.getElementById('mesh-btn').hidden = false;
+    } catch (e) {
+        // 保持隐藏
+    }
+}
+
+document.getElementById('mesh-btn').addEventListener('click', () => {
+    meshDialog.showModal();
+    // 对话框显示后才有尺寸，图表在此时初始化
+    if (!meshCharts) {
+        const opts = { backgroundColor: 'transparent' };
+        meshCharts = {
+            matrix: echarts.init(document.getElementById('chart-mesh'), 'dark', opts),
+            history: echarts.init(document.getElementById('chart-mesh-history'), 'dark', opts)
+        };
+    }
+    refreshMesh();
+});
+
+document.getElementById('mesh-close').addEventListener('click', () => meshDialog.close());
+meshAllEl.addEventListener('change', refreshMesh);
+
+document.getElementById('mesh-range').addEventListener('click', (ev) => {
+    const btn = ev.target.closest('.range-btn');
+    if (!btn) return;
+    meshRange = btn.dataset.range;
+    document.querySelectorAll('#mesh-range .range-btn').forEach(b => b.classList.toggle('active', b === btn));
+    refreshMesh();
+});
+
+function meshName(nodes, id) {
+    const n = nodes.find(n => n.node_id === id);
+    return (n && n.display_name) || nodeNames[id] || id;
+}
+
+async function refreshMesh() {
+    if (!meshDialog.open) return;
+    try {
+        const params = new URLSearchParams({ from: `-${meshRange}` });
+        if (meshAllEl.checked) params.set('all', '1');
+        const [matrix, history] = await Promise.all([
+            fetch('/api/mesh').then(r => r.json()),
+            fetch(`/api/mesh/history?${params}`).then(r => r.json())
+        ]);
+        renderMeshMatrix(matrix);
+        renderMeshHistory(matrix.nodes, history);
+    } catch (e) {
+        console.error('Failed to load mesh', e);
+    }
+}
+
+// 行为探测方 (src)，列为被探测方 (dst)，颜色为平均延迟，劣化的格子加红框
+function renderMeshMatrix(matrix) {
+    const names = matrix.nodes.map(n => meshName(matrix.nodes, n.node_id));
+    const index = Object.fromEntries(matrix.nodes.map((n, i) => [n.node_id, i]));
+    const data = matrix.cells.filter(c => c.src in index && c.dst in index).map(c => ({
+        value: [index[c.dst], index[c.src], +c.rtt_ms.toFixed(2)],
+        cell: c,
+        itemStyle: c.degraded ? { borderColor: '#ff3366', borderWidth: 2 } : {}
+    }));
+    const max = Math.max(1, ...data.map(d => d.value[2]));
+
+    meshCharts.matrix.setOption({
+        tooltip: {
+            formatter: (p) => {
+                const c = p.data.cell;
+                const base = c.baseline_ms ? `<br/>baseline ${c.baseline_ms.toFixed(2)} ms` : '';
+                const flag = c.degraded ? '<br/><b style="color:#ff3366">DEGRADED</b>' : '';
+                return `${names[index[c.src]]} → ${names[index[c.dst]]}<br/>${c.target}<br/>` +
+                    `RTT ${c.rtt_ms.toFixed(2)} ms, loss ${(c.loss * 100).toFixed(1)}%${base}${flag}`;
+            }
+        },
+        grid: { left: '3%', right: '3%', top: 10, bottom: 70, containLabel: true },
+        xAxis: { type: 'category', data: names, name: 'dst', axisLabel: { rotate: 30 } },
+        yAxis: { type: 'category', data: names, name: 'src' },
+        visualMap: {
+            min: 0, max, calculable: true, orient: 'horizontal', left: 'center', bottom: 0,
+            inRange: { color: ['#00ff88', '#ffcc00', '#ff3366'] },
+            textStyle: { color: '#ccc' }
+        },
+        series: [{ type: 'heatmap', data, label: { show: names.length <= 12, fontSize: 10 } }]
+    }, true);
+}
+
+// 每行一个节点对，每格一个时间桶：1 为劣化，0 为正常，没有数据的桶留空
+function renderMeshHistory(nodes, history) {
+    const pairs = history.series.map(s => `${meshName(nodes, s.src)} → ${meshName(nodes, s.dst)}`);
+    const times = [];
+    for (let t = history.from; t <= history.to; t += history.step) times.push(t);
+    const data = [];
+    history.series.forEach((s, y) => {
+        s.points.forEach(p => {
+            const x = Math.floor((p.t - history.from) / history.step);
+            data.push({ value: [x, y, p.degraded ? 1 : 0], point: p, pair: pairs[y] });
+        });
+    });
+    const daily = history.to - history.from > 86400e3;
+
+    meshCharts.history.setOption({
+        tooltip: {
+            formatter: (p) => {
+                const pt = p.data.point;
+                return `${p.data.pair}<br/>${new Date(pt.t).toLocaleString()}<br/>` +
+                    `RTT ${pt.rtt_ms.toFixed(2)} ms, loss ${(pt.loss * 100).toFixed(1)}%`;
+            }
+        },
+        title: pairs.length ? undefined : {
+            text: 'No degraded pairs in this range', left: 'center', top: 'middle',
+            textStyle: { color: '#8492a6', fontSize: 14 }
+        },
+        grid: { left: '3%', right: '3%', top: 10, bottom: 40, containLabel: true },
+        xAxis: {
+            type: 'category',
+            data: times.map(t => {
+                const d = new Date(t);
+                return daily ? `${d.getMonth() + 1}/${d.getDate()} ${d.getHours()}:00` : d.toLocaleTimeString();
+            })
+        },
+        yAxis: { type: 'category', data: pairs },
+        visualMap: {
+            show: false, type: 'piecewise',
+            pieces: [{ value: 0, color: 'rgba(0, 255, 136, 0.35)' }, { value: 1, color: '#ff3366' }]
+        },
+        series: [{ type: 'heatmap', data }]
+    }, true);
+}
+
+// 把 "2h30m" 之类的时长换算为毫秒，供一次性静默计算结束时间
+function parseDuration(s) {
+    const units = { s: 1e3, m: 60e3, h: 3600e3, d: 86400e3 };
+    let total = 0;
+    const re = /(\d+(?:\.\d+)?)([smhd])/g;
+    let m, consumed = 0;
+    while ((m = re.exec(s)) !== null) {
+        total += parseFloat(m[1]) * units[m[2]];
+        consumed += m[0].length;
+    }
+    return consumed === s.length ? total : NaN;
+}
+
+maintForm.addEventListener('submit', async (ev) => {
+    if (ev.submitter && ev.submitter.value === 'cancel') return;
+    ev.preventDefault();
+    const f = new FormData(maintForm);
+    const matchers = [{ name: 'node_id', value: activeNodeId }];
+    let url, body;
+    if (f.get('kind') === 'weekly') {
+        url = '/api/maintenance-windows';
+        body = {
+            name: `${activeNodeId} weekly`,
+            matchers,
+            weekdays: f.getAll('day'),
+            start: f.get('start'),
+            duration: f.get('duration'),
+            timezone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+            created_by: f.get('author'),
+            comment: f.get('comment')
+        };
+    } else {
+        const ms = parseDuration(f.get('duration'));
+        if (!(ms > 0)) {
+            maintError.innerText = 'Duration must look like 30m, 2h or 1d';
+            return;
+        }
+        url = '/api/silences';
+        body = {
+            matchers,
+            ends_at: Date.now() + ms,
+            created_by: f.get('author'),
+            comment: f.get('comment')
+        };
+    }
+    const res = await fetch(url, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+    });
+    if (!res.ok) {
+        maintError.innerText = await res.text();
+        return;
+    }
+    maintDialog.close();
+    fetchNodes();
+});
+
 // 点击侧边栏切换节点
 function selectNode(nodeId) {
     activeNodeId = nodeId;
-    nodeTitleEl.innerText = `Probe: ${nodeId}`;
+    const name = nodeNames[nodeId];
+    nodeTitleEl.innerText = name && name !== nodeId ? `Probe: ${name} (${nodeId})` : `Probe: ${nodeId}`;
 
     // 立即拉取一次该节点历史
     fetchNodeMetrics();
@@ -95,12 +472,36 @@ function selectNode(nodeId) {
     }, 3000);
 }
 
+// 范围模式下追加的查询参数，step 交由服务端按约 300 个点自动推算
+function rangeParams() {
+    return activeRange === 'live' ? '' : `&from=-${activeRange}`;
+}
+
+// 切换时间范围
+document.querySelectorAll('#range-picker .range-btn').forEach(btn => {
+    btn.addEventListener('click', () => {
+        activeRange = btn.dataset.range;
+        document.querySelectorAll('#range-picker .range-btn').forEach(b => b.classList.toggle('active', b === btn));
+        fetchNodeMetrics();
+    });
+});
+
+// 分桶结果 {timestamp, values: {k: {min, avg, max}}} 摊平为与原始点同构的 {timestamp, k: avg}
+function flattenBuckets(buckets) {
+    return buckets.map(b => {
+        const row = { timestamp: b.timestamp };
+        for (const k in b.values) row[k] = b.values[k].avg;
+        return row;
+    });
+}
+
 // 获取当前选中节点的高频特征历史流
 async function fetchNodeMetrics() {
     if (!activeNodeId) return;
     try {
-        const res = await fetch(`/api/metrics?node_id=${activeNodeId}`);
-        const history = await res.json();
+        const res = await fetch(`/api/metrics?node_id=${activeNodeId}${rangeParams()}`);
+        const data = await res.json();
+        const history = activeRange === 'live' ? data : flattenBuckets(data);
 
         if (history && history.length > 0) {
             updateDashboard(history);
@@ -108,12 +509,131 @@ async function fetchNodeMetrics() {
     } catch (e) {
         console.error(`Failed to fetch metrics for ${activeNodeId}`, e);
     }
+    fetchPingSeries();
 }
 
-// 格式化时间为 HH:mm:ss
+// 拉取当前节点全部探测目标的历史，每个目标一条折线
+async function fetchPingSeries() {
+    if (!activeNodeId) return;
+    const nodeId = activeNodeId;
+    try {
+        const res = await fetch(`/api/ping?node_id=${encodeURIComponent(nodeId)}`);
+        const targets = await res.json();
+        const [series, anomalies] = await Promise.all([
+            Promise.all(targets.map(async t => {
+                const r = await fetch(`/api/ping?node_id=${encodeURIComponent(nodeId)}&target=${encodeURIComponent(t.target)}${rangeParams()}`);
+                return { target: t.target, history: await r.json() };
+            })),
+            fetchAnomalies(nodeId)
+        ]);
+        // 请求期间切换了节点则丢弃
+        if (nodeId === activeNodeId) {
+            renderPingChart(series, anomalies);
+        }
+    } catch (e) {
+        console.error(`Failed to fetch ping series for ${nodeId}`, e);
+    }
+}
+
+// 当前范围内的延迟 / 丢包异常事件，未启用检测时返回空列表
+async function fetchAnomalies(nodeId) {
+    const from = activeRange === 'live' ? '' : `&from=-${activeRange}`;
+    try {
+        const res = await fetch(`/api/anomalies?node_id=${encodeURIComponent(nodeId)}${from}`);
+        return res.ok ? await res.json() : [];
+    } catch (e) {
+        return [];
+    }
+}
+
+// 异常事件画成所属目标折线上的红色背景带，截断到该目标已有数据的时间范围
+function anomalyMarkArea(history, events) {
+    if (history.length === 0 || events.length === 0) return undefined;
+    const first = history[0].timestamp;
+    const last = history[history.length - 1].timestamp;
+    const data = [];
+    events.forEach(ev => {
+        const start = Math.max(ev.start, first);
+        const end = Math.min(ev.end || last, last);
+        if (start > end) return;
+        const label = ev.kind === 'loss'
+            ? `loss ${(ev.value * 100).toFixed(0)}% (usual ${(ev.expected * 100).toFixed(0)}%)`
+            : `${ev.value.toFixed(1)}ms (usual ${ev.expected.toFixed(1)}ms)`;
+        data.push([{ xAxis: start, name: label }, { xAxis: end }]);
+    });
+    return {
+        silent: false,
+        itemStyle: { color: 'rgba(255, 51, 102, 0.12)' },
+        label: { show: false },
+        emphasis: { label: { show: true, position: 'insideTop', color: '#ff3366' } },
+        data
+    };
+}
+
+// 每个目标一种颜色，丢包率以同色半透明柱叠加在右侧 Y 轴
+const pingPalette = ['#00f0ff', '#ffcc00', '#aa00ff', '#00ff88', '#ff8800', '#3399ff'];
+
+function renderPingChart(series, anomalies = []) {
+    const lines = [];
+    series.forEach((s, i) => {
+        const color = pingPalette[i % pingPalette.length];
+        lines.push({
+            name: s.target,
+            type: 'line',
+            smooth: true,
+            symbol: 'none',
+            itemStyle: { color },
+            data: s.history.map(p => [p.timestamp, p.loss >= 1 ? null : p.avg_rtt_ms]),
+            markArea: anomalyMarkArea(s.history, anomalies.filter(ev => ev.target === s.target))
+        });
+        lines.push({
+            name: `${s.target} loss`,
+            type: 'bar',
+            yAxisIndex: 1,
+            barMaxWidth: 6,
+            itemStyle: { color: 'rgba(255, 51, 102, 0.35)' },
+            data: s.history.filter(p => p.loss > 0).map(p => [p.timestamp, +(p.loss * 100).toFixed(1)])
+        });
+        // udp 目标的原始点带有抖动与 MOS，抖动以同色虚线画在延迟轴上 (分桶后的历史没有这两项)
+        if (s.history.some(p => p.jitter_ms !== undefined || p.mos !== undefined)) {
+            const mos = s.history[s.history.length - 1].mos;
+            lines.push({
+                name: `${s.target} jitter${mos ? ` (MOS ${mos.toFixed(2)})` : ''}`,
+                type: 'line',
+                smooth: true,
+                symbol: 'none',
+                lineStyle: { type: 'dashed', width: 1 },
+                itemStyle: { color },
+                data: s.history.map(p => [p.timestamp, p.loss >= 1 ? null : (p.jitter_ms || 0)])
+            });
+        }
+    });
+
+    charts.ping.setOption({
+        grid: { top: 40, right: 50, bottom: 30, left: 50 },
+        tooltip: { trigger: 'axis', axisPointer: { type: 'cross' } },
+        legend: {
+            data: series.map(s => s.target), right: 10, top: 0,
+            textStyle: { color: '#ccc' }
+        },
+        xAxis: { type: 'time', splitLine: { show: false } },
+        yAxis: [
+            { type: 'value', name: 'ms', splitLine: { lineStyle: { color: 'rgba(255,255,255,0.05)' } } },
+            { type: 'value', name: 'loss %', min: 0, max: 100, splitLine: { show: false } }
+        ],
+        series: lines
+    }, true);
+}
+
+// 格式化时间为 HH:mm:ss，跨天的范围额外带上 MM-DD
 function formatTime(ts) {
     const d = new Date(ts);
-    return `${d.getHours().toString().padStart(2, '0')}:${d.getMinutes().toString().padStart(2, '0')}:${d.getSeconds().toString().padStart(2, '0')}`;
+    const pad = n => n.toString().padStart(2, '0');
+    const hms = `${pad(d.getHours())}:${pad(d.getMinutes())}:${pad(d.getSeconds())}`;
+    if (activeRange === '24h' || activeRange === '7d' || activeRange === '30d') {
+        return `${pad(d.getMonth() + 1)}-${pad(d.getDate())} ${hms}`;
+    }
+    return hms;
 }
 
 // 将 JSON 历史填入 ECharts 并渲染汇总看板
@@ -150,7 +670,6 @@ function updateDashboard(history) {
 
     // 2. 剥离时间轴与其他曲线 Y 轴
     const timeAxis = history.map(item => formatTime(item.timestamp));
-    const pingData = history.map(item => item.ping_avg_rtt || 0);
     const cpuData = history.map(item => item.cpu_load1 || 0);
     const memData = history.map(item => item.mem_used_percent || 0);
     const netBurstData = history.map(item => item.net_burst || 0);
@@ -162,26 +681,6 @@ function updateDashboard(history) {
         xAxis: { type: 'category', data: timeAxis, boundaryGap: false, splitLine: { show: false } },
     };
 
-    // 绘制 Ping 图 (高光赛博蓝)
-    charts.ping.setOption({
-        ...commonOpts,
-        yAxis: { type: 'value', splitLine: { lineStyle: { color: 'rgba(255,255,255,0.05)' } } },
-        series: [{
-            name: 'TCP RTT (ms)',
-            type: 'line',
-            smooth: true,
-            symbol: 'none',
-            itemStyle: { color: '#00f0ff' },
-            areaStyle: {
-                color: new echarts.graphic.LinearGradient(0, 0, 0, 1, [
-                    { offset: 0, color: 'rgba(0, 240, 255, 0.5)' },
-                    { offset: 1, color: 'rgba(0, 240, 255, 0)' }
-                ])
-            },
-            data: pingData
-        }]
-    }, true);
-
     // 绘制 系统占用图 (CPU / MEM 双折线)
     charts.resources.setOption({
         ...commonOpts,
@@ -215,9 +714,13 @@ function updateDashboard(history) {
 
 // Start application
 initCharts();
+fetchGroups();
+detectLifecycle();
+detectMesh();
 fetchNodes();
 
 // 初次启动设置轮询寻找存活节点
 if (!pollInterval) {
     setInterval(fetchNodes, 5000);
+    setInterval(refreshMesh, 30000);
 }
@@ -1,31 +1,83 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	pb "github.com/geelinx-ltd/geegee/api/proto"
 	"github.com/geelinx-ltd/geegee/node/internal/aggregator"
 	"github.com/geelinx-ltd/geegee/node/internal/client"
 	"github.com/geelinx-ltd/geegee/node/internal/collector"
+	"github.com/geelinx-ltd/geegee/node/internal/prober"
+	"github.com/geelinx-ltd/geegee/node/internal/tasks"
 )
 
 func main() {
+	nodeID := flag.String("id", "test-node-windows", "node ID reported to the controller")
+	controller := flag.String("controller", "localhost:50051", "controller gRPC address")
+	name := flag.String("name", "", "display name shown on the dashboard")
+	description := flag.String("description", "", "free-form node description")
+	labels := flag.String("labels", "", "comma separated labels, e.g. region=hk,provider=aws,role=edge")
+	groups := flag.String("groups", "", "comma separated groups, e.g. core,hk-pop")
+	taskLimit := flag.Int("task-concurrency", 2, "diagnostic tasks pushed by the controller that may run at once")
+	reflector := flag.String("reflector", "", fmt.Sprintf("run a UDP reflector for other nodes' udp probes on this address, e.g. :%d", prober.DefaultReflectorPort))
+	flag.Parse()
+
 	log.Println("Starting GeeGee Node Probe...")
 
-	// 1. 初始化 gRPC 客户端
-	grpcClient := client.NewGrpcClient("localhost:50051")
+	info, err := nodeInfo(*name, *description, *labels, *groups)
+	if err != nil {
+		log.Fatalf("Invalid node metadata: %v", err)
+	}
+
+	// 可选：UDP 反射器，供其他节点以 udp 类型探测本机
+	if *reflector != "" {
+		r, err := prober.ListenReflector(*reflector)
+		if err != nil {
+			log.Fatalf("Failed to start UDP reflector: %v", err)
+		}
+		defer r.Close()
+	}
+
+	// 1. 初始化边缘计算 Ring Buffer
+	ringBuf := aggregator.NewRingBuffer(*nodeID)
+	ringBuf.SetInfo(info)
+
+	// 2. 初始化采集器并使用回调关联 Ring Buffer
+	mgr := collector.NewManager(func(m collector.NodeMetrics) {
+		ringBuf.Push(m)
+	})
+
+	// 3. 初始化 gRPC 客户端，主控按标签 / 分组下发的探测目标替换本地列表，分配被撤销 (空列表) 时恢复默认目标
+	grpcClient := client.NewGrpcClient(*controller)
+	grpcClient.OnProbeTargets(func(targets []*pb.ProbeTarget) {
+		if len(targets) == 0 {
+			mgr.UpdateTargets(prober.DefaultTargets())
+			return
+		}
+		list := make([]prober.Target, 0, len(targets))
+		for _, t := range targets {
+			list = append(list, prober.Target{IP: t.Ip, Port: int(t.Port), TargetType: t.TargetType})
+		}
+		mgr.UpdateTargets(list)
+	})
+	// 主控下发的一次性诊断任务，结果立即回传
+	runner := tasks.NewRunner(*taskLimit, func(r *pb.TaskResult) error {
+		return grpcClient.SendTaskResult(*nodeID, r)
+	})
+	grpcClient.OnTask(runner.Submit)
 	if err := grpcClient.Connect(); err != nil {
 		log.Printf("Failed to connect to controller: %v\n", err)
 	}
 	defer grpcClient.Close()
 
-	// 2. 初始化边缘计算 Ring Buffer
-	ringBuf := aggregator.NewRingBuffer("test-node-windows")
-
-	// 3. 启动定时上报协程 (每 5 秒上报一次)
+	// 4. 启动定时上报协程 (每 5 秒上报一次)
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
@@ -37,10 +89,6 @@ func main() {
 		}
 	}()
 
-	// 4. 初始化采集器并使用回调关联 Ring Buffer
-	mgr := collector.NewManager(func(m collector.NodeMetrics) {
-		ringBuf.Push(m)
-	})
 	mgr.Start()
 
 	// 5. 优雅退出监听
@@ -51,3 +99,30 @@ func main() {
 	mgr.Stop()
 	log.Println("Shutting down GeeGee Node Probe...")
 }
+
+// nodeInfo 由启动参数组装节点自报的元数据，校验交给主控
+func nodeInfo(name, description, labels, groups string) (*pb.NodeInfo, error) {
+	info := &pb.NodeInfo{DisplayName: name, Description: description}
+	for _, kv := range splitList(labels) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("label %q must look like name=value", kv)
+		}
+		if info.Labels == nil {
+			info.Labels = make(map[string]string)
+		}
+		info.Labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	info.Groups = splitList(groups)
+	return info, nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -5,6 +5,8 @@ go 1.25.6
 require (
 	github.com/geelinx-ltd/geegee/api v0.0.0-00010101000000-000000000000
 	github.com/shirou/gopsutil/v4 v4.26.1
+	golang.org/x/net v0.48.0
+	golang.org/x/sys v0.40.0
 	google.golang.org/grpc v1.79.1
 )
 
@@ -16,8 +18,6 @@ require (
 	github.com/tklauser/go-sysconf v0.3.16 // indirect
 	github.com/tklauser/numcpus v0.11.0 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
-	golang.org/x/net v0.48.0 // indirect
-	golang.org/x/sys v0.40.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20251202230838-ff82c1b0f217 // indirect
 	google.golang.org/protobuf v1.36.11 // indirect
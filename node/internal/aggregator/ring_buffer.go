@@ -13,6 +13,7 @@ type RingBuffer struct {
 	mu      sync.Mutex
 	metrics []collector.NodeMetrics
 	nodeID  string
+	info    *pb.NodeInfo // 随每帧上报的元数据，主控只在变化时处理
 }
 
 func NewRingBuffer(nodeID string) *RingBuffer {
@@ -22,6 +23,13 @@ func NewRingBuffer(nodeID string) *RingBuffer {
 	}
 }
 
+// SetInfo 设置节点自报的显示名、描述、标签与分组
+func (r *RingBuffer) SetInfo(info *pb.NodeInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.info = info
+}
+
 // Push 放入最新的采集点
 func (r *RingBuffer) Push(m collector.NodeMetrics) {
 	r.mu.Lock()
@@ -52,6 +60,7 @@ func (r *RingBuffer) Aggregate() *pb.ReportRequest {
 	req := &pb.ReportRequest{
 		NodeId:    r.nodeID,
 		Timestamp: time.Now().UnixMilli(),
+		Info:      r.info,
 		Cpu: &pb.CPUSummary{
 			ModelName: latest.CPU.ModelName,
 			Cores:     int32(latest.CPU.Cores),
@@ -91,15 +100,30 @@ func (r *RingBuffer) Aggregate() *pb.ReportRequest {
 		},
 	}
 
+	for _, nic := range latest.Net.Interfaces {
+		req.Net.Interfaces = append(req.Net.Interfaces, &pb.NetInterface{
+			Name:      nic.Name,
+			BytesRecv: nic.BytesRecv,
+			BytesSent: nic.BytesSent,
+			Virtual:   nic.Virtual,
+		})
+	}
+
 	for _, p := range latest.Ping {
 		req.PingResults = append(req.PingResults, &pb.PingResult{
-			TargetIp:       p.Target.IP,
-			TargetPort:     int32(p.Target.Port),
-			MinRttMs:       p.MinRTTMs,
-			MaxRttMs:       p.MaxRTTMs,
-			AvgRttMs:       p.AvgRTTMs,
-			PacketLossRate: p.PacketLoss,
-			TargetType:     p.Target.TargetType,
+			TargetIp:        p.Target.IP,
+			TargetPort:      int32(p.Target.Port),
+			MinRttMs:        p.MinRTTMs,
+			MaxRttMs:        p.MaxRTTMs,
+			AvgRttMs:        p.AvgRTTMs,
+			PacketLossRate:  p.PacketLoss,
+			TargetType:      p.Target.TargetType,
+			JitterMs:        p.JitterMs,
+			Reordered:       uint32(p.Reordered),
+			Duplicates:      uint32(p.Duplicates),
+			LossForwardRate: p.LossForward,
+			LossReverseRate: p.LossReverse,
+			Mos:             p.MOS,
 		})
 	}
 
@@ -3,6 +3,8 @@ package client
 import (
 	"context"
 	"log"
+	"sync"
+	"time"
 
 	pb "github.com/geelinx-ltd/geegee/api/proto"
 	"google.golang.org/grpc"
@@ -14,6 +16,11 @@ type GrpcClient struct {
 	conn         *grpc.ClientConn
 	probeClient  pb.ProbeServiceClient
 	streamClient pb.ProbeService_ReportMetricsClient
+
+	onTargets func(targets []*pb.ProbeTarget)
+	onTask    func(task *pb.Task)
+
+	sendMu sync.Mutex // 上报与任务结果由不同协程发送，gRPC 流不允许并发 Send
 }
 
 func NewGrpcClient(serverAddr string) *GrpcClient {
@@ -22,6 +29,16 @@ func NewGrpcClient(serverAddr string) *GrpcClient {
 	}
 }
 
+// OnProbeTargets 注册主控下发探测目标时的回调，须在 Connect 前调用。空列表表示主控撤销了分配
+func (c *GrpcClient) OnProbeTargets(f func(targets []*pb.ProbeTarget)) {
+	c.onTargets = f
+}
+
+// OnTask 注册主控下发诊断任务时的回调，须在 Connect 前调用。回调在接收协程中执行，不应阻塞
+func (c *GrpcClient) OnTask(f func(task *pb.Task)) {
+	c.onTask = f
+}
+
 func (c *GrpcClient) Connect() error {
 	log.Printf("Connecting to controller at %s...", c.serverAddr)
 
@@ -58,9 +75,16 @@ func (c *GrpcClient) receiveLoop() {
 
 		if !resp.Success {
 			log.Printf("Server returned error: %s", resp.Message)
-		} else if len(resp.ProbeTargets) > 0 {
-			log.Printf("Received new probe targets: %d targets. (Mocking applied)", len(resp.ProbeTargets))
-			// TODO: 将下发的 Target 通知到 network/tcpping collector 组件
+		} else if resp.TargetsAssigned || len(resp.ProbeTargets) > 0 {
+			log.Printf("Received new probe targets: %d targets.", len(resp.ProbeTargets))
+			if c.onTargets != nil {
+				c.onTargets(resp.ProbeTargets)
+			}
+		}
+		for _, t := range resp.Tasks {
+			if c.onTask != nil {
+				c.onTask(t)
+			}
 		}
 	}
 }
@@ -70,7 +94,9 @@ func (c *GrpcClient) SendMetrics(req *pb.ReportRequest) error {
 		return nil
 	}
 	// 在高频上报时，我们直接向流写入即可，得益于 gRPC 流，TCP 层面复用而且基于 Protobuf，非常高效
+	c.sendMu.Lock()
 	err := c.streamClient.Send(req)
+	c.sendMu.Unlock()
 	if err != nil {
 		log.Printf("Failed to push metrics to stream: %v", err)
 		return err
@@ -79,6 +105,16 @@ func (c *GrpcClient) SendMetrics(req *pb.ReportRequest) error {
 	return nil
 }
 
+// SendTaskResult 立即回传一个诊断任务的结果，不带指标
+func (c *GrpcClient) SendTaskResult(nodeID string, r *pb.TaskResult) error {
+	if c.streamClient == nil {
+		return nil
+	}
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.streamClient.Send(&pb.ReportRequest{NodeId: nodeID, Timestamp: time.Now().UnixMilli(), TaskResults: []*pb.TaskResult{r}})
+}
+
 func (c *GrpcClient) Close() {
 	if c.streamClient != nil {
 		c.streamClient.CloseSend()
@@ -60,6 +60,11 @@ func (m *Manager) collectAll() NodeMetrics {
 	return n
 }
 
+// UpdateTargets 替换探测目标列表，下一轮探测生效
+func (m *Manager) UpdateTargets(targets []prober.Target) {
+	m.pingProber.UpdateTargets(targets)
+}
+
 func (m *Manager) Stop() {
 	log.Println("Probe collectors stopping...")
 	close(m.stopChan)
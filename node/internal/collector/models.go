@@ -50,6 +50,16 @@ type NetMetrics struct {
 	PacketsSent uint64 `json:"packets_sent"`
 	// eBPF 采集的微秒级突发包计量特征 (例如最大每秒发包突增数)
 	MicroburstEvents uint64 `json:"microburst_events"`
+	// 逐网卡累计计数，不含回环；上面的合计只含物理网卡
+	Interfaces []NetInterface `json:"interfaces"`
+}
+
+// NetInterface 单个网卡的累计收发字节数
+type NetInterface struct {
+	Name      string `json:"name"`
+	BytesRecv uint64 `json:"bytes_recv"`
+	BytesSent uint64 `json:"bytes_sent"`
+	Virtual   bool   `json:"virtual"` // 网桥、tap、veth 等，不计入合计
 }
 
 // KVMMetrics 包含 Libvirt 读取的 KVM 宿主机虚拟机分布与负载情况
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"log"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// collectNetCounters 读取各网卡的累计收发计数，跳过回环接口。
+// 整机合计只汇总物理网卡：网桥、tap、veth 上的流量同时经过物理网卡，一并相加会重复计数，
+// 虚拟机关机时 tap 消失还会让合计回退；没有物理网卡 (如运行在容器中) 时才汇总全部
+func collectNetCounters() NetMetrics {
+	var metrics NetMetrics
+	counters, err := net.IOCounters(true)
+	if err != nil {
+		log.Printf("Failed to get net io counters: %v", err)
+		return metrics
+	}
+	var (
+		all      NetMetrics
+		physical int
+	)
+	for _, stat := range counters {
+		if stat.Name == "lo" || strings.HasPrefix(stat.Name, "Loopback") {
+			continue
+		}
+		nic := NetInterface{
+			Name:      stat.Name,
+			BytesRecv: stat.BytesRecv,
+			BytesSent: stat.BytesSent,
+			Virtual:   virtualInterface(stat.Name),
+		}
+		metrics.Interfaces = append(metrics.Interfaces, nic)
+		all.addCounters(stat)
+		if !nic.Virtual {
+			physical++
+			metrics.addCounters(stat)
+		}
+	}
+	if physical == 0 {
+		metrics.BytesRecv, metrics.BytesSent = all.BytesRecv, all.BytesSent
+		metrics.PacketsRecv, metrics.PacketsSent = all.PacketsRecv, all.PacketsSent
+	}
+	return metrics
+}
+
+func (m *NetMetrics) addCounters(stat net.IOCountersStat) {
+	m.BytesRecv += stat.BytesRecv
+	m.BytesSent += stat.BytesSent
+	m.PacketsRecv += stat.PacketsRecv
+	m.PacketsSent += stat.PacketsSent
+}
@@ -0,0 +1,11 @@
+//go:build linux
+
+package collector
+
+import "os"
+
+// virtualInterface 内核把网桥、tap、veth、bond、vlan 等软件网卡放在 /sys/devices/virtual/net 下，物理网卡 (含 virtio) 不在其中
+func virtualInterface(name string) bool {
+	_, err := os.Stat("/sys/devices/virtual/net/" + name)
+	return err == nil
+}
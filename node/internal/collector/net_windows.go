@@ -0,0 +1,21 @@
+//go:build windows
+
+package collector
+
+import "strings"
+
+// virtualAdapterPrefixes Hyper-V 虚拟交换机、常见虚拟化软件与隧道适配器的名称前缀
+var virtualAdapterPrefixes = []string{
+	"vethernet", "hyper-v", "vmware", "virtualbox", "vbox", "npcap", "teredo", "isatap", "6to4", "wsl",
+}
+
+// virtualInterface Windows 下没有统一的标记，按适配器名称判断
+func virtualInterface(name string) bool {
+	lower := strings.ToLower(name)
+	for _, p := range virtualAdapterPrefixes {
+		if strings.HasPrefix(lower, p) {
+			return true
+		}
+	}
+	return false
+}
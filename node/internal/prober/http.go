@@ -0,0 +1,87 @@
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// httpBodyLimit HTTP 检查最多读取的响应体字节数
+const httpBodyLimit = 1 << 20
+
+// HTTPResult 一次 HTTP 请求的状态码与各阶段耗时 (毫秒)
+type HTTPResult struct {
+	StatusCode    int
+	RemoteAddr    string
+	DNSMs         float64
+	ConnectMs     float64
+	TLSMs         float64
+	TTFBMs        float64
+	TotalMs       float64
+	BodyBytes     int64
+	CertExpiresAt time.Time // 非 HTTPS 为零值
+}
+
+func sinceMs(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
+
+// CheckHTTP 对 url 发起一次 GET，不跟随重定向，每次使用新连接以便测出完整的建连耗时
+func CheckHTTP(ctx context.Context, url string) (HTTPResult, error) {
+	var (
+		res                           HTTPResult
+		dnsStart, connStart, tlsStart time.Time
+		start                         = time.Now()
+		mu                            sync.Mutex // 双栈拨号时回调可能来自不同协程
+	)
+	locked := func(f func()) { mu.Lock(); f(); mu.Unlock() }
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { locked(func() { dnsStart = time.Now() }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { locked(func() { res.DNSMs = sinceMs(dnsStart) }) },
+		ConnectStart: func(string, string) {
+			locked(func() { connStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			locked(func() { res.ConnectMs = sinceMs(connStart) })
+		},
+		TLSHandshakeStart: func() { locked(func() { tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			locked(func() { res.TLSMs = sinceMs(tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			locked(func() { res.RemoteAddr = info.Conn.RemoteAddr().String() })
+		},
+		GotFirstResponseByte: func() { locked(func() { res.TTFBMs = sinceMs(start) }) },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, url, nil)
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("User-Agent", "GeeGee-Probe")
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DisableKeepAlives: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	res.StatusCode = resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		res.CertExpiresAt = resp.TLS.PeerCertificates[0].NotAfter
+	}
+	res.BodyBytes, err = io.Copy(io.Discard, io.LimitReader(resp.Body, httpBodyLimit))
+	res.TotalMs = sinceMs(start)
+	return res, err
+}
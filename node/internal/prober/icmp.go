@@ -0,0 +1,238 @@
+package prober
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Hop traceroute 的一跳，Addr 为空表示该跳超时
+type Hop struct {
+	TTL     int
+	Addr    string
+	RTTMs   float64
+	Reached bool
+}
+
+const (
+	protoICMP   = 1
+	protoICMPv6 = 58
+)
+
+// icmpConn 按目标地址族打开的 ICMP 套接字
+type icmpConn struct {
+	*icmp.PacketConn
+	dst      net.Addr
+	v6       bool
+	raw      bool // 原始套接字；非特权的 udp 模式下内核会改写 ID，只能按序号匹配
+	echoType icmp.Type
+}
+
+// openICMP 优先使用原始套接字 (需 root 或 CAP_NET_RAW)，allowUnprivileged 时失败再退回 Linux 的非特权 ICMP 套接字
+func openICMP(host string, allowUnprivileged bool) (*icmpConn, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+	c := &icmpConn{v6: ip.IP.To4() == nil, echoType: ipv4.ICMPTypeEcho}
+	rawNet, udpNet, listen := "ip4:icmp", "udp4", "0.0.0.0"
+	if c.v6 {
+		rawNet, udpNet, listen, c.echoType = "ip6:ipv6-icmp", "udp6", "::", ipv6.ICMPTypeEchoRequest
+	}
+
+	if c.PacketConn, err = icmp.ListenPacket(rawNet, listen); err == nil {
+		c.raw, c.dst = true, ip
+		return c, nil
+	}
+	if !allowUnprivileged {
+		return nil, fmt.Errorf("raw ICMP socket unavailable (needs root or CAP_NET_RAW): %w", err)
+	}
+	if c.PacketConn, err = icmp.ListenPacket(udpNet, listen); err != nil {
+		return nil, fmt.Errorf("ICMP socket unavailable: %w", err)
+	}
+	c.dst = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+	return c, nil
+}
+
+func (c *icmpConn) proto() int {
+	if c.v6 {
+		return protoICMPv6
+	}
+	return protoICMP
+}
+
+func (c *icmpConn) setTTL(ttl int) error {
+	if c.v6 {
+		return c.IPv6PacketConn().SetHopLimit(ttl)
+	}
+	return c.IPv4PacketConn().SetTTL(ttl)
+}
+
+func (c *icmpConn) sendEcho(id, seq int) error {
+	msg := icmp.Message{Type: c.echoType, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("geegee")}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.WriteTo(b, c.dst)
+	return err
+}
+
+// icmpReply 读到的与本次探测相关的报文
+type icmpReply struct {
+	from    string
+	seq     int
+	reached bool // echo reply；否则为途中路由器的 time exceeded
+}
+
+// readReply 读到 deadline 为止，返回第一个属于 id 的应答
+func (c *icmpConn) readReply(id int, deadline time.Time) (icmpReply, error) {
+	if err := c.SetReadDeadline(deadline); err != nil {
+		return icmpReply{}, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := c.ReadFrom(buf)
+		if err != nil {
+			return icmpReply{}, err
+		}
+		msg, err := icmp.ParseMessage(c.proto(), buf[:n])
+		if err != nil {
+			continue
+		}
+		from := peer.String()
+		if a, ok := peer.(*net.UDPAddr); ok {
+			from = a.IP.String()
+		}
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			if (msg.Type == ipv4.ICMPTypeEchoReply || msg.Type == ipv6.ICMPTypeEchoReply) && (!c.raw || body.ID == id) {
+				return icmpReply{from: from, seq: body.Seq, reached: true}, nil
+			}
+		case *icmp.TimeExceeded:
+			if seq, ok := c.quotedEcho(body.Data, id); ok {
+				return icmpReply{from: from, seq: seq}, nil
+			}
+		}
+	}
+}
+
+// quotedEcho 从 time exceeded 引用的原始报文中取出 echo 请求的序号
+func (c *icmpConn) quotedEcho(data []byte, id int) (int, bool) {
+	hdr := ipv6.HeaderLen
+	if !c.v6 {
+		if len(data) < ipv4.HeaderLen {
+			return 0, false
+		}
+		hdr = int(data[0]&0x0f) * 4
+	}
+	if len(data) < hdr+8 {
+		return 0, false
+	}
+	inner := data[hdr:]
+	if c.raw && int(binary.BigEndian.Uint16(inner[4:6])) != id {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint16(inner[6:8])), true
+}
+
+// PerformICMPPing 发送 count 个 ICMP echo，统计往返延迟与丢包
+func PerformICMPPing(ctx context.Context, host string, count int, timeout time.Duration) (PingResult, error) {
+	res := PingResult{Target: Target{IP: host, TargetType: "ping"}, PacketLoss: 1}
+	c, err := openICMP(host, true)
+	if err != nil {
+		return res, err
+	}
+	defer c.Close()
+
+	id := rand.IntN(0xffff)
+	var sum float64
+	received := 0
+	for seq := 1; seq <= count && ctx.Err() == nil; seq++ {
+		start := time.Now()
+		if err := c.sendEcho(id, seq); err != nil {
+			return res, err
+		}
+		deadline := start.Add(timeout)
+		for {
+			r, err := c.readReply(id, deadline)
+			if err != nil {
+				break // 超时算丢包
+			}
+			if !r.reached || r.seq != seq {
+				continue
+			}
+			rtt := float64(time.Since(start).Microseconds()) / 1000
+			if received == 0 || rtt < res.MinRTTMs {
+				res.MinRTTMs = rtt
+			}
+			res.MaxRTTMs = max(res.MaxRTTMs, rtt)
+			sum += rtt
+			received++
+			break
+		}
+		if seq < count {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+	if received > 0 {
+		res.AvgRTTMs = sum / float64(received)
+	}
+	res.PacketLoss = 1 - float64(received)/float64(count)
+	return res, nil
+}
+
+// Traceroute 逐跳递增 TTL 发送 ICMP echo，直到目标应答或达到 maxHops。需要原始套接字权限
+func Traceroute(ctx context.Context, host string, maxHops int, hopTimeout time.Duration) ([]Hop, error) {
+	c, err := openICMP(host, false)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	id := rand.IntN(0xffff)
+	var hops []Hop
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		if err := ctx.Err(); err != nil {
+			return hops, err
+		}
+		if err := c.setTTL(ttl); err != nil {
+			return hops, err
+		}
+		start := time.Now()
+		if err := c.sendEcho(id, ttl); err != nil {
+			return hops, err
+		}
+		hop := Hop{TTL: ttl}
+		deadline := start.Add(hopTimeout)
+		for {
+			r, err := c.readReply(id, deadline)
+			if err != nil {
+				var ne net.Error
+				if !errors.As(err, &ne) || !ne.Timeout() {
+					return hops, err
+				}
+				break
+			}
+			if r.seq != ttl {
+				continue
+			}
+			hop.Addr, hop.Reached = r.from, r.reached
+			hop.RTTMs = float64(time.Since(start).Microseconds()) / 1000
+			break
+		}
+		hops = append(hops, hop)
+		if hop.Reached {
+			break
+		}
+	}
+	return hops, nil
+}
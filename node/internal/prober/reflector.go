@@ -0,0 +1,175 @@
+package prober
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultReflectorPort 反射器默认监听的 UDP 端口
+const DefaultReflectorPort = 8620
+
+// UDP 探测报文 (大端)：
+//
+//	[0:4)   magic "GGUP"
+//	[4]     类型：1 请求 / 2 回包
+//	[8:16)  会话 ID，同一轮探测共用
+//	[16:20) 序号，从 0 开始
+//	[20:24) 反射器在该会话中已收到的请求数，仅回包填写
+//	[24:32) 发送时刻 (探测方时钟，Unix 纳秒)，原样带回
+const (
+	udpHeaderLen = 32
+	udpRequest   = 1
+	udpReply     = 2
+)
+
+var udpMagic = [4]byte{'G', 'G', 'U', 'P'}
+
+type udpPacket struct {
+	kind    byte
+	session uint64
+	seq     uint32
+	rxCount uint32
+	sentAt  int64
+}
+
+func (p udpPacket) encode(buf []byte) {
+	copy(buf[0:4], udpMagic[:])
+	buf[4] = p.kind
+	binary.BigEndian.PutUint64(buf[8:16], p.session)
+	binary.BigEndian.PutUint32(buf[16:20], p.seq)
+	binary.BigEndian.PutUint32(buf[20:24], p.rxCount)
+	binary.BigEndian.PutUint64(buf[24:32], uint64(p.sentAt))
+}
+
+func decodeUDPPacket(buf []byte) (udpPacket, bool) {
+	if len(buf) < udpHeaderLen || [4]byte(buf[0:4]) != udpMagic {
+		return udpPacket{}, false
+	}
+	return udpPacket{
+		kind:    buf[4],
+		session: binary.BigEndian.Uint64(buf[8:16]),
+		seq:     binary.BigEndian.Uint32(buf[16:20]),
+		rxCount: binary.BigEndian.Uint32(buf[20:24]),
+		sentAt:  int64(binary.BigEndian.Uint64(buf[24:32])),
+	}, true
+}
+
+const (
+	// reflectorSessionTTL 超过该时长没有新请求的会话被清理
+	reflectorSessionTTL = time.Minute
+	// reflectorMaxSessions 同时跟踪的会话上限，防止伪造来源的报文撑爆内存
+	reflectorMaxSessions = 10000
+)
+
+type reflectorSession struct {
+	count    uint32
+	lastSeen time.Time
+}
+
+// Reflector 把 udp 探测请求原样回给发送方，并附上本会话已收到的请求数供对端推算去程丢包。
+// 回包长度与请求相同，不会放大流量；非本协议的报文直接丢弃
+type Reflector struct {
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	sessions map[uint64]*reflectorSession
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// ListenReflector 在 addr (如 ":8620") 上启动反射器
+func ListenReflector(addr string) (*Reflector, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reflector{
+		conn:     conn,
+		sessions: make(map[uint64]*reflectorSession),
+		stop:     make(chan struct{}),
+	}
+	r.wg.Add(2)
+	go r.serve()
+	go r.expire()
+	log.Printf("UDP reflector listening on %s", conn.LocalAddr())
+	return r, nil
+}
+
+func (r *Reflector) serve() {
+	defer r.wg.Done()
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := r.conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("UDP reflector read error: %v", err)
+			continue
+		}
+		p, ok := decodeUDPPacket(buf[:n])
+		if !ok || p.kind != udpRequest {
+			continue
+		}
+		p.kind = udpReply
+		p.rxCount = r.received(p.session)
+		p.encode(buf[:n])
+		if _, err := r.conn.WriteToUDPAddrPort(buf[:n], from); err != nil {
+			log.Printf("UDP reflector write to %s error: %v", from, err)
+		}
+	}
+}
+
+// received 会话的请求计数加一并返回
+func (r *Reflector) received(session uint64) uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[session]
+	if !ok {
+		if len(r.sessions) >= reflectorMaxSessions {
+			return 0
+		}
+		s = &reflectorSession{}
+		r.sessions[session] = s
+	}
+	s.count++
+	s.lastSeen = time.Now()
+	return s.count
+}
+
+func (r *Reflector) expire() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(reflectorSessionTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.mu.Lock()
+			for id, s := range r.sessions {
+				if now.Sub(s.lastSeen) > reflectorSessionTTL {
+					delete(r.sessions, id)
+				}
+			}
+			r.mu.Unlock()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Close 停止监听
+func (r *Reflector) Close() error {
+	close(r.stop)
+	err := r.conn.Close()
+	r.wg.Wait()
+	return err
+}
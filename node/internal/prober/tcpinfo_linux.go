@@ -0,0 +1,24 @@
+//go:build linux
+
+package prober
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpRetransmits 读取 TCP_INFO 中连接累计的重传段数
+func tcpRetransmits(c *net.TCPConn) uint32 {
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var n uint32
+	raw.Control(func(fd uintptr) {
+		if info, err := unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO); err == nil {
+			n = info.Total_retrans
+		}
+	})
+	return n
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package prober
+
+import "net"
+
+// tcpRetransmits Windows 下暂不统计重传
+func tcpRetransmits(c *net.TCPConn) uint32 {
+	return 0
+}
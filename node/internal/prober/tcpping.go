@@ -10,7 +10,7 @@ import (
 type Target struct {
 	IP         string
 	Port       int
-	TargetType string // "tcpping"
+	TargetType string // "tcpping" 或 "udp" (对端须运行反射器)
 }
 
 type PingResult struct {
@@ -19,21 +19,38 @@ type PingResult struct {
 	MaxRTTMs   float64
 	AvgRTTMs   float64
 	PacketLoss float64 // 0.0 - 1.0
+
+	// 以下仅 udp 探测填充
+	JitterMs    float64
+	Reordered   int
+	Duplicates  int
+	LossForward float64 // 去程丢包率
+	LossReverse float64 // 回程丢包率
+	MOS         float64
 }
 
 // Prober 负责发起对外探测并统计结果
 type Prober struct {
 	targets []Target
 	mu      sync.RWMutex
+
+	jitter   map[Target]float64 // udp 目标跨轮累积的抖动估计
+	jitterMu sync.Mutex
+}
+
+// DefaultTargets 本地默认探测目标，主控未分配目标时使用
+func DefaultTargets() []Target {
+	return []Target{
+		{IP: "8.8.8.8", Port: 53, TargetType: "tcpping"},    // Google DNS
+		{IP: "1.1.1.1", Port: 80, TargetType: "tcpping"},    // Cloudflare
+		{IP: "223.5.5.5", Port: 443, TargetType: "tcpping"}, // Aliyun DNS
+	}
 }
 
 func NewProber() *Prober {
 	return &Prober{
-		targets: []Target{
-			{IP: "8.8.8.8", Port: 53, TargetType: "tcpping"},    // Google DNS
-			{IP: "1.1.1.1", Port: 80, TargetType: "tcpping"},    // Cloudflare
-			{IP: "223.5.5.5", Port: 443, TargetType: "tcpping"}, // Aliyun DNS
-		},
+		targets: DefaultTargets(),
+		jitter:  make(map[Target]float64),
 	}
 }
 
@@ -42,9 +59,22 @@ func (p *Prober) UpdateTargets(targets []Target) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.targets = targets
+
+	// 不再探测的 udp 目标丢弃其抖动估计
+	keep := make(map[Target]bool, len(targets))
+	for _, t := range targets {
+		keep[t] = true
+	}
+	p.jitterMu.Lock()
+	for t := range p.jitter {
+		if !keep[t] {
+			delete(p.jitter, t)
+		}
+	}
+	p.jitterMu.Unlock()
 }
 
-// RunPingCycle 并发地对所有 Target 进行测试，每个 target 测指定次数（如 3 次）
+// RunPingCycle 并发地对所有 Target 进行测试，每个 tcpping target 测指定次数（如 3 次），udp target 固定发 udpPackets 个报文
 func (p *Prober) RunPingCycle(count int, timeout time.Duration) []PingResult {
 	p.mu.RLock()
 	targets := make([]Target, len(p.targets))
@@ -58,6 +88,10 @@ func (p *Prober) RunPingCycle(count int, timeout time.Duration) []PingResult {
 		wg.Add(1)
 		go func(idx int, target Target) {
 			defer wg.Done()
+			if target.TargetType == "udp" {
+				results[idx] = p.udpPing(target, timeout)
+				return
+			}
 			results[idx] = performTCPPing(target, count, timeout)
 		}(i, t)
 	}
@@ -66,6 +100,25 @@ func (p *Prober) RunPingCycle(count int, timeout time.Duration) []PingResult {
 	return results
 }
 
+// udpPing 执行一轮 udp 探测并保存该目标的抖动估计
+func (p *Prober) udpPing(t Target, timeout time.Duration) PingResult {
+	p.jitterMu.Lock()
+	jitter := p.jitter[t]
+	p.jitterMu.Unlock()
+
+	res := performUDPPing(t, udpPackets, timeout, jitter)
+
+	p.jitterMu.Lock()
+	p.jitter[t] = res.JitterMs
+	p.jitterMu.Unlock()
+	return res
+}
+
+// TCPPing 对单个目标执行 count 次 tcpping，供诊断任务使用
+func TCPPing(t Target, count int, timeout time.Duration) PingResult {
+	return performTCPPing(t, count, timeout)
+}
+
 // performTCPPing 对指定的一个目标执行数次连通测算
 func performTCPPing(t Target, count int, timeout time.Duration) PingResult {
 	var totalRtt float64
@@ -0,0 +1,465 @@
+package prober
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultThroughputPort 吞吐测试服务端默认监听的端口 (TCP 与 UDP)
+const DefaultThroughputPort = 8621
+
+const (
+	tputTCPChunk   = 128 * 1024
+	tputUDPSize    = 1200 // udp 报文长度，含报头
+	tputUDPHdr     = 29
+	tputUDPData    = 1
+	tputUDPEnd     = 2
+	tputUDPHello   = 3
+	tputUDPAck     = 4
+	tputDialRetry  = 5 * time.Second // 客户端等待服务端就绪的时长
+	tputHelloWait  = 5 * time.Second
+	tputUDPIdle    = 2 * time.Second // udp 服务端开始接收后，超过该时长无报文即结束
+	tputUDPAckStep = 250 * time.Millisecond
+	tputUDPAckLost = 2 * time.Second // udp 客户端超过该时长未收到确认即停止发送
+	tputEndRepeats = 3
+)
+
+// tcp 握手：magic "GGTP"、1 字节口令长度、口令，之后为负载
+var tputTCPMagic = [4]byte{'G', 'G', 'T', 'P'}
+
+// UDP 吞吐报文 (大端)：
+//
+//	[0:4)   magic "GGTU"
+//	[4]     类型：1 负载 / 2 结束 (seq 为已发送的负载报文数) / 3 握手 / 4 确认 (seq 为已收到的负载报文数)
+//	[5:13)  负载与结束报文为口令的 FNV-64 摘要；握手与确认为以口令为密钥的 HMAC-SHA256 前 8 字节
+//	[13:21) 序号，从 0 开始
+//	[21:29) 发送时刻 (发送端时钟，Unix 纳秒)；握手与确认为客户端选取的随机数
+//
+// 客户端先发握手，收到服务端带口令签名的确认后才发送负载；服务端接收期间定期回确认，
+// 客户端收不到确认即停止发送，测试流量不会打向不知道口令的地址
+var tputUDPMagic = [4]byte{'G', 'G', 'T', 'U'}
+
+// ThroughputParams 吞吐测试参数
+type ThroughputParams struct {
+	Protocol string // tcp / udp
+	Duration time.Duration
+	MaxBytes int64 // 0 表示只按时长
+	RateBps  int64 // udp 目标速率
+	Token    string
+}
+
+// ThroughputResult 吞吐测试一端的统计
+type ThroughputResult struct {
+	Bytes           int64
+	Duration        time.Duration
+	BitsPerSecond   float64
+	Retransmits     uint32
+	PacketsSent     uint64
+	PacketsReceived uint64
+	JitterMs        float64
+}
+
+func (r *ThroughputResult) finish(start time.Time) {
+	r.Duration = time.Since(start)
+	if s := r.Duration.Seconds(); s > 0 {
+		r.BitsPerSecond = float64(r.Bytes) * 8 / s
+	}
+}
+
+func tokenSum(token string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(token))
+	return h.Sum64()
+}
+
+// budget 发送是否已达时长或字节上限
+func (p ThroughputParams) budget(start time.Time, sent int64) bool {
+	return time.Since(start) >= p.Duration || (p.MaxBytes > 0 && sent >= p.MaxBytes)
+}
+
+// ThroughputServer 在 addr 上等待携带口令的一个客户端，接收到对端结束或 ctx 到期为止
+func ThroughputServer(ctx context.Context, addr string, p ThroughputParams) (ThroughputResult, error) {
+	switch p.Protocol {
+	case "tcp":
+		return tcpThroughputServer(ctx, addr, p)
+	case "udp":
+		return udpThroughputServer(ctx, addr, p)
+	}
+	return ThroughputResult{}, fmt.Errorf("unknown protocol %q", p.Protocol)
+}
+
+// ThroughputClient 向 addr 发送数据，直到时长或字节上限先到为止
+func ThroughputClient(ctx context.Context, addr string, p ThroughputParams) (ThroughputResult, error) {
+	switch p.Protocol {
+	case "tcp":
+		return tcpThroughputClient(ctx, addr, p)
+	case "udp":
+		return udpThroughputClient(ctx, addr, p)
+	}
+	return ThroughputResult{}, fmt.Errorf("unknown protocol %q", p.Protocol)
+}
+
+func tcpThroughputServer(ctx context.Context, addr string, p ThroughputParams) (ThroughputResult, error) {
+	var res ThroughputResult
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return res, err
+	}
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	defer ln.Close()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return res, errors.New("no client connected before the deadline")
+			}
+			return res, err
+		}
+		if !tcpHello(c, p.Token) {
+			c.Close()
+			continue
+		}
+		// 只服务一个客户端
+		ln.Close()
+		defer c.Close()
+		if d, ok := ctx.Deadline(); ok {
+			c.SetReadDeadline(d)
+		}
+		start := time.Now()
+		res.Bytes, err = io.Copy(io.Discard, c)
+		res.finish(start)
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			err = nil // 时限到达，按已收到的计算
+		}
+		return res, err
+	}
+}
+
+// tcpHello 读取并校验握手
+func tcpHello(c net.Conn, token string) bool {
+	c.SetReadDeadline(time.Now().Add(tputHelloWait))
+	defer c.SetReadDeadline(time.Time{})
+	var hdr [5]byte
+	if _, err := io.ReadFull(c, hdr[:]); err != nil || [4]byte(hdr[0:4]) != tputTCPMagic {
+		return false
+	}
+	got := make([]byte, hdr[4])
+	if _, err := io.ReadFull(c, got); err != nil {
+		return false
+	}
+	return string(got) == token
+}
+
+// dialRetry 服务端由另一个任务启动，就绪前连接会被拒绝，短时间内重试
+func dialRetry(ctx context.Context, network, addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, tputDialRetry)
+	defer cancel()
+	var d net.Dialer
+	for {
+		c, err := d.DialContext(ctx, network, addr)
+		if err == nil {
+			return c, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+func tcpThroughputClient(ctx context.Context, addr string, p ThroughputParams) (ThroughputResult, error) {
+	var res ThroughputResult
+	if len(p.Token) > 255 {
+		return res, errors.New("token too long")
+	}
+	c, err := dialRetry(ctx, "tcp", addr)
+	if err != nil {
+		return res, err
+	}
+	defer c.Close()
+	if d, ok := ctx.Deadline(); ok {
+		c.SetWriteDeadline(d)
+	}
+	hello := make([]byte, 0, 5+len(p.Token))
+	hello = append(hello, tputTCPMagic[:]...)
+	hello = append(append(hello, byte(len(p.Token))), p.Token...)
+	if _, err := c.Write(hello); err != nil {
+		return res, err
+	}
+
+	buf := make([]byte, tputTCPChunk)
+	start := time.Now()
+	for !p.budget(start, res.Bytes) {
+		n := int64(len(buf))
+		if p.MaxBytes > 0 {
+			n = min(n, p.MaxBytes-res.Bytes)
+		}
+		w, err := c.Write(buf[:n])
+		res.Bytes += int64(w)
+		if err != nil {
+			res.finish(start)
+			return res, err
+		}
+	}
+	res.finish(start)
+	if tc, ok := c.(*net.TCPConn); ok {
+		res.Retransmits = tcpRetransmits(tc)
+		tc.CloseWrite()
+	}
+	return res, nil
+}
+
+func encodeTputUDP(buf []byte, kind byte, sum, seq uint64, sentAt int64) {
+	copy(buf[0:4], tputUDPMagic[:])
+	buf[4] = kind
+	binary.BigEndian.PutUint64(buf[5:13], sum)
+	binary.BigEndian.PutUint64(buf[13:21], seq)
+	binary.BigEndian.PutUint64(buf[21:29], uint64(sentAt))
+}
+
+// tputMAC 握手与确认报文的签名，覆盖类型、序号与随机数
+func tputMAC(token string, pkt []byte) uint64 {
+	m := hmac.New(sha256.New, []byte(token))
+	m.Write(pkt[0:5])
+	m.Write(pkt[13:tputUDPHdr])
+	return binary.BigEndian.Uint64(m.Sum(nil))
+}
+
+// encodeTputSigned 编码握手或确认报文
+func encodeTputSigned(buf []byte, kind byte, token string, seq, nonce uint64) {
+	encodeTputUDP(buf, kind, 0, seq, int64(nonce))
+	binary.BigEndian.PutUint64(buf[5:13], tputMAC(token, buf))
+}
+
+// checkTputSigned 校验握手或确认报文，返回序号与随机数
+func checkTputSigned(pkt []byte, kind byte, token string) (seq, nonce uint64, ok bool) {
+	if len(pkt) < tputUDPHdr || [4]byte(pkt[0:4]) != tputUDPMagic || pkt[4] != kind {
+		return 0, 0, false
+	}
+	if !hmac.Equal(pkt[5:13], binary.BigEndian.AppendUint64(nil, tputMAC(token, pkt))) {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(pkt[13:21]), binary.BigEndian.Uint64(pkt[21:29]), true
+}
+
+// udpHello 重复发送握手直到收到服务端的确认。服务端由另一个任务启动，就绪前的握手会丢失
+func udpHello(ctx context.Context, c net.Conn, token string, nonce uint64) error {
+	ctx, cancel := context.WithTimeout(ctx, tputDialRetry)
+	defer cancel()
+	hello := make([]byte, tputUDPHdr)
+	encodeTputSigned(hello, tputUDPHello, token, 0, nonce)
+	buf := make([]byte, 2048)
+	for ctx.Err() == nil {
+		c.Write(hello)
+		c.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		for {
+			n, err := c.Read(buf)
+			if err != nil {
+				break // 超时重发；服务端未就绪时的 ICMP 不可达同样重试
+			}
+			if _, got, ok := checkTputSigned(buf[:n], tputUDPAck, token); ok && got == nonce {
+				c.SetReadDeadline(time.Time{})
+				return nil
+			}
+		}
+	}
+	return errors.New("no acknowledgement from the throughput server")
+}
+
+func udpThroughputClient(ctx context.Context, addr string, p ThroughputParams) (ThroughputResult, error) {
+	var res ThroughputResult
+	if p.RateBps <= 0 {
+		return res, errors.New("udp throughput test needs a target rate")
+	}
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		return res, err
+	}
+	defer c.Close()
+
+	var nb [8]byte
+	rand.Read(nb[:])
+	nonce := binary.BigEndian.Uint64(nb[:])
+	if err := udpHello(ctx, c, p.Token, nonce); err != nil {
+		return res, err
+	}
+
+	// 后台读取服务端的定期确认，直到连接关闭
+	var lastAck atomic.Int64
+	lastAck.Store(time.Now().UnixNano())
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, err := c.Read(buf)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if _, got, ok := checkTputSigned(buf[:n], tputUDPAck, p.Token); err == nil && ok && got == nonce {
+				lastAck.Store(time.Now().UnixNano())
+			}
+		}
+	}()
+
+	sum := tokenSum(p.Token)
+	buf := make([]byte, tputUDPSize)
+	// 按目标速率计算到目前为止应发出的报文数，落后时补发，超前时短暂休眠
+	perSecond := float64(p.RateBps) / (tputUDPSize * 8)
+	start := time.Now()
+	for !p.budget(start, res.Bytes) && ctx.Err() == nil {
+		if time.Since(time.Unix(0, lastAck.Load())) > tputUDPAckLost {
+			err = errors.New("throughput server stopped acknowledging")
+			break
+		}
+		due := uint64(time.Since(start).Seconds()*perSecond) + 1
+		if res.PacketsSent >= due {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		encodeTputUDP(buf, tputUDPData, sum, res.PacketsSent, time.Now().UnixNano())
+		// 本地发送缓冲满等错误计为丢包，不中断测试
+		if _, err := c.Write(buf); err == nil {
+			res.Bytes += tputUDPSize
+		}
+		res.PacketsSent++
+	}
+	res.finish(start)
+
+	// 结束报文可能丢失，重复几次；服务端收不到时按空闲超时结束
+	for i := 0; i < tputEndRepeats; i++ {
+		encodeTputUDP(buf[:tputUDPHdr], tputUDPEnd, sum, res.PacketsSent, time.Now().UnixNano())
+		c.Write(buf[:tputUDPHdr])
+		time.Sleep(50 * time.Millisecond)
+	}
+	return res, err
+}
+
+// seqSet 已收到的序号，用于剔除重复报文
+type seqSet []uint64
+
+func (s *seqSet) add(seq uint64) bool {
+	i, bit := seq/64, uint64(1)<<(seq%64)
+	if i >= 1<<24 { // 超出 10 亿个报文视为异常序号
+		return false
+	}
+	for uint64(len(*s)) <= i {
+		*s = append(*s, 0)
+	}
+	if (*s)[i]&bit != 0 {
+		return false
+	}
+	(*s)[i] |= bit
+	return true
+}
+
+func udpThroughputServer(ctx context.Context, addr string, p ThroughputParams) (ThroughputResult, error) {
+	var res ThroughputResult
+	c, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return res, err
+	}
+	var closeOnce sync.Once
+	closeConn := func() { closeOnce.Do(func() { c.Close() }) }
+	stop := context.AfterFunc(ctx, closeConn)
+	defer stop()
+	defer closeConn()
+
+	sum := tokenSum(p.Token)
+	buf := make([]byte, 2048)
+	ack := make([]byte, tputUDPHdr)
+	var (
+		peer              net.Addr
+		nonce             uint64
+		start, last       time.Time
+		acked             time.Time
+		seen              seqSet
+		prevTransit, jitr float64
+	)
+	sendAck := func(now time.Time) {
+		encodeTputSigned(ack, tputUDPAck, p.Token, res.PacketsReceived, nonce)
+		c.WriteTo(ack, peer)
+		acked = now
+	}
+	for {
+		// 握手前等到时限为止，之后空闲超时即认为对端已结束
+		if peer != nil {
+			c.SetReadDeadline(time.Now().Add(tputUDPIdle))
+		}
+		n, from, err := c.ReadFrom(buf)
+		if err != nil {
+			if peer == nil {
+				if ctx.Err() != nil {
+					return res, errors.New("no client connected before the deadline")
+				}
+				return res, err
+			}
+			break
+		}
+		now := time.Now()
+		if n < tputUDPHdr || [4]byte(buf[0:4]) != tputUDPMagic {
+			continue
+		}
+		if buf[4] == tputUDPHello {
+			_, got, ok := checkTputSigned(buf[:n], tputUDPHello, p.Token)
+			if !ok || (peer != nil && (from.String() != peer.String() || got != nonce)) {
+				continue
+			}
+			// 首个合法握手确定对端；确认丢失时对端会重发握手
+			peer, nonce = from, got
+			sendAck(now)
+			continue
+		}
+		if peer == nil || from.String() != peer.String() || binary.BigEndian.Uint64(buf[5:13]) != sum {
+			continue
+		}
+		seq := binary.BigEndian.Uint64(buf[13:21])
+		if buf[4] == tputUDPEnd {
+			res.PacketsSent = seq
+			break
+		}
+		if buf[4] != tputUDPData || !seen.add(seq) {
+			continue
+		}
+		if start.IsZero() {
+			start = now
+		}
+		res.PacketsReceived++
+		res.Bytes += int64(n)
+		last = now
+		if now.Sub(acked) >= tputUDPAckStep {
+			sendAck(now)
+		}
+		// RFC 3550：传输时间差分的平滑均值，两端时钟偏差在差分中抵消
+		transit := float64(now.UnixNano()-int64(binary.BigEndian.Uint64(buf[21:29]))) / 1e6
+		if res.PacketsReceived > 1 {
+			d := transit - prevTransit
+			if d < 0 {
+				d = -d
+			}
+			jitr += (d - jitr) / 16
+		}
+		prevTransit = transit
+	}
+	res.JitterMs = jitr
+	if !last.IsZero() {
+		res.Duration = last.Sub(start)
+	}
+	if s := res.Duration.Seconds(); s > 0 {
+		res.BitsPerSecond = float64(res.Bytes) * 8 / s
+	}
+	return res, nil
+}
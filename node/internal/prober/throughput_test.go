@@ -0,0 +1,141 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freeUDPAddr 取一个空闲的本机 UDP 端口
+func freeUDPAddr(t *testing.T) string {
+	t.Helper()
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	return c.LocalAddr().String()
+}
+
+// udpSink 记录收到的报文数，reply 非空时对每个报文按其返回值回包
+func udpSink(t *testing.T, reply func(pkt []byte) []byte) (string, func() (data int)) {
+	t.Helper()
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	counts := make(chan int, 1)
+	counts <- 0
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, from, err := c.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n > 4 && buf[4] == tputUDPData {
+				counts <- <-counts + 1
+			}
+			if reply != nil {
+				if out := reply(buf[:n]); out != nil {
+					c.WriteTo(out, from)
+				}
+			}
+		}
+	}()
+	return c.LocalAddr().String(), func() int {
+		v := <-counts
+		counts <- v
+		return v
+	}
+}
+
+var tputTestParams = ThroughputParams{Protocol: "udp", Duration: 500 * time.Millisecond, RateBps: 10_000_000, Token: "s3cret"}
+
+func TestUDPThroughputLoopback(t *testing.T) {
+	addr := freeUDPAddr(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	type result struct {
+		res ThroughputResult
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := ThroughputServer(ctx, addr, tputTestParams)
+		done <- result{res, err}
+	}()
+
+	// 服务端晚于客户端就绪时由握手重试兜底
+	sent, err := ThroughputClient(ctx, addr, tputTestParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := <-done
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	if sent.PacketsSent == 0 || got.res.PacketsReceived == 0 || got.res.PacketsSent != sent.PacketsSent {
+		t.Fatalf("client sent %d, server received %d of %d", sent.PacketsSent, got.res.PacketsReceived, got.res.PacketsSent)
+	}
+}
+
+// 目标不回确认 (任意不相干的主机) 时一个负载报文都不发
+func TestUDPThroughputNeedsHandshake(t *testing.T) {
+	for name, reply := range map[string]func([]byte) []byte{
+		"silent": nil,
+		// 原样反射的服务 (如 echo) 回的是握手本身
+		"echo": func(pkt []byte) []byte { return pkt },
+		// 不知道口令的对端伪造确认
+		"forged": func(pkt []byte) []byte {
+			out := append([]byte(nil), pkt...)
+			out[4] = tputUDPAck
+			return out
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			addr, received := udpSink(t, reply)
+			start := time.Now()
+			_, err := ThroughputClient(context.Background(), addr, tputTestParams)
+			if err == nil || !strings.Contains(err.Error(), "acknowledgement") {
+				t.Fatalf("err %v, want missing acknowledgement", err)
+			}
+			if d := time.Since(start); d > tputDialRetry+time.Second {
+				t.Fatalf("handshake gave up after %v", d)
+			}
+			if n := received(); n != 0 {
+				t.Fatalf("%d payload packets sent without a handshake", n)
+			}
+		})
+	}
+}
+
+// 服务端只确认握手、之后不再确认时客户端停止发送
+func TestUDPThroughputStopsWithoutAcks(t *testing.T) {
+	addr, _ := udpSink(t, func(pkt []byte) []byte {
+		_, nonce, ok := checkTputSigned(pkt, tputUDPHello, tputTestParams.Token)
+		if !ok {
+			return nil
+		}
+		ack := make([]byte, tputUDPHdr)
+		encodeTputSigned(ack, tputUDPAck, tputTestParams.Token, 0, nonce)
+		return ack
+	})
+	p := tputTestParams
+	p.Duration = time.Minute
+	start := time.Now()
+	res, err := ThroughputClient(context.Background(), addr, p)
+	if err == nil || !strings.Contains(err.Error(), "stopped acknowledging") {
+		t.Fatalf("err %v, want stopped acknowledging", err)
+	}
+	if d := time.Since(start); d > tputUDPAckLost+time.Second {
+		t.Fatalf("kept sending for %v", d)
+	}
+	if res.PacketsSent == 0 {
+		t.Fatal("nothing sent after the handshake")
+	}
+}
@@ -0,0 +1,171 @@
+package prober
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// udpPackets 每轮 udp 探测发出的报文数
+	udpPackets = 10
+	// udpInterval 报文发送间隔
+	udpInterval = 20 * time.Millisecond
+)
+
+// udpReplyInfo 收到的一个回包
+type udpReplyInfo struct {
+	seq     uint32
+	rtt     float64 // ms
+	rxCount uint32
+}
+
+// UDPPing 向反射器发送 count 个报文，抖动从零开始估计，供诊断任务使用
+func UDPPing(t Target, count int, timeout time.Duration) PingResult {
+	return performUDPPing(t, count, timeout, 0)
+}
+
+// performUDPPing 向对端反射器发送 n 个带序号与时间戳的报文，统计往返延迟、抖动、丢包、乱序与重复。
+// jitter 为该目标上一轮结束时的抖动估计，按 RFC 3550 在各轮之间连续累积
+func performUDPPing(t Target, n int, timeout time.Duration, jitter float64) PingResult {
+	conn, err := net.Dial("udp", net.JoinHostPort(t.IP, strconv.Itoa(t.Port)))
+	if err != nil {
+		return summarizeUDP(t, n, nil, jitter)
+	}
+	defer conn.Close()
+
+	session := rand.Uint64()
+	sentAt := make([]atomic.Int64, n) // Unix 纳秒，0 为尚未发送
+	replies := make(chan udpReplyInfo, n*2)
+
+	// 接收协程：读到发送结束后再等 timeout 为止
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 2048)
+		for {
+			nr, err := conn.Read(buf)
+			if err != nil {
+				var ne net.Error
+				if (errors.As(err, &ne) && ne.Timeout()) || errors.Is(err, net.ErrClosed) {
+					return
+				}
+				// 对端未开反射器时会收到 ICMP 端口不可达，继续等剩余报文
+				continue
+			}
+			now := time.Now().UnixNano()
+			// 序号由对端回显，越界的回包 (异常或恶意的反射器) 直接丢弃
+			p, ok := decodeUDPPacket(buf[:nr])
+			if !ok || p.kind != udpReply || p.session != session || int(p.seq) >= len(sentAt) {
+				continue
+			}
+			// 以本地记录的发送时刻为准，不信任回包中的时间戳
+			sent := sentAt[p.seq].Load()
+			if sent == 0 {
+				continue
+			}
+			rtt := float64(now-sent) / float64(time.Millisecond)
+			select {
+			case replies <- udpReplyInfo{seq: p.seq, rtt: rtt, rxCount: p.rxCount}:
+			default:
+			}
+		}
+	}()
+
+	buf := make([]byte, udpHeaderLen)
+	conn.SetReadDeadline(time.Now().Add(time.Duration(n)*udpInterval + timeout))
+	for seq := uint32(0); int(seq) < n; seq++ {
+		now := time.Now().UnixNano()
+		sentAt[seq].Store(now)
+		udpPacket{kind: udpRequest, session: session, seq: seq, sentAt: now}.encode(buf)
+		conn.Write(buf)
+		if int(seq) < n-1 {
+			time.Sleep(udpInterval)
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	<-done
+	close(replies)
+
+	var list []udpReplyInfo
+	for r := range replies {
+		list = append(list, r)
+	}
+	return summarizeUDP(t, n, list, jitter)
+}
+
+// summarizeUDP 按到达顺序汇总 n 个报文的回包
+func summarizeUDP(t Target, n int, list []udpReplyInfo, jitter float64) PingResult {
+	res := PingResult{Target: t, PacketLoss: 1, JitterMs: jitter, MOS: 1}
+	if len(list) == 0 {
+		return res
+	}
+
+	seen := make(map[uint32]bool, len(list))
+	var (
+		sum, minRtt, maxRtt float64
+		highest             = -1
+		highestRx           uint32
+		prev                = -1.0
+	)
+	for _, r := range list {
+		if seen[r.seq] {
+			res.Duplicates++
+			continue
+		}
+		seen[r.seq] = true
+		if int(r.seq) < highest {
+			res.Reordered++
+		} else {
+			highest, highestRx = int(r.seq), r.rxCount
+		}
+
+		sum += r.rtt
+		if len(seen) == 1 || r.rtt < minRtt {
+			minRtt = r.rtt
+		}
+		maxRtt = math.Max(maxRtt, r.rtt)
+
+		// RFC 3550 6.4.1：J += (|D| - J) / 16，D 取相邻两个回包的往返时间差
+		if prev >= 0 {
+			jitter += (math.Abs(r.rtt-prev) - jitter) / 16
+		}
+		prev = r.rtt
+	}
+
+	received := len(seen)
+	res.MinRTTMs, res.MaxRTTMs, res.AvgRTTMs = minRtt, maxRtt, sum/float64(received)
+	res.PacketLoss = 1 - float64(received)/float64(n)
+	res.JitterMs = jitter
+
+	// 反射器在收到序号 highest 的请求时共收到 highestRx 个，其间的差额丢在去程；回程丢包由总丢包反推
+	if sent := highest + 1; highestRx > 0 && sent > 0 {
+		res.LossForward = math.Max(0, float64(sent-int(highestRx))/float64(sent))
+		if res.LossForward < 1 {
+			res.LossReverse = math.Max(0, 1-(1-res.PacketLoss)/(1-res.LossForward))
+		}
+	}
+	res.MOS = mos(res.AvgRTTMs, res.JitterMs, res.PacketLoss)
+	return res
+}
+
+// mos 简化 E-model：单程延迟取 RTT 的一半，抖动按两倍计入，再扣除丢包
+func mos(rtt, jitter, loss float64) float64 {
+	effective := rtt/2 + jitter*2 + 10
+	r := 93.2 - effective/40
+	if effective >= 160 {
+		r = 93.2 - (effective-120)/10
+	}
+	r -= loss * 100 * 2.5
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	}
+	return 1 + 0.035*r + 0.000007*r*(r-60)*(100-r)
+}
@@ -0,0 +1,92 @@
+package prober
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeReflector 按 reply 改写每个请求后回包，reply 返回的每个报文都会发出
+func fakeReflector(t *testing.T, reply func(p udpPacket) []udpPacket) Target {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 2048)
+		out := make([]byte, udpHeaderLen)
+		for {
+			n, from, err := conn.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			p, ok := decodeUDPPacket(buf[:n])
+			if !ok || p.kind != udpRequest {
+				continue
+			}
+			for _, r := range reply(p) {
+				r.kind = udpReply
+				r.encode(out)
+				conn.WriteToUDPAddrPort(out, from)
+			}
+		}
+	}()
+	host, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	p, _ := strconv.Atoi(port)
+	return Target{IP: host, Port: p, TargetType: "udp"}
+}
+
+func TestUDPPingReflector(t *testing.T) {
+	r, err := ListenReflector("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	host, port, _ := net.SplitHostPort(r.conn.LocalAddr().String())
+	p, _ := strconv.Atoi(port)
+
+	res := UDPPing(Target{IP: host, Port: p, TargetType: "udp"}, 10, 200*time.Millisecond)
+	if res.PacketLoss != 0 || res.Duplicates != 0 || res.Reordered != 0 || res.LossForward != 0 {
+		t.Fatalf("loopback result %+v", res)
+	}
+	if res.AvgRTTMs <= 0 || res.MinRTTMs > res.AvgRTTMs || res.AvgRTTMs > res.MaxRTTMs {
+		t.Fatalf("rtt min/avg/max %v/%v/%v", res.MinRTTMs, res.AvgRTTMs, res.MaxRTTMs)
+	}
+}
+
+// 回包序号由对端控制，越界的序号不能拖垮探测协程
+func TestUDPPingBadSequence(t *testing.T) {
+	target := fakeReflector(t, func(p udpPacket) []udpPacket {
+		shifted, huge := p, p
+		shifted.seq += 15
+		huge.seq = ^uint32(0)
+		out := []udpPacket{shifted, huge}
+		// 偶数序号正常回包两次，奇数序号只回越界的包
+		if p.seq%2 == 0 {
+			p.rxCount = p.seq + 1
+			out = append(out, p, p)
+		}
+		return out
+	})
+
+	res := UDPPing(target, 10, 200*time.Millisecond)
+	if res.PacketLoss != 0.5 || res.Duplicates != 5 {
+		t.Fatalf("loss %v duplicates %d, want 0.5 / 5", res.PacketLoss, res.Duplicates)
+	}
+	if res.LossForward != 0 || res.LossReverse != 0.5 {
+		t.Fatalf("forward %v reverse %v, want 0 / 0.5", res.LossForward, res.LossReverse)
+	}
+}
+
+func TestUDPPingForeignSession(t *testing.T) {
+	target := fakeReflector(t, func(p udpPacket) []udpPacket {
+		p.session++
+		return []udpPacket{p}
+	})
+	if res := UDPPing(target, 5, 100*time.Millisecond); res.PacketLoss != 1 {
+		t.Fatalf("replies from another session counted: %+v", res)
+	}
+}
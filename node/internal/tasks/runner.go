@@ -0,0 +1,204 @@
+// Package tasks 在节点上执行主控下发的一次性诊断任务，结果经上报流回传
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	pb "github.com/geelinx-ltd/geegee/api/proto"
+	"github.com/geelinx-ltd/geegee/node/internal/prober"
+)
+
+const (
+	defaultTimeout = 15 * time.Second
+	maxTimeout     = 2 * time.Minute
+
+	defaultCount   = 5
+	defaultUDPSend = 50
+	defaultMaxHops = 30
+	hopTimeout     = time.Second
+)
+
+// Runner 并发执行诊断任务，超出上限的任务直接回报失败 (主控按同样的上限排队，正常不会触发)
+type Runner struct {
+	slots chan struct{}
+	send  func(*pb.TaskResult) error
+}
+
+// NewRunner limit 为同时执行的任务数，send 负责把结果回传主控
+func NewRunner(limit int, send func(*pb.TaskResult) error) *Runner {
+	if limit <= 0 {
+		limit = 2
+	}
+	return &Runner{slots: make(chan struct{}, limit), send: send}
+}
+
+// Submit 异步执行任务，立即返回
+func (r *Runner) Submit(t *pb.Task) {
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		now := time.Now().UnixMilli()
+		r.reply(&pb.TaskResult{Id: t.Id, Error: "node busy: too many concurrent tasks", StartedAt: now, FinishedAt: now})
+		return
+	}
+	go func() {
+		defer func() { <-r.slots }()
+		log.Printf("Running task %s: %s %s", t.Id, t.Type, t.Target)
+		r.reply(run(t))
+	}()
+}
+
+func (r *Runner) reply(res *pb.TaskResult) {
+	if err := r.send(res); err != nil {
+		log.Printf("Failed to report result of task %s: %v", res.Id, err)
+	}
+}
+
+// run 执行单个任务，错误记在结果中
+func run(t *pb.Task) *pb.TaskResult {
+	timeout := time.Duration(t.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	timeout = min(timeout, maxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	res := &pb.TaskResult{Id: t.Id, StartedAt: time.Now().UnixMilli()}
+	if err := execute(ctx, t, timeout, res); err != nil {
+		res.Error = err.Error()
+	}
+	res.FinishedAt = time.Now().UnixMilli()
+	return res
+}
+
+func execute(ctx context.Context, t *pb.Task, timeout time.Duration, res *pb.TaskResult) error {
+	count := int(t.Count)
+	switch t.Type {
+	case "tcpping", "udp":
+		host, portStr, err := net.SplitHostPort(t.Target)
+		if err != nil {
+			return err
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return fmt.Errorf("bad port %q", portStr)
+		}
+		target := prober.Target{IP: host, Port: port, TargetType: t.Type}
+		var p prober.PingResult
+		if t.Type == "udp" {
+			if count <= 0 {
+				count = defaultUDPSend
+			}
+			p = prober.UDPPing(target, count, min(timeout/2, 2*time.Second))
+		} else {
+			if count <= 0 {
+				count = defaultCount
+			}
+			// 每次探测各自超时，总耗时不超过任务时限
+			p = prober.TCPPing(target, count, min(timeout/time.Duration(count), 2*time.Second))
+		}
+		res.Ping = pingResult(p)
+
+	case "ping":
+		if count <= 0 {
+			count = defaultCount
+		}
+		p, err := prober.PerformICMPPing(ctx, t.Target, count, min(timeout/time.Duration(count), 2*time.Second))
+		if err != nil {
+			return err
+		}
+		res.Ping = pingResult(p)
+
+	case "traceroute":
+		if count <= 0 {
+			count = defaultMaxHops
+		}
+		hops, err := prober.Traceroute(ctx, t.Target, count, hopTimeout)
+		for _, h := range hops {
+			res.Hops = append(res.Hops, &pb.TraceHop{Ttl: int32(h.TTL), Addr: h.Addr, RttMs: h.RTTMs, Reached: h.Reached})
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %d hops", len(hops))
+		}
+		return err
+
+	case "http":
+		u, err := url.Parse(t.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("bad URL %q", t.Target)
+		}
+		h, err := prober.CheckHTTP(ctx, t.Target)
+		res.Http = &pb.HttpResult{
+			StatusCode: int32(h.StatusCode),
+			RemoteAddr: h.RemoteAddr,
+			DnsMs:      h.DNSMs,
+			ConnectMs:  h.ConnectMs,
+			TlsMs:      h.TLSMs,
+			TtfbMs:     h.TTFBMs,
+			TotalMs:    h.TotalMs,
+			BodyBytes:  h.BodyBytes,
+		}
+		if !h.CertExpiresAt.IsZero() {
+			res.Http.CertExpiresAt = h.CertExpiresAt.UnixMilli()
+		}
+		return err
+
+	case "throughput_server", "throughput_client":
+		tp := t.GetThroughput()
+		if tp == nil || tp.DurationMs <= 0 {
+			return errors.New("missing throughput parameters")
+		}
+		params := prober.ThroughputParams{
+			Protocol: tp.Protocol,
+			Duration: time.Duration(tp.DurationMs) * time.Millisecond,
+			MaxBytes: tp.MaxBytes,
+			RateBps:  tp.RateBps,
+			Token:    tp.Token,
+		}
+		run := prober.ThroughputClient
+		if t.Type == "throughput_server" {
+			run = prober.ThroughputServer
+		}
+		r, err := run(ctx, t.Target, params)
+		res.Throughput = &pb.ThroughputResult{
+			Bytes:           r.Bytes,
+			DurationMs:      r.Duration.Milliseconds(),
+			BitsPerSecond:   r.BitsPerSecond,
+			Retransmits:     r.Retransmits,
+			PacketsSent:     r.PacketsSent,
+			PacketsReceived: r.PacketsReceived,
+			JitterMs:        r.JitterMs,
+		}
+		return err
+
+	default:
+		return fmt.Errorf("unknown task type %q", t.Type)
+	}
+	return nil
+}
+
+func pingResult(p prober.PingResult) *pb.PingResult {
+	return &pb.PingResult{
+		TargetIp:        p.Target.IP,
+		TargetPort:      int32(p.Target.Port),
+		TargetType:      p.Target.TargetType,
+		MinRttMs:        p.MinRTTMs,
+		MaxRttMs:        p.MaxRTTMs,
+		AvgRttMs:        p.AvgRTTMs,
+		PacketLossRate:  p.PacketLoss,
+		JitterMs:        p.JitterMs,
+		Reordered:       uint32(p.Reordered),
+		Duplicates:      uint32(p.Duplicates),
+		LossForwardRate: p.LossForward,
+		LossReverseRate: p.LossReverse,
+		Mos:             p.MOS,
+	}
+}